@@ -0,0 +1,56 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"encoding/json"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/pkg/errors"
+)
+
+// aclAuditEntry is a single ACL granted by a topic resource, reported so
+// that security reviewers can see the access granted in one place.
+type aclAuditEntry struct {
+	ResourceType string
+	Resource     string
+	PatternType  string
+	Operation    string
+	Principal    string
+	Host         string
+}
+
+// Returns the ACLs granted to the users of info on topicName, one entry
+// per operation, in the order of users. Entries are derived from the
+// specs of the ACLs applied to the cluster.
+func userACLAudit(info *types.TopicInfo, topicName, shortStackID string, names secretNameTemplate) []aclAuditEntry {
+	entries := make([]aclAuditEntry, 0)
+	for i := range info.Users {
+		u := &info.Users[i]
+		name, pattern := topicACLResource(topicName, u)
+		specs := userACLSpecs(name, pattern, u.AdditionalTopics, u.Hosts(), principalName(u, shortStackID, names), u.Permissions, userGroupACLs(u), u.GroupPrefix, u.DescribesTopic())
+		for _, spec := range specs {
+			for _, op := range spec.Operations {
+				entries = append(entries, aclAuditEntry{
+					ResourceType: spec.ResourceType.String(),
+					Resource:     spec.Name,
+					PatternType:  spec.Pattern.String(),
+					Operation:    op.String(),
+					Principal:    spec.Principal,
+					Host:         spec.Host,
+				})
+			}
+		}
+	}
+	return entries
+}
+
+func aclAuditJSON(entries []aclAuditEntry) (string, error) {
+	buf, err := json.Marshal(entries)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,38 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"testing"
+
+	tt "github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserACLAudit(t *testing.T) {
+	// Arrange
+	info := &tt.TopicInfo{Users: []tt.User{
+		{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}},
+		{Username: "bob", TopicPrefix: "orders.", Permissions: []tt.Permission{tt.PermissionWrite}},
+		{Username: "carol", AuthType: tt.AuthTypeTLS, Principal: "CN=carol", Permissions: []tt.Permission{tt.PermissionWrite}, NoGroupAcls: true},
+	}}
+	alice := "User:" + defaultSecretNameTemplate.Name("alice", "stack")
+	bob := "User:" + defaultSecretNameTemplate.Name("bob", "stack")
+
+	// Act
+	entries := userACLAudit(info, "topic", "stack", defaultSecretNameTemplate)
+
+	// Assert
+	assert.Equal(t, []aclAuditEntry{
+		{ResourceType: "TOPIC", Resource: "topic", PatternType: "LITERAL", Operation: "READ", Principal: alice, Host: "*"},
+		{ResourceType: "TOPIC", Resource: "topic", PatternType: "LITERAL", Operation: "DESCRIBE", Principal: alice, Host: "*"},
+		{ResourceType: "GROUP", Resource: "*", PatternType: "LITERAL", Operation: "READ", Principal: alice, Host: "*"},
+		{ResourceType: "GROUP", Resource: "*", PatternType: "LITERAL", Operation: "DESCRIBE", Principal: alice, Host: "*"},
+		{ResourceType: "TOPIC", Resource: "orders.", PatternType: "PREFIXED", Operation: "WRITE", Principal: bob, Host: "*"},
+		{ResourceType: "TOPIC", Resource: "orders.", PatternType: "PREFIXED", Operation: "DESCRIBE", Principal: bob, Host: "*"},
+		{ResourceType: "TOPIC", Resource: "topic", PatternType: "LITERAL", Operation: "WRITE", Principal: "User:CN=carol", Host: "*"},
+		{ResourceType: "TOPIC", Resource: "topic", PatternType: "LITERAL", Operation: "DESCRIBE", Principal: "User:CN=carol", Host: "*"},
+	}, entries)
+}
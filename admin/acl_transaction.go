@@ -0,0 +1,48 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+var contextKeyACLTransaction contextKey = contextKey("ACLTransaction")
+
+// aclTransaction collects the ACLs of all users created by a command so
+// that they are applied as a unit once every user is provisioned, rather
+// than user by user.
+type aclTransaction struct {
+	mu   sync.Mutex
+	acls []*kadm.ACLBuilder
+}
+
+func newACLTransaction() *aclTransaction {
+	return &aclTransaction{acls: make([]*kadm.ACLBuilder, 0)}
+}
+
+// Returns a copy of ctx carrying tx. ACLs created with the returned
+// context are added to tx instead of being created.
+func withACLTransaction(ctx context.Context, tx *aclTransaction) context.Context {
+	return context.WithValue(ctx, contextKeyACLTransaction, tx)
+}
+
+func aclTransactionFrom(ctx context.Context) *aclTransaction {
+	tx, _ := ctx.Value(contextKeyACLTransaction).(*aclTransaction)
+	return tx
+}
+
+func (tx *aclTransaction) Add(acls ...*kadm.ACLBuilder) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.acls = append(tx.acls, acls...)
+}
+
+func (tx *aclTransaction) ACLs() []*kadm.ACLBuilder {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return tx.acls
+}
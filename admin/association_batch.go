@@ -0,0 +1,56 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"sync"
+)
+
+var contextKeyAssociationBatch contextKey = contextKey("AssociationBatch")
+
+// associationBatch collects the secrets of all users created by a command
+// so that they are associated with the cluster in a single call, rather
+// than user by user. MSK throttles association calls.
+type associationBatch struct {
+	mu         sync.Mutex
+	secretArns []string
+	usernames  map[string]bool
+}
+
+func newAssociationBatch() *associationBatch {
+	return &associationBatch{secretArns: make([]string, 0), usernames: make(map[string]bool)}
+}
+
+// Returns a copy of ctx carrying b. Secrets of users created with the
+// returned context are added to b instead of being associated.
+func withAssociationBatch(ctx context.Context, b *associationBatch) context.Context {
+	return context.WithValue(ctx, contextKeyAssociationBatch, b)
+}
+
+func associationBatchFrom(ctx context.Context) *associationBatch {
+	b, _ := ctx.Value(contextKeyAssociationBatch).(*associationBatch)
+	return b
+}
+
+// Adds the secret of the user with the declared username.
+func (b *associationBatch) Add(username, secretArn string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.secretArns = append(b.secretArns, secretArn)
+	b.usernames[username] = true
+}
+
+func (b *associationBatch) SecretArns() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.secretArns
+}
+
+// Reports whether the secret of the user with the declared username is in b.
+func (b *associationBatch) Has(username string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.usernames[username]
+}
@@ -0,0 +1,35 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/pkg/errors"
+)
+
+// Loads the configuration shared by AWS SDK clients used by TR.
+func LoadAWSConfig(ctx context.Context, settings *Settings) (aws.Config, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, awsConfigOptions(settings)...)
+	if err != nil {
+		return aws.Config{}, errors.WithStack(err)
+	}
+	return cfg, nil
+}
+
+// Returns the options overriding the default retryer of SDK clients.
+// MSK throttles control plane calls aggressively, so operators may need
+// more attempts than the SDK default.
+func awsConfigOptions(settings *Settings) []func(*config.LoadOptions) error {
+	opts := make([]func(*config.LoadOptions) error, 0)
+	if settings.AWSRetryMaxAttempts > 0 {
+		opts = append(opts, config.WithRetryMaxAttempts(settings.AWSRetryMaxAttempts))
+	}
+	if settings.AWSRetryMode != "" {
+		opts = append(opts, config.WithRetryMode(aws.RetryMode(settings.AWSRetryMode)))
+	}
+	return opts
+}
@@ -0,0 +1,47 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAWSConfigOptions(t *testing.T) {
+	type testCase struct {
+		env      map[string]string
+		expected config.LoadOptions
+	}
+
+	cases := map[string]testCase{
+		"SDK defaults": {
+			env:      map[string]string{},
+			expected: config.LoadOptions{},
+		},
+		"Retryer from env": {
+			env:      map[string]string{EnvAWSRetryMaxAttempts: "10", EnvAWSRetryMode: "adaptive"},
+			expected: config.LoadOptions{RetryMaxAttempts: 10, RetryMode: aws.RetryModeAdaptive},
+		},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			for ek, ev := range c.env {
+				t.Setenv(ek, ev)
+			}
+			settings, err := NewSettingsFromEnv()
+			assert.Nil(t, err)
+
+			var actual config.LoadOptions
+			for _, opt := range awsConfigOptions(settings) {
+				assert.Nil(t, opt(&actual))
+			}
+
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tt "github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/aws/aws-sdk-go-v2/service/kafka"
+	"go.uber.org/zap"
+)
+
+// Recommended maximum number of partition replicas per broker for MSK
+// provisioned broker sizes.
+// https://docs.aws.amazon.com/msk/latest/developerguide/bestpractices.html#partitions-per-broker
+func partitionLimitPerBroker(instanceType string) int {
+	size := instanceType[strings.LastIndex(instanceType, ".")+1:]
+	switch {
+	case instanceType == "":
+		return 0
+	case strings.HasSuffix(instanceType, "t3.small"):
+		return 300
+	case size == "large" || size == "xlarge":
+		return 1000
+	case size == "2xlarge":
+		return 2000
+	default:
+		return 4000
+	}
+}
+
+// capacityReport describes the utilization of a provisioned cluster
+// relative to its limits. Zero limits are unknown and not checked.
+type capacityReport struct {
+	PartitionReplicas          int
+	ProjectedPartitionReplicas int
+	PartitionReplicaLimit      int
+	StorageBytes               int64
+	StorageLimitBytes          int64
+	Warnings                   []string
+}
+
+// capacityPreflight reports the capacity of the cluster before a topic is
+// created and warns when the topic would push it past a threshold. It is
+// advisory only; failures to gather the metrics are logged and ignored.
+type capacityPreflight struct {
+	mskClient      MskClient
+	kafkaClient    KafkaClient
+	warningPercent int
+	logger         *zap.Logger
+}
+
+func newCapacityPreflight(mskClient MskClient, kafkaClient KafkaClient, warningPercent int, logger *zap.Logger) *capacityPreflight {
+	return &capacityPreflight{
+		mskClient:      mskClient,
+		kafkaClient:    kafkaClient,
+		warningPercent: warningPercent,
+		logger:         logger,
+	}
+}
+
+func (p *capacityPreflight) Check(ctx context.Context, info *tt.TopicInfo) *capacityReport {
+	if p.warningPercent == 0 {
+		return nil
+	}
+	r := &capacityReport{}
+	dc, err := p.mskClient.DescribeCluster(ctx, &kafka.DescribeClusterInput{ClusterArn: &info.ClusterArn})
+	if err != nil {
+		p.logger.Sugar().Warnw("Skip capacity preflight", "Reason", err.Error())
+		return nil
+	}
+	brokers := 0
+	if ci := dc.ClusterInfo; ci != nil {
+		brokers = int(ci.NumberOfBrokerNodes)
+		if bg := ci.BrokerNodeGroupInfo; bg != nil {
+			if bg.InstanceType != nil {
+				r.PartitionReplicaLimit = partitionLimitPerBroker(*bg.InstanceType) * brokers
+			}
+			if bg.StorageInfo != nil && bg.StorageInfo.EbsStorageInfo != nil {
+				r.StorageLimitBytes = int64(bg.StorageInfo.EbsStorageInfo.VolumeSize) * int64(brokers) << 30
+			}
+		}
+	}
+
+	topics, err := p.kafkaClient.ListTopics(ctx)
+	if err != nil {
+		p.logger.Sugar().Warnw("Skip partition capacity check", "Reason", err.Error())
+		r.PartitionReplicaLimit = 0
+	} else {
+		for _, t := range topics {
+			for _, pd := range t.Partitions {
+				r.PartitionReplicas += len(pd.Replicas)
+			}
+		}
+	}
+	rf := info.ReplicationFactor
+	if rf <= 0 {
+		// Default replication factor. Assume one replica per broker up to 3.
+		rf = 3
+		if brokers > 0 && brokers < rf {
+			rf = brokers
+		}
+	}
+	r.ProjectedPartitionReplicas = r.PartitionReplicas + info.Partitions*rf
+
+	dirs, err := p.kafkaClient.DescribeAllLogDirs(ctx, nil)
+	if err != nil {
+		p.logger.Sugar().Warnw("Skip storage capacity check", "Reason", err.Error())
+		r.StorageLimitBytes = 0
+	} else {
+		for _, d := range dirs {
+			r.StorageBytes += d.Size()
+		}
+	}
+
+	if exceeds(int64(r.ProjectedPartitionReplicas), int64(r.PartitionReplicaLimit), p.warningPercent) {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("Topic would bring the cluster to %d of the recommended %d partition replicas", r.ProjectedPartitionReplicas, r.PartitionReplicaLimit))
+	}
+	if exceeds(r.StorageBytes, r.StorageLimitBytes, p.warningPercent) {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("Cluster uses %d of %d bytes of broker storage", r.StorageBytes, r.StorageLimitBytes))
+	}
+
+	fields := []interface{}{
+		"PartitionReplicas", r.PartitionReplicas,
+		"ProjectedPartitionReplicas", r.ProjectedPartitionReplicas,
+		"PartitionReplicaLimit", r.PartitionReplicaLimit,
+		"StorageBytes", r.StorageBytes,
+		"StorageLimitBytes", r.StorageLimitBytes,
+	}
+	if len(r.Warnings) > 0 {
+		p.logger.Sugar().Warnw("Cluster near capacity", append(fields, "Warnings", r.Warnings, "ThresholdPercent", p.warningPercent)...)
+	} else {
+		p.logger.Sugar().Infow("Cluster capacity", fields...)
+	}
+	return r
+}
+
+// Returns true when used is at least percent of a known limit.
+func exceeds(used, limit int64, percent int) bool {
+	return limit > 0 && used*100 >= limit*int64(percent)
+}
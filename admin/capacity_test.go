@@ -0,0 +1,119 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	tt "github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/admin/mocks"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kafka"
+	kt "github.com/aws/aws-sdk-go-v2/service/kafka/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"go.uber.org/zap"
+)
+
+func TestCapacityPreflight(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type testCase struct {
+		partitions   int
+		replicas     int
+		storageBytes int64
+		warnings     int
+	}
+
+	// Three kafka.t3.small brokers with 1 GiB volumes allow 900 replicas
+	// and 3 GiB of storage.
+	cases := map[string]testCase{
+		"Plenty of capacity":       {partitions: 10, replicas: 99, storageBytes: 1 << 30, warnings: 0},
+		"Topic exceeds partitions": {partitions: 50, replicas: 600, storageBytes: 1 << 30, warnings: 1},
+		"Storage below threshold":  {partitions: 10, replicas: 99, storageBytes: 3 << 29, warnings: 0},
+		"Storage exceeds":          {partitions: 10, replicas: 99, storageBytes: 3 << 30, warnings: 1},
+		"Both exceed":              {partitions: 100, replicas: 798, storageBytes: 3 << 30, warnings: 2},
+	}
+
+	for k, c := range cases {
+		// Arrange
+		ctx := context.TODO()
+		clusterArn := "arn:aws:kafka:us-east-1:123456789012:cluster/c/uuid"
+		mskClient := mocks.NewMockMskClient(ctrl)
+		kafkaClient := mocks.NewMockKafkaClient(ctrl)
+		preflight := newCapacityPreflight(mskClient, kafkaClient, 80, zap.NewNop())
+
+		mskClient.EXPECT().DescribeCluster(ctx, &kafka.DescribeClusterInput{ClusterArn: &clusterArn}).Return(&kafka.DescribeClusterOutput{ClusterInfo: &kt.ClusterInfo{
+			NumberOfBrokerNodes: 3,
+			BrokerNodeGroupInfo: &kt.BrokerNodeGroupInfo{
+				InstanceType: aws.String("kafka.t3.small"),
+				StorageInfo:  &kt.StorageInfo{EbsStorageInfo: &kt.EBSStorageInfo{VolumeSize: 1}},
+			},
+		}}, error(nil))
+		kafkaClient.EXPECT().ListTopics(ctx).Return(kadm.TopicDetails{"existing": {Topic: "existing", Partitions: replicatedPartitions(c.replicas)}}, error(nil))
+		kafkaClient.EXPECT().DescribeAllLogDirs(ctx, nil).Return(kadm.DescribedAllLogDirs{
+			1: {"/data": {Broker: 1, Dir: "/data", Topics: kadm.DescribedLogDirTopics{"existing": {0: {Topic: "existing", Size: c.storageBytes}}}}},
+		}, error(nil))
+
+		// Act
+		r := preflight.Check(ctx, &tt.TopicInfo{ClusterArn: clusterArn, Partitions: c.partitions, ReplicationFactor: 3})
+
+		// Assert
+		assert.Equal(t, 900, r.PartitionReplicaLimit, k)
+		assert.Equal(t, c.replicas, r.PartitionReplicas, k)
+		assert.Equal(t, c.replicas+c.partitions*3, r.ProjectedPartitionReplicas, k)
+		assert.Equal(t, c.storageBytes, r.StorageBytes, k)
+		assert.Len(t, r.Warnings, c.warnings, k)
+	}
+}
+
+func TestCapacityPreflightAdvisory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.TODO()
+	mskClient := mocks.NewMockMskClient(ctrl)
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+
+	// Disabled
+	assert.Nil(t, newCapacityPreflight(mskClient, kafkaClient, 0, zap.NewNop()).Check(ctx, &tt.TopicInfo{}))
+
+	// Cluster cannot be described
+	mskClient.EXPECT().DescribeCluster(ctx, gomock.Any()).Return(nil, errors.New("denied"))
+	assert.Nil(t, newCapacityPreflight(mskClient, kafkaClient, 80, zap.NewNop()).Check(ctx, &tt.TopicInfo{}))
+
+	// Metrics cannot be gathered
+	mskClient.EXPECT().DescribeCluster(ctx, gomock.Any()).Return(&kafka.DescribeClusterOutput{ClusterInfo: &kt.ClusterInfo{
+		NumberOfBrokerNodes: 1,
+		BrokerNodeGroupInfo: &kt.BrokerNodeGroupInfo{InstanceType: aws.String("kafka.t3.small")},
+	}}, error(nil))
+	kafkaClient.EXPECT().ListTopics(ctx).Return(nil, errors.New("denied"))
+	kafkaClient.EXPECT().DescribeAllLogDirs(ctx, nil).Return(nil, errors.New("denied"))
+	r := newCapacityPreflight(mskClient, kafkaClient, 80, zap.NewNop()).Check(ctx, &tt.TopicInfo{Partitions: 1000, ReplicationFactor: 1})
+	assert.Empty(t, r.Warnings)
+}
+
+func TestPartitionLimitPerBroker(t *testing.T) {
+	assert.Equal(t, 300, partitionLimitPerBroker("kafka.t3.small"))
+	assert.Equal(t, 1000, partitionLimitPerBroker("kafka.m5.large"))
+	assert.Equal(t, 1000, partitionLimitPerBroker("kafka.m7g.xlarge"))
+	assert.Equal(t, 2000, partitionLimitPerBroker("kafka.m5.2xlarge"))
+	assert.Equal(t, 4000, partitionLimitPerBroker("kafka.m5.24xlarge"))
+	assert.Equal(t, 0, partitionLimitPerBroker(""))
+}
+
+// Returns partitions with n replicas in total, three per partition.
+func replicatedPartitions(n int) kadm.PartitionDetails {
+	ps := kadm.PartitionDetails{}
+	for i := 0; i < n/3; i++ {
+		ps[int32(i)] = kadm.PartitionDetail{Partition: int32(i), Replicas: []int32{1, 2, 3}}
+	}
+	return ps
+}
@@ -5,10 +5,16 @@ package admin
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws-samples/amazon-msk-topic-resource/types"
 
 	"github.com/pkg/errors"
+	"github.com/twmb/franz-go/pkg/kadm"
 	"github.com/twmb/franz-go/pkg/kerr"
 	"go.uber.org/zap"
 )
@@ -17,47 +23,422 @@ type cmdCreate struct {
 	kafkaClient    KafkaClient
 	kmsKeyResolver KmsKeyResolverService
 	userManager    UserManagerService
-	logger         *zap.Logger
+	topicMarkers   TopicMarkerService
+	guardrails     *guardrails
+	serverless     bool
+	// Apply ACLs of all users as a unit once every user is created.
+	transactionalACLs bool
+	// Wait up to topicReadyTimeout for a created topic to be listed with
+	// all of its partitions before creating users. Disabled when zero.
+	topicReadyTimeout time.Duration
+	sleep             func(time.Duration)
+	logger            *zap.Logger
 }
 
+// Interval between checks made while waiting for a created topic.
+const topicReadyInterval = time.Second
+
 type createTopicResult struct {
 	PhysicalResourceID string
+	TopicName          string
 	UsernameSuffix     string
+	Plan               *changePlan
+	UserResults        []userResult
+	// Replica brokers of each partition chosen by the cluster, e.g.
+	// "0:1,2,3;1:2,3,1". Empty if the assignment could not be described.
+	PartitionAssignment string
+	// ARNs of the secrets of users by username. Users without a secret
+	// (e.g. TLS users) are omitted.
+	SecretArns map[string]string
+	// KMS key encrypting the generated secrets of users. Empty when the
+	// topic has no SASL/SCRAM users.
+	KmsKeyArn string
 }
 
-func newCmdCreate(kafkaClient KafkaClient, kmsKeyResolver KmsKeyResolverService, userManager UserManagerService, logger *zap.Logger) *cmdCreate {
+func newCmdCreate(kafkaClient KafkaClient, kmsKeyResolver KmsKeyResolverService, userManager UserManagerService, topicMarkers TopicMarkerService, serverless bool, settings *Settings, logger *zap.Logger) *cmdCreate {
 	return &cmdCreate{
-		kafkaClient:    kafkaClient,
-		kmsKeyResolver: kmsKeyResolver,
-		userManager:    userManager,
-		logger:         logger,
+		kafkaClient:       kafkaClient,
+		kmsKeyResolver:    kmsKeyResolver,
+		userManager:       userManager,
+		topicMarkers:      topicMarkers,
+		guardrails:        newGuardrails(settings),
+		serverless:        serverless,
+		transactionalACLs: settings.TransactionalACLs,
+		topicReadyTimeout: settings.TopicReadyTimeout,
+		sleep:             time.Sleep,
+		logger:            logger,
 	}
 }
 
 func (a *cmdCreate) Run(ctx context.Context, info *types.TopicInfo, stackID string) (*createTopicResult, error) {
+	err := a.guardrails.Validate(info)
+	if err != nil {
+		return nil, err
+	}
+	info.Config = a.guardrails.EnforceConfig(info.Config, a.logger)
 	kmsKeyID, err := a.kmsKeyResolver.Resolve(ctx, info)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	shortStackID := shortStackID(stackID)
+	shortStackID := nameSuffix(info, stackID)
 	topicName := canonicalTopicName(info.Name, shortStackID)
+	rid := physicalResourceID(info.ClusterArn, topicName)
+	// Serverless clusters manage replication automatically.
+	replicationFactor := int16(-1)
+	if a.serverless && len(info.ReplicaAssignment) > 0 {
+		return nil, errors.New("ReplicaAssignment is not supported by serverless clusters")
+	}
+	if a.serverless && info.RackAware {
+		return nil, errors.New("RackAware is not supported by serverless clusters")
+	}
+	assignment := info.ReplicaAssignment
+	if !a.serverless {
+		brokers, err := a.validateReplicationFactor(ctx, info)
+		if err != nil {
+			return nil, err
+		}
+		replicationFactor = int16(info.ReplicationFactor)
+		if info.RackAware {
+			assignment, err = rackAwareAssignment(brokers, info.Partitions, info.ReplicationFactor)
+			if err != nil {
+				return nil, err
+			}
+			a.logger.Sugar().Infow("Rack aware assignment computed", "TopicName", topicName, "Assignment", assignment)
+		}
+	}
+	err = a.validateOwnership(ctx, info, topicName, stackID)
+	if err != nil {
+		return nil, err
+	}
+	if info.DryRun {
+		a.logger.Sugar().Infow("Dry run requested, skipping topic creation", "TopicName", topicName)
+		return &createTopicResult{
+			PhysicalResourceID: rid,
+			TopicName:          topicName,
+			UsernameSuffix:     shortStackID,
+			Plan:               newCreatePlan(topicName, info),
+		}, nil
+	}
+	err = a.topicMarkers.Put(ctx, info.ClusterArn, topicName, &types.TopicMarker{StackID: stackID, Tags: info.Tags, Labels: info.Labels, RequestID: existencePolicyFrom(ctx).RequestID()})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
 	a.logger.Sugar().Infow("Start Operation", "Name", "CreateTopic", "TopicName", topicName)
-	_, err = a.kafkaClient.CreateTopic(ctx, int32(info.Partitions), int16(info.ReplicationFactor), info.Config, topicName)
+	_, err = a.kafkaClient.CreateTopic(ctx, int32(info.Partitions), replicationFactor, info.Config, assignment, topicName)
 	if err != nil {
 		if !errors.Is(err, kerr.TopicAlreadyExists) {
 			return nil, errors.WithStack(err)
 		}
+		if err := existencePolicyFrom(ctx).Adopt("topic", topicName); err != nil {
+			return nil, err
+		}
 		a.logger.Sugar().Infow("Retry Handled", "Operation", "CreateTopic", "TopicName", topicName)
+		opSummaryFrom(ctx).Skipped("CreateTopic")
+	} else {
+		opSummaryFrom(ctx).Performed("CreateTopic")
+		partitions := info.Partitions
+		if len(assignment) > 0 {
+			partitions = len(assignment)
+		}
+		err = a.awaitTopic(ctx, topicName, partitions)
+		if err != nil {
+			return nil, err
+		}
+	}
+	batch := newAssociationBatch()
+	userCtx := withAssociationBatch(ctx, batch)
+	var tx *aclTransaction
+	if a.transactionalACLs {
+		tx = newACLTransaction()
+		userCtx = withACLTransaction(userCtx, tx)
 	}
-	for _, u := range info.Users {
-		err := a.userManager.CreateUser(ctx, shortStackID, topicName, kmsKeyID, info.ClusterArn, &u)
+	results := make([]userResult, 0, len(info.Users))
+	for i, u := range info.Users {
+		// Stop creating users once the invocation is cancelled or about
+		// to time out. The user is reported as failed with the reason.
+		err := ctx.Err()
+		if err == nil {
+			err = a.userManager.CreateUser(userCtx, shortStackID, topicName, kmsKeyID, info.ClusterArn, &u)
+		}
+		results = append(results, newUserResult(u.Username, err))
 		if err != nil {
-			return nil, errors.WithStack(err)
+			if tx != nil {
+				// None of the collected ACLs were applied.
+				for j := range results[:i] {
+					results[j] = userResult{Username: results[j].Username, Status: UserStatusCreated, Reason: fmt.Sprintf("ACLs not applied because user %s failed", u.Username)}
+				}
+			}
+			for _, s := range info.Users[i+1:] {
+				results = append(results, userResult{Username: s.Username, Status: UserStatusSkipped})
+			}
+			a.rollbackUsers(ctx, info, info.Users[:i], kmsKeyID, topicName, shortStackID, results)
+			// Return the partial result so that the outcome of each user
+			// can be reported along with the error.
+			return &createTopicResult{
+				PhysicalResourceID: rid,
+				UsernameSuffix:     shortStackID,
+				UserResults:        results,
+			}, errors.WithStack(err)
 		}
 	}
+	err = a.associateSecrets(ctx, info.ClusterArn, batch, results)
+	if err != nil {
+		a.rollbackUsers(ctx, info, info.Users, kmsKeyID, topicName, shortStackID, results)
+		return &createTopicResult{
+			PhysicalResourceID: rid,
+			UsernameSuffix:     shortStackID,
+			UserResults:        results,
+		}, errors.WithStack(err)
+	}
+	if tx != nil {
+		err = a.userManager.ApplyACLs(ctx, tx.ACLs())
+		if err != nil {
+			for j := range results {
+				results[j] = newUserResult(results[j].Username, &aclError{err})
+			}
+			a.rollbackUsers(ctx, info, info.Users, kmsKeyID, topicName, shortStackID, results)
+			return &createTopicResult{
+				PhysicalResourceID: rid,
+				UsernameSuffix:     shortStackID,
+				UserResults:        results,
+			}, errors.WithStack(err)
+		}
+	}
+	secretArns, err := lookupSecretArns(ctx, a.userManager, info, shortStackID, a.serverless)
+	if err != nil {
+		return &createTopicResult{
+			PhysicalResourceID: rid,
+			UsernameSuffix:     shortStackID,
+			UserResults:        results,
+		}, err
+	}
 	a.logger.Sugar().Infow("Topic configuration successfully completed")
 	return &createTopicResult{
-		PhysicalResourceID: topicName,
-		UsernameSuffix:     shortStackID,
+		PhysicalResourceID:  rid,
+		TopicName:           topicName,
+		UsernameSuffix:      shortStackID,
+		UserResults:         results,
+		PartitionAssignment: describeAssignment(ctx, a.kafkaClient, topicName, a.logger),
+		SecretArns:          secretArns,
+		KmsKeyArn:           kmsKeyID,
 	}, nil
 }
+
+// Deletes the users created before a user failed, or all of them when
+// their secrets or ACLs could not be applied, so that CloudFormation does
+// not roll back a partially provisioned topic. Secrets associated by a
+// partially successful batch are disassociated by DeleteUser. Rollback is
+// best-effort: users that cannot be deleted are left to the delete that
+// rolls back the resource. A user whose creation failed is left to it
+// too, since it may have adopted resources it does not own.
+func (a *cmdCreate) rollbackUsers(ctx context.Context, info *types.TopicInfo, users []types.User, kmsKeyID, topicName, shortStackID string, results []userResult) {
+	if len(users) == 0 {
+		return
+	}
+	if ctx.Err() != nil {
+		a.logger.Sugar().Warnw("Skip Operation", "Name", "RollbackUser", "Count", len(users), "Reason", "Invocation cancelled")
+		opSummaryFrom(ctx).Skipped("RollbackUser")
+		return
+	}
+	for j := range users {
+		u := users[j]
+		a.logger.Sugar().Infow("Start Operation", "Name", "RollbackUser", "Username", u.Username)
+		err := a.userManager.DeleteUser(ctx, &u, kmsKeyID, topicName, shortStackID, info.ClusterArn)
+		if err != nil {
+			a.logger.Sugar().Errorw("Operation Failed", "Name", "RollbackUser", "Username", u.Username, "Error", err)
+			results[j].Reason = fmt.Sprintf("rollback failed: %s", err)
+			continue
+		}
+		results[j] = userResult{Username: u.Username, Status: UserStatusRolledBack}
+		opSummaryFrom(ctx).Performed("RollbackUser")
+	}
+}
+
+// Associates the secrets of all users created by the command in one call
+// rather than one call per user. Users whose secret is not associated are
+// reported as failed.
+func (a *cmdCreate) associateSecrets(ctx context.Context, clusterArn string, batch *associationBatch, results []userResult) error {
+	secretArns := batch.SecretArns()
+	if len(secretArns) == 0 {
+		return nil
+	}
+	err := a.userManager.AssociateSecrets(ctx, clusterArn, secretArns)
+	if err != nil {
+		for j := range results {
+			if batch.Has(results[j].Username) {
+				results[j] = newUserResult(results[j].Username, err)
+			}
+		}
+	}
+	return err
+}
+
+// Polls until the created topic is listed with the expected number of
+// partitions. Brokers learn about new topics asynchronously, therefore
+// ACLs and clients of users created right away may otherwise hit
+// UNKNOWN_TOPIC_OR_PARTITION. Returns an error when the topic is not
+// ready within topicReadyTimeout.
+func (a *cmdCreate) awaitTopic(ctx context.Context, topicName string, partitions int) error {
+	if a.topicReadyTimeout <= 0 {
+		return nil
+	}
+	a.logger.Sugar().Infow("Start Operation", "Name", "AwaitTopic", "TopicName", topicName, "Partitions", partitions, "Timeout", a.topicReadyTimeout.String())
+	var waited time.Duration
+	for {
+		topics, err := a.kafkaClient.ListTopics(ctx, topicName)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if t, ok := topics[topicName]; ok && t.Err == nil && len(t.Partitions) == partitions {
+			a.logger.Sugar().Infow("Operation Finished", "Name", "AwaitTopic", "TopicName", topicName, "Waited", waited.String())
+			opSummaryFrom(ctx).Performed("AwaitTopic")
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return errors.WithStack(err)
+		}
+		if waited >= a.topicReadyTimeout {
+			return errors.WithStack(fmt.Errorf("topic %s not listed with %d partitions after %s", topicName, partitions, a.topicReadyTimeout))
+		}
+		a.sleep(topicReadyInterval)
+		waited += topicReadyInterval
+	}
+}
+
+// Describes the replica assignment of the topic so that operators can
+// verify the spread of replicas across brokers and racks. The topic is
+// already created, therefore failures are logged rather than returned.
+func describeAssignment(ctx context.Context, kafkaClient KafkaClient, topicName string, logger *zap.Logger) string {
+	logger.Sugar().Infow("Start Operation", "Name", "ListTopics", "TopicName", topicName)
+	topics, err := kafkaClient.ListTopics(ctx, topicName)
+	if err == nil {
+		err = topics[topicName].Err
+	}
+	if err != nil {
+		logger.Sugar().Warnw("Unable to describe partition assignment", "TopicName", topicName, "Error", err)
+		return ""
+	}
+	return partitionAssignment(topics[topicName].Partitions)
+}
+
+// Formats the replicas of each partition as partition:replica,... pairs
+// separated by semicolons, ordered by partition.
+func partitionAssignment(partitions kadm.PartitionDetails) string {
+	parts := make([]string, 0, len(partitions))
+	for _, p := range partitions.Numbers() {
+		replicas := make([]string, len(partitions[p].Replicas))
+		for i, r := range partitions[p].Replicas {
+			replicas[i] = strconv.Itoa(int(r))
+		}
+		parts = append(parts, fmt.Sprintf("%d:%s", p, strings.Join(replicas, ",")))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Kafka rejects a replication factor larger than the number of brokers
+// with an error that is hard to interpret in CloudFormation events.
+// Check it upfront so that the failure reason is obvious.
+// Returns the brokers of the cluster.
+func (a *cmdCreate) validateReplicationFactor(ctx context.Context, info *types.TopicInfo) (kadm.BrokerDetails, error) {
+	a.logger.Sugar().Infow("Start Operation", "Name", "ListBrokers")
+	brokers, err := a.kafkaClient.ListBrokers(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if info.ReplicationFactor > len(brokers) {
+		return nil, errors.WithStack(newClassifiedError(ErrCodeReplicationFactor, "ReplicationFactor %d exceeds available brokers %d", info.ReplicationFactor, len(brokers)))
+	}
+	ids := make(map[int32]bool, len(brokers))
+	for _, b := range brokers {
+		ids[b.NodeID] = true
+	}
+	for _, p := range sortedPartitions(info.ReplicaAssignment) {
+		for _, id := range info.ReplicaAssignment[p] {
+			if !ids[id] {
+				return nil, errors.WithStack(newClassifiedError(ErrCodeReplicationFactor, "ReplicaAssignment.%d references broker %d that is not in the cluster", p, id))
+			}
+		}
+	}
+	return brokers, nil
+}
+
+// Assigns the replicas of each partition to brokers in distinct racks.
+// Brokers are ordered by alternating between racks, e.g. a1, b1, c1, a2,
+// b2, c2, and each partition takes the next brokers of distinct racks
+// starting one position after the previous partition, so that leaders and
+// replicas are spread evenly across brokers.
+func rackAwareAssignment(brokers kadm.BrokerDetails, partitions, replicationFactor int) (types.ReplicaAssignment, error) {
+	byRack := make(map[string][]int32)
+	racks := make([]string, 0)
+	for _, b := range brokers {
+		if b.Rack == nil || *b.Rack == "" {
+			return nil, errors.WithStack(newClassifiedError(ErrCodeReplicationFactor, "RackAware requires every broker to have a rack but broker %d has none", b.NodeID))
+		}
+		if _, ok := byRack[*b.Rack]; !ok {
+			racks = append(racks, *b.Rack)
+		}
+		byRack[*b.Rack] = append(byRack[*b.Rack], b.NodeID)
+	}
+	if replicationFactor > len(racks) {
+		return nil, errors.WithStack(newClassifiedError(ErrCodeReplicationFactor, "RackAware requires ReplicationFactor %d distinct racks but the cluster has %d", replicationFactor, len(racks)))
+	}
+	sort.Strings(racks)
+	for _, r := range racks {
+		ids := byRack[r]
+		sort.Slice(ids, func(x, y int) bool { return ids[x] < ids[y] })
+	}
+	rackOf := make(map[int32]string, len(brokers))
+	ordered := make([]int32, 0, len(brokers))
+	for i := 0; len(ordered) < len(brokers); i++ {
+		for _, r := range racks {
+			ids := byRack[r]
+			if i < len(ids) {
+				ordered = append(ordered, ids[i])
+				rackOf[ids[i]] = r
+			}
+		}
+	}
+	assignment := make(types.ReplicaAssignment, partitions)
+	for p := 0; p < partitions; p++ {
+		replicas := make([]int32, 0, replicationFactor)
+		used := make(map[string]bool, replicationFactor)
+		for i := 0; i < len(ordered) && len(replicas) < replicationFactor; i++ {
+			id := ordered[(p+i)%len(ordered)]
+			if used[rackOf[id]] {
+				continue
+			}
+			used[rackOf[id]] = true
+			replicas = append(replicas, id)
+		}
+		assignment[int32(p)] = replicas
+	}
+	return assignment, nil
+}
+
+// Short stack hash used in canonical topic names is not collision free.
+// Fail with a report naming both stacks when the topic already exists
+// and is managed by another stack.
+func (a *cmdCreate) validateOwnership(ctx context.Context, info *types.TopicInfo, topicName, stackID string) error {
+	a.logger.Sugar().Infow("Start Operation", "Name", "ListTopics", "TopicName", topicName)
+	topics, err := a.kafkaClient.ListTopics(ctx, topicName)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if t, ok := topics[topicName]; !ok || t.Err != nil {
+		return nil
+	}
+	marker, err := a.topicMarkers.Get(ctx, info.ClusterArn, topicName)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if marker == nil {
+		a.logger.Sugar().Warnw("Topic already exists but it is not managed by TR", "TopicName", topicName)
+		return existencePolicyFrom(ctx).Adopt("topic", topicName)
+	}
+	if marker.StackID != stackID {
+		return errors.WithStack(newClassifiedError(ErrCodeTopicAlreadyExists, "topic name collision: topic %s in cluster %s is managed by stack %s and cannot be created by stack %s", topicName, info.ClusterArn, marker.StackID, stackID))
+	}
+	policy := existencePolicyFrom(ctx)
+	policy.Observe(marker)
+	return policy.Adopt("topic", topicName)
+}
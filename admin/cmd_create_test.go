@@ -0,0 +1,965 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tt "github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/admin/mocks"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"go.uber.org/zap"
+)
+
+func TestCmdCreate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+	type testCase struct {
+		name              string
+		info              *tt.TopicInfo
+		listBrokersOutput []interface{}
+		topicExists       bool
+		markerOutput      []interface{}
+		expectCreateTopic bool
+		expectPlan        bool
+		expectTopicName   string
+		err               string
+	}
+
+	stackID := "test"
+	shortStackID := shortStackID(stackID)
+	threeBrokers := kadm.BrokerDetails{{NodeID: 1}, {NodeID: 2}, {NodeID: 3}}
+
+	cases := []testCase{
+		{
+			name:              "Replication factor within broker count",
+			info:              &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 3},
+			listBrokersOutput: []interface{}{threeBrokers, error(nil)},
+			expectCreateTopic: true,
+		},
+		{
+			name:              "Replication factor exceeds broker count",
+			info:              &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 5},
+			listBrokersOutput: []interface{}{threeBrokers, error(nil)},
+			err:               "ReplicationFactor 5 exceeds available brokers 3",
+		},
+		{
+			name:              "Dry run",
+			info:              &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 3, Users: []tt.User{{Username: "alice"}}, DryRun: true},
+			listBrokersOutput: []interface{}{threeBrokers, error(nil)},
+			expectPlan:        true,
+		},
+		{
+			name:              "Custom name suffix",
+			info:              &tt.TopicInfo{Name: "a", NameSuffix: aws.String("v2"), Partitions: 1, ReplicationFactor: 3},
+			listBrokersOutput: []interface{}{threeBrokers, error(nil)},
+			expectCreateTopic: true,
+			expectTopicName:   "a-v2",
+		},
+		{
+			name:              "Empty name suffix",
+			info:              &tt.TopicInfo{Name: "a", NameSuffix: aws.String(""), Partitions: 1, ReplicationFactor: 3},
+			listBrokersOutput: []interface{}{threeBrokers, error(nil)},
+			expectCreateTopic: true,
+			expectTopicName:   "a",
+		},
+		{
+			name:              "Read-only config is set on create",
+			info:              &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 3, Config: map[string]*string{"remote.storage.enable": aws.String("true")}},
+			listBrokersOutput: []interface{}{threeBrokers, error(nil)},
+			expectCreateTopic: true,
+		},
+		{
+			name:              "Tags are recorded in marker",
+			info:              &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 3, ClusterArn: "cluster", Tags: map[string]string{"team": "payments"}},
+			listBrokersOutput: []interface{}{threeBrokers, error(nil)},
+			expectCreateTopic: true,
+		},
+		{
+			name:              "Existing topic managed by same stack",
+			info:              &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 3, ClusterArn: "cluster"},
+			listBrokersOutput: []interface{}{threeBrokers, error(nil)},
+			topicExists:       true,
+			markerOutput:      []interface{}{&tt.TopicMarker{StackID: stackID}, error(nil)},
+			expectCreateTopic: true,
+		},
+		{
+			name:              "Existing topic managed by different stack",
+			info:              &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 3, ClusterArn: "cluster"},
+			listBrokersOutput: []interface{}{threeBrokers, error(nil)},
+			topicExists:       true,
+			markerOutput:      []interface{}{&tt.TopicMarker{StackID: "other"}, error(nil)},
+			err:               "topic name collision: topic a-" + shortStackID + " in cluster cluster is managed by stack other and cannot be created by stack test",
+		},
+		{
+			name:              "Existing topic not managed by TR",
+			info:              &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 3, ClusterArn: "cluster"},
+			listBrokersOutput: []interface{}{threeBrokers, error(nil)},
+			topicExists:       true,
+			markerOutput:      []interface{}{(*tt.TopicMarker)(nil), error(nil)},
+			expectCreateTopic: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			ctx := context.TODO()
+			topicName := canonicalTopicName(c.info.Name, nameSuffix(c.info, stackID))
+
+			kafkaClient := mocks.NewMockKafkaClient(ctrl)
+			kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+			userManager := mocks.NewMockUserManagerService(ctrl)
+			topicMarkers := mocks.NewMockTopicMarkerService(ctrl)
+
+			cmdCreate := newCmdCreate(kafkaClient, kmsKeyResolver, userManager, topicMarkers, false, testSettings(), logger)
+
+			kmsKeyResolver.EXPECT().Resolve(ctx, c.info).Return("", error(nil))
+			kafkaClient.EXPECT().ListBrokers(ctx).Return(c.listBrokersOutput...)
+			if c.info.ReplicationFactor <= len(threeBrokers) {
+				topicDetail := kadm.TopicDetail{Topic: topicName, Err: kerr.UnknownTopicOrPartition}
+				if c.topicExists {
+					topicDetail.Err = nil
+				}
+				kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: topicDetail}, error(nil))
+			}
+			if c.markerOutput != nil {
+				topicMarkers.EXPECT().Get(ctx, c.info.ClusterArn, topicName).Return(c.markerOutput...)
+			}
+			if c.expectCreateTopic {
+				topicMarkers.EXPECT().Put(ctx, c.info.ClusterArn, topicName, &tt.TopicMarker{StackID: stackID, Tags: c.info.Tags}).Return(error(nil))
+				kafkaClient.EXPECT().CreateTopic(ctx, int32(c.info.Partitions), int16(c.info.ReplicationFactor), c.info.Config, gomock.Nil(), topicName).
+					Return(kadm.CreateTopicResponse{Topic: topicName}, error(nil))
+				kafkaClient.EXPECT().ListTopics(ctx, topicName).
+					Return(kadm.TopicDetails{topicName: {Topic: topicName, Partitions: kadm.PartitionDetails{0: {Partition: 0, Replicas: []int32{2, 1, 3}}}}}, error(nil))
+			}
+
+			// Act
+			result, err := cmdCreate.Run(ctx, c.info, stackID)
+
+			// Assert
+			if c.err != "" {
+				assert.EqualError(t, err, c.err)
+				assert.Nil(t, result)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, physicalResourceID(c.info.ClusterArn, topicName), result.PhysicalResourceID)
+				assert.Equal(t, topicName, result.TopicName)
+				if c.expectTopicName != "" {
+					assert.Equal(t, c.expectTopicName, result.TopicName)
+				}
+				if c.expectCreateTopic {
+					assert.Equal(t, "0:2,1,3", result.PartitionAssignment)
+				}
+				// No key is resolved for topics without users.
+				assert.Empty(t, result.KmsKeyArn)
+			}
+			if c.expectPlan {
+				assert.Equal(t, &changePlan{
+					TopicName:         topicName,
+					CreateTopic:       true,
+					Partitions:        c.info.Partitions,
+					ReplicationFactor: c.info.ReplicationFactor,
+					AddedUsers:        []string{"alice"},
+				}, result.Plan)
+			}
+		})
+	}
+}
+
+func TestCmdCreateUserResults(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	// Arrange
+	ctx := context.TODO()
+	stackID := "test"
+	shortStackID := shortStackID(stackID)
+	alice := tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}
+	bob := tt.User{Username: "bob", Permissions: []tt.Permission{tt.PermissionWrite}}
+	charlie := tt.User{Username: "charlie", Permissions: []tt.Permission{tt.PermissionRead}}
+	info := &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 1, ClusterArn: "cluster", Users: []tt.User{alice, bob, charlie}}
+	topicName := canonicalTopicName(info.Name, shortStackID)
+
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+	userManager := mocks.NewMockUserManagerService(ctrl)
+	topicMarkers := mocks.NewMockTopicMarkerService(ctrl)
+	cmdCreate := newCmdCreate(kafkaClient, kmsKeyResolver, userManager, topicMarkers, false, testSettings(), logger)
+
+	kmsKeyResolver.EXPECT().Resolve(ctx, info).Return("key", error(nil))
+	kafkaClient.EXPECT().ListBrokers(ctx).Return(kadm.BrokerDetails{{NodeID: 1}}, error(nil))
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: {Topic: topicName, Err: kerr.UnknownTopicOrPartition}}, error(nil))
+	topicMarkers.EXPECT().Put(ctx, info.ClusterArn, topicName, &tt.TopicMarker{StackID: stackID}).Return(error(nil))
+	kafkaClient.EXPECT().CreateTopic(ctx, int32(1), int16(1), info.Config, gomock.Nil(), topicName).Return(kadm.CreateTopicResponse{Topic: topicName}, error(nil))
+	gomock.InOrder(
+		userManager.EXPECT().CreateUser(gomock.Any(), shortStackID, topicName, "key", info.ClusterArn, &alice).Return(error(nil)),
+		userManager.EXPECT().CreateUser(gomock.Any(), shortStackID, topicName, "key", info.ClusterArn, &bob).Return(&aclError{kerr.SecurityDisabled}),
+		userManager.EXPECT().DeleteUser(ctx, &alice, "key", topicName, shortStackID, info.ClusterArn).Return(error(nil)),
+	)
+
+	// Act
+	result, err := cmdCreate.Run(ctx, info, stackID)
+
+	// Assert
+	assert.NotNil(t, err)
+	assert.Equal(t, []userResult{
+		{Username: "alice", Status: UserStatusRolledBack},
+		{Username: "bob", Status: UserStatusCreated, Reason: kerr.SecurityDisabled.Error()},
+		{Username: "charlie", Status: UserStatusSkipped},
+	}, result.UserResults)
+	assert.Equal(t, "user results [alice: ROLLED_BACK, bob: CREATED, charlie: SKIPPED]", userResultsSummary(result.UserResults))
+}
+
+func TestCmdCreateRollbackFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	ctx := context.TODO()
+	stackID := "test"
+	shortStackID := shortStackID(stackID)
+	alice := tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}
+	bob := tt.User{Username: "bob", Permissions: []tt.Permission{tt.PermissionWrite}}
+	charlie := tt.User{Username: "charlie", Permissions: []tt.Permission{tt.PermissionRead}}
+	info := &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 1, ClusterArn: "cluster", Users: []tt.User{alice, bob, charlie}}
+	topicName := canonicalTopicName(info.Name, shortStackID)
+
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+	userManager := mocks.NewMockUserManagerService(ctrl)
+	topicMarkers := mocks.NewMockTopicMarkerService(ctrl)
+	cmdCreate := newCmdCreate(kafkaClient, kmsKeyResolver, userManager, topicMarkers, false, testSettings(), zap.NewNop())
+
+	kmsKeyResolver.EXPECT().Resolve(ctx, info).Return("key", error(nil))
+	kafkaClient.EXPECT().ListBrokers(ctx).Return(kadm.BrokerDetails{{NodeID: 1}}, error(nil))
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: {Topic: topicName, Err: kerr.UnknownTopicOrPartition}}, error(nil))
+	topicMarkers.EXPECT().Put(ctx, info.ClusterArn, topicName, gomock.Any()).Return(error(nil))
+	kafkaClient.EXPECT().CreateTopic(ctx, int32(1), int16(1), info.Config, gomock.Nil(), topicName).Return(kadm.CreateTopicResponse{Topic: topicName}, error(nil))
+	// A user that cannot be rolled back does not stop the rollback of
+	// the other users.
+	gomock.InOrder(
+		userManager.EXPECT().CreateUser(gomock.Any(), shortStackID, topicName, "key", info.ClusterArn, &alice).Return(error(nil)),
+		userManager.EXPECT().CreateUser(gomock.Any(), shortStackID, topicName, "key", info.ClusterArn, &bob).Return(error(nil)),
+		userManager.EXPECT().CreateUser(gomock.Any(), shortStackID, topicName, "key", info.ClusterArn, &charlie).Return(errors.New("secret not created")),
+		userManager.EXPECT().DeleteUser(ctx, &alice, "key", topicName, shortStackID, info.ClusterArn).Return(errors.New("throttled")),
+		userManager.EXPECT().DeleteUser(ctx, &bob, "key", topicName, shortStackID, info.ClusterArn).Return(error(nil)),
+	)
+
+	// Act
+	result, err := cmdCreate.Run(ctx, info, stackID)
+
+	// Assert
+	assert.EqualError(t, err, "secret not created")
+	assert.Equal(t, []userResult{
+		{Username: "alice", Status: UserStatusACLsApplied, Reason: "rollback failed: throttled"},
+		{Username: "bob", Status: UserStatusRolledBack},
+		{Username: "charlie", Status: UserStatusFailed, Reason: "secret not created"},
+	}, result.UserResults)
+}
+
+func TestCmdCreateAssociationBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	type testCase struct {
+		name          string
+		bobErr        error
+		associateErr  error
+		expectArns    []string
+		expectErr     bool
+		expectResults []userResult
+	}
+
+	cases := []testCase{
+		{
+			name:       "All secrets associated in one call",
+			expectArns: []string{"secret-alice", "secret-bob", "secret-charlie"},
+			expectResults: []userResult{
+				{Username: "alice", Status: UserStatusACLsApplied},
+				{Username: "bob", Status: UserStatusACLsApplied},
+				{Username: "charlie", Status: UserStatusACLsApplied},
+			},
+		},
+		{
+			name:         "Association failure rolls back users",
+			associateErr: errors.New("failed to associate secret secret-bob: 400 invalid secret"),
+			expectArns:   []string{"secret-alice", "secret-bob", "secret-charlie"},
+			expectErr:    true,
+			expectResults: []userResult{
+				{Username: "alice", Status: UserStatusRolledBack},
+				{Username: "bob", Status: UserStatusRolledBack},
+				{Username: "charlie", Status: UserStatusRolledBack},
+			},
+		},
+		{
+			name:      "Users created before a failure are rolled back",
+			bobErr:    errors.New("secret not created"),
+			expectErr: true,
+			expectResults: []userResult{
+				{Username: "alice", Status: UserStatusRolledBack},
+				{Username: "bob", Status: UserStatusFailed, Reason: "secret not created"},
+				{Username: "charlie", Status: UserStatusSkipped},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			ctx := context.TODO()
+			stackID := "test"
+			shortStackID := shortStackID(stackID)
+			alice := tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}
+			bob := tt.User{Username: "bob", Permissions: []tt.Permission{tt.PermissionWrite}}
+			charlie := tt.User{Username: "charlie", Permissions: []tt.Permission{tt.PermissionRead}}
+			info := &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 1, ClusterArn: "cluster", Users: []tt.User{alice, bob, charlie}}
+			topicName := canonicalTopicName(info.Name, shortStackID)
+
+			kafkaClient := mocks.NewMockKafkaClient(ctrl)
+			kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+			userManager := mocks.NewMockUserManagerService(ctrl)
+			topicMarkers := mocks.NewMockTopicMarkerService(ctrl)
+			cmdCreate := newCmdCreate(kafkaClient, kmsKeyResolver, userManager, topicMarkers, false, testSettings(), logger)
+
+			kmsKeyResolver.EXPECT().Resolve(ctx, info).Return("key", error(nil))
+			kafkaClient.EXPECT().ListBrokers(ctx).Return(kadm.BrokerDetails{{NodeID: 1}}, error(nil))
+			kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: {Topic: topicName, Err: kerr.UnknownTopicOrPartition}}, error(nil))
+			topicMarkers.EXPECT().Put(ctx, info.ClusterArn, topicName, gomock.Any()).Return(error(nil))
+			kafkaClient.EXPECT().CreateTopic(ctx, int32(1), int16(1), info.Config, gomock.Nil(), topicName).Return(kadm.CreateTopicResponse{Topic: topicName}, error(nil))
+			batched := func(ctx context.Context, s, tn, k, ca string, u *tt.User) error {
+				associationBatchFrom(ctx).Add(u.Username, "secret-"+u.Username)
+				return nil
+			}
+			calls := []*gomock.Call{
+				userManager.EXPECT().CreateUser(gomock.Any(), shortStackID, topicName, "key", info.ClusterArn, &alice).DoAndReturn(batched),
+			}
+			if c.bobErr != nil {
+				calls = append(calls,
+					userManager.EXPECT().CreateUser(gomock.Any(), shortStackID, topicName, "key", info.ClusterArn, &bob).Return(c.bobErr),
+					userManager.EXPECT().DeleteUser(ctx, &alice, "key", topicName, shortStackID, info.ClusterArn).Return(error(nil)),
+				)
+			} else {
+				calls = append(calls,
+					userManager.EXPECT().CreateUser(gomock.Any(), shortStackID, topicName, "key", info.ClusterArn, &bob).DoAndReturn(batched),
+					userManager.EXPECT().CreateUser(gomock.Any(), shortStackID, topicName, "key", info.ClusterArn, &charlie).DoAndReturn(batched),
+					userManager.EXPECT().AssociateSecrets(ctx, info.ClusterArn, c.expectArns).Return(c.associateErr),
+				)
+				if c.associateErr != nil {
+					for _, u := range []*tt.User{&alice, &bob, &charlie} {
+						calls = append(calls, userManager.EXPECT().DeleteUser(ctx, u, "key", topicName, shortStackID, info.ClusterArn).Return(error(nil)))
+					}
+				}
+			}
+			gomock.InOrder(calls...)
+			if !c.expectErr {
+				userManager.EXPECT().SecretArns(ctx, info.Users, shortStackID).Return(map[string]string{"alice": "secret-alice"}, error(nil))
+				kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: {Topic: topicName}}, error(nil))
+			}
+
+			// Act
+			result, err := cmdCreate.Run(ctx, info, stackID)
+
+			// Assert
+			if c.expectErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, map[string]string{"alice": "secret-alice"}, result.SecretArns)
+				assert.Equal(t, "key", result.KmsKeyArn)
+			}
+			assert.Equal(t, c.expectResults, result.UserResults)
+		})
+	}
+}
+
+func TestCmdCreateCancelled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	// Arrange
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	stackID := "test"
+	shortStackID := shortStackID(stackID)
+	alice := tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}
+	bob := tt.User{Username: "bob", Permissions: []tt.Permission{tt.PermissionWrite}}
+	charlie := tt.User{Username: "charlie", Permissions: []tt.Permission{tt.PermissionRead}}
+	info := &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 1, ClusterArn: "cluster", Users: []tt.User{alice, bob, charlie}}
+	topicName := canonicalTopicName(info.Name, shortStackID)
+
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+	userManager := mocks.NewMockUserManagerService(ctrl)
+	topicMarkers := mocks.NewMockTopicMarkerService(ctrl)
+	cmdCreate := newCmdCreate(kafkaClient, kmsKeyResolver, userManager, topicMarkers, false, testSettings(), logger)
+
+	kmsKeyResolver.EXPECT().Resolve(ctx, info).Return("key", error(nil))
+	kafkaClient.EXPECT().ListBrokers(ctx).Return(kadm.BrokerDetails{{NodeID: 1}}, error(nil))
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: {Topic: topicName, Err: kerr.UnknownTopicOrPartition}}, error(nil))
+	topicMarkers.EXPECT().Put(ctx, info.ClusterArn, topicName, gomock.Any()).Return(error(nil))
+	kafkaClient.EXPECT().CreateTopic(ctx, int32(1), int16(1), info.Config, gomock.Nil(), topicName).Return(kadm.CreateTopicResponse{Topic: topicName}, error(nil))
+	// The invocation is cancelled while alice is created. No other user
+	// is attempted and alice is left to the delete rolling back the
+	// resource.
+	userManager.EXPECT().CreateUser(gomock.Any(), shortStackID, topicName, "key", info.ClusterArn, &alice).DoAndReturn(
+		func(ctx context.Context, s, tn, k, ca string, u *tt.User) error {
+			associationBatchFrom(ctx).Add(u.Username, "secret-"+u.Username)
+			cancel()
+			return nil
+		})
+
+	// Act
+	result, err := cmdCreate.Run(ctx, info, stackID)
+
+	// Assert
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []userResult{
+		{Username: "alice", Status: UserStatusACLsApplied},
+		{Username: "bob", Status: UserStatusFailed, Reason: context.Canceled.Error()},
+		{Username: "charlie", Status: UserStatusSkipped},
+	}, result.UserResults)
+}
+
+func TestCmdCreatePhysicalResourceID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	// Arrange
+	ctx := context.TODO()
+	stackID := "test"
+	topicName := canonicalTopicName("a", shortStackID(stackID))
+	run := func(clusterArn string) *createTopicResult {
+		info := &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 1, ClusterArn: clusterArn}
+		kafkaClient := mocks.NewMockKafkaClient(ctrl)
+		kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+		topicMarkers := mocks.NewMockTopicMarkerService(ctrl)
+		cmdCreate := newCmdCreate(kafkaClient, kmsKeyResolver, mocks.NewMockUserManagerService(ctrl), topicMarkers, false, testSettings(), logger)
+		kmsKeyResolver.EXPECT().Resolve(ctx, info).Return("key", error(nil))
+		kafkaClient.EXPECT().ListBrokers(ctx).Return(kadm.BrokerDetails{{NodeID: 1}}, error(nil))
+		kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: {Topic: topicName, Err: kerr.UnknownTopicOrPartition}}, error(nil))
+		topicMarkers.EXPECT().Put(ctx, clusterArn, topicName, gomock.Any()).Return(error(nil))
+		kafkaClient.EXPECT().CreateTopic(ctx, int32(1), int16(1), info.Config, gomock.Nil(), topicName).Return(kadm.CreateTopicResponse{Topic: topicName}, error(nil))
+		kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{}, error(nil))
+
+		result, err := cmdCreate.Run(ctx, info, stackID)
+		assert.Nil(t, err)
+		return result
+	}
+
+	// Act
+	first := run("arn:aws:kafka:us-east-1:123456789012:cluster/first/1")
+	second := run("arn:aws:kafka:us-east-1:123456789012:cluster/second/2")
+	again := run("arn:aws:kafka:us-east-1:123456789012:cluster/first/1")
+
+	// Assert
+	assert.Equal(t, topicName, first.TopicName)
+	assert.Equal(t, topicName, second.TopicName)
+	assert.NotEqual(t, first.PhysicalResourceID, second.PhysicalResourceID)
+	assert.Equal(t, first.PhysicalResourceID, again.PhysicalResourceID)
+}
+
+func TestCmdCreateTransactionalACLs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	type testCase struct {
+		name          string
+		bobErr        error
+		applyACLsErr  error
+		expectApply   bool
+		expectResults []userResult
+	}
+
+	stackID := "test"
+	shortStackID := shortStackID(stackID)
+	alice := tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}
+	bob := tt.User{Username: "bob", Permissions: []tt.Permission{tt.PermissionWrite}}
+	acl := kadm.NewACLs().Topics("a").Allow("User:alice")
+
+	cases := []testCase{
+		{
+			name:        "ACLs applied",
+			expectApply: true,
+			expectResults: []userResult{
+				{Username: "alice", Status: UserStatusACLsApplied},
+				{Username: "bob", Status: UserStatusACLsApplied},
+			},
+		},
+		{
+			name:         "ACLs rolled back",
+			applyACLsErr: kerr.InvalidRequest,
+			expectApply:  true,
+			expectResults: []userResult{
+				{Username: "alice", Status: UserStatusRolledBack},
+				{Username: "bob", Status: UserStatusRolledBack},
+			},
+		},
+		{
+			name:   "User fails",
+			bobErr: kerr.SecurityDisabled,
+			expectResults: []userResult{
+				{Username: "alice", Status: UserStatusRolledBack},
+				{Username: "bob", Status: UserStatusFailed, Reason: kerr.SecurityDisabled.Error()},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			ctx := context.TODO()
+			info := &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 1, ClusterArn: "cluster", Users: []tt.User{alice, bob}}
+			topicName := canonicalTopicName(info.Name, shortStackID)
+
+			kafkaClient := mocks.NewMockKafkaClient(ctrl)
+			kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+			userManager := mocks.NewMockUserManagerService(ctrl)
+			topicMarkers := mocks.NewMockTopicMarkerService(ctrl)
+			settings := testSettings()
+			settings.TransactionalACLs = true
+			cmdCreate := newCmdCreate(kafkaClient, kmsKeyResolver, userManager, topicMarkers, false, settings, logger)
+
+			kmsKeyResolver.EXPECT().Resolve(ctx, info).Return("key", error(nil))
+			kafkaClient.EXPECT().ListBrokers(ctx).Return(kadm.BrokerDetails{{NodeID: 1}}, error(nil))
+			kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: {Topic: topicName, Err: kerr.UnknownTopicOrPartition}}, error(nil))
+			topicMarkers.EXPECT().Put(ctx, info.ClusterArn, topicName, &tt.TopicMarker{StackID: stackID}).Return(error(nil))
+			kafkaClient.EXPECT().CreateTopic(ctx, int32(1), int16(1), info.Config, gomock.Nil(), topicName).Return(kadm.CreateTopicResponse{Topic: topicName}, error(nil))
+			// ACLs of each user are collected in the transaction carried
+			// by the context rather than being created.
+			collect := func(ctx context.Context, _, _, _, _ string, _ *tt.User) error {
+				aclTransactionFrom(ctx).Add(acl)
+				return nil
+			}
+			gomock.InOrder(
+				userManager.EXPECT().CreateUser(gomock.Any(), shortStackID, topicName, "key", info.ClusterArn, &alice).DoAndReturn(collect),
+				userManager.EXPECT().CreateUser(gomock.Any(), shortStackID, topicName, "key", info.ClusterArn, &bob).DoAndReturn(func(ctx context.Context, s, tn, k, ca string, u *tt.User) error {
+					if c.bobErr != nil {
+						return c.bobErr
+					}
+					return collect(ctx, s, tn, k, ca, u)
+				}),
+			)
+			if c.expectApply {
+				userManager.EXPECT().ApplyACLs(ctx, []*kadm.ACLBuilder{acl, acl}).Return(c.applyACLsErr)
+			}
+			if c.bobErr != nil || c.applyACLsErr != nil {
+				userManager.EXPECT().DeleteUser(ctx, &alice, "key", topicName, shortStackID, info.ClusterArn).Return(error(nil))
+			}
+			if c.applyACLsErr != nil {
+				userManager.EXPECT().DeleteUser(ctx, &bob, "key", topicName, shortStackID, info.ClusterArn).Return(error(nil))
+			}
+			if c.bobErr == nil && c.applyACLsErr == nil {
+				userManager.EXPECT().SecretArns(ctx, info.Users, shortStackID).Return(map[string]string{}, error(nil))
+				kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: {Topic: topicName}}, error(nil))
+			}
+
+			// Act
+			result, err := cmdCreate.Run(ctx, info, stackID)
+
+			// Assert
+			if c.bobErr == nil && c.applyACLsErr == nil {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+			}
+			assert.Equal(t, c.expectResults, result.UserResults)
+		})
+	}
+}
+
+func TestCmdCreateReplicaAssignment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	// Arrange
+	ctx := context.TODO()
+	stackID := "test"
+	assignment := tt.ReplicaAssignment{0: {2, 1}, 1: {1, 3}}
+	info := &tt.TopicInfo{Name: "a", Partitions: 2, ReplicationFactor: 2, ReplicaAssignment: assignment, ClusterArn: "cluster"}
+	topicName := canonicalTopicName(info.Name, shortStackID(stackID))
+	brokers := kadm.BrokerDetails{{NodeID: 1}, {NodeID: 2}, {NodeID: 3}}
+
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+	topicMarkers := mocks.NewMockTopicMarkerService(ctrl)
+	cmdCreate := newCmdCreate(kafkaClient, kmsKeyResolver, mocks.NewMockUserManagerService(ctrl), topicMarkers, false, testSettings(), logger)
+
+	kmsKeyResolver.EXPECT().Resolve(ctx, info).Return("key", error(nil))
+	kafkaClient.EXPECT().ListBrokers(ctx).Return(brokers, error(nil))
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: {Topic: topicName, Err: kerr.UnknownTopicOrPartition}}, error(nil))
+	topicMarkers.EXPECT().Put(ctx, info.ClusterArn, topicName, &tt.TopicMarker{StackID: stackID}).Return(error(nil))
+	kafkaClient.EXPECT().CreateTopic(ctx, int32(2), int16(2), info.Config, map[int32][]int32(assignment), topicName).Return(kadm.CreateTopicResponse{Topic: topicName}, error(nil))
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: {Topic: topicName, Partitions: kadm.PartitionDetails{
+		0: {Partition: 0, Replicas: []int32{2, 1}},
+		1: {Partition: 1, Replicas: []int32{1, 3}},
+	}}}, error(nil))
+
+	// Act
+	result, err := cmdCreate.Run(ctx, info, stackID)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "0:2,1;1:1,3", result.PartitionAssignment)
+
+	// Broker 4 is not in the cluster
+	info = &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 2, ReplicaAssignment: tt.ReplicaAssignment{0: {1, 4}}, ClusterArn: "cluster"}
+	kmsKeyResolver.EXPECT().Resolve(ctx, info).Return("key", error(nil))
+	kafkaClient.EXPECT().ListBrokers(ctx).Return(brokers, error(nil))
+	_, err = cmdCreate.Run(ctx, info, stackID)
+	assert.EqualError(t, err, "ReplicaAssignment.0 references broker 4 that is not in the cluster")
+	assert.Equal(t, "TR003: ReplicaAssignment.0 references broker 4 that is not in the cluster", describeError(err))
+
+	// Serverless clusters place replicas automatically
+	serverless := newCmdCreate(kafkaClient, kmsKeyResolver, newIamUserManager(logger), topicMarkers, true, testSettings(), logger)
+	kmsKeyResolver.EXPECT().Resolve(ctx, info).Return("", error(nil))
+	_, err = serverless.Run(ctx, info, stackID)
+	assert.EqualError(t, err, "ReplicaAssignment is not supported by serverless clusters")
+}
+
+func TestCmdCreateAwaitTopic(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type testCase struct {
+		name    string
+		pending int
+		err     string
+	}
+
+	cases := []testCase{
+		{name: "Listed at once"},
+		{name: "Listed after retries", pending: 2},
+		{name: "Not listed within timeout", pending: 4, err: "topic a-T6DNBAMI not listed with 2 partitions after 3s"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			ctx := context.TODO()
+			stackID := "test"
+			info := &tt.TopicInfo{Name: "a", Partitions: 2, ReplicationFactor: 1, ClusterArn: "cluster"}
+			topicName := canonicalTopicName(info.Name, shortStackID(stackID))
+			missing := kadm.TopicDetails{topicName: {Topic: topicName, Err: kerr.UnknownTopicOrPartition}}
+			partial := kadm.TopicDetails{topicName: {Topic: topicName, Partitions: kadm.PartitionDetails{0: {Partition: 0}}}}
+			ready := kadm.TopicDetails{topicName: {Topic: topicName, Partitions: kadm.PartitionDetails{0: {Partition: 0}, 1: {Partition: 1}}}}
+
+			kafkaClient := mocks.NewMockKafkaClient(ctrl)
+			kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+			topicMarkers := mocks.NewMockTopicMarkerService(ctrl)
+			settings := testSettings()
+			settings.TopicReadyTimeout = 3 * time.Second
+			cmdCreate := newCmdCreate(kafkaClient, kmsKeyResolver, mocks.NewMockUserManagerService(ctrl), topicMarkers, false, settings, zap.NewNop())
+			var slept time.Duration
+			cmdCreate.sleep = func(d time.Duration) { slept += d }
+
+			kmsKeyResolver.EXPECT().Resolve(ctx, info).Return("key", error(nil))
+			kafkaClient.EXPECT().ListBrokers(ctx).Return(kadm.BrokerDetails{{NodeID: 1}}, error(nil))
+			calls := []*gomock.Call{
+				kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(missing, error(nil)),
+				topicMarkers.EXPECT().Put(ctx, info.ClusterArn, topicName, &tt.TopicMarker{StackID: stackID}).Return(error(nil)),
+				kafkaClient.EXPECT().CreateTopic(ctx, int32(2), int16(1), info.Config, nil, topicName).Return(kadm.CreateTopicResponse{Topic: topicName}, error(nil)),
+			}
+			for i := 0; i < c.pending; i++ {
+				pending := missing
+				if i%2 == 1 {
+					pending = partial
+				}
+				calls = append(calls, kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(pending, error(nil)))
+			}
+			if c.err == "" {
+				// The second call describes the partition assignment.
+				calls = append(calls, kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(ready, error(nil)).Times(2))
+			}
+			gomock.InOrder(calls...)
+
+			// Act
+			_, err := cmdCreate.Run(ctx, info, stackID)
+
+			// Assert
+			if c.err == "" {
+				assert.Nil(t, err)
+				assert.Equal(t, time.Duration(c.pending)*topicReadyInterval, slept)
+			} else {
+				assert.EqualError(t, err, c.err)
+			}
+		})
+	}
+}
+
+func TestCmdCreateRackAware(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	// Arrange
+	ctx := context.TODO()
+	stackID := "test"
+	info := &tt.TopicInfo{Name: "a", Partitions: 2, ReplicationFactor: 2, RackAware: true, ClusterArn: "cluster"}
+	topicName := canonicalTopicName(info.Name, shortStackID(stackID))
+	brokers := kadm.BrokerDetails{{NodeID: 1, Rack: aws.String("use1-az1")}, {NodeID: 2, Rack: aws.String("use1-az2")}, {NodeID: 3, Rack: aws.String("use1-az1")}}
+
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+	topicMarkers := mocks.NewMockTopicMarkerService(ctrl)
+	cmdCreate := newCmdCreate(kafkaClient, kmsKeyResolver, mocks.NewMockUserManagerService(ctrl), topicMarkers, false, testSettings(), logger)
+
+	kmsKeyResolver.EXPECT().Resolve(ctx, info).Return("key", error(nil))
+	kafkaClient.EXPECT().ListBrokers(ctx).Return(brokers, error(nil))
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: {Topic: topicName, Err: kerr.UnknownTopicOrPartition}}, error(nil))
+	topicMarkers.EXPECT().Put(ctx, info.ClusterArn, topicName, &tt.TopicMarker{StackID: stackID}).Return(error(nil))
+	kafkaClient.EXPECT().CreateTopic(ctx, int32(2), int16(2), info.Config, map[int32][]int32{0: {1, 2}, 1: {2, 3}}, topicName).Return(kadm.CreateTopicResponse{Topic: topicName}, error(nil))
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{}, error(nil))
+
+	// Act
+	_, err = cmdCreate.Run(ctx, info, stackID)
+
+	// Assert
+	assert.Nil(t, err)
+
+	// Two racks cannot hold three replicas
+	info = &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 3, RackAware: true, ClusterArn: "cluster"}
+	kmsKeyResolver.EXPECT().Resolve(ctx, info).Return("key", error(nil))
+	kafkaClient.EXPECT().ListBrokers(ctx).Return(brokers, error(nil))
+	_, err = cmdCreate.Run(ctx, info, stackID)
+	assert.Equal(t, "TR003: RackAware requires ReplicationFactor 3 distinct racks but the cluster has 2", describeError(err))
+
+	// Serverless clusters place replicas automatically
+	serverless := newCmdCreate(kafkaClient, kmsKeyResolver, newIamUserManager(logger), topicMarkers, true, testSettings(), logger)
+	kmsKeyResolver.EXPECT().Resolve(ctx, info).Return("", error(nil))
+	_, err = serverless.Run(ctx, info, stackID)
+	assert.EqualError(t, err, "RackAware is not supported by serverless clusters")
+}
+
+func TestRackAwareAssignment(t *testing.T) {
+	type testCase struct {
+		brokers           kadm.BrokerDetails
+		partitions        int
+		replicationFactor int
+		expected          tt.ReplicaAssignment
+		err               string
+	}
+
+	broker := func(id int32, rack string) kadm.BrokerDetail {
+		return kadm.BrokerDetail{NodeID: id, Rack: aws.String(rack)}
+	}
+
+	cases := map[string]testCase{
+		"Evenly spread racks": {
+			brokers:           kadm.BrokerDetails{broker(1, "a"), broker(2, "b"), broker(3, "c"), broker(4, "a"), broker(5, "b"), broker(6, "c")},
+			partitions:        3,
+			replicationFactor: 3,
+			expected:          tt.ReplicaAssignment{0: {1, 2, 3}, 1: {2, 3, 4}, 2: {3, 4, 5}},
+		},
+		"Uneven racks": {
+			brokers:           kadm.BrokerDetails{broker(3, "a"), broker(2, "b"), broker(1, "a")},
+			partitions:        3,
+			replicationFactor: 2,
+			expected:          tt.ReplicaAssignment{0: {1, 2}, 1: {2, 3}, 2: {3, 2}},
+		},
+		"Not enough racks": {
+			brokers:           kadm.BrokerDetails{broker(1, "a"), broker(2, "a"), broker(3, "b")},
+			partitions:        1,
+			replicationFactor: 3,
+			err:               "TR003: RackAware requires ReplicationFactor 3 distinct racks but the cluster has 2",
+		},
+		"Broker without rack": {
+			brokers:           kadm.BrokerDetails{broker(1, "a"), {NodeID: 2}},
+			partitions:        1,
+			replicationFactor: 2,
+			err:               "TR003: RackAware requires every broker to have a rack but broker 2 has none",
+		},
+	}
+
+	for k, c := range cases {
+		assignment, err := rackAwareAssignment(c.brokers, c.partitions, c.replicationFactor)
+		if c.err != "" {
+			assert.Equal(t, c.err, describeError(err), k)
+		} else {
+			assert.Nil(t, err, k)
+			assert.Equal(t, c.expected, assignment, k)
+		}
+	}
+}
+
+func TestCmdCreateEnforcedConfig(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	// Arrange
+	ctx := context.TODO()
+	stackID := "test"
+	info := &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 1, ClusterArn: "cluster", Config: map[string]*string{
+		"retention.ms":        aws.String("1000"),
+		"min.insync.replicas": aws.String("1"),
+	}}
+	topicName := canonicalTopicName(info.Name, shortStackID(stackID))
+	settings := testSettings()
+	settings.EnforcedTopicConfig = map[string]string{"min.insync.replicas": "2", "unclean.leader.election.enable": "false"}
+
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+	topicMarkers := mocks.NewMockTopicMarkerService(ctrl)
+	cmdCreate := newCmdCreate(kafkaClient, kmsKeyResolver, mocks.NewMockUserManagerService(ctrl), topicMarkers, false, settings, logger)
+
+	kmsKeyResolver.EXPECT().Resolve(ctx, info).Return("", error(nil))
+	kafkaClient.EXPECT().ListBrokers(ctx).Return(kadm.BrokerDetails{{NodeID: 1}}, error(nil))
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: {Topic: topicName, Err: kerr.UnknownTopicOrPartition}}, error(nil))
+	topicMarkers.EXPECT().Put(ctx, info.ClusterArn, topicName, &tt.TopicMarker{StackID: stackID}).Return(error(nil))
+	kafkaClient.EXPECT().CreateTopic(ctx, int32(1), int16(1), map[string]*string{
+		"retention.ms":                   aws.String("1000"),
+		"min.insync.replicas":            aws.String("2"),
+		"unclean.leader.election.enable": aws.String("false"),
+	}, gomock.Nil(), topicName).Return(kadm.CreateTopicResponse{Topic: topicName}, error(nil))
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).
+		Return(kadm.TopicDetails{topicName: {Topic: topicName, Partitions: kadm.PartitionDetails{0: {Partition: 0, Replicas: []int32{1}}}}}, error(nil))
+
+	// Act
+	_, err = cmdCreate.Run(ctx, info, stackID)
+
+	// Assert
+	assert.Nil(t, err)
+}
+
+func TestCmdCreateStrictExistence(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	stackID := "test"
+	topicName := canonicalTopicName("a", shortStackID(stackID))
+	cases := map[string]struct {
+		strict      bool
+		topicExists bool
+		marker      *tt.TopicMarker
+		createErr   error
+		err         string
+	}{
+		"Lenient adopts unmanaged topic": {
+			topicExists: true,
+		},
+		"Strict rejects unmanaged topic": {
+			strict:      true,
+			topicExists: true,
+			err:         "topic " + topicName + " already exists and was not created by this request. Remove it or disable StrictExistence to adopt it.",
+		},
+		"Lenient adopts topic of previous request": {
+			topicExists: true,
+			marker:      &tt.TopicMarker{StackID: stackID, RequestID: "previous"},
+		},
+		"Strict rejects topic of previous request": {
+			strict:      true,
+			topicExists: true,
+			marker:      &tt.TopicMarker{StackID: stackID, RequestID: "previous"},
+			err:         "topic " + topicName + " already exists and was not created by this request. Remove it or disable StrictExistence to adopt it.",
+		},
+		"Strict adopts topic on retry": {
+			strict:      true,
+			topicExists: true,
+			marker:      &tt.TopicMarker{StackID: stackID, RequestID: "request"},
+		},
+		"Lenient adopts topic created concurrently": {
+			createErr: kerr.TopicAlreadyExists,
+		},
+		"Strict rejects topic created concurrently": {
+			strict:    true,
+			createErr: kerr.TopicAlreadyExists,
+			err:       "topic " + topicName + " already exists and was not created by this request. Remove it or disable StrictExistence to adopt it.",
+		},
+	}
+
+	for k, c := range cases {
+		// Arrange
+		ctx := withExistencePolicy(context.TODO(), newExistencePolicy(c.strict, "request"))
+		info := &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 1, ClusterArn: "cluster", StrictExistence: c.strict}
+		kafkaClient := mocks.NewMockKafkaClient(ctrl)
+		kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+		topicMarkers := mocks.NewMockTopicMarkerService(ctrl)
+		cmdCreate := newCmdCreate(kafkaClient, kmsKeyResolver, mocks.NewMockUserManagerService(ctrl), topicMarkers, false, testSettings(), logger)
+
+		kmsKeyResolver.EXPECT().Resolve(ctx, info).Return("", error(nil))
+		kafkaClient.EXPECT().ListBrokers(ctx).Return(kadm.BrokerDetails{{NodeID: 1}}, error(nil))
+		topicDetail := kadm.TopicDetail{Topic: topicName, Err: kerr.UnknownTopicOrPartition}
+		if c.topicExists {
+			topicDetail.Err = nil
+			topicMarkers.EXPECT().Get(ctx, "cluster", topicName).Return(c.marker, error(nil))
+		}
+		kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: topicDetail}, error(nil))
+		if c.err == "" || c.createErr != nil {
+			topicMarkers.EXPECT().Put(ctx, "cluster", topicName, &tt.TopicMarker{StackID: stackID, RequestID: "request"}).Return(error(nil))
+			kafkaClient.EXPECT().CreateTopic(ctx, int32(1), int16(1), info.Config, gomock.Nil(), topicName).Return(kadm.CreateTopicResponse{Topic: topicName}, c.createErr)
+		}
+		if c.err == "" {
+			kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: {Topic: topicName}}, error(nil))
+		}
+
+		// Act
+		_, err := cmdCreate.Run(ctx, info, stackID)
+
+		// Assert
+		if c.err != "" {
+			assert.EqualError(t, err, c.err, k)
+			assert.Equal(t, "TR010: "+c.err, describeError(err), k)
+		} else {
+			assert.Nil(t, err, k)
+		}
+	}
+}
+
+func TestPartitionAssignment(t *testing.T) {
+	partitions := kadm.PartitionDetails{
+		10: {Partition: 10, Replicas: []int32{3}},
+		2:  {Partition: 2, Replicas: []int32{1, 2}},
+		0:  {Partition: 0, Replicas: []int32{2, 1}},
+	}
+	assert.Equal(t, "0:2,1;2:1,2;10:3", partitionAssignment(partitions))
+	assert.Equal(t, "", partitionAssignment(kadm.PartitionDetails{}))
+}
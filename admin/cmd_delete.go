@@ -5,6 +5,7 @@ package admin
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/aws-samples/amazon-msk-topic-resource/types"
 
@@ -17,37 +18,105 @@ type cmdDelete struct {
 	kmsKeyResolver KmsKeyResolverService
 	userManager    UserManagerService
 	kafkaClient    KafkaClient
+	topicMarkers   TopicMarkerService
+	snapshots      *topicSnapshotter
 	logger         *zap.Logger
 }
 
-func newCmdDelete(kmsKeyResolver KmsKeyResolverService, userManager UserManagerService, kafkaClient KafkaClient, logger *zap.Logger) *cmdDelete {
+func newCmdDelete(kmsKeyResolver KmsKeyResolverService, userManager UserManagerService, kafkaClient KafkaClient, topicMarkers TopicMarkerService, snapshots *topicSnapshotter, logger *zap.Logger) *cmdDelete {
 	return &cmdDelete{
 		kmsKeyResolver: kmsKeyResolver,
 		userManager:    userManager,
 		kafkaClient:    kafkaClient,
+		topicMarkers:   topicMarkers,
+		snapshots:      snapshots,
 		logger:         logger,
 	}
 }
 
 func (a *cmdDelete) Run(ctx context.Context, info *types.TopicInfo, stackID string) error {
-	kmsKeyID, err := a.kmsKeyResolver.Resolve(ctx, info)
+	shortStackID := nameSuffix(info, stackID)
+	resourceID := canonicalTopicName(info.Name, shortStackID)
+
+	// A stack that failed while it was created may not have created all
+	// resources. Only clean up the resources that exist.
+	topics, err := a.kafkaClient.ListTopics(ctx, resourceID)
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	shortStackID := shortStackID(stackID)
-	resourceID := canonicalTopicName(info.Name, shortStackID)
-	if info.Users != nil {
-		for _, u := range info.Users {
-			err := a.userManager.DeleteUser(ctx, &u, kmsKeyID, resourceID, shortStackID, info.ClusterArn)
-			if err != nil {
-				return errors.WithStack(err)
-			}
+	topic, topicExists := topics[resourceID]
+	if topicExists && topic.Err != nil {
+		if !errors.Is(topic.Err, kerr.UnknownTopicOrPartition) {
+			return errors.WithStack(topic.Err)
+		}
+		topicExists = false
+	}
+	marker, err := a.topicMarkers.Get(ctx, info.ClusterArn, resourceID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if marker != nil && marker.StackID != stackID {
+		// The create of this resource failed on a name collision with the
+		// topic of another stack, whose users, ACLs and topic are left
+		// intact so that CloudFormation can complete the rollback.
+		a.logger.Sugar().Infow("Skip Operation", "Name", "DeleteTopics", "TopicName", resourceID, "Reason", "Topic managed by another stack", "StackID", marker.StackID)
+		opSummaryFrom(ctx).Skipped("DeleteUser")
+		opSummaryFrom(ctx).Skipped("DeleteTopic")
+		return nil
+	}
+	// Check protection before removing any users so that a blocked delete
+	// leaves the resource intact.
+	retain := info.DeletionPolicy == types.DeletionPolicyRetain
+	if !retain && topicExists && info.DeleteProtection && info.ConfirmDelete != info.Name {
+		retain, err = a.retainProtectedTopic(ctx, info, marker, resourceID)
+		if err != nil {
+			return err
+		}
+	}
+	// Export the snapshot before removing any users so that a failed export
+	// leaves the resource intact.
+	if info.DeletionPolicy == types.DeletionPolicySnapshot && topicExists && !retain {
+		_, err := a.snapshots.Export(ctx, info.ClusterArn, stackID, topic)
+		if err != nil {
+			return err
+		}
+	}
+	users, err := a.userManager.ProvisionedUsers(ctx, info.Users, shortStackID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if skipped := len(info.Users) - len(users); skipped > 0 {
+		a.logger.Sugar().Infow("Skip Operation", "Name", "DeleteUser", "Count", skipped, "Reason", "Secret not found")
+		opSummaryFrom(ctx).Skipped("DeleteUser")
+	}
+	if len(users) > 0 {
+		linkedTopic, err := a.linkedTopic(ctx, info.ClusterArn, marker)
+		if err != nil {
+			return err
+		}
+		if linkedTopic != "" {
+			err = a.revokeTopicACLs(ctx, users, resourceID, shortStackID, linkedTopic)
+		} else {
+			err = a.deleteUsers(ctx, info, users, resourceID, shortStackID)
+		}
+		if err != nil {
+			return err
 		}
 	}
 
 	if info.DeletionPolicy == types.DeletionPolicyRetain {
 		a.logger.Sugar().Infow("Topic data not deleted due to deletion policy", "TopicName", resourceID)
-		return nil
+		return a.deleteMarker(ctx, info, resourceID)
+	}
+	if retain {
+		a.logger.Sugar().Infow("Skip Operation", "Name", "DeleteTopics", "TopicName", resourceID, "Reason", "Topic protected by DeleteProtection")
+		opSummaryFrom(ctx).Skipped("DeleteTopic")
+		return a.deleteMarker(ctx, info, resourceID)
+	}
+	if !topicExists {
+		a.logger.Sugar().Infow("Skip Operation", "Name", "DeleteTopics", "TopicName", resourceID, "Reason", "Topic not found")
+		opSummaryFrom(ctx).Skipped("DeleteTopic")
+		return a.deleteMarker(ctx, info, resourceID)
 	}
 
 	a.logger.Sugar().Infow("Start Operation", "Name", "DeleteTopics", "TopicName", resourceID)
@@ -56,13 +125,95 @@ func (a *cmdDelete) Run(ctx context.Context, info *types.TopicInfo, stackID stri
 		return errors.WithStack(err)
 	}
 	a.logger.Sugar().Infow("DeleteTopics response", "Length", len(responses))
-	if res, ok := responses[resourceID]; ok {
-		if res.Err != nil {
-			if !errors.Is(res.Err, kerr.UnknownTopicOrPartition) {
-				return errors.WithStack(res.Err)
-			}
-			a.logger.Sugar().Infow("Retry Handled", "Operation", "DeleteTopics")
+	if res, ok := responses[resourceID]; ok && res.Err != nil {
+		if !errors.Is(res.Err, kerr.UnknownTopicOrPartition) {
+			return errors.WithStack(res.Err)
 		}
+		a.logger.Sugar().Infow("Retry Handled", "Operation", "DeleteTopics")
+		opSummaryFrom(ctx).Skipped("DeleteTopic")
+	} else {
+		opSummaryFrom(ctx).Performed("DeleteTopic")
+	}
+	return a.deleteMarker(ctx, info, resourceID)
+}
+
+func (a *cmdDelete) deleteUsers(ctx context.Context, info *types.TopicInfo, users []types.User, resourceID, shortStackID string) error {
+	kmsKeyID, err := a.kmsKeyResolver.Resolve(ctx, info)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, u := range users {
+		if err := ctx.Err(); err != nil {
+			return errors.WithStack(err)
+		}
+		err := a.userManager.DeleteUser(ctx, &u, kmsKeyID, resourceID, shortStackID, info.ClusterArn)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// Users of a topic replaced on Name change are shared with the linked
+// topic. Only their access to the deleted topic is revoked. Prefixed ACLs
+// also grant access to the linked topic, therefore they are kept.
+func (a *cmdDelete) revokeTopicACLs(ctx context.Context, users []types.User, resourceID, shortStackID, linkedTopic string) error {
+	a.logger.Sugar().Infow("Skip Operation", "Name", "DeleteUser", "Count", len(users), "Reason", "Users shared with linked topic", "LinkedTopic", linkedTopic)
+	opSummaryFrom(ctx).Skipped("DeleteUser")
+	for _, u := range users {
+		if err := ctx.Err(); err != nil {
+			return errors.WithStack(err)
+		}
+		if u.TopicPrefix != "" {
+			continue
+		}
+		err := a.userManager.RevokeTopicACLs(ctx, resourceID, &u, shortStackID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// Decides what happens to an existing topic of this stack protected by
+// DeleteProtection without confirmation. Its data is protected by failing
+// the delete unless the topic was replaced on Name change, in which case it
+// is retained instead so that CloudFormation can complete the replacement.
+func (a *cmdDelete) retainProtectedTopic(ctx context.Context, info *types.TopicInfo, marker *types.TopicMarker, topic string) (bool, error) {
+	linkedTopic, err := a.linkedTopic(ctx, info.ClusterArn, marker)
+	if err != nil {
+		return false, err
+	}
+	if linkedTopic != "" {
+		a.logger.Sugar().Warnw("Topic data not deleted due to DeleteProtection", "TopicName", topic, "Reason", "Topic replaced", "LinkedTopic", linkedTopic)
+		return true, nil
+	}
+	a.logger.Sugar().Warnw("Topic delete blocked by DeleteProtection. Set ConfirmDelete property to the topic name, update the stack and retry the delete.", "TopicName", info.Name)
+	return false, errors.WithStack(fmt.Errorf("topic %s is protected from deletion: set ConfirmDelete to %s to delete its data", info.Name, info.Name))
+}
+
+// Returns the topic linked to marker when it is still managed by TR, or an
+// empty string otherwise. See types.TopicMarker.
+func (a *cmdDelete) linkedTopic(ctx context.Context, clusterArn string, marker *types.TopicMarker) (string, error) {
+	if marker == nil || marker.LinkedTopic == "" {
+		return "", nil
+	}
+	linked, err := a.topicMarkers.Get(ctx, clusterArn, marker.LinkedTopic)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if linked == nil {
+		return "", nil
+	}
+	return marker.LinkedTopic, nil
+}
+
+// Once the stack is deleted, the topic is no longer managed by TR
+// regardless of whether its data is retained.
+func (a *cmdDelete) deleteMarker(ctx context.Context, info *types.TopicInfo, topicName string) error {
+	err := a.topicMarkers.Delete(ctx, info.ClusterArn, topicName)
+	if err != nil {
+		return errors.WithStack(err)
 	}
 	return nil
 }
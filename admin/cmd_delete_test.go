@@ -0,0 +1,263 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	tt "github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/admin/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"go.uber.org/zap"
+)
+
+func TestCmdDelete(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+	type testCase struct {
+		name              string
+		info              *tt.TopicInfo
+		topicMissing      bool
+		provisionedUsers  []tt.User
+		linkedTopic       string
+		linkedManaged     bool
+		markerStackID     string
+		expectDeleteTopic bool
+		err               string
+	}
+
+	stackID := "test"
+	shortStackID := shortStackID(stackID)
+
+	cases := []testCase{
+		{
+			name:              "Delete",
+			info:              &tt.TopicInfo{Name: "a", ClusterArn: "cluster", DeletionPolicy: tt.DeletionPolicyDelete},
+			expectDeleteTopic: true,
+		},
+		{
+			name: "Retain",
+			info: &tt.TopicInfo{Name: "a", ClusterArn: "cluster", DeletionPolicy: tt.DeletionPolicyRetain, DeleteProtection: true},
+		},
+		{
+			name: "Delete protection without confirmation",
+			info: &tt.TopicInfo{Name: "a", ClusterArn: "cluster", DeletionPolicy: tt.DeletionPolicyDelete, DeleteProtection: true, Users: []tt.User{{Username: "alice"}}},
+			err:  "topic a is protected from deletion: set ConfirmDelete to a to delete its data",
+		},
+		{
+			name: "Delete protection with mismatched confirmation",
+			info: &tt.TopicInfo{Name: "a", ClusterArn: "cluster", DeletionPolicy: tt.DeletionPolicyDelete, DeleteProtection: true, ConfirmDelete: "b"},
+			err:  "topic a is protected from deletion: set ConfirmDelete to a to delete its data",
+		},
+		{
+			name: "Snapshot protection without confirmation",
+			info: &tt.TopicInfo{Name: "a", ClusterArn: "cluster", DeletionPolicy: tt.DeletionPolicySnapshot, DeleteProtection: true},
+			err:  "topic a is protected from deletion: set ConfirmDelete to a to delete its data",
+		},
+		{
+			name:         "Delete protection of missing topic",
+			info:         &tt.TopicInfo{Name: "a", ClusterArn: "cluster", DeletionPolicy: tt.DeletionPolicyDelete, DeleteProtection: true, Users: []tt.User{{Username: "alice"}}},
+			topicMissing: true,
+		},
+		{
+			name:             "Delete protection of replaced topic",
+			info:             &tt.TopicInfo{Name: "a", ClusterArn: "cluster", DeletionPolicy: tt.DeletionPolicyDelete, DeleteProtection: true, Users: []tt.User{{Username: "alice"}}},
+			provisionedUsers: []tt.User{{Username: "alice"}},
+			linkedTopic:      "b-T6DNBAMI",
+			linkedManaged:    true,
+		},
+		{
+			name:          "Delete protection of topic managed by another stack",
+			info:          &tt.TopicInfo{Name: "a", ClusterArn: "cluster", DeletionPolicy: tt.DeletionPolicyDelete, DeleteProtection: true},
+			markerStackID: "other",
+		},
+		{
+			name:          "Rollback of create colliding with topic of another stack",
+			info:          &tt.TopicInfo{Name: "a", ClusterArn: "cluster", DeletionPolicy: tt.DeletionPolicyDelete, Users: []tt.User{{Username: "alice"}}},
+			markerStackID: "other",
+		},
+		{
+			name:              "Delete protection with confirmation",
+			info:              &tt.TopicInfo{Name: "a", ClusterArn: "cluster", DeletionPolicy: tt.DeletionPolicyDelete, DeleteProtection: true, ConfirmDelete: "a"},
+			expectDeleteTopic: true,
+		},
+		{
+			name:              "Delete users",
+			info:              &tt.TopicInfo{Name: "a", ClusterArn: "cluster", DeletionPolicy: tt.DeletionPolicyDelete, Users: []tt.User{{Username: "alice"}, {Username: "bob"}}},
+			provisionedUsers:  []tt.User{{Username: "alice"}, {Username: "bob"}},
+			expectDeleteTopic: true,
+		},
+		{
+			name:              "Replaced topic keeps users of linked topic",
+			info:              &tt.TopicInfo{Name: "a", ClusterArn: "cluster", DeletionPolicy: tt.DeletionPolicyDelete, Users: []tt.User{{Username: "alice"}, {Username: "bob", TopicPrefix: "a"}}},
+			provisionedUsers:  []tt.User{{Username: "alice"}, {Username: "bob", TopicPrefix: "a"}},
+			linkedTopic:       "b-T6DNBAMI",
+			linkedManaged:     true,
+			expectDeleteTopic: true,
+		},
+		{
+			name:              "Replaced topic deletes users once linked topic is deleted",
+			info:              &tt.TopicInfo{Name: "a", ClusterArn: "cluster", DeletionPolicy: tt.DeletionPolicyDelete, Users: []tt.User{{Username: "alice"}}},
+			provisionedUsers:  []tt.User{{Username: "alice"}},
+			linkedTopic:       "b-T6DNBAMI",
+			expectDeleteTopic: true,
+		},
+		{
+			name:             "Partially created",
+			info:             &tt.TopicInfo{Name: "a", ClusterArn: "cluster", DeletionPolicy: tt.DeletionPolicyDelete, Users: []tt.User{{Username: "alice"}, {Username: "bob"}}},
+			topicMissing:     true,
+			provisionedUsers: []tt.User{{Username: "alice"}},
+		},
+		{
+			name:         "Nothing created",
+			info:         &tt.TopicInfo{Name: "a", ClusterArn: "cluster", DeletionPolicy: tt.DeletionPolicyDelete, Users: []tt.User{{Username: "alice"}}},
+			topicMissing: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			ctx := context.TODO()
+			topicName := canonicalTopicName(c.info.Name, shortStackID)
+
+			kafkaClient := mocks.NewMockKafkaClient(ctrl)
+			kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+			userManager := mocks.NewMockUserManagerService(ctrl)
+			topicMarkers := mocks.NewMockTopicMarkerService(ctrl)
+
+			cmdDelete := newCmdDelete(kmsKeyResolver, userManager, kafkaClient, topicMarkers, nil, logger)
+
+			topic := kadm.TopicDetail{Topic: topicName}
+			if c.topicMissing {
+				topic.Err = kerr.UnknownTopicOrPartition
+			}
+			kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: topic}, error(nil))
+			markerStackID := stackID
+			if c.markerStackID != "" {
+				markerStackID = c.markerStackID
+			}
+			topicMarkers.EXPECT().Get(ctx, c.info.ClusterArn, topicName).Return(&tt.TopicMarker{StackID: markerStackID, LinkedTopic: c.linkedTopic}, error(nil))
+			// The linked topic is looked up by the protection check and for
+			// the users.
+			gets := 0
+			if c.info.DeleteProtection && c.info.ConfirmDelete != c.info.Name && !c.topicMissing && c.info.DeletionPolicy != tt.DeletionPolicyRetain {
+				gets++
+			}
+			if c.err == "" && len(c.provisionedUsers) > 0 {
+				gets++
+			}
+			if c.linkedTopic != "" {
+				var linked *tt.TopicMarker
+				if c.linkedManaged {
+					linked = &tt.TopicMarker{StackID: stackID, LinkedTopic: topicName}
+				}
+				topicMarkers.EXPECT().Get(ctx, c.info.ClusterArn, c.linkedTopic).Return(linked, error(nil)).Times(gets)
+			}
+			if c.err == "" && c.markerStackID == "" {
+				userManager.EXPECT().ProvisionedUsers(ctx, c.info.Users, shortStackID).Return(c.provisionedUsers, error(nil))
+				if c.linkedManaged {
+					for i := range c.provisionedUsers {
+						if c.provisionedUsers[i].TopicPrefix == "" {
+							userManager.EXPECT().RevokeTopicACLs(ctx, topicName, &c.provisionedUsers[i], shortStackID).Return(error(nil))
+						}
+					}
+				} else {
+					if len(c.provisionedUsers) > 0 {
+						kmsKeyResolver.EXPECT().Resolve(ctx, c.info).Return("key", error(nil))
+					}
+					for i := range c.provisionedUsers {
+						userManager.EXPECT().DeleteUser(ctx, &c.provisionedUsers[i], "key", topicName, shortStackID, c.info.ClusterArn).Return(error(nil))
+					}
+				}
+				topicMarkers.EXPECT().Delete(ctx, c.info.ClusterArn, topicName).Return(error(nil))
+			}
+			if c.expectDeleteTopic {
+				kafkaClient.EXPECT().DeleteTopics(ctx, topicName).Return(kadm.DeleteTopicResponses{topicName: {Topic: topicName}}, error(nil))
+			}
+
+			// Act
+			err := cmdDelete.Run(ctx, c.info, stackID)
+
+			// Assert
+			if c.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, c.err)
+			}
+		})
+	}
+}
+
+func TestCmdDeleteSnapshot(t *testing.T) {
+	type testCase struct {
+		putErr            error
+		expectDeleteTopic bool
+		err               string
+	}
+
+	stackID := "test"
+	shortStackID := shortStackID(stackID)
+
+	cases := map[string]testCase{
+		"Snapshot exported":       {expectDeleteTopic: true},
+		"Snapshot export aborted": {putErr: errors.New("denied"), err: "denied"},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			// Arrange
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			ctx := context.TODO()
+			info := &tt.TopicInfo{Name: "a", ClusterArn: "cluster", DeletionPolicy: tt.DeletionPolicySnapshot, Users: []tt.User{{Username: "alice"}}}
+			topicName := canonicalTopicName(info.Name, shortStackID)
+
+			kafkaClient := mocks.NewMockKafkaClient(ctrl)
+			kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+			userManager := mocks.NewMockUserManagerService(ctrl)
+			topicMarkers := mocks.NewMockTopicMarkerService(ctrl)
+			s3Client := mocks.NewMockS3Client(ctrl)
+			snapshots := newTopicSnapshotter(kafkaClient, s3Client, "bucket", "", zap.NewNop())
+
+			cmdDelete := newCmdDelete(kmsKeyResolver, userManager, kafkaClient, topicMarkers, snapshots, zap.NewNop())
+
+			kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: {Topic: topicName}}, error(nil))
+			topicMarkers.EXPECT().Get(ctx, info.ClusterArn, topicName).Return(&tt.TopicMarker{StackID: stackID}, error(nil))
+			kafkaClient.EXPECT().DescribeTopicConfigs(ctx, topicName).Return(kadm.ResourceConfigs{{Name: topicName}}, error(nil))
+			kafkaClient.EXPECT().ListStartOffsets(ctx, topicName).Return(kadm.ListedOffsets{}, error(nil))
+			kafkaClient.EXPECT().ListEndOffsets(ctx, topicName).Return(kadm.ListedOffsets{}, error(nil))
+			kafkaClient.EXPECT().ListGroups(ctx).Return(kadm.ListedGroups{}, error(nil))
+			s3Client.EXPECT().PutObject(ctx, "bucket", gomock.Any(), gomock.Any()).Return(c.putErr)
+			if c.expectDeleteTopic {
+				userManager.EXPECT().ProvisionedUsers(ctx, info.Users, shortStackID).Return(info.Users, error(nil))
+				kmsKeyResolver.EXPECT().Resolve(ctx, info).Return("key", error(nil))
+				userManager.EXPECT().DeleteUser(ctx, &info.Users[0], "key", topicName, shortStackID, info.ClusterArn).Return(error(nil))
+				kafkaClient.EXPECT().DeleteTopics(ctx, topicName).Return(kadm.DeleteTopicResponses{topicName: {Topic: topicName}}, error(nil))
+				topicMarkers.EXPECT().Delete(ctx, info.ClusterArn, topicName).Return(error(nil))
+			}
+
+			// Act
+			err := cmdDelete.Run(ctx, info, stackID)
+
+			// Assert
+			if c.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, c.err)
+			}
+		})
+	}
+}
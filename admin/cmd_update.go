@@ -5,12 +5,18 @@ package admin
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/aws-samples/amazon-msk-topic-resource/types"
 
 	"github.com/pkg/errors"
 	"github.com/twmb/franz-go/pkg/kadm"
 	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
 	"go.uber.org/zap"
 )
 
@@ -18,74 +24,221 @@ type cmdUpdate struct {
 	kmsKeyResolver KmsKeyResolverService
 	userManager    UserManagerService
 	kafkaClient    KafkaClient
-	fixedDelay     func()
-	logger         *zap.Logger
+	topicMarkers   TopicMarkerService
+	guardrails     *guardrails
+	secretNames    secretNameTemplate
+	serverless     bool
+	// Apply ACLs of added users and permissions as a unit.
+	transactionalACLs bool
+	userDeleteDelay   func()
+	// Passed to the command creating the topic on replacement.
+	settings *Settings
+	logger   *zap.Logger
 }
 
-func newCmdUpdate(kmsKeyResolver KmsKeyResolverService, userManager UserManagerService, kafkaClient KafkaClient, fixedDelay func(), logger *zap.Logger) *cmdUpdate {
+func newCmdUpdate(kmsKeyResolver KmsKeyResolverService, userManager UserManagerService, kafkaClient KafkaClient, topicMarkers TopicMarkerService, serverless bool, settings *Settings, logger *zap.Logger) *cmdUpdate {
 	return &cmdUpdate{
-		kmsKeyResolver: kmsKeyResolver,
-		userManager:    userManager,
-		kafkaClient:    kafkaClient,
-		fixedDelay:     fixedDelay,
-		logger:         logger,
+		kmsKeyResolver:    kmsKeyResolver,
+		userManager:       userManager,
+		kafkaClient:       kafkaClient,
+		topicMarkers:      topicMarkers,
+		guardrails:        newGuardrails(settings),
+		secretNames:       secretNameTemplate(settings.SecretNameTemplate),
+		serverless:        serverless,
+		transactionalACLs: settings.TransactionalACLs,
+		userDeleteDelay: func() {
+			time.Sleep(settings.UserDeleteDelay)
+		},
+		settings: settings,
+		logger:   logger,
 	}
 }
 
-func (a *cmdUpdate) Run(ctx context.Context, old, new *types.TopicInfo, stackID string) error {
-	shortStackID := shortStackID(stackID)
+type updateTopicResult struct {
+	// Name of the topic including the suffix.
+	TopicName string
+	// Set when the topic was replaced on Name change, in which case
+	// CloudFormation deletes the resource of the old topic.
+	PhysicalResourceID string
+	Plan               *changePlan
+	// Set when old and new properties are identical and the update
+	// was skipped.
+	NoChanges bool
+	// Replica assignment of the topic, see createTopicResult.
+	PartitionAssignment string
+	// ARNs of the secrets of users by username, see createTopicResult.
+	SecretArns map[string]string
+	// KMS key encrypting secrets, see createTopicResult.
+	KmsKeyArn string
+}
+
+func (a *cmdUpdate) Run(ctx context.Context, old, new *types.TopicInfo, stackID string) (_ *updateTopicResult, err error) {
+	// CloudFormation re-invokes updates with identical properties when
+	// retrying a stack operation. There is nothing to apply in that case.
+	// Drift is only corrected when properties change.
+	if reflect.DeepEqual(old, new) {
+		topicName := canonicalTopicName(new.Name, nameSuffix(new, stackID))
+		a.logger.Sugar().Infow("No changes detected, skipping topic update", "Topic", topicName)
+		opSummaryFrom(ctx).Skipped("UpdateTopic")
+		result := &updateTopicResult{TopicName: topicName, NoChanges: true}
+		if new.DryRun {
+			result.Plan = newUpdatePlan(topicName, nil, newUserDiff())
+			return result, nil
+		}
+		// CloudFormation replaces all attributes of the resource on
+		// update, therefore they are returned as by any other update.
+		result.KmsKeyArn, err = a.kmsKeyResolver.Resolve(ctx, new)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		result.SecretArns, err = lookupSecretArns(ctx, a.userManager, new, nameSuffix(new, stackID), a.serverless)
+		if err != nil {
+			return nil, err
+		}
+		result.PartitionAssignment = describeAssignment(ctx, a.kafkaClient, topicName, a.logger)
+		return result, nil
+	}
+
+	err = a.guardrails.Validate(new)
+	if err != nil {
+		return nil, err
+	}
+	// Enforced configs are reapplied on every update so that changes made
+	// outside CloudFormation are reverted too.
+	new.Config = a.guardrails.EnforceConfig(new.Config, a.logger)
+	if !reflect.DeepEqual(old.NameSuffix, new.NameSuffix) {
+		return nil, errors.New("Cannot update NameSuffix")
+	}
+	// A dry run does not apply its properties, yet CloudFormation records
+	// them as the old properties of the next update. The update, or its
+	// plan, starts from the state of the cluster instead.
+	if old.DryRun {
+		created, err := a.topicCreated(ctx, canonicalTopicName(old.Name, nameSuffix(old, stackID)))
+		if err != nil {
+			return nil, err
+		}
+		if !created {
+			return a.createPlanned(ctx, new, stackID)
+		}
+		old, err = a.appliedState(ctx, old, new, nameSuffix(new, stackID))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if old.Name != new.Name && new.ReplaceOnNameChange {
+		return a.replace(ctx, old, new, stackID)
+	}
+	shortStackID := nameSuffix(new, stackID)
 	topicName := canonicalTopicName(new.Name, shortStackID)
 	topics, err := a.kafkaClient.ListTopics(ctx, topicName)
 	if err != nil {
-		return errors.WithStack(err)
+		return nil, errors.WithStack(err)
 	}
 	if currentTopic, ok := topics[topicName]; ok && currentTopic.Err != nil {
 		if errors.Is(currentTopic.Err, kerr.UnknownTopicOrPartition) && old.Name != new.Name {
-			return errors.New("cannot update Name and ClusterArn properties")
+			return nil, errors.New("cannot update Name and ClusterArn properties")
 		} else {
-			return errors.WithStack(currentTopic.Err)
+			return nil, errors.WithStack(currentTopic.Err)
 		}
 	}
 	currentTopic := topics[topicName]
 	if len(currentTopic.Partitions.Numbers()) != new.Partitions {
-		return errors.New("Cannot update Partitions")
+		return nil, errors.New("Cannot update Partitions")
 	}
-	if currentTopic.Partitions.NumReplicas() != new.ReplicationFactor {
-		return errors.New("Cannot update ReplicationFactor")
+	if !a.serverless && currentTopic.Partitions.NumReplicas() != new.ReplicationFactor {
+		return nil, errors.New("Cannot update ReplicationFactor")
+	}
+	// Dropping the assignment from the template leaves replicas in place.
+	if len(new.ReplicaAssignment) > 0 && assignmentChanged(old.ReplicaAssignment, new.ReplicaAssignment) {
+		return nil, errors.New("Cannot update ReplicaAssignment")
 	}
 
 	kmsKeyID, err := a.kmsKeyResolver.Resolve(ctx, new)
 	if err != nil {
-		return errors.WithStack(err)
+		return nil, errors.WithStack(err)
 	}
-	cdiff, err := a.diffConfig(ctx, topicName, new.Config, old.Config)
+	cdiff, restore, err := a.diffConfig(ctx, topicName, new.Config, old.Config, new.ForceConfigReset)
 	if err != nil {
-		return errors.WithStack(err)
+		return nil, errors.WithStack(err)
 	}
+	udiff := a.diffUsers(old.Name, old, new)
+	if new.DryRun {
+		a.logger.Sugar().Infow("Dry run requested, skipping topic update", "Topic", topicName)
+		return &updateTopicResult{TopicName: topicName, Plan: newUpdatePlan(topicName, cdiff, udiff)}, nil
+	}
+
+	// Users created by a previous attempt of this request are recognised
+	// by the request ID recorded in the marker.
+	policy := existencePolicyFrom(ctx)
+	strictUsers := policy.Strict() && len(udiff.AddedUsers) > 0
+	if strictUsers {
+		marker, err := a.topicMarkers.Get(ctx, old.ClusterArn, topicName)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		policy.Observe(marker)
+	}
+	if strictUsers || mapChanged(old.Tags, new.Tags) || mapChanged(old.Labels, new.Labels) {
+		err = a.topicMarkers.Put(ctx, old.ClusterArn, topicName, &types.TopicMarker{StackID: stackID, Tags: new.Tags, Labels: new.Labels, RequestID: policy.RequestID()})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
 	a.logger.Sugar().Infow("Start Operation", "Name", "AlterTopicConfigs", "Topic", topicName)
-	if len(cdiff) > 0 {
-		responses, err := a.kafkaClient.AlterTopicConfigs(ctx, cdiff, topicName)
+	if len(cdiff) == 0 {
+		opSummaryFrom(ctx).Skipped("AlterTopicConfigs")
+	} else {
+		var responses kadm.AlterConfigsResponses
+		responses, err = a.kafkaClient.AlterTopicConfigs(ctx, cdiff, topicName)
 		if err != nil {
-			return errors.WithStack(err)
+			return nil, errors.WithStack(err)
 		}
-		response, err := responses.On(topicName, nil)
+		var response kadm.AlterConfigsResponse
+		response, err = responses.On(topicName, nil)
 		if err != nil {
-			return errors.WithStack(err)
+			return nil, errors.WithStack(err)
 		}
 		if response.Err != nil {
-			return errors.WithStack(response.Err)
+			return nil, errors.WithStack(response.Err)
 		}
+		opSummaryFrom(ctx).Performed("AlterTopicConfigs")
+		// Users are updated after the configs. Should any of them fail,
+		// the previous configs are restored so that the topic is not
+		// left half updated.
+		defer func() {
+			if err != nil {
+				a.restoreConfig(ctx, topicName, restore)
+			}
+		}()
 	}
 
-	udiff := a.diffUsers(old.Name, old, new)
+	// The other clusters of the topic keep the secrets of recreated users
+	// associated, therefore they are reused rather than deleted.
+	deleteCtx := ctx
+	if len(new.Clusters()) > 1 && retainedSecretsFrom(ctx) == nil {
+		deleteCtx = withRetainedSecrets(ctx, recreatedSecrets(udiff, shortStackID, a.secretNames))
+	}
 
 	// Perform deletes first so that the updates performed via a delete operation
 	// followed by an add are handled correctly.
 	// e.g. When user ARN is modified we delete the old user and create a new one.
 	for _, u := range udiff.DeletedUsers {
-		err := a.userManager.DeleteUser(ctx, u, kmsKeyID, topicName, shortStackID, old.ClusterArn)
+		// Stop issuing calls once the invocation is cancelled or about to
+		// time out.
+		if err := ctx.Err(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		err := a.userManager.DeleteUser(deleteCtx, u, kmsKeyID, topicName, shortStackID, old.ClusterArn)
 		if err != nil {
-			return errors.WithStack(err)
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	if new.CleanupOrphanedSecrets && !a.serverless {
+		err := a.userManager.CleanupOrphanedSecrets(deleteCtx, new.Users, shortStackID, old.ClusterArn)
+		if err != nil {
+			return nil, errors.WithStack(err)
 		}
 	}
 
@@ -93,57 +246,297 @@ func (a *cmdUpdate) Run(ctx context.Context, old, new *types.TopicInfo, stackID
 	// Otherwise, next step may fail.
 	// TODO: Make this wait deterministic by interrogating Secrets Manager.
 	if len(udiff.DeletedUsers) > 0 {
-		a.fixedDelay()
+		a.userDeleteDelay()
 	}
 
+	var tx *aclTransaction
+	userCtx := ctx
+	if a.transactionalACLs {
+		tx = newACLTransaction()
+		userCtx = withACLTransaction(ctx, tx)
+	}
 	for _, u := range udiff.AddedUsers {
-		err := a.userManager.CreateUser(ctx, shortStackID, topicName, kmsKeyID, old.ClusterArn, u)
+		if err := ctx.Err(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		err := a.userManager.CreateUser(userCtx, shortStackID, topicName, kmsKeyID, old.ClusterArn, u)
 		if err != nil {
-			return errors.WithStack(err)
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	for u, oldArns := range udiff.ChangedArns {
+		if err := ctx.Err(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		err := a.userManager.UpdateArn(ctx, findUser(new.Users, u), oldArns, kmsKeyID, shortStackID)
+		if err != nil {
+			return nil, errors.WithStack(err)
 		}
 	}
 
 	for u, aacls := range udiff.AddedPermissions {
-		err := a.userManager.CreateACLs(ctx, topicName, u, shortStackID, aacls)
+		if err := ctx.Err(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		err := a.userManager.CreateACLs(userCtx, topicName, findUser(new.Users, u), shortStackID, aacls)
 		if err != nil {
-			return errors.WithStack(err)
+			return nil, errors.WithStack(err)
+		}
+	}
+	if tx != nil {
+		err = a.userManager.ApplyACLs(ctx, tx.ACLs())
+		if err != nil {
+			return nil, errors.WithStack(err)
 		}
 	}
 
 	for u, dacls := range udiff.DeletedPermissions {
-		err := a.userManager.DeleteACLs(ctx, topicName, u, shortStackID, dacls)
+		if err := ctx.Err(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		err := a.userManager.DeleteACLs(ctx, topicName, findUser(old.Users, u), shortStackID, dacls)
 		if err != nil {
-			return errors.WithStack(err)
+			return nil, errors.WithStack(err)
 		}
 	}
 
-	return nil
+	if !a.serverless {
+		err = a.reconcileUserACLs(ctx, topicName, shortStackID, new.Users, udiff)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		err = a.reconcileUserGrants(ctx, kmsKeyID, shortStackID, new.Users, udiff)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		err = a.reconcileACLs(ctx, topicName, shortStackID, new.Users)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	secretArns, err := lookupSecretArns(ctx, a.userManager, new, shortStackID, a.serverless)
+	if err != nil {
+		return nil, err
+	}
+	return &updateTopicResult{
+		TopicName:           topicName,
+		PartitionAssignment: partitionAssignment(currentTopic.Partitions),
+		SecretArns:          secretArns,
+		KmsKeyArn:           kmsKeyID,
+	}, nil
 }
 
-func (a *cmdUpdate) diffConfig(ctx context.Context, topic string, new, old map[string]*string) ([]kadm.AlterConfig, error) {
-	c, err := a.kafkaClient.DescribeTopicConfigs(ctx, topic)
+// Reports whether topic exists in the cluster.
+func (a *cmdUpdate) topicCreated(ctx context.Context, topic string) (bool, error) {
+	topics, err := a.kafkaClient.ListTopics(ctx, topic)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	t, ok := topics[topic]
+	if !ok || errors.Is(t.Err, kerr.UnknownTopicOrPartition) {
+		return false, nil
+	}
+	if t.Err != nil {
+		return false, errors.WithStack(t.Err)
+	}
+	return true, nil
+}
+
+// Creates the topic of a resource created with DryRun, whose physical
+// resource ID was returned without creating the topic. Only plans the
+// creation while DryRun remains set.
+func (a *cmdUpdate) createPlanned(ctx context.Context, new *types.TopicInfo, stackID string) (*updateTopicResult, error) {
+	a.logger.Sugar().Infow("Start Operation", "Name", "CreatePlannedTopic", "TopicName", canonicalTopicName(new.Name, nameSuffix(new, stackID)))
+	cmdCreate := newCmdCreate(a.kafkaClient, a.kmsKeyResolver, a.userManager, a.topicMarkers, a.serverless, a.settings, a.logger)
+	created, err := cmdCreate.Run(ctx, new, stackID)
+	if err != nil {
+		return nil, err
+	}
+	return &updateTopicResult{
+		TopicName:           created.TopicName,
+		PhysicalResourceID:  created.PhysicalResourceID,
+		Plan:                created.Plan,
+		PartitionAssignment: created.PartitionAssignment,
+		SecretArns:          created.SecretArns,
+		KmsKeyArn:           created.KmsKeyArn,
+	}, nil
+}
+
+// Returns old with the users provisioned in the cluster in place of the
+// users of a dry run. Declared users that were never provisioned are then
+// added, and users of old that are no longer declared are deleted if they
+// were provisioned. Provisioned users keep their declaration, therefore
+// their permissions are corrected by ReconcileACLs rather than by
+// recreating them.
+func (a *cmdUpdate) appliedState(ctx context.Context, old, new *types.TopicInfo, shortStackID string) (*types.TopicInfo, error) {
+	users := make([]types.User, 0, len(old.Users)+len(new.Users))
+	users = append(users, new.Users...)
+	for _, u := range old.Users {
+		if findUser(new.Users, u.Username) == nil {
+			users = append(users, u)
+		}
+	}
+	provisioned, err := a.userManager.ProvisionedUsers(ctx, users, shortStackID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	a.logger.Sugar().Infow("Previous update was a dry run, diffing users against the cluster", "Declared", len(new.Users), "Provisioned", len(provisioned))
+	applied := *old
+	applied.DryRun = false
+	applied.Users = provisioned
+	return &applied, nil
+}
+
+// Topic configs MSK does not allow to be altered once the topic is
+// created. Brokers reject such changes with an error that does not name
+// the config, therefore they are detected before altering the topic.
+var readOnlyTopicConfigs = map[string]bool{
+	"message.format.version": true,
+	"remote.storage.enable":  true,
+}
+
+// Topic configs holding a list of values. Brokers return them as a comma
+// separated list, while templates may use other formatting such as
+// [delete, compact]. Only these configs are appended to when added.
+var listTopicConfigs = map[string]bool{
+	"cleanup.policy":                          true,
+	"leader.replication.throttled.replicas":   true,
+	"follower.replication.throttled.replicas": true,
+}
+
+// Creates the topic under its new name and grants the users access to it.
+// Users and their secrets are shared with the old topic, whose resource
+// CloudFormation deletes once the new physical resource ID is returned.
+// The markers of both topics are linked so that deleting either resource
+// keeps the secrets while the other one is managed.
+func (a *cmdUpdate) replace(ctx context.Context, old, new *types.TopicInfo, stackID string) (*updateTopicResult, error) {
+	if !reflect.DeepEqual(old.Users, new.Users) {
+		return nil, errors.New("Cannot update Users along with Name")
+	}
+	shortStackID := nameSuffix(new, stackID)
+	oldTopicName := canonicalTopicName(old.Name, shortStackID)
+	// Users already exist, therefore the topic is created without them.
+	info := *new
+	info.Users = nil
+	cmdCreate := newCmdCreate(a.kafkaClient, a.kmsKeyResolver, a.userManager, a.topicMarkers, a.serverless, a.settings, a.logger)
+	created, err := cmdCreate.Run(ctx, &info, stackID)
+	if err != nil {
+		return nil, err
+	}
+	topicName := created.TopicName
+	if new.DryRun {
+		// The old resource must not be deleted.
+		return &updateTopicResult{TopicName: topicName, Plan: created.Plan}, nil
+	}
+	a.logger.Sugar().Infow("Start Operation", "Name", "ReplaceTopic", "OldTopicName", oldTopicName, "TopicName", topicName)
+	requestID := existencePolicyFrom(ctx).RequestID()
+	err = a.topicMarkers.Put(ctx, new.ClusterArn, topicName, &types.TopicMarker{StackID: stackID, Tags: new.Tags, Labels: new.Labels, RequestID: requestID, LinkedTopic: oldTopicName})
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	err = a.topicMarkers.Put(ctx, old.ClusterArn, oldTopicName, &types.TopicMarker{StackID: stackID, Tags: old.Tags, Labels: old.Labels, RequestID: requestID, LinkedTopic: topicName})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, u := range new.Users {
+		if err := ctx.Err(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		err = a.userManager.CreateACLs(ctx, topicName, &u, shortStackID, u.Permissions)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	secretArns, err := lookupSecretArns(ctx, a.userManager, new, shortStackID, a.serverless)
+	if err != nil {
+		return nil, err
+	}
+	// The topic was created without users, therefore the key of their
+	// secrets is resolved separately.
+	kmsKeyID, err := a.kmsKeyResolver.Resolve(ctx, new)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &updateTopicResult{
+		TopicName:           topicName,
+		PhysicalResourceID:  created.PhysicalResourceID,
+		PartitionAssignment: created.PartitionAssignment,
+		SecretArns:          secretArns,
+		KmsKeyArn:           kmsKeyID,
+	}, nil
+}
+
+// Reports whether a and b are the same value of config k. List values
+// only differing in brackets and spacing are equal.
+func configValuesEqual(k, a, b string) bool {
+	if listTopicConfigs[k] {
+		return normalizeConfigList(a) == normalizeConfigList(b)
+	}
+	return a == b
+}
+
+func normalizeConfigList(v string) string {
+	v = strings.TrimSpace(v)
+	v = strings.TrimSuffix(strings.TrimPrefix(v, "["), "]")
+	items := strings.Split(v, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return strings.Join(items, ",")
+}
+
+// Returns the changes that turn the current configs of topic into new,
+// along with the changes that restore the current configs afterwards.
+// Configs in old that are missing from new are reset to the broker
+// default. Unless force is set, such a config whose current value no
+// longer matches old is left in place, as it was changed outside
+// CloudFormation.
+func (a *cmdUpdate) diffConfig(ctx context.Context, topic string, new, old map[string]*string, force bool) ([]kadm.AlterConfig, []kadm.AlterConfig, error) {
+	c, err := a.kafkaClient.DescribeTopicConfigs(ctx, topic)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	rc, err := topicConfig(c, topic)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The topic may be deleted outside CloudFormation after it was listed.
+	if rc.Err != nil {
+		if errors.Is(rc.Err, kerr.UnknownTopicOrPartition) {
+			return nil, nil, errors.WithStack(fmt.Errorf("topic %s no longer exists in the cluster, it may have been deleted outside CloudFormation", topic))
+		}
+		return nil, nil, errors.WithStack(rc.Err)
+	}
 	current := make(map[string]*string)
-	for _, e := range c[0].Configs {
+	// Configs set on the topic itself rather than inherited from the
+	// broker.
+	overridden := make(map[string]*string)
+	for _, e := range rc.Configs {
 		current[e.Key] = e.Value
+		if e.Source == kmsg.ConfigSourceDynamicTopicConfig && e.Value != nil {
+			overridden[e.Key] = e.Value
+		}
 	}
 
 	updates := make([]kadm.AlterConfig, 0)
 	for k, nv := range new {
 		if cv, ok := current[k]; ok {
-			if *nv != *cv {
+			if !configValuesEqual(k, *nv, *cv) {
 				updates = append(updates, kadm.AlterConfig{Op: kadm.SetConfig, Name: k, Value: nv})
 			}
-		} else {
+		} else if listTopicConfigs[k] {
 			updates = append(updates, kadm.AlterConfig{Op: kadm.AppendConfig, Name: k, Value: nv})
+		} else {
+			// Some brokers append to scalar values as if they were lists.
+			updates = append(updates, kadm.AlterConfig{Op: kadm.SetConfig, Name: k, Value: nv})
 		}
 	}
 	for k, ov := range old {
 		if _, ok := new[k]; !ok {
 			if cv, ok := current[k]; ok {
-				if *ov != *cv {
+				if !configValuesEqual(k, *ov, *cv) && !force {
 					a.logger.Sugar().Infow("Ignore delete because current value does not match", "Name", k, "Value", *ov, "CurrentValue", *cv)
 					continue
 				}
@@ -153,8 +546,48 @@ func (a *cmdUpdate) diffConfig(ctx context.Context, topic string, new, old map[s
 	}
 	for _, u := range updates {
 		a.logger.Sugar().Infow("Config Update Detected", "Name", u.Name, "Op", u.Op, "Value", *u.Value)
+		if readOnlyTopicConfigs[u.Name] {
+			return nil, nil, errors.WithStack(newClassifiedError(ErrCodeReadOnlyConfig, "Config %s is read-only in MSK. It can only be set when the topic is created.", u.Name))
+		}
 	}
-	return updates, nil
+	restore := make([]kadm.AlterConfig, 0, len(updates))
+	for _, u := range updates {
+		if v, ok := overridden[u.Name]; ok {
+			restore = append(restore, kadm.AlterConfig{Op: kadm.SetConfig, Name: u.Name, Value: v})
+		} else {
+			restore = append(restore, kadm.AlterConfig{Op: kadm.DeleteConfig, Name: u.Name})
+		}
+	}
+	return updates, restore, nil
+}
+
+// Restores the configs of the topic that Run altered before a later step
+// failed. Restoring is best-effort: failures are logged and the error of
+// the failed step is returned to CloudFormation.
+func (a *cmdUpdate) restoreConfig(ctx context.Context, topicName string, restore []kadm.AlterConfig) {
+	if ctx.Err() != nil {
+		a.logger.Sugar().Warnw("Skip Operation", "Name", "RestoreTopicConfigs", "Topic", topicName, "Reason", "Invocation cancelled")
+		opSummaryFrom(ctx).Skipped("RestoreTopicConfigs")
+		return
+	}
+	names := make([]string, len(restore))
+	for i, r := range restore {
+		names[i] = r.Name
+	}
+	a.logger.Sugar().Warnw("Start Operation", "Name", "RestoreTopicConfigs", "Topic", topicName, "Configs", names)
+	responses, err := a.kafkaClient.AlterTopicConfigs(ctx, restore, topicName)
+	if err == nil {
+		var response kadm.AlterConfigsResponse
+		response, err = responses.On(topicName, nil)
+		if err == nil {
+			err = response.Err
+		}
+	}
+	if err != nil {
+		a.logger.Sugar().Errorw("Operation Failed", "Name", "RestoreTopicConfigs", "Topic", topicName, "Error", err)
+		return
+	}
+	opSummaryFrom(ctx).Performed("RestoreTopicConfigs")
 }
 
 func (a *cmdUpdate) diffUsers(topic string, old, new *types.TopicInfo) *userDiff {
@@ -206,16 +639,22 @@ func (a *cmdUpdate) diffUsers(topic string, old, new *types.TopicInfo) *userDiff
 				diff.AddedPermissions[o.Username] = addedPermissions
 			}
 
-			// When a secret is created for a user with an ARN its
-			// policy contains permissions granted by TR as well as
-			// the ones granted by MSK during secret association.
-			// We don't want to accidentally wipe the policy changes made
-			// by MSK when the ARN is modified.
-			// Consequently, whenever user's ARN is modified, we delete
-			// and recreate the user.
-			if o.Arn != n.Arn {
+			// Changes in externally managed secret, authentication and
+			// the topics, including additional topics, groups or hosts
+			// the user's ACLs apply to require the user to be deleted and
+			// recreated.
+			// When only the ARNs are modified, access to the secret is moved
+			// to the new ARNs instead so that clients keep their
+			// credentials. The secret policy also contains permissions
+			// granted by MSK during secret association, which are
+			// preserved.
+			// Changes in GroupDescribe, NoGroupAcls and TopicDescribe are
+			// applied by ReconcileACLs.
+			if o.SecretArn != n.SecretArn || o.UsesTLS() != n.UsesTLS() || o.Principal != n.Principal || o.IamPrincipal != n.IamPrincipal || o.TopicPrefix != n.TopicPrefix || setChanged(o.AdditionalTopics, n.AdditionalTopics) || o.GroupPrefix != n.GroupPrefix || setChanged(o.AllowedHosts, n.AllowedHosts) {
 				diff.AddedUsers = append(diff.AddedUsers, n)
 				diff.DeletedUsers = append(diff.DeletedUsers, &old.Users[idx])
+			} else if setChanged(o.Principals(), n.Principals()) {
+				diff.ChangedArns[o.Username] = o.Principals()
 			}
 		} else {
 			diff.DeletedUsers = append(diff.DeletedUsers, &old.Users[idx])
@@ -232,11 +671,164 @@ func (a *cmdUpdate) diffUsers(topic string, old, new *types.TopicInfo) *userDiff
 	return diff
 }
 
+// Returns the secrets of users that are recreated with a generated
+// secret.
+func recreatedSecrets(udiff *userDiff, shortStackID string, names secretNameTemplate) *retainedSecrets {
+	r := newRetainedSecrets()
+	added := make(map[string]*types.User)
+	for _, u := range udiff.AddedUsers {
+		added[u.Username] = u
+	}
+	for _, u := range udiff.DeletedUsers {
+		n, ok := added[u.Username]
+		if ok && !u.Secretless() && u.SecretArn == "" && !n.Secretless() && n.SecretArn == "" {
+			r.Add(names.Name(u.Username, shortStackID))
+		}
+	}
+	return r
+}
+
+// Corrects ACLs of declared users that were changed outside TR. Users
+// added in this update already have the ACLs created by CreateUser.
+func (a *cmdUpdate) reconcileUserACLs(ctx context.Context, topicName, shortStackID string, users []types.User, udiff *userDiff) error {
+	added := make(map[string]bool)
+	for _, u := range udiff.AddedUsers {
+		added[u.Username] = true
+	}
+	for i := range users {
+		if added[users[i].Username] {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return errors.WithStack(err)
+		}
+		err := a.userManager.ReconcileACLs(ctx, topicName, &users[i], shortStackID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// Recreates KMS grants of declared users that were revoked outside TR.
+// Users added in this update and users whose ARNs changed were granted
+// access by CreateUser and UpdateArn.
+func (a *cmdUpdate) reconcileUserGrants(ctx context.Context, kmsKeyID, shortStackID string, users []types.User, udiff *userDiff) error {
+	granted := make(map[string]bool)
+	for _, u := range udiff.AddedUsers {
+		granted[u.Username] = true
+	}
+	for username := range udiff.ChangedArns {
+		granted[username] = true
+	}
+	for i := range users {
+		if granted[users[i].Username] {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return errors.WithStack(err)
+		}
+		err := a.userManager.ReconcileGrants(ctx, &users[i], kmsKeyID, shortStackID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// ACLs of a user removed from the template persist if its deletion never
+// completed (e.g. a prior update failed). Remove topic ACLs granted to
+// principals created by this stack that are no longer declared.
+func (a *cmdUpdate) reconcileACLs(ctx context.Context, topicName, shortStackID string, users []types.User) error {
+	if shortStackID == "" {
+		// Principals of this stack cannot be told apart from others.
+		a.logger.Sugar().Infow("Skip Operation", "Name", "ReconcileACLs", "Reason", "NameSuffix is empty")
+		return nil
+	}
+	desired := make(map[string]bool)
+	for i := range users {
+		desired[fmt.Sprintf("User:%s", principalName(&users[i], shortStackID, a.secretNames))] = true
+	}
+	a.logger.Sugar().Infow("Start Operation", "Name", "DescribeACLs", "Topic", topicName)
+	filter := kadm.NewACLs().Topics(topicName).ResourcePatternType(kadm.ACLPatternLiteral).Allow().AllowHosts().Operations()
+	results, err := a.kafkaClient.DescribeACLs(ctx, filter)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	stale := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, r := range results {
+		if r.Err != nil {
+			return errors.WithStack(r.Err)
+		}
+		for _, d := range r.Described {
+			if seen[d.Principal] || desired[d.Principal] || !a.secretNames.IsStackPrincipal(d.Principal, shortStackID) {
+				continue
+			}
+			seen[d.Principal] = true
+			stale = append(stale, d.Principal)
+		}
+	}
+	if len(stale) == 0 {
+		opSummaryFrom(ctx).Skipped("DeleteStaleACLs")
+		return nil
+	}
+	sort.Strings(stale)
+	a.logger.Sugar().Warnw("Removing ACLs of users no longer declared", "Topic", topicName, "Principals", stale)
+	dr, err := a.kafkaClient.DeleteACLs(ctx, kadm.NewACLs().Topics(topicName).ResourcePatternType(kadm.ACLPatternLiteral).Allow(stale...).AllowHosts().Operations())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, r := range dr {
+		if r.Err != nil {
+			return errors.WithStack(r.Err)
+		}
+	}
+	opSummaryFrom(ctx).Performed("DeleteStaleACLs")
+	return nil
+}
+
+// Reports whether the maps differ, treating nil and empty maps as equal.
+func assignmentChanged(old, new types.ReplicaAssignment) bool {
+	if len(old) != len(new) {
+		return true
+	}
+	for p, replicas := range new {
+		if !reflect.DeepEqual(old[p], replicas) {
+			return true
+		}
+	}
+	return false
+}
+
+func mapChanged(old, new map[string]string) bool {
+	return !reflect.DeepEqual(old, new) && (len(old) > 0 || len(new) > 0)
+}
+
+// Reports whether old and new contain different strings, e.g. grant
+// access to different principals. The order in which they are declared
+// does not matter.
+func setChanged(old, new []string) bool {
+	return len(subtractStrings(old, new)) > 0 || len(subtractStrings(new, old)) > 0
+}
+
+func findUser(users []types.User, username string) *types.User {
+	for i := range users {
+		if users[i].Username == username {
+			return &users[i]
+		}
+	}
+	return nil
+}
+
 type userDiff struct {
 	AddedUsers         []*types.User
 	AddedPermissions   map[string][]types.Permission
 	DeletedPermissions map[string][]types.Permission
 	DeletedUsers       []*types.User
+	// Previous ARNs of users whose ARNs are the only change requiring
+	// their secret to be updated.
+	ChangedArns map[string][]string
 }
 
 type userDiffOption func(*userDiff)
@@ -259,6 +851,12 @@ func withDeletedPermissions(username string, permissions []types.Permission) use
 	}
 }
 
+func withChangedArn(username string, oldArns ...string) userDiffOption {
+	return func(ud *userDiff) {
+		ud.ChangedArns[username] = oldArns
+	}
+}
+
 func withDeletedUsers(users []*types.User) userDiffOption {
 	return func(ud *userDiff) {
 		ud.DeletedUsers = users
@@ -271,6 +869,7 @@ func newUserDiff(options ...userDiffOption) *userDiff {
 		AddedPermissions:   make(map[string][]types.Permission),
 		DeletedPermissions: make(map[string][]types.Permission),
 		DeletedUsers:       make([]*types.User, 0),
+		ChangedArns:        make(map[string][]string),
 	}
 	for _, opt := range options {
 		opt(ud)
@@ -5,6 +5,7 @@ package admin
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	tt "github.com/aws-samples/amazon-msk-topic-resource/types"
@@ -16,6 +17,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
 	"go.uber.org/zap"
 )
 
@@ -43,14 +46,26 @@ func TestCmdUpdate(t *testing.T) {
 		addedConfigProps           map[string]*string
 		updatedConfigProps         map[string]*string
 		listTopicsOutput           []interface{}
+		noChanges                  bool
 	}
 
+	topicA := canonicalTopicName("a", shortStackID("test"))
 	alice := tt.User{Username: "alice", Arn: "1", Permissions: []tt.Permission{tt.PermissionRead}}
 	bob := tt.User{Username: "bob", Arn: "2", Permissions: []tt.Permission{tt.PermissionRead}}
 	bobRW := tt.User{Username: "bob", Arn: "2", Permissions: []tt.Permission{tt.PermissionRead, tt.PermissionWrite}}
 	bobW := tt.User{Username: "bob", Arn: "2", Permissions: []tt.Permission{tt.PermissionWrite}}
 	bobArn3 := tt.User{Username: "bob", Arn: "3", Permissions: []tt.Permission{tt.PermissionRead}}
+	bobArn3RW := tt.User{Username: "bob", Arn: "3", Permissions: []tt.Permission{tt.PermissionRead, tt.PermissionWrite}}
+	bobNoArn := tt.User{Username: "bob", Permissions: []tt.Permission{tt.PermissionRead}}
+	bobArn3Prefix := tt.User{Username: "bob", Arn: "3", TopicPrefix: "orders.", Permissions: []tt.Permission{tt.PermissionRead}}
 	aliceNoArn := tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}
+	bobPrefix := tt.User{Username: "bob", Arn: "2", TopicPrefix: "orders.", Permissions: []tt.Permission{tt.PermissionRead}}
+	bobGroupPrefix := tt.User{Username: "bob", Arn: "2", GroupPrefix: "orders-", Permissions: []tt.Permission{tt.PermissionRead}}
+	bobHosts := tt.User{Username: "bob", Arn: "2", AllowedHosts: []string{"10.0.0.1"}, Permissions: []tt.Permission{tt.PermissionRead}}
+	bobAdditional := tt.User{Username: "bob", Arn: "2", AdditionalTopics: []string{"b", "c"}, Permissions: []tt.Permission{tt.PermissionRead}}
+	bobAdditionalReordered := tt.User{Username: "bob", Arn: "2", AdditionalTopics: []string{"c", "b"}, Permissions: []tt.Permission{tt.PermissionRead}}
+	carolIAM := tt.User{Username: "carol", IamPrincipal: "arn:aws:iam::123456789012:role/a", Permissions: []tt.Permission{tt.PermissionRead}}
+	carolIAMChanged := tt.User{Username: "carol", IamPrincipal: "arn:aws:iam::123456789012:role/b", Permissions: []tt.Permission{tt.PermissionRead}}
 
 	configValue1 := aws.String("1")
 	configValue2 := aws.String("2")
@@ -64,6 +79,7 @@ func TestCmdUpdate(t *testing.T) {
 			old:              &tt.TopicInfo{Name: "a"},
 			new:              &tt.TopicInfo{Name: "a"},
 			expectedUserDiff: newUserDiff(),
+			noChanges:        true,
 		},
 		{
 			name:             "Identical users list",
@@ -71,6 +87,14 @@ func TestCmdUpdate(t *testing.T) {
 			old:              &tt.TopicInfo{Name: "a", Users: []tt.User{alice, bob}},
 			new:              &tt.TopicInfo{Name: "a", Users: []tt.User{alice, bob}},
 			expectedUserDiff: newUserDiff(),
+			noChanges:        true,
+		},
+		{
+			name:             "Identical users list with other changes",
+			topic:            "a",
+			old:              &tt.TopicInfo{Name: "a", Users: []tt.User{alice, bob}},
+			new:              &tt.TopicInfo{Name: "a", Users: []tt.User{alice, bob}, DeletionPolicy: tt.DeletionPolicyDelete},
+			expectedUserDiff: newUserDiff(),
 		},
 		{
 			name:             "Added user",
@@ -97,15 +121,109 @@ func TestCmdUpdate(t *testing.T) {
 			),
 		},
 		{
-			name:  "Updated arn",
+			name:             "Updated arn",
+			topic:            "a",
+			old:              &tt.TopicInfo{Name: "a", Users: []tt.User{bob}},
+			new:              &tt.TopicInfo{Name: "a", Users: []tt.User{bobArn3}},
+			expectedUserDiff: newUserDiff(withChangedArn("bob", "2")),
+		},
+		{
+			name:  "Updated arn and permissions",
+			topic: "a",
+			old:   &tt.TopicInfo{Name: "a", Users: []tt.User{bob}},
+			new:   &tt.TopicInfo{Name: "a", Users: []tt.User{bobArn3RW}},
+			expectedUserDiff: newUserDiff(
+				withChangedArn("bob", "2"),
+				withAddedPermissions("bob", []tt.Permission{tt.PermissionWrite}),
+			),
+		},
+		{
+			name:             "Added arns",
+			topic:            "a",
+			old:              &tt.TopicInfo{Name: "a", Users: []tt.User{bob}},
+			new:              &tt.TopicInfo{Name: "a", Users: []tt.User{{Username: "bob", Arn: "2", Arns: []string{"4"}, Permissions: []tt.Permission{tt.PermissionRead}}}},
+			expectedUserDiff: newUserDiff(withChangedArn("bob", "2")),
+		},
+		{
+			name:  "Reordered arns",
+			topic: "a",
+			old:   &tt.TopicInfo{Name: "a", Users: []tt.User{{Username: "bob", Arn: "2", Arns: []string{"4"}, Permissions: []tt.Permission{tt.PermissionRead}}}},
+			new:   &tt.TopicInfo{Name: "a", Users: []tt.User{{Username: "bob", Arn: "4", Arns: []string{"2"}, Permissions: []tt.Permission{tt.PermissionRead}}}},
+		},
+		{
+			name:             "Added arn",
+			topic:            "a",
+			old:              &tt.TopicInfo{Name: "a", Users: []tt.User{bobNoArn}},
+			new:              &tt.TopicInfo{Name: "a", Users: []tt.User{bobArn3}},
+			expectedUserDiff: newUserDiff(withChangedArn("bob")),
+		},
+		{
+			name:  "Updated arn and topic prefix",
+			topic: "a",
+			old:   &tt.TopicInfo{Name: "a", Users: []tt.User{bob}},
+			new:   &tt.TopicInfo{Name: "a", Users: []tt.User{bobArn3Prefix}},
+			expectedUserDiff: newUserDiff(
+				withAddedUsers([]*tt.User{&bobArn3Prefix}),
+				withDeletedUsers([]*tt.User{&bob}),
+			),
+		},
+		{
+			name:  "Updated topic prefix",
+			topic: "a",
+			old:   &tt.TopicInfo{Name: "a", Users: []tt.User{bob}},
+			new:   &tt.TopicInfo{Name: "a", Users: []tt.User{bobPrefix}},
+			expectedUserDiff: newUserDiff(
+				withAddedUsers([]*tt.User{&bobPrefix}),
+				withDeletedUsers([]*tt.User{&bob}),
+			),
+		},
+		{
+			name:  "Updated group prefix",
+			topic: "a",
+			old:   &tt.TopicInfo{Name: "a", Users: []tt.User{bob}},
+			new:   &tt.TopicInfo{Name: "a", GroupPrefix: "orders-", Users: []tt.User{bobGroupPrefix}},
+			expectedUserDiff: newUserDiff(
+				withAddedUsers([]*tt.User{&bobGroupPrefix}),
+				withDeletedUsers([]*tt.User{&bob}),
+			),
+		},
+		{
+			name:  "Updated allowed hosts",
 			topic: "a",
 			old:   &tt.TopicInfo{Name: "a", Users: []tt.User{bob}},
-			new:   &tt.TopicInfo{Name: "a", Users: []tt.User{bobArn3}},
+			new:   &tt.TopicInfo{Name: "a", AllowedHosts: []string{"10.0.0.1"}, Users: []tt.User{bobHosts}},
 			expectedUserDiff: newUserDiff(
-				withAddedUsers([]*tt.User{&bobArn3}),
+				withAddedUsers([]*tt.User{&bobHosts}),
 				withDeletedUsers([]*tt.User{&bob}),
 			),
 		},
+		{
+			name:  "Updated additional topics",
+			topic: "a",
+			old:   &tt.TopicInfo{Name: "a", Users: []tt.User{bob}},
+			new:   &tt.TopicInfo{Name: "a", Users: []tt.User{bobAdditional}},
+			expectedUserDiff: newUserDiff(
+				withAddedUsers([]*tt.User{&bobAdditional}),
+				withDeletedUsers([]*tt.User{&bob}),
+			),
+		},
+		{
+			name:             "Reordered additional topics",
+			topic:            "a",
+			old:              &tt.TopicInfo{Name: "a", Users: []tt.User{bobAdditional}},
+			new:              &tt.TopicInfo{Name: "a", Users: []tt.User{bobAdditionalReordered}},
+			expectedUserDiff: newUserDiff(),
+		},
+		{
+			name:  "Updated IAM principal",
+			topic: "a",
+			old:   &tt.TopicInfo{Name: "a", Users: []tt.User{carolIAM}},
+			new:   &tt.TopicInfo{Name: "a", Users: []tt.User{carolIAMChanged}},
+			expectedUserDiff: newUserDiff(
+				withAddedUsers([]*tt.User{&carolIAMChanged}),
+				withDeletedUsers([]*tt.User{&carolIAM}),
+			),
+		},
 		{
 			name:             "Deleted user",
 			topic:            "a",
@@ -137,11 +255,41 @@ func TestCmdUpdate(t *testing.T) {
 			topic:                      "a",
 			old:                        &tt.TopicInfo{Name: "a", Users: []tt.User{aliceNoArn}, Config: map[string]*string{"a": configValue1, "b": configValue2, "c": configValue1}},
 			new:                        &tt.TopicInfo{Name: "a", Users: []tt.User{aliceNoArn}, Config: map[string]*string{"a": configValue2, "c": configValue1, "d": configValue3}},
-			describeTopicConfigsOutput: []interface{}{kadm.ResourceConfigs{kadm.ResourceConfig{Name: "a", Configs: []kadm.Config{{Key: "a", Value: configValue4}, {Key: "b", Value: configValue2}, {Key: "c", Value: configValue4}, {Key: "x", Value: configValue1}}}}, error(nil)},
+			describeTopicConfigsOutput: []interface{}{kadm.ResourceConfigs{kadm.ResourceConfig{Name: topicA, Configs: []kadm.Config{{Key: "a", Value: configValue4}, {Key: "b", Value: configValue2}, {Key: "c", Value: configValue4}, {Key: "x", Value: configValue1}}}}, error(nil)},
 			addedConfigProps:           map[string]*string{"d": configValue3},
 			updatedConfigProps:         map[string]*string{"a": configValue2},
 			deletedConfigProps:         map[string]*string{"b": configValue2},
 		},
+		{
+			name:                       "Removed config changed outside CloudFormation",
+			topic:                      "a",
+			old:                        &tt.TopicInfo{Name: "a", Users: []tt.User{aliceNoArn}, Config: map[string]*string{"a": configValue1, "b": configValue2}},
+			new:                        &tt.TopicInfo{Name: "a", Users: []tt.User{aliceNoArn}, Config: map[string]*string{"a": configValue1}},
+			describeTopicConfigsOutput: []interface{}{kadm.ResourceConfigs{kadm.ResourceConfig{Name: topicA, Configs: []kadm.Config{{Key: "a", Value: configValue1}, {Key: "b", Value: configValue3}}}}, error(nil)},
+		},
+		{
+			name:                       "Removed config reset when forced",
+			topic:                      "a",
+			old:                        &tt.TopicInfo{Name: "a", Users: []tt.User{aliceNoArn}, Config: map[string]*string{"a": configValue1, "b": configValue2}},
+			new:                        &tt.TopicInfo{Name: "a", Users: []tt.User{aliceNoArn}, Config: map[string]*string{"a": configValue1}, ForceConfigReset: true},
+			describeTopicConfigsOutput: []interface{}{kadm.ResourceConfigs{kadm.ResourceConfig{Name: topicA, Configs: []kadm.Config{{Key: "a", Value: configValue1}, {Key: "b", Value: configValue3}}}}, error(nil)},
+			deletedConfigProps:         map[string]*string{"b": configValue2},
+		},
+		{
+			name:                       "List config only differing in formatting",
+			topic:                      "a",
+			old:                        &tt.TopicInfo{Name: "a", Users: []tt.User{aliceNoArn}, Config: map[string]*string{"cleanup.policy": aws.String("delete")}},
+			new:                        &tt.TopicInfo{Name: "a", Users: []tt.User{aliceNoArn}, Config: map[string]*string{"cleanup.policy": aws.String("[delete, compact]")}},
+			describeTopicConfigsOutput: []interface{}{kadm.ResourceConfigs{kadm.ResourceConfig{Name: topicA, Configs: []kadm.Config{{Key: "cleanup.policy", Value: aws.String("delete,compact")}}}}, error(nil)},
+		},
+		{
+			name:                       "Removed list config only differing in formatting",
+			topic:                      "a",
+			old:                        &tt.TopicInfo{Name: "a", Users: []tt.User{aliceNoArn}, Config: map[string]*string{"a": configValue1, "cleanup.policy": aws.String("[delete, compact]")}},
+			new:                        &tt.TopicInfo{Name: "a", Users: []tt.User{aliceNoArn}, Config: map[string]*string{"a": configValue1}},
+			describeTopicConfigsOutput: []interface{}{kadm.ResourceConfigs{kadm.ResourceConfig{Name: topicA, Configs: []kadm.Config{{Key: "a", Value: configValue1}, {Key: "cleanup.policy", Value: aws.String("delete,compact")}}}}, error(nil)},
+			deletedConfigProps:         map[string]*string{"cleanup.policy": aws.String("[delete, compact]")},
+		},
 	}
 
 	stackID := "test"
@@ -155,7 +303,7 @@ func TestCmdUpdate(t *testing.T) {
 			topicName := canonicalTopicName(c.new.Name, shortStackID)
 
 			if c.describeTopicConfigsOutput == nil {
-				c.describeTopicConfigsOutput = []interface{}{kadm.ResourceConfigs{kadm.ResourceConfig{}}, error(nil)}
+				c.describeTopicConfigsOutput = []interface{}{kadm.ResourceConfigs{kadm.ResourceConfig{Name: topicName}}, error(nil)}
 			}
 			if c.kmsResolverOutput == nil {
 				c.kmsResolverOutput = []interface{}{kmsKeyID, error(nil)}
@@ -197,10 +345,24 @@ func TestCmdUpdate(t *testing.T) {
 			kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
 			userManager := mocks.NewMockUserManagerService(ctrl)
 
-			cmdUpdate := newCmdUpdate(kmsKeyResolver, userManager, kafkaClient, func() {}, logger)
+			cmdUpdate := newCmdUpdate(kmsKeyResolver, userManager, kafkaClient, mocks.NewMockTopicMarkerService(ctrl), false, testSettings(), logger)
+
+			if c.noChanges {
+				kmsKeyResolver.EXPECT().Resolve(ctx, c.new).Return("", error(nil))
+				userManager.EXPECT().SecretArns(ctx, c.new.Users, shortStackID).Return(map[string]string{}, error(nil)).AnyTimes()
+				kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(c.listTopicsOutput...)
+				result, err := cmdUpdate.Run(ctx, c.old, c.new, stackID)
+				assert.Nil(t, err)
+				assert.True(t, result.NoChanges)
+				return
+			}
 
 			kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(c.listTopicsOutput...)
 			kafkaClient.EXPECT().DescribeTopicConfigs(ctx, topicName).Return(c.describeTopicConfigsOutput...)
+			kafkaClient.EXPECT().DescribeACLs(ctx, gomock.Any()).Return(kadm.DescribeACLsResults{}, error(nil)).AnyTimes()
+			userManager.EXPECT().ReconcileACLs(ctx, topicName, gomock.Any(), shortStackID).Return(error(nil)).AnyTimes()
+			userManager.EXPECT().ReconcileGrants(ctx, gomock.Any(), gomock.Any(), shortStackID).Return(error(nil)).AnyTimes()
+			userManager.EXPECT().SecretArns(ctx, c.new.Users, shortStackID).Return(map[string]string{}, error(nil)).AnyTimes()
 			kmsKeyResolver.EXPECT().Resolve(ctx, c.new).Return(c.kmsResolverOutput...)
 
 			if len(c.addedConfigProps) > 0 || len(c.updatedConfigProps) > 0 || len(c.deletedConfigProps) > 0 {
@@ -208,11 +370,19 @@ func TestCmdUpdate(t *testing.T) {
 					Do(func(actx context.Context, alts []kadm.AlterConfig, tn string) {
 						assert.Equal(t, ctx, actx)
 						assert.Equal(t, topicName, tn)
+						assert.Len(t, alts, len(c.addedConfigProps)+len(c.updatedConfigProps)+len(c.deletedConfigProps))
 						for _, a := range alts {
 							switch a.Op {
 							case kadm.SetConfig:
-								assert.Equal(t, c.updatedConfigProps[a.Name], a.Value)
+								// Added scalar configs are set.
+								if v, ok := c.addedConfigProps[a.Name]; ok {
+									assert.False(t, listTopicConfigs[a.Name], a.Name)
+									assert.Equal(t, v, a.Value)
+								} else {
+									assert.Equal(t, c.updatedConfigProps[a.Name], a.Value)
+								}
 							case kadm.AppendConfig:
+								assert.True(t, listTopicConfigs[a.Name], a.Name)
 								assert.Equal(t, c.addedConfigProps[a.Name], a.Value)
 							case kadm.DeleteConfig:
 								assert.Equal(t, c.deletedConfigProps[a.Name], a.Value)
@@ -236,25 +406,811 @@ func TestCmdUpdate(t *testing.T) {
 				userManager.EXPECT().CreateUser(ctx, shortStackID, topicName, kmsKeyID, c.old.ClusterArn, a).Return(c.createUserOutput[a.Username]...)
 			}
 
+			for u, oldArn := range c.expectedUserDiff.ChangedArns {
+				userManager.EXPECT().UpdateArn(ctx, findUser(c.new.Users, u), oldArn, kmsKeyID, shortStackID).Return(error(nil))
+			}
+
 			for u := range c.expectedUserDiff.AddedPermissions {
 				if _, ok := c.createACLsOutput[u]; !ok {
 					c.createACLsOutput[u] = []interface{}{error(nil)}
 				}
-				userManager.EXPECT().CreateACLs(ctx, topicName, u, shortStackID, c.expectedUserDiff.AddedPermissions[u])
+				userManager.EXPECT().CreateACLs(ctx, topicName, findUser(c.new.Users, u), shortStackID, c.expectedUserDiff.AddedPermissions[u])
 			}
 
 			for u := range c.expectedUserDiff.DeletedPermissions {
 				if _, ok := c.deleteACLsOutput[u]; !ok {
 					c.deleteACLsOutput[u] = []interface{}{error(nil)}
 				}
-				userManager.EXPECT().DeleteACLs(ctx, topicName, u, shortStackID, c.expectedUserDiff.DeletedPermissions[u])
+				userManager.EXPECT().DeleteACLs(ctx, topicName, findUser(c.old.Users, u), shortStackID, c.expectedUserDiff.DeletedPermissions[u])
 			}
 
 			// Act
-			err := cmdUpdate.Run(ctx, c.old, c.new, stackID)
+			_, err := cmdUpdate.Run(ctx, c.old, c.new, stackID)
 
 			// Assert
 			assert.Equal(t, c.err, err)
 		})
 	}
 }
+
+func TestCmdUpdateDryRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	// Arrange
+	ctx := context.TODO()
+	stackID := "test"
+	shortStackID := shortStackID(stackID)
+	topicName := canonicalTopicName("a", shortStackID)
+	alice := tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}
+	bob := tt.User{Username: "bob", Permissions: []tt.Permission{tt.PermissionRead}}
+	aliceRW := tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead, tt.PermissionWrite}}
+	old := &tt.TopicInfo{Name: "a", Users: []tt.User{alice, bob}}
+	new := &tt.TopicInfo{Name: "a", Users: []tt.User{aliceRW}, Config: map[string]*string{"a": aws.String("1")}, DryRun: true}
+
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+	userManager := mocks.NewMockUserManagerService(ctrl)
+	cmdUpdate := newCmdUpdate(kmsKeyResolver, userManager, kafkaClient, mocks.NewMockTopicMarkerService(ctrl), false, testSettings(), logger)
+
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: kadm.TopicDetail{Topic: topicName, Partitions: kadm.PartitionDetails{}}}, error(nil))
+	kafkaClient.EXPECT().DescribeTopicConfigs(ctx, topicName).Return(kadm.ResourceConfigs{kadm.ResourceConfig{Name: topicName}}, error(nil))
+	kmsKeyResolver.EXPECT().Resolve(ctx, new).Return("", error(nil))
+
+	// Act
+	result, err := cmdUpdate.Run(ctx, old, new, stackID)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, &changePlan{
+		TopicName:          topicName,
+		ConfigChanges:      []plannedConfigChange{{Op: "SET", Name: "a", Value: aws.String("1")}},
+		DeletedUsers:       []string{"bob"},
+		AddedPermissions:   map[string][]tt.Permission{"alice": {tt.PermissionWrite}},
+		DeletedPermissions: map[string][]tt.Permission{},
+	}, result.Plan)
+}
+
+func TestCmdUpdateAfterDryRunCreate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	ctx := context.TODO()
+	stackID := "test"
+	shortStackID := shortStackID(stackID)
+	topicName := canonicalTopicName("a", shortStackID)
+	users := []tt.User{{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}}
+	old := &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 1, ClusterArn: "cluster", Users: users, DryRun: true}
+	new := &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 1, ClusterArn: "cluster", Users: users}
+
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+	userManager := mocks.NewMockUserManagerService(ctrl)
+	topicMarkers := mocks.NewMockTopicMarkerService(ctrl)
+	cmdUpdate := newCmdUpdate(kmsKeyResolver, userManager, kafkaClient, topicMarkers, false, testSettings(), zap.NewNop())
+
+	// The dry run did not create the topic, therefore it is created now.
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: {Topic: topicName, Err: kerr.UnknownTopicOrPartition}}, error(nil)).Times(2)
+	kmsKeyResolver.EXPECT().Resolve(ctx, new).Return("key", error(nil))
+	kafkaClient.EXPECT().ListBrokers(ctx).Return(kadm.BrokerDetails{{NodeID: 1}}, error(nil))
+	topicMarkers.EXPECT().Put(ctx, "cluster", topicName, &tt.TopicMarker{StackID: stackID}).Return(error(nil))
+	kafkaClient.EXPECT().CreateTopic(ctx, int32(1), int16(1), gomock.Any(), gomock.Any(), topicName).Return(kadm.CreateTopicResponse{Topic: topicName}, error(nil))
+	userManager.EXPECT().CreateUser(gomock.Any(), shortStackID, topicName, "key", "cluster", &users[0]).Return(error(nil))
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{}, error(nil))
+	userManager.EXPECT().SecretArns(ctx, users, shortStackID).Return(map[string]string{"alice": "secret"}, error(nil))
+
+	// Act
+	result, err := cmdUpdate.Run(ctx, old, new, stackID)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, topicName, result.TopicName)
+	assert.Equal(t, physicalResourceID("cluster", topicName), result.PhysicalResourceID)
+	assert.Equal(t, map[string]string{"alice": "secret"}, result.SecretArns)
+	assert.Equal(t, "key", result.KmsKeyArn)
+}
+
+func TestCmdUpdateAfterDryRunUpdate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	ctx := context.TODO()
+	stackID := "test"
+	shortStackID := shortStackID(stackID)
+	topicName := canonicalTopicName("a", shortStackID)
+	alice := tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}
+	bob := tt.User{Username: "bob", Permissions: []tt.Permission{tt.PermissionRead}}
+	carol := tt.User{Username: "carol", Permissions: []tt.Permission{tt.PermissionRead}}
+	// The dry run planned to add bob. CloudFormation recorded its
+	// properties although bob was not created.
+	old := &tt.TopicInfo{Name: "a", Users: []tt.User{alice, bob}, DryRun: true}
+	new := &tt.TopicInfo{Name: "a", Users: []tt.User{alice, bob}}
+
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+	userManager := mocks.NewMockUserManagerService(ctrl)
+	cmdUpdate := newCmdUpdate(kmsKeyResolver, userManager, kafkaClient, mocks.NewMockTopicMarkerService(ctrl), false, testSettings(), zap.NewNop())
+
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: kadm.TopicDetail{Topic: topicName, Partitions: kadm.PartitionDetails{}}}, error(nil)).Times(2)
+	// Only alice was provisioned before the dry run.
+	userManager.EXPECT().ProvisionedUsers(ctx, []tt.User{alice, bob}, shortStackID).Return([]tt.User{alice}, error(nil))
+	kafkaClient.EXPECT().DescribeTopicConfigs(ctx, topicName).Return(kadm.ResourceConfigs{kadm.ResourceConfig{Name: topicName}}, error(nil))
+	kmsKeyResolver.EXPECT().Resolve(ctx, new).Return("", error(nil))
+	userManager.EXPECT().CreateUser(ctx, shortStackID, topicName, "", "", &new.Users[1]).Return(error(nil))
+	userManager.EXPECT().ReconcileACLs(ctx, topicName, &new.Users[0], shortStackID).Return(error(nil))
+	userManager.EXPECT().ReconcileGrants(ctx, &new.Users[0], "", shortStackID).Return(error(nil))
+	kafkaClient.EXPECT().DescribeACLs(ctx, gomock.Any()).Return(kadm.DescribeACLsResults{}, error(nil))
+	userManager.EXPECT().SecretArns(ctx, new.Users, shortStackID).Return(map[string]string{}, error(nil))
+
+	// Act
+	_, err := cmdUpdate.Run(ctx, old, new, stackID)
+	assert.Nil(t, err)
+
+	// Users no longer declared are deleted when they were provisioned.
+	old.Users = []tt.User{alice, carol}
+	new.Users = []tt.User{alice}
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: kadm.TopicDetail{Topic: topicName, Partitions: kadm.PartitionDetails{}}}, error(nil)).Times(2)
+	userManager.EXPECT().ProvisionedUsers(ctx, []tt.User{alice, carol}, shortStackID).Return([]tt.User{alice, carol}, error(nil))
+	kafkaClient.EXPECT().DescribeTopicConfigs(ctx, topicName).Return(kadm.ResourceConfigs{kadm.ResourceConfig{Name: topicName}}, error(nil))
+	kmsKeyResolver.EXPECT().Resolve(ctx, new).Return("", error(nil))
+	userManager.EXPECT().DeleteUser(ctx, &carol, "", topicName, shortStackID, "").Return(error(nil))
+	userManager.EXPECT().ReconcileACLs(ctx, topicName, &new.Users[0], shortStackID).Return(error(nil))
+	userManager.EXPECT().ReconcileGrants(ctx, &new.Users[0], "", shortStackID).Return(error(nil))
+	kafkaClient.EXPECT().DescribeACLs(ctx, gomock.Any()).Return(kadm.DescribeACLsResults{}, error(nil))
+	userManager.EXPECT().SecretArns(ctx, new.Users, shortStackID).Return(map[string]string{}, error(nil))
+	_, err = cmdUpdate.Run(ctx, old, new, stackID)
+
+	// Assert
+	assert.Nil(t, err)
+}
+
+func TestCmdUpdateReconcileACLs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	// Arrange
+	ctx := context.TODO()
+	stackID := "test"
+	shortStackID := shortStackID(stackID)
+	topicName := canonicalTopicName("a", shortStackID)
+	alice := tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}
+	old := &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 2, Users: []tt.User{alice}}
+	new := &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 2, Users: []tt.User{alice}, DeletionPolicy: tt.DeletionPolicyDelete}
+	alicePrincipal := "User:" + defaultSecretNameTemplate.Name("alice", shortStackID)
+	ghostPrincipal := "User:" + defaultSecretNameTemplate.Name("ghost", shortStackID)
+	otherPrincipal := "User:" + defaultSecretNameTemplate.Name("ghost", "other")
+
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+	userManager := mocks.NewMockUserManagerService(ctrl)
+	cmdUpdate := newCmdUpdate(kmsKeyResolver, userManager, kafkaClient, mocks.NewMockTopicMarkerService(ctrl), false, testSettings(), logger)
+
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: kadm.TopicDetail{Topic: topicName, Partitions: kadm.PartitionDetails{0: {Partition: 0, Replicas: []int32{1, 2}}}}}, error(nil))
+	kafkaClient.EXPECT().DescribeTopicConfigs(ctx, topicName).Return(kadm.ResourceConfigs{kadm.ResourceConfig{Name: topicName}}, error(nil))
+	kmsKeyResolver.EXPECT().Resolve(ctx, new).Return("", error(nil))
+	kafkaClient.EXPECT().DescribeACLs(ctx, gomock.Any()).Return(kadm.DescribeACLsResults{
+		kadm.DescribeACLsResult{Described: kadm.DescribedACLs{
+			{Name: topicName, Principal: alicePrincipal},
+			{Name: topicName, Principal: ghostPrincipal},
+			{Name: topicName, Principal: ghostPrincipal},
+			{Name: topicName, Principal: otherPrincipal},
+		}},
+	}, error(nil))
+	kafkaClient.EXPECT().DeleteACLs(ctx, kadm.NewACLs().Topics(topicName).ResourcePatternType(kadm.ACLPatternLiteral).Allow(ghostPrincipal).AllowHosts().Operations()).Return(kadm.DeleteACLsResults{}, error(nil))
+	userManager.EXPECT().ReconcileACLs(ctx, topicName, &new.Users[0], shortStackID).Return(error(nil))
+	userManager.EXPECT().ReconcileGrants(ctx, &new.Users[0], "", shortStackID).Return(error(nil))
+	userManager.EXPECT().SecretArns(ctx, new.Users, shortStackID).Return(map[string]string{"alice": "secret-alice"}, error(nil))
+
+	// Act
+	result, err := cmdUpdate.Run(ctx, old, new, stackID)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "0:1,2", result.PartitionAssignment)
+	assert.Equal(t, topicName, result.TopicName)
+	assert.Equal(t, map[string]string{"alice": "secret-alice"}, result.SecretArns)
+}
+
+func TestCmdUpdateReconcileUserGrants(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	ctx := context.TODO()
+	shortStackID := shortStackID("test")
+	users := []tt.User{
+		{Username: "alice", Arn: "arn:aws:iam::123456789012:role/alice", Permissions: []tt.Permission{tt.PermissionRead}},
+		{Username: "bob", Arn: "arn:aws:iam::123456789012:role/bob-v2", Permissions: []tt.Permission{tt.PermissionRead}},
+		{Username: "carol", Arn: "arn:aws:iam::123456789012:role/carol", Permissions: []tt.Permission{tt.PermissionRead}},
+	}
+	udiff := newUserDiff(withChangedArn("bob", "arn:aws:iam::123456789012:role/bob"))
+	udiff.AddedUsers = append(udiff.AddedUsers, &users[2])
+	userManager := mocks.NewMockUserManagerService(ctrl)
+	cmdUpdate := newCmdUpdate(nil, userManager, nil, nil, false, testSettings(), zap.NewNop())
+
+	// Only alice is retained unchanged. Bob and carol were granted
+	// access by UpdateArn and CreateUser.
+	userManager.EXPECT().ReconcileGrants(ctx, &users[0], "key", shortStackID).Return(error(nil))
+
+	// Act
+	err := cmdUpdate.reconcileUserGrants(ctx, "key", shortStackID, users, udiff)
+
+	// Assert
+	assert.Nil(t, err)
+}
+
+func TestCmdUpdateReplaceOnNameChange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	ctx := context.TODO()
+	stackID := "test"
+	shortStackID := shortStackID(stackID)
+	oldTopicName := canonicalTopicName("a", shortStackID)
+	topicName := canonicalTopicName("b", shortStackID)
+	users := []tt.User{{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}}
+	old := &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 1, ClusterArn: "cluster", Users: users}
+	new := &tt.TopicInfo{Name: "b", Partitions: 1, ReplicationFactor: 1, ClusterArn: "cluster", Users: users, ReplaceOnNameChange: true}
+
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+	userManager := mocks.NewMockUserManagerService(ctrl)
+	topicMarkers := mocks.NewMockTopicMarkerService(ctrl)
+	cmdUpdate := newCmdUpdate(kmsKeyResolver, userManager, kafkaClient, topicMarkers, false, testSettings(), zap.NewNop())
+
+	// The topic is created without users, which keep their secrets.
+	kmsKeyResolver.EXPECT().Resolve(ctx, gomock.Any()).Return("key", error(nil))
+	kafkaClient.EXPECT().ListBrokers(ctx).Return(kadm.BrokerDetails{{NodeID: 1}}, error(nil))
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: {Topic: topicName, Err: kerr.UnknownTopicOrPartition}}, error(nil))
+	topicMarkers.EXPECT().Put(ctx, "cluster", topicName, &tt.TopicMarker{StackID: stackID}).Return(error(nil))
+	kafkaClient.EXPECT().CreateTopic(ctx, int32(1), int16(1), gomock.Any(), gomock.Any(), topicName).Return(kadm.CreateTopicResponse{Topic: topicName}, error(nil))
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{}, error(nil))
+	topicMarkers.EXPECT().Put(ctx, "cluster", topicName, &tt.TopicMarker{StackID: stackID, LinkedTopic: oldTopicName}).Return(error(nil))
+	topicMarkers.EXPECT().Put(ctx, "cluster", oldTopicName, &tt.TopicMarker{StackID: stackID, LinkedTopic: topicName}).Return(error(nil))
+	userManager.EXPECT().CreateACLs(ctx, topicName, &users[0], shortStackID, users[0].Permissions).Return(error(nil))
+	userManager.EXPECT().SecretArns(ctx, users, shortStackID).Return(map[string]string{"alice": "secret"}, error(nil))
+	kmsKeyResolver.EXPECT().Resolve(ctx, new).Return("key", error(nil))
+
+	// Act
+	result, err := cmdUpdate.Run(ctx, old, new, stackID)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, topicName, result.TopicName)
+	assert.Equal(t, physicalResourceID("cluster", topicName), result.PhysicalResourceID)
+	assert.Equal(t, map[string]string{"alice": "secret"}, result.SecretArns)
+	assert.Equal(t, "key", result.KmsKeyArn)
+
+	// Users cannot change along with the name.
+	changed := *new
+	changed.Users = []tt.User{{Username: "bob"}}
+	_, err = cmdUpdate.Run(ctx, old, &changed, stackID)
+	assert.EqualError(t, err, "Cannot update Users along with Name")
+}
+
+func TestCmdUpdateUserDeleteDelay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	// Arrange
+	ctx := context.TODO()
+	stackID := "test"
+	shortStackID := shortStackID(stackID)
+	topicName := canonicalTopicName("a", shortStackID)
+	old := &tt.TopicInfo{Name: "a", Users: []tt.User{{Username: "alice", Arn: "arn1", Permissions: []tt.Permission{tt.PermissionRead}}}}
+	new := &tt.TopicInfo{Name: "a", Users: []tt.User{{Username: "alice", Arn: "arn1", TopicPrefix: "orders.", Permissions: []tt.Permission{tt.PermissionRead}}}}
+
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+	userManager := mocks.NewMockUserManagerService(ctrl)
+	delays := 0
+	cmdUpdate := newCmdUpdate(kmsKeyResolver, userManager, kafkaClient, mocks.NewMockTopicMarkerService(ctrl), false, testSettings(), logger)
+	cmdUpdate.userDeleteDelay = func() { delays++ }
+
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: kadm.TopicDetail{Topic: topicName, Partitions: kadm.PartitionDetails{}}}, error(nil))
+	kafkaClient.EXPECT().DescribeTopicConfigs(ctx, topicName).Return(kadm.ResourceConfigs{kadm.ResourceConfig{Name: topicName}}, error(nil))
+	kafkaClient.EXPECT().DescribeACLs(ctx, gomock.Any()).Return(kadm.DescribeACLsResults{}, error(nil))
+	kmsKeyResolver.EXPECT().Resolve(ctx, new).Return("", error(nil))
+	userManager.EXPECT().DeleteUser(ctx, &old.Users[0], "", topicName, shortStackID, "").DoAndReturn(
+		func(context.Context, *tt.User, string, string, string, string) error {
+			assert.Equal(t, 0, delays)
+			return nil
+		})
+	userManager.EXPECT().CreateUser(ctx, shortStackID, topicName, "", "", &new.Users[0]).DoAndReturn(
+		func(context.Context, string, string, string, string, *tt.User) error {
+			assert.Equal(t, 1, delays)
+			return nil
+		})
+	userManager.EXPECT().SecretArns(ctx, new.Users, shortStackID).Return(map[string]string{}, error(nil))
+
+	// Act
+	_, err = cmdUpdate.Run(ctx, old, new, stackID)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, 1, delays)
+}
+
+func TestCmdUpdateMultipleClustersRecreatedUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	// Arrange
+	ctx := context.TODO()
+	stackID := "test"
+	shortStackID := shortStackID(stackID)
+	topicName := canonicalTopicName("a", shortStackID)
+	clusters := []string{"cluster-a", "cluster-b"}
+	old := &tt.TopicInfo{Name: "a", ClusterArn: "cluster-b", ClusterArns: clusters, Users: []tt.User{
+		{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}},
+		{Username: "bob", Permissions: []tt.Permission{tt.PermissionRead}},
+	}}
+	new := &tt.TopicInfo{Name: "a", ClusterArn: "cluster-b", ClusterArns: clusters, Users: []tt.User{
+		{Username: "alice", TopicPrefix: "orders.", Permissions: []tt.Permission{tt.PermissionRead}},
+	}}
+
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+	userManager := mocks.NewMockUserManagerService(ctrl)
+	cmdUpdate := newCmdUpdate(kmsKeyResolver, userManager, kafkaClient, mocks.NewMockTopicMarkerService(ctrl), false, testSettings(), logger)
+
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: kadm.TopicDetail{Topic: topicName, Partitions: kadm.PartitionDetails{}}}, error(nil))
+	kafkaClient.EXPECT().DescribeTopicConfigs(ctx, topicName).Return(kadm.ResourceConfigs{kadm.ResourceConfig{Name: topicName}}, error(nil))
+	kafkaClient.EXPECT().DescribeACLs(ctx, gomock.Any()).Return(kadm.DescribeACLsResults{}, error(nil))
+	kmsKeyResolver.EXPECT().Resolve(ctx, new).Return("", error(nil))
+	// Other clusters keep using the secret of the recreated user. The
+	// secret of the removed user is deleted.
+	userManager.EXPECT().DeleteUser(gomock.Any(), &old.Users[0], "", topicName, shortStackID, "cluster-b").DoAndReturn(
+		func(ctx context.Context, _ *tt.User, _, _, _, _ string) error {
+			assert.True(t, retainedSecretsFrom(ctx).Retains(defaultSecretNameTemplate.Name("alice", shortStackID)))
+			assert.False(t, retainedSecretsFrom(ctx).Retains(defaultSecretNameTemplate.Name("bob", shortStackID)))
+			return nil
+		})
+	userManager.EXPECT().DeleteUser(gomock.Any(), &old.Users[1], "", topicName, shortStackID, "cluster-b").Return(nil)
+	userManager.EXPECT().CreateUser(gomock.Any(), shortStackID, topicName, "", "cluster-b", &new.Users[0]).Return(nil)
+	userManager.EXPECT().SecretArns(ctx, new.Users, shortStackID).Return(map[string]string{}, error(nil))
+
+	// Act
+	_, err = cmdUpdate.Run(ctx, old, new, stackID)
+
+	// Assert
+	assert.Nil(t, err)
+}
+
+func TestCmdUpdateCleanupOrphanedSecrets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	// Arrange
+	ctx := context.TODO()
+	stackID := "test"
+	shortStackID := shortStackID(stackID)
+	topicName := canonicalTopicName("a", shortStackID)
+	old := &tt.TopicInfo{Name: "a", ClusterArn: "cluster"}
+	new := &tt.TopicInfo{Name: "a", ClusterArn: "cluster", CleanupOrphanedSecrets: true}
+
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+	userManager := mocks.NewMockUserManagerService(ctrl)
+	cmdUpdate := newCmdUpdate(kmsKeyResolver, userManager, kafkaClient, mocks.NewMockTopicMarkerService(ctrl), false, testSettings(), logger)
+
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: kadm.TopicDetail{Topic: topicName, Partitions: kadm.PartitionDetails{}}}, error(nil))
+	kafkaClient.EXPECT().DescribeTopicConfigs(ctx, topicName).Return(kadm.ResourceConfigs{kadm.ResourceConfig{Name: topicName}}, error(nil))
+	kafkaClient.EXPECT().DescribeACLs(ctx, gomock.Any()).Return(kadm.DescribeACLsResults{}, error(nil))
+	kmsKeyResolver.EXPECT().Resolve(ctx, new).Return("", error(nil))
+	userManager.EXPECT().CleanupOrphanedSecrets(ctx, new.Users, shortStackID, "cluster").Return(nil)
+
+	// Act
+	_, err = cmdUpdate.Run(ctx, old, new, stackID)
+
+	// Assert
+	assert.Nil(t, err)
+}
+
+func TestCmdUpdateReadOnlyConfig(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	// Arrange
+	ctx := context.TODO()
+	stackID := "test"
+	shortStackID := shortStackID(stackID)
+	topicName := canonicalTopicName("a", shortStackID)
+	old := &tt.TopicInfo{Name: "a", Config: map[string]*string{"remote.storage.enable": aws.String("false")}}
+	new := &tt.TopicInfo{Name: "a", Config: map[string]*string{"remote.storage.enable": aws.String("true")}}
+
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+	userManager := mocks.NewMockUserManagerService(ctrl)
+	cmdUpdate := newCmdUpdate(kmsKeyResolver, userManager, kafkaClient, mocks.NewMockTopicMarkerService(ctrl), false, testSettings(), logger)
+
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: kadm.TopicDetail{Topic: topicName, Partitions: kadm.PartitionDetails{}}}, error(nil))
+	kafkaClient.EXPECT().DescribeTopicConfigs(ctx, topicName).Return(kadm.ResourceConfigs{kadm.ResourceConfig{Name: topicName, Configs: []kadm.Config{{Key: "remote.storage.enable", Value: aws.String("false")}}}}, error(nil))
+	kmsKeyResolver.EXPECT().Resolve(ctx, new).Return("", error(nil))
+
+	// Act
+	_, err = cmdUpdate.Run(ctx, old, new, stackID)
+
+	// Assert
+	assert.EqualError(t, err, "Config remote.storage.enable is read-only in MSK. It can only be set when the topic is created.")
+	assert.Equal(t, "TR006: Config remote.storage.enable is read-only in MSK. It can only be set when the topic is created.", describeError(err))
+}
+
+func TestCmdUpdateDiffConfigAddedKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type testCase struct {
+		key      string
+		value    string
+		expected kadm.IncrementalOp
+	}
+
+	cases := map[string]testCase{
+		"Scalar config is set":    {key: "retention.ms", value: "3600000", expected: kadm.SetConfig},
+		"List config is appended": {key: "leader.replication.throttled.replicas", value: "0:1", expected: kadm.AppendConfig},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			// Arrange
+			ctx := context.TODO()
+			kafkaClient := mocks.NewMockKafkaClient(ctrl)
+			cmdUpdate := newCmdUpdate(mocks.NewMockKmsKeyResolverService(ctrl), mocks.NewMockUserManagerService(ctrl), kafkaClient, mocks.NewMockTopicMarkerService(ctrl), false, testSettings(), zap.NewNop())
+			kafkaClient.EXPECT().DescribeTopicConfigs(ctx, "a").Return(kadm.ResourceConfigs{kadm.ResourceConfig{Name: "a"}}, error(nil))
+
+			// Act
+			updates, _, err := cmdUpdate.diffConfig(ctx, "a", map[string]*string{c.key: aws.String(c.value)}, nil, false)
+
+			// Assert
+			assert.Nil(t, err)
+			assert.Equal(t, []kadm.AlterConfig{{Op: c.expected, Name: c.key, Value: aws.String(c.value)}}, updates)
+		})
+	}
+}
+
+func TestCmdUpdateEnforcedConfig(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	// Arrange
+	ctx := context.TODO()
+	stackID := "test"
+	topicName := canonicalTopicName("a", shortStackID(stackID))
+	old := &tt.TopicInfo{Name: "a", Config: map[string]*string{"retention.ms": aws.String("1000")}}
+	new := &tt.TopicInfo{Name: "a", Config: map[string]*string{"retention.ms": aws.String("2000"), "min.insync.replicas": aws.String("1")}, DryRun: true}
+	settings := testSettings()
+	settings.EnforcedTopicConfig = map[string]string{"min.insync.replicas": "2"}
+
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+	userManager := mocks.NewMockUserManagerService(ctrl)
+	cmdUpdate := newCmdUpdate(kmsKeyResolver, userManager, kafkaClient, mocks.NewMockTopicMarkerService(ctrl), false, settings, logger)
+
+	// The enforced config was changed outside CloudFormation.
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: kadm.TopicDetail{Topic: topicName, Partitions: kadm.PartitionDetails{}}}, error(nil))
+	kafkaClient.EXPECT().DescribeTopicConfigs(ctx, topicName).Return(kadm.ResourceConfigs{kadm.ResourceConfig{Name: topicName, Configs: []kadm.Config{
+		{Key: "retention.ms", Value: aws.String("1000")},
+		{Key: "min.insync.replicas", Value: aws.String("1")},
+	}}}, error(nil))
+	kmsKeyResolver.EXPECT().Resolve(ctx, new).Return("", error(nil))
+
+	// Act
+	result, err := cmdUpdate.Run(ctx, old, new, stackID)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []plannedConfigChange{
+		{Op: "SET", Name: "retention.ms", Value: aws.String("2000")},
+		{Op: "SET", Name: "min.insync.replicas", Value: aws.String("2")},
+	}, result.Plan.ConfigChanges)
+}
+
+func TestCmdUpdateRestoreConfig(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type testCase struct {
+		name       string
+		source     kmsg.ConfigSource
+		restore    kadm.AlterConfig
+		restoreErr error
+	}
+
+	cases := []testCase{
+		{
+			name:    "Topic config restored",
+			source:  kmsg.ConfigSourceDynamicTopicConfig,
+			restore: kadm.AlterConfig{Op: kadm.SetConfig, Name: "retention.ms", Value: aws.String("1000")},
+		},
+		{
+			name:    "Default config reset",
+			source:  kmsg.ConfigSourceDefaultConfig,
+			restore: kadm.AlterConfig{Op: kadm.DeleteConfig, Name: "retention.ms"},
+		},
+		{
+			name:       "Restore fails",
+			source:     kmsg.ConfigSourceDynamicTopicConfig,
+			restore:    kadm.AlterConfig{Op: kadm.SetConfig, Name: "retention.ms", Value: aws.String("1000")},
+			restoreErr: errors.New("timed out"),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			ctx := context.TODO()
+			stackID := "test"
+			shortStackID := shortStackID(stackID)
+			topicName := canonicalTopicName("a", shortStackID)
+			bob := tt.User{Username: "bob", Permissions: []tt.Permission{tt.PermissionRead}}
+			old := &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 1, ClusterArn: "cluster", Config: map[string]*string{"retention.ms": aws.String("1000")}}
+			new := &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 1, ClusterArn: "cluster", Config: map[string]*string{"retention.ms": aws.String("2000")}, Users: []tt.User{bob}}
+			createErr := errors.New("denied")
+
+			kafkaClient := mocks.NewMockKafkaClient(ctrl)
+			kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+			userManager := mocks.NewMockUserManagerService(ctrl)
+			cmdUpdate := newCmdUpdate(kmsKeyResolver, userManager, kafkaClient, mocks.NewMockTopicMarkerService(ctrl), false, testSettings(), zap.NewNop())
+
+			kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: {Topic: topicName, Partitions: kadm.PartitionDetails{0: {Partition: 0, Replicas: []int32{1}}}}}, error(nil))
+			kmsKeyResolver.EXPECT().Resolve(ctx, new).Return("key", error(nil))
+			kafkaClient.EXPECT().DescribeTopicConfigs(ctx, topicName).Return(kadm.ResourceConfigs{kadm.ResourceConfig{Name: topicName, Configs: []kadm.Config{
+				{Key: "retention.ms", Value: aws.String("1000"), Source: c.source},
+			}}}, error(nil))
+			gomock.InOrder(
+				kafkaClient.EXPECT().AlterTopicConfigs(ctx, []kadm.AlterConfig{{Op: kadm.SetConfig, Name: "retention.ms", Value: aws.String("2000")}}, topicName).
+					Return(kadm.AlterConfigsResponses{kadm.AlterConfigsResponse{Name: topicName}}, error(nil)),
+				userManager.EXPECT().CreateUser(ctx, shortStackID, topicName, "key", "cluster", &new.Users[0]).Return(createErr),
+				kafkaClient.EXPECT().AlterTopicConfigs(ctx, []kadm.AlterConfig{c.restore}, topicName).
+					Return(kadm.AlterConfigsResponses{kadm.AlterConfigsResponse{Name: topicName, Err: c.restoreErr}}, error(nil)),
+			)
+
+			// Act
+			_, err := cmdUpdate.Run(ctx, old, new, stackID)
+
+			// Assert
+			assert.ErrorIs(t, err, createErr)
+		})
+	}
+}
+
+func TestCmdUpdateStrictExistence(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	// Arrange
+	stackID := "test"
+	shortStackID := shortStackID(stackID)
+	topicName := canonicalTopicName("a", shortStackID)
+	policy := newExistencePolicy(true, "request")
+	ctx := withExistencePolicy(context.TODO(), policy)
+	old := &tt.TopicInfo{Name: "a", ClusterArn: "cluster", StrictExistence: true}
+	new := &tt.TopicInfo{Name: "a", ClusterArn: "cluster", StrictExistence: true, Users: []tt.User{{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}}}
+
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+	userManager := mocks.NewMockUserManagerService(ctrl)
+	topicMarkers := mocks.NewMockTopicMarkerService(ctrl)
+	cmdUpdate := newCmdUpdate(kmsKeyResolver, userManager, kafkaClient, topicMarkers, false, testSettings(), logger)
+
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: kadm.TopicDetail{Topic: topicName, Partitions: kadm.PartitionDetails{}}}, error(nil))
+	kafkaClient.EXPECT().DescribeTopicConfigs(ctx, topicName).Return(kadm.ResourceConfigs{kadm.ResourceConfig{Name: topicName}}, error(nil))
+	kmsKeyResolver.EXPECT().Resolve(ctx, new).Return("", error(nil))
+	// A previous attempt of this request recorded its ID before it failed.
+	topicMarkers.EXPECT().Get(ctx, "cluster", topicName).Return(&tt.TopicMarker{StackID: stackID, RequestID: "request"}, error(nil))
+	topicMarkers.EXPECT().Put(ctx, "cluster", topicName, &tt.TopicMarker{StackID: stackID, RequestID: "request"}).Return(error(nil))
+	userManager.EXPECT().CreateUser(ctx, shortStackID, topicName, "", "cluster", &new.Users[0]).DoAndReturn(func(ctx context.Context, _, _, _, _ string, _ *tt.User) error {
+		return existencePolicyFrom(ctx).Adopt("secret", "alice")
+	})
+	kafkaClient.EXPECT().DescribeACLs(ctx, gomock.Any()).Return(kadm.DescribeACLsResults{}, error(nil))
+	userManager.EXPECT().SecretArns(ctx, new.Users, shortStackID).Return(map[string]string{}, error(nil))
+
+	// Act
+	_, err = cmdUpdate.Run(ctx, old, new, stackID)
+
+	// Assert
+	assert.Nil(t, err)
+}
+
+func TestCmdUpdateTopicDeleted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	stackID := "test"
+	topicName := canonicalTopicName("a", shortStackID(stackID))
+	cases := map[string]struct {
+		configs  kadm.ResourceConfigs
+		expected string
+	}{
+		"Unknown topic": {
+			configs:  kadm.ResourceConfigs{{Name: topicName, Err: kerr.UnknownTopicOrPartition}},
+			expected: "topic " + topicName + " no longer exists in the cluster, it may have been deleted outside CloudFormation",
+		},
+		"Other error": {
+			configs:  kadm.ResourceConfigs{{Name: topicName, Err: kerr.TopicAuthorizationFailed}},
+			expected: kerr.TopicAuthorizationFailed.Error(),
+		},
+		"Topic not described": {
+			configs:  kadm.ResourceConfigs{{Name: "other", Configs: []kadm.Config{{Key: "retention.ms", Value: aws.String("1")}}}},
+			expected: "configs of topic " + topicName + " were not described",
+		},
+	}
+
+	for k, c := range cases {
+		// Arrange
+		ctx := context.TODO()
+		old := &tt.TopicInfo{Name: "a", Config: map[string]*string{"retention.ms": aws.String("1")}}
+		new := &tt.TopicInfo{Name: "a", Config: map[string]*string{"retention.ms": aws.String("2")}}
+
+		kafkaClient := mocks.NewMockKafkaClient(ctrl)
+		kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+		cmdUpdate := newCmdUpdate(kmsKeyResolver, mocks.NewMockUserManagerService(ctrl), kafkaClient, mocks.NewMockTopicMarkerService(ctrl), false, testSettings(), logger)
+
+		// The topic is deleted after it was listed.
+		kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: kadm.TopicDetail{Topic: topicName, Partitions: kadm.PartitionDetails{}}}, error(nil))
+		kafkaClient.EXPECT().DescribeTopicConfigs(ctx, topicName).Return(c.configs, error(nil))
+		kmsKeyResolver.EXPECT().Resolve(ctx, new).Return("", error(nil))
+
+		// Act
+		_, err = cmdUpdate.Run(ctx, old, new, stackID)
+
+		// Assert
+		assert.EqualError(t, err, c.expected, k)
+	}
+}
+
+func TestCmdUpdateNoChanges(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	type testCase struct {
+		name         string
+		dryRun       bool
+		expectedPlan *changePlan
+	}
+
+	stackID := "test"
+	topicName := canonicalTopicName("a", shortStackID(stackID))
+	cases := []testCase{
+		{
+			name: "Identical properties",
+		},
+		{
+			name:   "Identical properties in dry run",
+			dryRun: true,
+			expectedPlan: &changePlan{
+				TopicName:          topicName,
+				AddedPermissions:   map[string][]tt.Permission{},
+				DeletedPermissions: map[string][]tt.Permission{},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			ctx := context.TODO()
+			info := func() *tt.TopicInfo {
+				return &tt.TopicInfo{
+					Name:       "a",
+					Partitions: 1,
+					Users:      []tt.User{{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}},
+					Config:     map[string]*string{"retention.ms": aws.String("1000")},
+					Tags:       map[string]string{"team": "payments"},
+					DryRun:     c.dryRun,
+				}
+			}
+			// Mocks fail the test on any call other than those reading
+			// the attributes of the resource.
+			kafkaClient := mocks.NewMockKafkaClient(ctrl)
+			kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+			userManager := mocks.NewMockUserManagerService(ctrl)
+			topicMarkers := mocks.NewMockTopicMarkerService(ctrl)
+			cmdUpdate := newCmdUpdate(kmsKeyResolver, userManager, kafkaClient, topicMarkers, false, testSettings(), logger)
+			if !c.dryRun {
+				partitions := kadm.PartitionDetails{0: {Partition: 0, Replicas: []int32{1, 2}}}
+				kmsKeyResolver.EXPECT().Resolve(ctx, info()).Return("key", error(nil))
+				userManager.EXPECT().SecretArns(ctx, info().Users, shortStackID(stackID)).Return(map[string]string{"alice": "secret"}, error(nil))
+				kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: {Topic: topicName, Partitions: partitions}}, error(nil))
+			}
+
+			// Act
+			result, err := cmdUpdate.Run(ctx, info(), info(), stackID)
+
+			// Assert
+			assert.Nil(t, err)
+			assert.True(t, result.NoChanges)
+			assert.Equal(t, c.expectedPlan, result.Plan)
+			if !c.dryRun {
+				assert.Equal(t, "key", result.KmsKeyArn)
+				assert.Equal(t, map[string]string{"alice": "secret"}, result.SecretArns)
+				assert.Equal(t, "0:1,2", result.PartitionAssignment)
+			}
+		})
+	}
+}
+
+func TestCmdUpdateLabels(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	// Arrange
+	ctx := context.TODO()
+	stackID := "test"
+	shortStackID := shortStackID(stackID)
+	topicName := canonicalTopicName("a", shortStackID)
+	old := &tt.TopicInfo{Name: "a", ClusterArn: "cluster", Tags: map[string]string{"team": "payments"}, Labels: map[string]string{"owner": "alice"}}
+	new := &tt.TopicInfo{Name: "a", ClusterArn: "cluster", Tags: map[string]string{"team": "payments"}, Labels: map[string]string{"owner": "bob"}}
+
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+	topicMarkers := mocks.NewMockTopicMarkerService(ctrl)
+	cmdUpdate := newCmdUpdate(kmsKeyResolver, mocks.NewMockUserManagerService(ctrl), kafkaClient, topicMarkers, false, testSettings(), logger)
+
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: kadm.TopicDetail{Topic: topicName, Partitions: kadm.PartitionDetails{}}}, error(nil))
+	kafkaClient.EXPECT().DescribeTopicConfigs(ctx, topicName).Return(kadm.ResourceConfigs{kadm.ResourceConfig{Name: topicName}}, error(nil))
+	kafkaClient.EXPECT().DescribeACLs(ctx, gomock.Any()).Return(kadm.DescribeACLsResults{}, error(nil))
+	kmsKeyResolver.EXPECT().Resolve(ctx, new).Return("", error(nil))
+	topicMarkers.EXPECT().Put(ctx, "cluster", topicName, &tt.TopicMarker{StackID: stackID, Tags: new.Tags, Labels: new.Labels}).Return(error(nil))
+
+	// Act
+	_, err = cmdUpdate.Run(ctx, old, new, stackID)
+
+	// Assert
+	assert.Nil(t, err)
+}
+
+func TestAssignmentChanged(t *testing.T) {
+	assert.False(t, assignmentChanged(nil, tt.ReplicaAssignment{}))
+	assert.False(t, assignmentChanged(tt.ReplicaAssignment{0: {1, 2}}, tt.ReplicaAssignment{0: {1, 2}}))
+	assert.True(t, assignmentChanged(tt.ReplicaAssignment{0: {1, 2}}, tt.ReplicaAssignment{0: {2, 1}}))
+	assert.True(t, assignmentChanged(nil, tt.ReplicaAssignment{0: {1}}))
+}
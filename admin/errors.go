@@ -0,0 +1,127 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/pkg/errors"
+	"github.com/twmb/franz-go/pkg/kerr"
+)
+
+// Error codes reported to CloudFormation. They are documented in
+// Troubleshooting section of README so that users can look them up.
+const (
+	ErrCodeKmsKeyTagMissing     = "TR001"
+	ErrCodeIamAuthDisabled      = "TR002"
+	ErrCodeReplicationFactor    = "TR003"
+	ErrCodeTopicAlreadyExists   = "TR004"
+	ErrCodeAuthorizationFailed  = "TR005"
+	ErrCodeReadOnlyConfig       = "TR006"
+	ErrCodeConnectFailed        = "TR007"
+	ErrCodeAuthFailed           = "TR008"
+	ErrCodePrincipalNotFound    = "TR009"
+	ErrCodeResourceExists       = "TR010"
+	ErrCodeScramAuthDisabled    = "TR011"
+	ErrCodePublicAccessDisabled = "TR012"
+	ErrCodeCreateTopicTimeout   = "TR013"
+)
+
+// classifiedError is a failure with a known cause and a message telling
+// the user how to resolve it.
+type classifiedError struct {
+	code string
+	msg  string
+}
+
+func (e *classifiedError) Error() string {
+	return e.msg
+}
+
+func newClassifiedError(code, format string, args ...interface{}) error {
+	return &classifiedError{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+// clusterError is a failure in one of several clusters a topic is
+// managed in. It names the cluster and the clusters the request was
+// already applied to so that partial failures can be told apart.
+type clusterError struct {
+	clusterArn string
+	applied    []string
+	err        error
+}
+
+// Returns err as is when the topic is managed in a single cluster.
+// Otherwise names clusters[i] as the failed cluster.
+func newClusterError(err error, clusters []string, i int) error {
+	if err == nil || len(clusters) == 1 {
+		return err
+	}
+	return &clusterError{clusterArn: clusters[i], applied: clusters[:i], err: err}
+}
+
+func (e *clusterError) Error() string {
+	return fmt.Sprintf("%s: %s", e.context(), e.err)
+}
+
+func (e *clusterError) Unwrap() error {
+	return e.err
+}
+
+func (e *clusterError) context() string {
+	if len(e.applied) == 0 {
+		return fmt.Sprintf("cluster %s failed, other clusters were not changed", e.clusterArn)
+	}
+	return fmt.Sprintf("cluster %s failed after changes were applied to %s", e.clusterArn, strings.Join(e.applied, ", "))
+}
+
+// Returns a concise message for err prefixed with its error code.
+// Errors that cannot be classified are described as is.
+func describeError(err error) string {
+	var cle *clusterError
+	if errors.As(err, &cle) {
+		return fmt.Sprintf("%s: %s", cle.context(), describeError(cle.err))
+	}
+	var ce *classifiedError
+	if errors.As(err, &ce) {
+		return fmt.Sprintf("%s: %s", ce.code, ce.msg)
+	}
+	var ve *types.ValidationError
+	if errors.As(err, &ve) {
+		return describeValidationError(ve)
+	}
+	var cxe *connectError
+	if errors.As(err, &cxe) {
+		return fmt.Sprintf("%s: TR function %s. Check network connectivity between TR function and the cluster (subnets, security groups, routes).", ErrCodeConnectFailed, cxe.Error())
+	}
+	switch {
+	case errors.Is(err, kerr.SaslAuthenticationFailed):
+		return fmt.Sprintf("%s: TR function connected to the cluster but failed to authenticate. Check that IAM authentication is enabled and the IAM role of TR function is allowed kafka-cluster:Connect: %s", ErrCodeAuthFailed, err.Error())
+	case errors.Is(err, kerr.TopicAlreadyExists):
+		return fmt.Sprintf("%s: Topic already exists in the cluster.", ErrCodeTopicAlreadyExists)
+	case errors.Is(err, kerr.InvalidReplicationFactor):
+		return fmt.Sprintf("%s: ReplicationFactor is invalid or exceeds the number of brokers in the cluster.", ErrCodeReplicationFactor)
+	case errors.Is(err, kerr.TopicAuthorizationFailed), errors.Is(err, kerr.ClusterAuthorizationFailed), errors.Is(err, kerr.GroupAuthorizationFailed):
+		return fmt.Sprintf("%s: TR function is not authorized to perform this operation in the cluster. Check kafka-cluster permissions of its IAM role: %s", ErrCodeAuthorizationFailed, err.Error())
+	}
+	return err.Error()
+}
+
+// Lists each invalid property on its own line so that they can be told
+// apart in CloudFormation events.
+func describeValidationError(e *types.ValidationError) string {
+	var b strings.Builder
+	b.WriteString("Invalid properties:")
+	for _, f := range e.Fields {
+		b.WriteString("\n- ")
+		if f.Pointer != "" {
+			b.WriteString(f.Pointer + ": ")
+		}
+		b.WriteString(f.Message)
+	}
+	return b.String()
+}
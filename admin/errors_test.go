@@ -0,0 +1,85 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/franz-go/pkg/kerr"
+)
+
+func TestDescribeError(t *testing.T) {
+	cases := map[string]struct {
+		err      error
+		expected string
+	}{
+		"Classified": {
+			err:      errors.WithStack(newClassifiedError(ErrCodeReplicationFactor, "ReplicationFactor %d exceeds available brokers %d", 5, 3)),
+			expected: "TR003: ReplicationFactor 5 exceeds available brokers 3",
+		},
+		"Wrapped classified": {
+			err:      errors.WithMessage(errors.WithStack(newClassifiedError(ErrCodeKmsKeyTagMissing, "missing tag")), "context"),
+			expected: "TR001: missing tag",
+		},
+		"Classified in one of several clusters": {
+			err:      newClusterError(errors.WithStack(newClassifiedError(ErrCodeKmsKeyTagMissing, "missing tag")), []string{"a", "b", "c"}, 2),
+			expected: "cluster c failed after changes were applied to a, b: TR001: missing tag",
+		},
+		"First of several clusters": {
+			err:      newClusterError(errors.New("boom"), []string{"a", "b"}, 0),
+			expected: "cluster a failed, other clusters were not changed: boom",
+		},
+		"Single cluster": {
+			err:      newClusterError(errors.New("boom"), []string{"a"}, 0),
+			expected: "boom",
+		},
+		"Kafka topic already exists": {
+			err:      errors.WithStack(kerr.TopicAlreadyExists),
+			expected: "TR004: Topic already exists in the cluster.",
+		},
+		"Kafka invalid replication factor": {
+			err:      errors.WithStack(kerr.InvalidReplicationFactor),
+			expected: "TR003: ReplicationFactor is invalid or exceeds the number of brokers in the cluster.",
+		},
+		"Create topic timeout": {
+			err:      errors.WithStack(createTopicError(kerr.RequestTimedOut, 15*time.Second)),
+			expected: "TR013: Cluster did not create the topic within 15s. The topic may still be created. Increase TR_CREATE_TOPIC_TIMEOUT and retry.",
+		},
+		"Other create topic error": {
+			err:      errors.WithStack(createTopicError(kerr.InvalidReplicationFactor, 15*time.Second)),
+			expected: "TR003: ReplicationFactor is invalid or exceeds the number of brokers in the cluster.",
+		},
+		"Connect timeout": {
+			err:      fmt.Errorf("unable to dial: %w", &connectError{addr: "b-1:9098", timeout: 10 * time.Second, err: &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}}),
+			expected: "TR007: TR function timed out connecting to broker b-1:9098 after 10s. Check network connectivity between TR function and the cluster (subnets, security groups, routes).",
+		},
+		"Kafka authentication failed": {
+			err:      errors.WithStack(kerr.SaslAuthenticationFailed),
+			expected: "TR008: TR function connected to the cluster but failed to authenticate. Check that IAM authentication is enabled and the IAM role of TR function is allowed kafka-cluster:Connect: " + kerr.SaslAuthenticationFailed.Error(),
+		},
+		"Invalid properties": {
+			err: errors.WithStack(&types.ValidationError{Fields: []types.FieldError{
+				{Field: "(root)", Message: "Name is required"},
+				{Pointer: "/Users/0/Permissions/0", Field: "Users.0.Permissions.0", Message: "Users.0.Permissions.0 must be one of the following: \"READ\", \"WRITE\""},
+			}}),
+			expected: "Invalid properties:\n- Name is required\n- /Users/0/Permissions/0: Users.0.Permissions.0 must be one of the following: \"READ\", \"WRITE\"",
+		},
+		"Unclassified": {
+			err:      errors.New("boom"),
+			expected: "boom",
+		},
+	}
+
+	for k, c := range cases {
+		assert.Equal(t, c.expected, describeError(c.err), k)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/pkg/errors"
+)
+
+var contextKeyExistencePolicy contextKey = contextKey("ExistencePolicy")
+
+// existencePolicy decides how resources found to already exist while they
+// are being created are handled. By default they are adopted so that
+// retries are idempotent. In strict mode they are reported as collisions,
+// unless the request is a retry of the CloudFormation request that created
+// them. Retries are recognised by the request ID recorded in the topic
+// marker.
+type existencePolicy struct {
+	strict    bool
+	requestID string
+	retry     bool
+	// Resources created or kept by this request in another cluster.
+	owned map[string]bool
+}
+
+func newExistencePolicy(strict bool, requestID string) *existencePolicy {
+	return &existencePolicy{
+		strict:    strict,
+		requestID: requestID,
+		owned:     make(map[string]bool),
+	}
+}
+
+// Returns a copy of ctx carrying p.
+func withExistencePolicy(ctx context.Context, p *existencePolicy) context.Context {
+	return context.WithValue(ctx, contextKeyExistencePolicy, p)
+}
+
+// Returns the policy in ctx. The result is nil when ctx does not carry one,
+// in which case existing resources are adopted.
+func existencePolicyFrom(ctx context.Context) *existencePolicy {
+	p, _ := ctx.Value(contextKeyExistencePolicy).(*existencePolicy)
+	return p
+}
+
+func (p *existencePolicy) Strict() bool {
+	return p != nil && p.strict
+}
+
+// Returns the ID of the request recorded in topic markers.
+func (p *existencePolicy) RequestID() string {
+	if p == nil {
+		return ""
+	}
+	return p.requestID
+}
+
+// Records whether marker was written by the current request, in which case
+// the request is a retry and resources it finds are its own.
+func (p *existencePolicy) Observe(marker *types.TopicMarker) {
+	if p == nil || marker == nil || p.requestID == "" {
+		return
+	}
+	p.retry = marker.RequestID == p.requestID
+}
+
+// Records that the resource of the specified kind belongs to this request.
+// Secrets are shared by the clusters of a topic, therefore a secret created
+// or kept for one cluster is found to exist by the next.
+func (p *existencePolicy) Own(kind, name string) {
+	if p == nil {
+		return
+	}
+	p.owned[kind+"/"+name] = true
+}
+
+// Returns an error when the existing resource of the specified kind must
+// not be adopted.
+func (p *existencePolicy) Adopt(kind, name string) error {
+	if !p.Strict() || p.retry || p.owned[kind+"/"+name] {
+		return nil
+	}
+	return errors.WithStack(newClassifiedError(ErrCodeResourceExists, "%s %s already exists and was not created by this request. Remove it or disable StrictExistence to adopt it.", kind, name))
+}
+
+// Returns an error when the existing ACL of principal must not be adopted.
+// ACLs of a principal owned by this request, e.g. a user shared with
+// another resource of the stack, are expected to exist.
+func (p *existencePolicy) AdoptACL(principal, name string) error {
+	if p != nil && p.owned["principal/"+principal] {
+		return nil
+	}
+	return p.Adopt("ACL", name)
+}
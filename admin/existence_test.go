@@ -0,0 +1,43 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"testing"
+
+	tt "github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExistencePolicy(t *testing.T) {
+	// Existing resources are adopted without a policy.
+	p := existencePolicyFrom(context.TODO())
+	assert.Nil(t, p)
+	assert.False(t, p.Strict())
+	assert.Equal(t, "", p.RequestID())
+	assert.Nil(t, p.Adopt("topic", "a"))
+
+	assert.Nil(t, newExistencePolicy(false, "request").Adopt("topic", "a"))
+
+	p = newExistencePolicy(true, "request")
+	assert.Equal(t, p, existencePolicyFrom(withExistencePolicy(context.TODO(), p)))
+	assert.EqualError(t, p.Adopt("topic", "a"), "topic a already exists and was not created by this request. Remove it or disable StrictExistence to adopt it.")
+
+	// Markers written by other requests do not make a request a retry.
+	p.Observe(&tt.TopicMarker{RequestID: "other"})
+	assert.NotNil(t, p.Adopt("topic", "a"))
+	p.Observe(nil)
+	assert.NotNil(t, p.Adopt("topic", "a"))
+	p.Observe(&tt.TopicMarker{RequestID: "request"})
+	assert.Nil(t, p.Adopt("topic", "a"))
+
+	// Resources of this request in other clusters are adopted.
+	p = newExistencePolicy(true, "request")
+	p.Own("secret", "a")
+	assert.Nil(t, p.Adopt("secret", "a"))
+	assert.NotNil(t, p.Adopt("topic", "a"))
+	existencePolicyFrom(context.TODO()).Own("secret", "a")
+}
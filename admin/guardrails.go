@@ -0,0 +1,131 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/pkg/errors"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"go.uber.org/zap"
+)
+
+// guardrails reject requests that are valid as per the schema but are
+// likely to be mistakes (e.g. copy-paste errors in large templates).
+type guardrails struct {
+	maxACLOperationsPerUser int
+	maxResourceFootprint    int
+	enforcedTopicConfig     map[string]string
+}
+
+func newGuardrails(settings *Settings) *guardrails {
+	return &guardrails{
+		maxACLOperationsPerUser: settings.MaxACLOperationsPerUser,
+		maxResourceFootprint:    settings.MaxResourceFootprint,
+		enforcedTopicConfig:     settings.EnforcedTopicConfig,
+	}
+}
+
+// footprint is the number of resources a request creates in the account
+// and the cluster.
+type footprint struct {
+	topics  int
+	secrets int
+	grants  int
+	acls    int
+}
+
+func newFootprint(info *types.TopicInfo) *footprint {
+	f := &footprint{topics: 1}
+	for _, u := range info.Users {
+		if !u.Secretless() && u.SecretArn == "" {
+			f.secrets++
+		}
+		f.grants += len(u.Principals())
+		f.acls += userACLCount(&u)
+	}
+	return f
+}
+
+// Returns the number of ACLs created for u: every operation on the topic,
+// each additional topic and the groups, for each allowed host.
+func userACLCount(u *types.User) int {
+	n := 0
+	topic, pattern := topicACLResource("", u)
+	for _, spec := range userACLSpecs(topic, pattern, u.AdditionalTopics, u.Hosts(), u.Username, u.Permissions, userGroupACLs(u), u.GroupPrefix, u.DescribesTopic()) {
+		n += len(spec.Operations)
+	}
+	return n
+}
+
+// Returns the number of distinct operations u requests on each resource
+// type. Additional topics and hosts repeat the same operations, therefore
+// they only count against the footprint budget.
+func userOperations(u *types.User) int {
+	topicOps, groupOps := permissionsToOperations(u.Permissions, userGroupACLs(u), u.DescribesTopic())
+	return len(distinctOperations(topicOps)) + len(distinctOperations(groupOps))
+}
+
+func distinctOperations(ops []kadm.ACLOperation) map[kadm.ACLOperation]bool {
+	distinct := make(map[kadm.ACLOperation]bool, len(ops))
+	for _, op := range ops {
+		distinct[op] = true
+	}
+	return distinct
+}
+
+func (f *footprint) Total() int {
+	return f.topics + f.secrets + f.grants + f.acls
+}
+
+func (f *footprint) String() string {
+	return fmt.Sprintf("topics=%d secrets=%d grants=%d acls=%d", f.topics, f.secrets, f.grants, f.acls)
+}
+
+func (g *guardrails) Validate(info *types.TopicInfo) error {
+	if g.maxACLOperationsPerUser > 0 {
+		for _, u := range info.Users {
+			if n := userOperations(&u); n > g.maxACLOperationsPerUser {
+				return errors.WithStack(fmt.Errorf("user %s requests %d ACL operations which exceeds the maximum of %d operations per user", u.Username, n, g.maxACLOperationsPerUser))
+			}
+		}
+	}
+	if g.maxResourceFootprint > 0 {
+		// Checked before any AWS call so that combinatorial mistakes
+		// (e.g. users duplicated across permissions) do not partially apply.
+		if f := newFootprint(info); f.Total() > g.maxResourceFootprint {
+			return errors.WithStack(fmt.Errorf("request creates %d resources which exceeds the footprint budget of %d (%s)", f.Total(), g.maxResourceFootprint, f))
+		}
+	}
+	return nil
+}
+
+// Returns config with the topic configs mandated by the operator applied.
+// Enforced values always win over the values declared in the template.
+// Conflicting declarations are logged so that template authors notice.
+func (g *guardrails) EnforceConfig(config map[string]*string, logger *zap.Logger) map[string]*string {
+	if len(g.enforcedTopicConfig) == 0 {
+		return config
+	}
+	enforced := make(map[string]*string, len(config)+len(g.enforcedTopicConfig))
+	for k, v := range config {
+		enforced[k] = v
+	}
+	keys := make([]string, 0, len(g.enforcedTopicConfig))
+	for k := range g.enforcedTopicConfig {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := g.enforcedTopicConfig[k]
+		if declared, ok := config[k]; ok && (declared == nil || *declared != v) {
+			logger.Sugar().Warnw("Enforced Config Overrides Template", "Name", k, "Value", v, "DeclaredValue", declared)
+		}
+		enforced[k] = &v
+	}
+	return enforced
+}
@@ -0,0 +1,78 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"testing"
+
+	tt "github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuardrails(t *testing.T) {
+	type testCase struct {
+		settings *Settings
+		info     *tt.TopicInfo
+		err      string
+	}
+
+	readWrite := []tt.Permission{tt.PermissionRead, tt.PermissionWrite}
+	topicDescribeDisabled := false
+
+	cases := map[string]testCase{
+		"Operations within limit": {
+			settings: &Settings{MaxACLOperationsPerUser: 5},
+			info:     &tt.TopicInfo{Users: []tt.User{{Username: "alice", Permissions: readWrite}}},
+		},
+		"Operations over limit": {
+			settings: &Settings{MaxACLOperationsPerUser: 4},
+			info:     &tt.TopicInfo{Users: []tt.User{{Username: "bob", Permissions: []tt.Permission{tt.PermissionWrite}}, {Username: "alice", Permissions: readWrite}}},
+			err:      "user alice requests 5 ACL operations which exceeds the maximum of 4 operations per user",
+		},
+		"Operations on additional topics and hosts counted once": {
+			settings: &Settings{MaxACLOperationsPerUser: 5},
+			info:     &tt.TopicInfo{Users: []tt.User{{Username: "alice", AdditionalTopics: []string{"b", "c"}, AllowedHosts: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5"}, Permissions: readWrite}}},
+		},
+		"Operations limit disabled": {
+			settings: &Settings{MaxACLOperationsPerUser: 0},
+			info:     &tt.TopicInfo{Users: []tt.User{{Username: "alice", Permissions: readWrite}}},
+		},
+		"Footprint within budget": {
+			settings: &Settings{MaxResourceFootprint: 8},
+			info:     &tt.TopicInfo{Users: []tt.User{{Username: "alice", Arn: "arn", Permissions: readWrite}}},
+		},
+		"Footprint over budget": {
+			settings: &Settings{MaxResourceFootprint: 10},
+			info: &tt.TopicInfo{Users: []tt.User{
+				{Username: "alice", Arn: "arn", Permissions: readWrite},
+				{Username: "bob", SecretArn: "secret", Permissions: []tt.Permission{tt.PermissionRead}},
+				{Username: "carol", AuthType: tt.AuthTypeTLS, Principal: "CN=carol", Permissions: []tt.Permission{tt.PermissionWrite}},
+			}},
+			err: "request creates 14 resources which exceeds the footprint budget of 10 (topics=1 secrets=1 grants=1 acls=11)",
+		},
+		"Footprint of additional topics and hosts": {
+			settings: &Settings{MaxResourceFootprint: 8},
+			info:     &tt.TopicInfo{Users: []tt.User{{Username: "alice", SecretArn: "secret", AdditionalTopics: []string{"b"}, AllowedHosts: []string{"10.0.0.1", "10.0.0.2"}, Permissions: []tt.Permission{tt.PermissionWrite}}}},
+			err:      "request creates 9 resources which exceeds the footprint budget of 8 (topics=1 secrets=0 grants=0 acls=8)",
+		},
+		"Operations without topic describe": {
+			settings: &Settings{MaxACLOperationsPerUser: 4},
+			info:     &tt.TopicInfo{Users: []tt.User{{Username: "alice", Permissions: readWrite, TopicDescribe: &topicDescribeDisabled}}},
+		},
+		"Footprint budget disabled": {
+			settings: &Settings{MaxResourceFootprint: 0},
+			info:     &tt.TopicInfo{Users: []tt.User{{Username: "alice", Arn: "arn", Permissions: readWrite}}},
+		},
+	}
+
+	for k, c := range cases {
+		err := newGuardrails(c.settings).Validate(c.info)
+		if c.err == "" {
+			assert.Nil(t, err, k)
+		} else {
+			assert.EqualError(t, err, c.err, k)
+		}
+	}
+}
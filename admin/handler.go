@@ -6,11 +6,14 @@ package admin
 import (
 	"context"
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/aws-samples/amazon-msk-topic-resource/types"
 
 	"github.com/aws/aws-lambda-go/cfn"
+	"github.com/aws/aws-sdk-go-v2/service/kafka"
 	"github.com/aws/smithy-go"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
@@ -20,11 +23,35 @@ import (
 type contextKey string
 
 type KafkaClientProvider interface {
-	NewKafkaClient(ctx context.Context, clusterArn string) (KafkaClient, error)
+	NewKafkaClient(ctx context.Context, clusterArn string) (KafkaClient, *kafka.GetBootstrapBrokersOutput, error)
 }
 
 const (
-	PropUsernameSuffix string = "UsernameSuffix"
+	PropTopicName           string = "TopicName"
+	PropUsernameSuffix      string = "UsernameSuffix"
+	PropNameSuffix          string = "NameSuffix"
+	PropDryRunPlan          string = "DryRunPlan"
+	PropUserResults         string = "UserResults"
+	PropPartitionAssignment string = "PartitionAssignment"
+	PropACLs                string = "ACLs"
+	PropKmsKeyArn           string = "KmsKeyArn"
+	// Followed by username for each user of topics in serverless clusters.
+	PropIamPolicyPrefix string = "IamPolicy."
+	PropLabelPrefix     string = "Label."
+	// Followed by username for each user with a SASL/SCRAM secret.
+	PropSecretArnPrefix string = "SecretArn."
+	// Followed by the index of a cluster in ClusterArn and the name of
+	// one of its attributes, for clusters other than the first.
+	PropClusterPrefix string = "Cluster."
+
+	// Presigned URL and S3 URI of the object holding all output
+	// attributes when they do not fit in the response to CloudFormation.
+	PropOutputURL      string = "OutputUrl"
+	PropOutputLocation string = "OutputLocation"
+
+	PropBootstrapBrokerStringSaslScram     string = "BootstrapBrokerStringSaslScram"
+	PropBootstrapBrokerStringSaslIam       string = "BootstrapBrokerStringSaslIam"
+	PropBootstrapBrokerStringPublicSaslIam string = "BootstrapBrokerStringPublicSaslIam"
 )
 
 var contextKeyLogger contextKey = contextKey("Logger")
@@ -34,7 +61,11 @@ type Handler struct {
 	mskClient            MskClient
 	kmsClient            KmsClient
 	secretsManagerClient SecretsManagerClient
+	iamClient            IamClient
+	s3Client             S3Client
 	kafkaClientProvider  KafkaClientProvider
+	settings             *Settings
+	metrics              *metrics
 }
 
 // Entrypoint for handling custom resource managed by this extension.
@@ -46,8 +77,12 @@ func (h *Handler) Handle(ctx context.Context, event cfn.Event) (string, map[stri
 	// Prepare logger with contextual information
 	logger := h.initializeLogger(&event)
 	ctx = context.WithValue(ctx, contextKeyLogger, logger)
+	summary := newOpSummary()
+	ctx = withOpSummary(ctx, summary)
+	ctx = withStackResource(ctx, newStackResource(event.StackID, event.LogicalResourceID))
 	defer logger.Sync()
 	logger.Info("Start", zap.Any("ResourceProperties", event.ResourceProperties), zap.Any("OldResourceProperties", event.OldResourceProperties))
+	start := time.Now()
 
 	switch event.RequestType {
 	case cfn.RequestCreate:
@@ -59,6 +94,11 @@ func (h *Handler) Handle(ctx context.Context, event cfn.Event) (string, map[stri
 	default:
 		err = fmt.Errorf("unknown request type: %v", event.RequestType)
 	}
+	if err == nil && event.RequestType != cfn.RequestDelete {
+		props, err = h.limitResponseSize(ctx, event, physicalResourceID, props, logger)
+	}
+	summary.Log(logger)
+	h.recordMetrics(event, start, err)
 	return physicalResourceID, props, h.logAndEchoError(event, err, logger)
 }
 
@@ -67,6 +107,7 @@ func (h *Handler) logAndEchoError(event cfn.Event, err error, logger *zap.Logger
 		return err
 	}
 	logger.Error("Failed to process request", zap.Error(err))
+	logger.Debug("Error stack", zap.String("Stack", fmt.Sprintf("%+v", err)))
 	// Log more information if this is an error cause by an AWS SDK operation
 	var oerr *smithy.OperationError
 	if errors.As(err, &oerr) {
@@ -74,7 +115,19 @@ func (h *Handler) logAndEchoError(event cfn.Event, err error, logger *zap.Logger
 			logger.Error("Smithy Operation Error", zap.Error(oerr))
 		}
 	}
-	return err
+	// Echo a concise message to CloudFormation. Full error is in the logs.
+	return errors.New(describeError(err))
+}
+
+func (h *Handler) recordMetrics(event cfn.Event, start time.Time, err error) {
+	dimensions := map[string]string{"RequestType": string(event.RequestType)}
+	failures := 0
+	if err != nil {
+		failures = 1
+	}
+	h.metrics.Count("Requests", 1, dimensions)
+	h.metrics.Count("Failures", failures, dimensions)
+	h.metrics.Latency("Latency", start, dimensions)
 }
 
 func (h *Handler) create(ctx context.Context, event cfn.Event, logger *zap.Logger) (string, map[string]interface{}, error) {
@@ -88,17 +141,99 @@ func (h *Handler) create(ctx context.Context, event cfn.Event, logger *zap.Logge
 	if err != nil {
 		return rid, nil, err
 	}
-	kafkaClient, err := h.kafkaClientProvider.NewKafkaClient(ctx, ti.ClusterArn)
+	// Clusters are created in turn. When one fails, CloudFormation deletes
+	// the resource from every cluster while rolling back.
+	ctx = withExistencePolicy(ctx, newExistencePolicy(ti.StrictExistence, event.RequestID))
+	clusters := ti.Clusters()
+	for i, clusterArn := range clusters {
+		cctx := ctx
+		if i > 0 {
+			// Secrets are shared with the clusters created before, therefore
+			// users rolled back in this cluster keep them.
+			cctx = withRetainedSecrets(ctx, retainAllSecrets())
+		}
+		id, clusterProps, err := h.createInCluster(cctx, event, ti.ForCluster(clusterArn), logger.With(zap.String("ClusterArn", clusterArn)))
+		// The resource is identified by its topic in the first cluster.
+		if id != "" && i == 0 {
+			rid = id
+		}
+		if err != nil {
+			return rid, nil, newClusterError(err, clusters, i)
+		}
+		addClusterProps(props, clusterProps, i)
+	}
+	return rid, props, nil
+}
+
+// Creates the topic in the cluster of ti. The returned physical resource
+// ID is empty unless the topic was created.
+func (h *Handler) createInCluster(ctx context.Context, event cfn.Event, ti *types.TopicInfo, logger *zap.Logger) (string, map[string]interface{}, error) {
+	props := make(map[string]interface{})
+	kafkaClient, brokers, err := h.kafkaClientProvider.NewKafkaClient(ctx, ti.ClusterArn)
 	if err != nil {
-		return rid, nil, err
+		return "", nil, err
+	}
+	serverless, userManager, kmsKeyResolver, err := h.newUserServices(ctx, ti.ClusterArn, kafkaClient, logger)
+	if err != nil {
+		return "", nil, err
+	}
+	if !serverless {
+		newCapacityPreflight(h.mskClient, kafkaClient, h.settings.CapacityWarningPercent, logger).Check(ctx, ti)
 	}
-	userManager := newUserManager(h.secretsManagerClient, h.kmsClient, h.mskClient, kafkaClient, logger, func() { time.Sleep(time.Second * 30) })
-	kmsKeyResolver := newKmsKeyResolver(h.mskClient)
-	cmdCreate := newCmdCreate(kafkaClient, kmsKeyResolver, userManager, logger)
+	var created *createdResources
+	if h.settings.SettleTimeout > 0 && !serverless {
+		created = newCreatedResources()
+		ctx = withCreatedResources(ctx, created)
+	}
+	topicMarkers := newTopicMarkerStore(h.secretsManagerClient, logger)
+	cmdCreate := newCmdCreate(kafkaClient, kmsKeyResolver, userManager, topicMarkers, serverless, h.settings, logger)
 	id, err := cmdCreate.Run(ctx, ti, event.StackID)
-	if err == nil {
-		rid = id.PhysicalResourceID
-		props[PropUsernameSuffix] = id.UsernameSuffix
+	if err != nil {
+		if id != nil && len(id.UserResults) > 0 {
+			logger.Sugar().Errorw("User creation failed", "UserResults", id.UserResults)
+			err = errors.WithMessage(err, userResultsSummary(id.UserResults))
+		}
+		return "", nil, err
+	}
+	rid := id.PhysicalResourceID
+	if created != nil {
+		// Report the topic so that CloudFormation deletes it on rollback.
+		err = newSettlePoller(h.mskClient, kafkaClient, h.settings.SettleTimeout, logger).Await(ctx, ti.ClusterArn, created)
+		if err != nil {
+			return rid, nil, err
+		}
+	}
+	props[PropTopicName] = id.TopicName
+	props[PropUsernameSuffix] = id.UsernameSuffix
+	props[PropNameSuffix] = id.UsernameSuffix
+	addBootstrapBrokers(props, brokers)
+	addLabels(props, ti.Labels)
+	if id.PartitionAssignment != "" {
+		props[PropPartitionAssignment] = id.PartitionAssignment
+	}
+	addSecretArns(props, id.SecretArns)
+	if id.KmsKeyArn != "" {
+		props[PropKmsKeyArn] = id.KmsKeyArn
+	}
+	if serverless {
+		err = addIamPolicies(props, ti.ClusterArn, id.TopicName, ti.Users)
+		if err != nil {
+			return rid, nil, err
+		}
+	} else if !ti.DryRun {
+		err = h.addACLAudit(props, ti, id.TopicName, id.UsernameSuffix)
+		if err != nil {
+			return rid, nil, err
+		}
+	}
+	if len(id.UserResults) > 0 {
+		props[PropUserResults], err = userResultsJSON(id.UserResults)
+		if err != nil {
+			return rid, nil, err
+		}
+	}
+	if id.Plan != nil {
+		props[PropDryRunPlan], err = id.Plan.JSON()
 	}
 	return rid, props, err
 }
@@ -112,15 +247,73 @@ func (h *Handler) update(ctx context.Context, event cfn.Event, logger *zap.Logge
 	if err != nil {
 		return event.PhysicalResourceID, nil, errors.WithStack(err)
 	}
-	kafkaClient, err := h.kafkaClientProvider.NewKafkaClient(ctx, old.ClusterArn)
+	if !old.SameClusters(new) {
+		return event.PhysicalResourceID, nil, errors.New("Cannot update ClusterArn")
+	}
+	rid := event.PhysicalResourceID
+	props := make(map[string]interface{})
+	ctx = withExistencePolicy(ctx, newExistencePolicy(new.StrictExistence, event.RequestID))
+	clusters := new.Clusters()
+	for i, clusterArn := range clusters {
+		id, clusterProps, err := h.updateInCluster(sharedSecretsContext(ctx, clusters, i), event, old.ForCluster(clusterArn), new.ForCluster(clusterArn), logger.With(zap.String("ClusterArn", clusterArn)))
+		if err != nil {
+			return event.PhysicalResourceID, nil, newClusterError(err, clusters, i)
+		}
+		// A topic replaced on Name change is identified by its topic in
+		// the first cluster, as on create.
+		if id != "" && i == 0 {
+			rid = id
+		}
+		addClusterProps(props, clusterProps, i)
+	}
+	return rid, props, nil
+}
+
+// Updates the topic in the cluster of new. The returned physical resource
+// ID is empty unless the topic was replaced.
+func (h *Handler) updateInCluster(ctx context.Context, event cfn.Event, old, new *types.TopicInfo, logger *zap.Logger) (string, map[string]interface{}, error) {
+	kafkaClient, brokers, err := h.kafkaClientProvider.NewKafkaClient(ctx, old.ClusterArn)
 	if err != nil {
-		return event.PhysicalResourceID, nil, err
+		return "", nil, err
+	}
+	serverless, userManager, kmsKeyResolver, err := h.newUserServices(ctx, old.ClusterArn, kafkaClient, logger)
+	if err != nil {
+		return "", nil, err
+	}
+	topicMarkers := newTopicMarkerStore(h.secretsManagerClient, logger)
+	cmdUpdate := newCmdUpdate(kmsKeyResolver, userManager, kafkaClient, topicMarkers, serverless, h.settings, logger)
+	result, err := cmdUpdate.Run(ctx, old, new, event.StackID)
+	if err != nil {
+		return "", nil, err
+	}
+	props := make(map[string]interface{})
+	props[PropTopicName] = result.TopicName
+	props[PropUsernameSuffix] = nameSuffix(new, event.StackID)
+	props[PropNameSuffix] = nameSuffix(new, event.StackID)
+	addBootstrapBrokers(props, brokers)
+	addLabels(props, new.Labels)
+	if result.PartitionAssignment != "" {
+		props[PropPartitionAssignment] = result.PartitionAssignment
 	}
-	userManager := newUserManager(h.secretsManagerClient, h.kmsClient, h.mskClient, kafkaClient, logger, func() { time.Sleep(time.Second * 30) })
-	kmsKeyResolver := newKmsKeyResolver(h.mskClient)
-	cmdUpdate := newCmdUpdate(kmsKeyResolver, userManager, kafkaClient, func() { time.Sleep(time.Second * 30) }, logger)
-	err = cmdUpdate.Run(ctx, old, new, event.StackID)
-	return event.PhysicalResourceID, nil, err
+	addSecretArns(props, result.SecretArns)
+	if result.KmsKeyArn != "" {
+		props[PropKmsKeyArn] = result.KmsKeyArn
+	}
+	if serverless {
+		err = addIamPolicies(props, old.ClusterArn, result.TopicName, new.Users)
+		if err != nil {
+			return "", nil, err
+		}
+	} else if !new.DryRun {
+		err = h.addACLAudit(props, new, result.TopicName, nameSuffix(new, event.StackID))
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	if result.Plan != nil {
+		props[PropDryRunPlan], err = result.Plan.JSON()
+	}
+	return result.PhysicalResourceID, props, err
 }
 
 func (h *Handler) delete(ctx context.Context, event cfn.Event, logger *zap.Logger) (string, map[string]interface{}, error) {
@@ -128,19 +321,117 @@ func (h *Handler) delete(ctx context.Context, event cfn.Event, logger *zap.Logge
 	if err != nil {
 		return event.PhysicalResourceID, nil, err
 	}
-	kafkaClient, err := h.kafkaClientProvider.NewKafkaClient(ctx, ti.ClusterArn)
+	clusters := ti.Clusters()
+	for i, clusterArn := range clusters {
+		err = h.deleteInCluster(sharedSecretsContext(ctx, clusters, i), event, ti.ForCluster(clusterArn), logger.With(zap.String("ClusterArn", clusterArn)))
+		if err != nil {
+			return event.PhysicalResourceID, nil, newClusterError(err, clusters, i)
+		}
+	}
+	return event.PhysicalResourceID, nil, nil
+}
+
+func (h *Handler) deleteInCluster(ctx context.Context, event cfn.Event, ti *types.TopicInfo, logger *zap.Logger) error {
+	kafkaClient, _, err := h.kafkaClientProvider.NewKafkaClient(ctx, ti.ClusterArn)
 	if err != nil {
-		return event.PhysicalResourceID, nil, err
+		return err
+	}
+	_, userManager, kmsKeyResolver, err := h.newUserServices(ctx, ti.ClusterArn, kafkaClient, logger)
+	if err != nil {
+		return err
+	}
+	topicMarkers := newTopicMarkerStore(h.secretsManagerClient, logger)
+	snapshots := newTopicSnapshotter(kafkaClient, h.s3Client, h.settings.SnapshotBucket, h.settings.SnapshotPrefix, logger)
+	cmdDelete := newCmdDelete(kmsKeyResolver, userManager, kafkaClient, topicMarkers, snapshots, logger)
+	return cmdDelete.Run(ctx, ti, event.StackID)
+}
+
+// Secrets of users are shared by the clusters of a topic. They are only
+// deleted once they are disassociated from the last cluster.
+func sharedSecretsContext(ctx context.Context, clusters []string, i int) context.Context {
+	if i == len(clusters)-1 {
+		return ctx
+	}
+	return withRetainedSecrets(ctx, retainAllSecrets())
+}
+
+// Attributes of the first cluster keep their names so that templates
+// written for a single cluster are unaffected. Attributes that differ
+// between clusters are prefixed for the other clusters, e.g.
+// Cluster.1.BootstrapBrokerStringSaslScram for the second cluster.
+func addClusterProps(props, clusterProps map[string]interface{}, i int) {
+	for k, v := range clusterProps {
+		if i == 0 {
+			props[k] = v
+			continue
+		}
+		if k == PropTopicName || k == PropUsernameSuffix || k == PropNameSuffix || strings.HasPrefix(k, PropLabelPrefix) || strings.HasPrefix(k, PropSecretArnPrefix) {
+			continue
+		}
+		props[fmt.Sprintf("%s%d.%s", PropClusterPrefix, i, k)] = v
+	}
+}
+
+// Reports the ACLs granted to the users of ti on topicName. Serverless
+// clusters authorise users with IAM policies instead.
+func (h *Handler) addACLAudit(props map[string]interface{}, ti *types.TopicInfo, topicName, shortStackID string) error {
+	acls, err := aclAuditJSON(userACLAudit(ti, topicName, shortStackID, secretNameTemplate(h.settings.SecretNameTemplate)))
+	if err != nil {
+		return err
+	}
+	props[PropACLs] = acls
+	return nil
+}
+
+func addLabels(props map[string]interface{}, labels map[string]string) {
+	for k, v := range labels {
+		props[PropLabelPrefix+k] = v
+	}
+}
+
+func addSecretArns(props map[string]interface{}, secretArns map[string]string) {
+	for username, arn := range secretArns {
+		props[PropSecretArnPrefix+username] = arn
 	}
-	userManager := newUserManager(h.secretsManagerClient, h.kmsClient, h.mskClient, kafkaClient, logger, func() { time.Sleep(time.Second * 30) })
-	kmsKeyResolver := newKmsKeyResolver(h.mskClient)
-	cmdDelete := newCmdDelete(kmsKeyResolver, userManager, kafkaClient, logger)
-	err = cmdDelete.Run(ctx, ti, event.StackID)
-	return event.PhysicalResourceID, nil, err
+}
+
+// Application teams use bootstrap broker strings to configure their clients.
+// Broker strings for authentication modes not enabled in the cluster are omitted.
+func addBootstrapBrokers(props map[string]interface{}, brokers *kafka.GetBootstrapBrokersOutput) {
+	if brokers == nil {
+		return
+	}
+	if brokers.BootstrapBrokerStringSaslScram != nil {
+		props[PropBootstrapBrokerStringSaslScram] = *brokers.BootstrapBrokerStringSaslScram
+	}
+	if brokers.BootstrapBrokerStringSaslIam != nil {
+		props[PropBootstrapBrokerStringSaslIam] = *brokers.BootstrapBrokerStringSaslIam
+	}
+	if brokers.BootstrapBrokerStringPublicSaslIam != nil {
+		props[PropBootstrapBrokerStringPublicSaslIam] = *brokers.BootstrapBrokerStringPublicSaslIam
+	}
+}
+
+// Returns services for managing users in the cluster. Users of serverless
+// clusters are granted access via IAM policies instead of SASL/SCRAM.
+func (h *Handler) newUserServices(ctx context.Context, clusterArn string, kafkaClient KafkaClient, logger *zap.Logger) (bool, UserManagerService, KmsKeyResolverService, error) {
+	serverless, err := isServerless(ctx, h.mskClient, clusterArn)
+	if err != nil {
+		return false, nil, nil, err
+	}
+	if serverless {
+		logger.Sugar().Infow("Serverless cluster detected, users are managed via IAM policies", "ClusterArn", clusterArn)
+		return true, newIamUserManager(logger), &iamKmsKeyResolver{}, nil
+	}
+	return false, h.newUserManager(kafkaClient, logger), newKmsKeyResolver(h.mskClient, h.settings.KmsKeyTag), nil
+}
+
+func (h *Handler) newUserManager(kafkaClient KafkaClient, logger *zap.Logger) *userManager {
+	return newUserManager(h.secretsManagerClient, h.kmsClient, h.mskClient, kafkaClient, h.iamClient, h.metrics, h.settings, logger)
 }
 
 func (h *Handler) initializeLogger(event *cfn.Event) *zap.Logger {
-	logger, err := zap.NewProduction()
+	logger, err := newLogger(h.settings)
 	if err != nil {
 		panic(err)
 	}
@@ -154,11 +445,15 @@ func (h *Handler) initializeLogger(event *cfn.Event) *zap.Logger {
 	)
 }
 
-func NewHandler(mskClient MskClient, kmsClient KmsClient, secretsManagerClient SecretsManagerClient, kafkaClientProvider KafkaClientProvider) *Handler {
+func NewHandler(mskClient MskClient, kmsClient KmsClient, secretsManagerClient SecretsManagerClient, iamClient IamClient, s3Client S3Client, kafkaClientProvider KafkaClientProvider, settings *Settings) *Handler {
 	return &Handler{
 		mskClient:            mskClient,
 		kmsClient:            kmsClient,
 		secretsManagerClient: secretsManagerClient,
+		iamClient:            iamClient,
+		s3Client:             s3Client,
 		kafkaClientProvider:  kafkaClientProvider,
+		settings:             settings,
+		metrics:              newMetrics(settings.MetricsEnabled, os.Stdout),
 	}
 }
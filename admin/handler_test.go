@@ -64,8 +64,8 @@ func runHandler(t *testing.T, requestType cfn.RequestType, stackID, physicalReso
 	mskClient := kafka.NewFromConfig(cfg)
 	secretsManagerClient := secretsmanager.NewFromConfig(cfg)
 	kmsClient := kms.NewFromConfig(cfg)
-	kafkaClientProvider := NewIamKafkaClientProvider(mskClient)
-	handler := NewHandler(mskClient, kmsClient, secretsManagerClient, kafkaClientProvider)
+	kafkaClientProvider := NewIamKafkaClientProvider(mskClient, DefaultSettings())
+	handler := NewHandler(mskClient, kmsClient, secretsManagerClient, nil, nil, kafkaClientProvider, DefaultSettings())
 	rid, d, err := handler.Handle(ctx, cfn.Event{
 		PhysicalResourceID:    physicalResourceID,
 		RequestType:           requestType,
@@ -0,0 +1,262 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/admin/mocks"
+
+	"github.com/aws/aws-lambda-go/cfn"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kafka"
+	kt "github.com/aws/aws-sdk-go-v2/service/kafka/types"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+)
+
+func TestHandleCreateWithoutProperties(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cases := map[string]map[string]interface{}{
+		"Empty properties": {},
+		"Nil properties":   nil,
+	}
+
+	for k, props := range cases {
+		// Arrange
+		handler := NewHandler(mocks.NewMockMskClient(ctrl), mocks.NewMockKmsClient(ctrl), mocks.NewMockSecretsManagerClient(ctrl), nil, nil, nil, DefaultSettings())
+
+		// Act
+		rid, data, err := handler.Handle(context.TODO(), cfn.Event{
+			RequestType:        cfn.RequestCreate,
+			StackID:            "test",
+			ResourceProperties: props,
+		})
+
+		// Assert
+		_, perr := uuid.Parse(rid)
+		assert.Nil(t, perr, k)
+		assert.Nil(t, data, k)
+		assert.EqualError(t, err, "Invalid properties:\n- ServiceToken is required\n- Name is required\n- Partitions is required\n- ReplicationFactor is required\n- ClusterArn is required", k)
+	}
+}
+
+func TestAddBootstrapBrokers(t *testing.T) {
+	type testCase struct {
+		brokers *kafka.GetBootstrapBrokersOutput
+		props   map[string]interface{}
+	}
+
+	cases := map[string]testCase{
+		"SASL/SCRAM and IAM": {
+			brokers: &kafka.GetBootstrapBrokersOutput{BootstrapBrokerStringSaslScram: aws.String("b-1:9096"), BootstrapBrokerStringSaslIam: aws.String("b-1:9098")},
+			props:   map[string]interface{}{PropBootstrapBrokerStringSaslScram: "b-1:9096", PropBootstrapBrokerStringSaslIam: "b-1:9098"},
+		},
+		"IAM only": {
+			brokers: &kafka.GetBootstrapBrokersOutput{BootstrapBrokerStringSaslIam: aws.String("b-1:9098")},
+			props:   map[string]interface{}{PropBootstrapBrokerStringSaslIam: "b-1:9098"},
+		},
+		"Public IAM": {
+			brokers: &kafka.GetBootstrapBrokersOutput{BootstrapBrokerStringSaslIam: aws.String("b-1:9098"), BootstrapBrokerStringPublicSaslIam: aws.String("b-1-public:9198")},
+			props:   map[string]interface{}{PropBootstrapBrokerStringSaslIam: "b-1:9098", PropBootstrapBrokerStringPublicSaslIam: "b-1-public:9198"},
+		},
+		"No brokers": {
+			props: map[string]interface{}{},
+		},
+	}
+
+	for k, c := range cases {
+		props := make(map[string]interface{})
+		addBootstrapBrokers(props, c.brokers)
+		assert.Equal(t, c.props, props, k)
+	}
+}
+
+func TestAddLabels(t *testing.T) {
+	props := make(map[string]interface{})
+	addLabels(props, map[string]string{"owner": "alice", "tier": "gold"})
+	assert.Equal(t, map[string]interface{}{"Label.owner": "alice", "Label.tier": "gold"}, props)
+}
+
+type testKafkaClientProvider struct {
+	kafkaClient KafkaClient
+	brokers     *kafka.GetBootstrapBrokersOutput
+	err         error
+}
+
+func (p *testKafkaClientProvider) NewKafkaClient(ctx context.Context, clusterArn string) (KafkaClient, *kafka.GetBootstrapBrokersOutput, error) {
+	return p.kafkaClient, p.brokers, p.err
+}
+
+func TestHandleCreateServerless(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	clusterArn := "arn:aws:kafka:ap-southeast-2:111222333444:cluster/serverless/abc-1"
+	stackID := "test"
+	topicName := canonicalTopicName("orders", shortStackID(stackID))
+	mskClient := mocks.NewMockMskClient(ctrl)
+	secretsManagerClient := mocks.NewMockSecretsManagerClient(ctrl)
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	provider := &testKafkaClientProvider{
+		kafkaClient: kafkaClient,
+		brokers:     &kafka.GetBootstrapBrokersOutput{BootstrapBrokerStringSaslIam: aws.String("boot-1:9098")},
+	}
+	settings := DefaultSettings()
+	settings.MetricsEnabled = false
+	handler := NewHandler(mskClient, mocks.NewMockKmsClient(ctrl), secretsManagerClient, nil, nil, provider, settings)
+
+	mskClient.EXPECT().DescribeClusterV2(gomock.Any(), &kafka.DescribeClusterV2Input{ClusterArn: &clusterArn}).
+		Return(&kafka.DescribeClusterV2Output{ClusterInfo: &kt.Cluster{ClusterType: kt.ClusterTypeServerless}}, error(nil))
+	kafkaClient.EXPECT().ListTopics(gomock.Any(), topicName).
+		Return(kadm.TopicDetails{topicName: {Topic: topicName, Err: kerr.UnknownTopicOrPartition}}, error(nil))
+	secretsManagerClient.EXPECT().CreateSecret(gomock.Any(), gomock.Any()).Return(&secretsmanager.CreateSecretOutput{}, error(nil))
+	kafkaClient.EXPECT().CreateTopic(gomock.Any(), int32(3), int16(-1), gomock.Any(), gomock.Nil(), topicName).
+		Return(kadm.CreateTopicResponse{Topic: topicName}, error(nil))
+	kafkaClient.EXPECT().ListTopics(gomock.Any(), topicName).
+		Return(kadm.TopicDetails{topicName: {Topic: topicName, Partitions: kadm.PartitionDetails{
+			0: {Partition: 0, Replicas: []int32{1, 2, 3}},
+			1: {Partition: 1, Replicas: []int32{2, 3, 1}},
+			2: {Partition: 2, Replicas: []int32{3, 1, 2}},
+		}}}, error(nil))
+
+	// Act
+	rid, data, err := handler.Handle(context.TODO(), cfn.Event{
+		RequestType: cfn.RequestCreate,
+		StackID:     stackID,
+		ResourceProperties: map[string]interface{}{
+			"ServiceToken":      "st",
+			"Name":              "orders",
+			"Partitions":        "3",
+			"ReplicationFactor": "3",
+			"ClusterArn":        clusterArn,
+			"Users": []interface{}{
+				map[string]interface{}{"Username": "alice", "Permissions": []interface{}{"READ"}},
+				map[string]interface{}{"Username": "bob", "Permissions": []interface{}{"WRITE"}, "AdditionalTopics": []interface{}{"audit"}},
+			},
+		},
+	})
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, physicalResourceID(clusterArn, topicName), rid)
+	assert.Equal(t, topicName, data[PropTopicName])
+	assert.Equal(t, "boot-1:9098", data[PropBootstrapBrokerStringSaslIam])
+	assert.Equal(t, "0:1,2,3;1:2,3,1;2:3,1,2", data[PropPartitionAssignment])
+	assert.NotContains(t, data, PropKmsKeyArn)
+	topicArn := "arn:aws:kafka:ap-southeast-2:111222333444:topic/serverless/abc-1/" + topicName
+	groupArn := "arn:aws:kafka:ap-southeast-2:111222333444:group/serverless/abc-1/*"
+	assert.JSONEq(t, `{"Version":"2012-10-17","Statement":[`+
+		`{"Effect":"Allow","Action":["kafka-cluster:Connect"],"Resource":["`+clusterArn+`"]},`+
+		`{"Effect":"Allow","Action":["kafka-cluster:AlterGroup","kafka-cluster:DescribeGroup"],"Resource":["`+groupArn+`"]},`+
+		`{"Effect":"Allow","Action":["kafka-cluster:DescribeTopic","kafka-cluster:ReadData"],"Resource":["`+topicArn+`"]}]}`,
+		data[PropIamPolicyPrefix+"alice"].(string))
+	assert.JSONEq(t, `{"Version":"2012-10-17","Statement":[`+
+		`{"Effect":"Allow","Action":["kafka-cluster:Connect"],"Resource":["`+clusterArn+`"]},`+
+		`{"Effect":"Allow","Action":["kafka-cluster:DescribeTopic","kafka-cluster:WriteData"],"Resource":["`+topicArn+`","arn:aws:kafka:ap-southeast-2:111222333444:topic/serverless/abc-1/audit"]}]}`,
+		data[PropIamPolicyPrefix+"bob"].(string))
+}
+
+type testClusterKafkaClientProvider struct {
+	kafkaClients map[string]KafkaClient
+	brokers      map[string]*kafka.GetBootstrapBrokersOutput
+}
+
+func (p *testClusterKafkaClientProvider) NewKafkaClient(ctx context.Context, clusterArn string) (KafkaClient, *kafka.GetBootstrapBrokersOutput, error) {
+	return p.kafkaClients[clusterArn], p.brokers[clusterArn], nil
+}
+
+func TestHandleCreateMultipleClusters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	clusterArns := []string{
+		"arn:aws:kafka:ap-southeast-2:111222333444:cluster/serverless/abc-1",
+		"arn:aws:kafka:us-west-2:111222333444:cluster/serverless/def-2",
+	}
+	stackID := "test"
+	topicName := canonicalTopicName("orders", shortStackID(stackID))
+	event := cfn.Event{
+		RequestType: cfn.RequestCreate,
+		StackID:     stackID,
+		ResourceProperties: map[string]interface{}{
+			"ServiceToken":      "st",
+			"Name":              "orders",
+			"Partitions":        "1",
+			"ReplicationFactor": "3",
+			"ClusterArn":        []interface{}{clusterArns[0], clusterArns[1]},
+		},
+	}
+	arrange := func(createErr error) *Handler {
+		mskClient := mocks.NewMockMskClient(ctrl)
+		secretsManagerClient := mocks.NewMockSecretsManagerClient(ctrl)
+		provider := &testClusterKafkaClientProvider{
+			kafkaClients: make(map[string]KafkaClient),
+			brokers:      make(map[string]*kafka.GetBootstrapBrokersOutput),
+		}
+		for i, clusterArn := range clusterArns {
+			clusterArn := clusterArn
+			kafkaClient := mocks.NewMockKafkaClient(ctrl)
+			provider.kafkaClients[clusterArn] = kafkaClient
+			provider.brokers[clusterArn] = &kafka.GetBootstrapBrokersOutput{BootstrapBrokerStringSaslIam: aws.String(fmt.Sprintf("boot-%d:9098", i))}
+			mskClient.EXPECT().DescribeClusterV2(gomock.Any(), &kafka.DescribeClusterV2Input{ClusterArn: &clusterArn}).
+				Return(&kafka.DescribeClusterV2Output{ClusterInfo: &kt.Cluster{ClusterType: kt.ClusterTypeServerless}}, error(nil))
+			kafkaClient.EXPECT().ListTopics(gomock.Any(), topicName).
+				Return(kadm.TopicDetails{topicName: {Topic: topicName, Err: kerr.UnknownTopicOrPartition}}, error(nil))
+			secretsManagerClient.EXPECT().CreateSecret(gomock.Any(), gomock.Any()).Return(&secretsmanager.CreateSecretOutput{}, error(nil))
+			if i == 1 && createErr != nil {
+				kafkaClient.EXPECT().CreateTopic(gomock.Any(), int32(1), int16(-1), gomock.Any(), gomock.Nil(), topicName).
+					Return(kadm.CreateTopicResponse{}, createErr)
+				continue
+			}
+			kafkaClient.EXPECT().CreateTopic(gomock.Any(), int32(1), int16(-1), gomock.Any(), gomock.Nil(), topicName).
+				Return(kadm.CreateTopicResponse{Topic: topicName}, error(nil))
+			kafkaClient.EXPECT().ListTopics(gomock.Any(), topicName).
+				Return(kadm.TopicDetails{topicName: {Topic: topicName, Partitions: kadm.PartitionDetails{
+					0: {Partition: 0, Replicas: []int32{int32(i + 1)}},
+				}}}, error(nil))
+		}
+		settings := DefaultSettings()
+		settings.MetricsEnabled = false
+		return NewHandler(mskClient, mocks.NewMockKmsClient(ctrl), secretsManagerClient, nil, nil, provider, settings)
+	}
+
+	t.Run("Created in every cluster", func(t *testing.T) {
+		handler := arrange(nil)
+
+		// Act
+		rid, data, err := handler.Handle(context.TODO(), event)
+
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, physicalResourceID(clusterArns[0], topicName), rid)
+		assert.Equal(t, topicName, data[PropTopicName])
+		assert.Equal(t, "boot-0:9098", data[PropBootstrapBrokerStringSaslIam])
+		assert.Equal(t, "0:1", data[PropPartitionAssignment])
+		assert.Equal(t, "boot-1:9098", data[PropClusterPrefix+"1."+PropBootstrapBrokerStringSaslIam])
+		assert.Equal(t, "0:2", data[PropClusterPrefix+"1."+PropPartitionAssignment])
+		assert.NotContains(t, data, PropClusterPrefix+"1."+PropNameSuffix)
+		assert.NotContains(t, data, PropClusterPrefix+"1."+PropTopicName)
+	})
+
+	t.Run("Failed cluster is reported", func(t *testing.T) {
+		handler := arrange(kerr.PolicyViolation)
+
+		// Act
+		_, _, err := handler.Handle(context.TODO(), event)
+
+		// Assert
+		assert.EqualError(t, err, fmt.Sprintf("cluster %s failed after changes were applied to %s: %s", clusterArns[1], clusterArns[0], kerr.PolicyViolation))
+	})
+}
@@ -0,0 +1,43 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/pkg/errors"
+)
+
+var errIamNoSuchEntity = errors.New("IAM entity does not exist")
+
+// iamSdkClient adapts the IAM module of the AWS SDK to IamClient.
+type iamSdkClient struct {
+	client *iam.Client
+}
+
+func NewIamClient(cfg aws.Config, optFns ...func(*iam.Options)) IamClient {
+	return &iamSdkClient{client: iam.NewFromConfig(cfg, optFns...)}
+}
+
+func (c *iamSdkClient) GetRole(ctx context.Context, roleName string) error {
+	_, err := c.client.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)})
+	return iamError(err)
+}
+
+func (c *iamSdkClient) GetUser(ctx context.Context, userName string) error {
+	_, err := c.client.GetUser(ctx, &iam.GetUserInput{UserName: aws.String(userName)})
+	return iamError(err)
+}
+
+// Returns errIamNoSuchEntity when IAM reports the entity does not exist.
+func iamError(err error) error {
+	var nse *iamtypes.NoSuchEntityException
+	if errors.As(err, &nse) {
+		return errIamNoSuchEntity
+	}
+	return errors.WithStack(err)
+}
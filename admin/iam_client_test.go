@@ -0,0 +1,64 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIamSdkClient(t *testing.T) {
+	type testCase struct {
+		status   int
+		response string
+		expected error
+	}
+
+	cases := map[string]testCase{
+		"Exists": {
+			status:   http.StatusOK,
+			response: `<GetRoleResponse><GetRoleResult><Role><RoleName>consumer</RoleName></Role></GetRoleResult></GetRoleResponse>`,
+		},
+		"No such entity": {
+			status:   http.StatusNotFound,
+			response: `<ErrorResponse><Error><Type>Sender</Type><Code>NoSuchEntity</Code><Message>Role consumer not found.</Message></Error></ErrorResponse>`,
+			expected: errIamNoSuchEntity,
+		},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			// Arrange
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.NoError(t, r.ParseForm())
+				assert.Equal(t, "GetRole", r.PostForm.Get("Action"))
+				assert.Equal(t, "consumer", r.PostForm.Get("RoleName"))
+				w.Header().Set("Content-Type", "text/xml")
+				w.WriteHeader(c.status)
+				_, _ = w.Write([]byte(c.response))
+			}))
+			defer server.Close()
+			client := NewIamClient(aws.Config{
+				Region: "eu-west-1",
+				Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+					return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, nil
+				}),
+			}, func(o *iam.Options) {
+				o.EndpointResolver = iam.EndpointResolverFromURL(server.URL)
+			})
+
+			// Act
+			err := client.GetRole(context.TODO(), "consumer")
+
+			// Assert
+			assert.Equal(t, c.expected, err)
+		})
+	}
+}
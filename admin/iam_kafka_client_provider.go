@@ -6,6 +6,7 @@ package admin
 import (
 	"context"
 	"crypto/tls"
+	"fmt"
 	"net"
 	"strings"
 	"time"
@@ -13,53 +14,188 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/kafka"
 	"github.com/pkg/errors"
-	"github.com/twmb/franz-go/pkg/kadm"
 	"github.com/twmb/franz-go/pkg/kgo"
 	"github.com/twmb/franz-go/pkg/kversion"
+	"github.com/twmb/franz-go/pkg/sasl"
 	"github.com/twmb/franz-go/pkg/sasl/aws"
 	"go.uber.org/zap"
 )
 
+const (
+	BrokerConnectivityPrivate = "private"
+	BrokerConnectivityPublic  = "public"
+)
+
 type IamKafkaClientProvider struct {
-	mskClient MskClient
+	mskClient          MskClient
+	dialTimeout        time.Duration
+	requestTimeout     time.Duration
+	createTopicTimeout time.Duration
+	connectivity       string
+	clientID           string
+	bootstrapRetry     *retryPolicy
+}
+
+// connectError is returned when a connection to a broker cannot be
+// established. It distinguishes network issues from authentication
+// failures which surface once the connection is established.
+type connectError struct {
+	addr    string
+	timeout time.Duration
+	err     error
+}
+
+func (e *connectError) Error() string {
+	var ne net.Error
+	if errors.As(e.err, &ne) && ne.Timeout() {
+		return fmt.Sprintf("timed out connecting to broker %s after %s", e.addr, e.timeout)
+	}
+	return fmt.Sprintf("unable to connect to broker %s: %s", e.addr, e.err)
+}
+
+func (e *connectError) Unwrap() error {
+	return e.err
 }
 
-func (p *IamKafkaClientProvider) NewKafkaClient(ctx context.Context, clusterArn string) (KafkaClient, error) {
+// Wraps dial so that its failures are reported as connectError.
+func newDialFunc(dial func(ctx context.Context, network, addr string) (net.Conn, error), timeout time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, &connectError{addr: addr, timeout: timeout, err: err}
+		}
+		return conn, nil
+	}
+}
+
+// Returns a client for administering the cluster along with bootstrap
+// broker strings of the cluster.
+func (p *IamKafkaClientProvider) NewKafkaClient(ctx context.Context, clusterArn string) (KafkaClient, *kafka.GetBootstrapBrokersOutput, error) {
 	logger := ctx.Value(contextKeyLogger).(*zap.Logger)
-	b, err := p.mskClient.GetBootstrapBrokers(ctx, &kafka.GetBootstrapBrokersInput{ClusterArn: &clusterArn})
+	b, brokers, err := describeBootstrapBrokers(ctx, p.mskClient, p.bootstrapRetry, clusterArn, func(b *kafka.GetBootstrapBrokersOutput) (string, error) {
+		return p.bootstrapBrokers(ctx, clusterArn, b)
+	}, logger)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	if b.BootstrapBrokerStringSaslIam == nil {
-		return nil, errors.WithStack(errors.New("MSK cluster does not have IAM authentication enabled. IAM authentication must be enabled before managing topics using this CloudFormation custom resource."))
+	logger.Sugar().Infow("Operation Finished", "Name", "GetBootstrapBrokers", "Connectivity", p.connectivity, "BootstrapBrokers", brokers)
+	mechanism := aws.ManagedStreamingIAM(func(ctx context.Context) (aws.Auth, error) {
+		cfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return aws.Auth{}, errors.WithStack(err)
+		}
+		creds, err := cfg.Credentials.Retrieve(ctx)
+		if err != nil {
+			return aws.Auth{}, errors.WithStack(err)
+		}
+		return aws.Auth{
+			AccessKey:    creds.AccessKeyID,
+			SecretKey:    creds.SecretAccessKey,
+			SessionToken: creds.SessionToken,
+		}, nil
+	})
+	cl, err := newSASLKafkaClient(ctx, brokers, mechanism, p.clientID, p.dialTimeout, p.requestTimeout, p.createTopicTimeout)
+	if err != nil {
+		return nil, nil, err
 	}
-	logger.Sugar().Infow("Operation Finished", "Name", "GetBootstrapBrokers", "BootstrapBrokerStringSaslIam", *b.BootstrapBrokerStringSaslIam)
-	cl, err := kgo.NewClient(
-		kgo.SeedBrokers(strings.Split(*b.BootstrapBrokerStringSaslIam, ",")...),
-		kgo.SASL(aws.ManagedStreamingIAM(func(ctx context.Context) (aws.Auth, error) {
-			cfg, err := config.LoadDefaultConfig(ctx)
-			if err != nil {
-				return aws.Auth{}, errors.WithStack(err)
-			}
-			creds, err := cfg.Credentials.Retrieve(ctx)
-			if err != nil {
-				return aws.Auth{}, errors.WithStack(err)
+	return cl, b, nil
+}
+
+// Describes the bootstrap brokers of the cluster and selects the broker
+// string to connect to with selectBrokers. MSK may briefly return failures
+// or empty broker strings while the cluster is scaled, therefore both are
+// retried with backoff. Errors of selectBrokers are not retried.
+func describeBootstrapBrokers(ctx context.Context, mskClient MskClient, retry *retryPolicy, clusterArn string, selectBrokers func(*kafka.GetBootstrapBrokersOutput) (string, error), logger *zap.Logger) (*kafka.GetBootstrapBrokersOutput, string, error) {
+	var b *kafka.GetBootstrapBrokersOutput
+	var brokers string
+	err := retry.Do(ctx, func() error {
+		var err error
+		b, err = mskClient.GetBootstrapBrokers(ctx, &kafka.GetBootstrapBrokersInput{ClusterArn: &clusterArn})
+		if err != nil {
+			logger.Sugar().Warnw("Operation Failed", "Name", "GetBootstrapBrokers", "Error", err)
+			if !isRetriable(err) {
+				return permanent(errors.WithStack(err))
 			}
-			return aws.Auth{
-				AccessKey:    creds.AccessKeyID,
-				SecretKey:    creds.SecretAccessKey,
-				SessionToken: creds.SessionToken,
-			}, nil
-		})),
-		kgo.Dialer((&tls.Dialer{NetDialer: &net.Dialer{Timeout: 10 * time.Second}}).DialContext),
+			return errors.WithStack(err)
+		}
+		brokers, err = selectBrokers(b)
+		if err != nil {
+			return permanent(err)
+		}
+		if strings.Trim(brokers, " ,") == "" {
+			logger.Sugar().Warnw("Operation Failed", "Name", "GetBootstrapBrokers", "Error", "Empty bootstrap brokers")
+			return errors.WithStack(fmt.Errorf("MSK returned empty bootstrap brokers for cluster %s", clusterArn))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return b, brokers, nil
+}
+
+// Returns a client connecting to brokers over TLS and authenticating with
+// mechanism.
+func newSASLKafkaClient(ctx context.Context, brokers string, mechanism sasl.Mechanism, clientID string, dialTimeout, requestTimeout, createTopicTimeout time.Duration) (KafkaClient, error) {
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(strings.Split(brokers, ",")...),
+		kgo.ClientID(kafkaClientID(ctx, clientID)),
+		kgo.SASL(mechanism),
+		kgo.Dialer(newDialFunc((&tls.Dialer{NetDialer: &net.Dialer{Timeout: dialTimeout}}).DialContext, dialTimeout)),
+		kgo.RetryTimeout(requestTimeout),
 		kgo.MaxVersions(kversion.V2_4_0()),
 	)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	return kadm.NewClient(cl), nil
+	return newKafkaAdminClient(cl, createTopicTimeout), nil
+}
+
+// Returns the client ID TR identifies itself with in broker logs and
+// metrics. Requests made for a stack carry its short stack ID so that the
+// traffic can be correlated with CloudFormation events.
+func kafkaClientID(ctx context.Context, clientID string) string {
+	r := stackResourceFrom(ctx)
+	if r == nil || r.StackID == "" {
+		return clientID
+	}
+	return fmt.Sprintf("%s-%s", clientID, shortStackID(r.StackID))
+}
+
+// Returns the IAM bootstrap broker string of the cluster for the
+// connectivity of the provider. Clusters without the requested
+// connectivity enabled fail with a classified error.
+func (p *IamKafkaClientProvider) bootstrapBrokers(ctx context.Context, clusterArn string, b *kafka.GetBootstrapBrokersOutput) (string, error) {
+	if p.connectivity != BrokerConnectivityPublic {
+		if b.BootstrapBrokerStringSaslIam == nil {
+			return "", errors.WithStack(newClassifiedError(ErrCodeIamAuthDisabled, "MSK cluster does not have IAM authentication enabled. IAM authentication must be enabled before managing topics using this CloudFormation custom resource."))
+		}
+		return *b.BootstrapBrokerStringSaslIam, nil
+	}
+	if b.BootstrapBrokerStringPublicSaslIam != nil {
+		return *b.BootstrapBrokerStringPublicSaslIam, nil
+	}
+	serverless, err := isServerless(ctx, p.mskClient, clusterArn)
+	if err != nil {
+		return "", err
+	}
+	if serverless {
+		return "", errors.WithStack(newClassifiedError(ErrCodePublicAccessDisabled, "%s is %s but MSK Serverless clusters do not support public connectivity. Set it to %s and run TR function in the VPC of the cluster.", EnvBrokerConnectivity, BrokerConnectivityPublic, BrokerConnectivityPrivate))
+	}
+	if b.BootstrapBrokerStringSaslIam == nil {
+		return "", errors.WithStack(newClassifiedError(ErrCodeIamAuthDisabled, "MSK cluster does not have IAM authentication enabled. IAM authentication must be enabled before managing topics using this CloudFormation custom resource."))
+	}
+	return "", errors.WithStack(newClassifiedError(ErrCodePublicAccessDisabled, "%s is %s but MSK cluster does not have public access with IAM authentication enabled. Turn on public access in the cluster, or set it to %s.", EnvBrokerConnectivity, BrokerConnectivityPublic, BrokerConnectivityPrivate))
 }
 
-func NewIamKafkaClientProvider(mskClient MskClient) *IamKafkaClientProvider {
-	return &IamKafkaClientProvider{mskClient}
+func NewIamKafkaClientProvider(mskClient MskClient, settings *Settings) *IamKafkaClientProvider {
+	return &IamKafkaClientProvider{
+		mskClient:          mskClient,
+		dialTimeout:        settings.KafkaDialTimeout,
+		requestTimeout:     settings.KafkaRequestTimeout,
+		createTopicTimeout: settings.CreateTopicTimeout,
+		connectivity:       settings.BrokerConnectivity,
+		clientID:           settings.KafkaClientID,
+		bootstrapRetry:     settings.Retry.policy(settings.BootstrapMaxAttempts, 0),
+	}
 }
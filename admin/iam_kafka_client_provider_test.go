@@ -0,0 +1,211 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/admin/mocks"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kafka"
+	kt "github.com/aws/aws-sdk-go-v2/service/kafka/types"
+	"github.com/aws/smithy-go"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestNewDialFunc(t *testing.T) {
+	type testCase struct {
+		err      error
+		expected string
+	}
+
+	cases := map[string]testCase{
+		"Timeout": {
+			err:      &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded},
+			expected: "timed out connecting to broker b-1:9098 after 10s",
+		},
+		"Connection refused": {
+			err:      &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			expected: "unable to connect to broker b-1:9098: dial tcp: connection refused",
+		},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			dial := newDialFunc(func(context.Context, string, string) (net.Conn, error) {
+				return nil, c.err
+			}, 10*time.Second)
+
+			_, err := dial(context.TODO(), "tcp", "b-1:9098")
+
+			// kgo wraps dial errors before returning them.
+			err = fmt.Errorf("unable to dial: %w", err)
+			var cxe *connectError
+			assert.True(t, errors.As(err, &cxe))
+			assert.Equal(t, c.expected, cxe.Error())
+			assert.True(t, errors.Is(err, c.err))
+		})
+	}
+}
+
+func TestBootstrapBrokers(t *testing.T) {
+	type testCase struct {
+		connectivity string
+		brokers      *kafka.GetBootstrapBrokersOutput
+		clusterType  kt.ClusterType
+		expected     string
+		err          string
+	}
+
+	private := aws.String("b-1:9098")
+	public := aws.String("b-1-public:9198")
+	cases := map[string]testCase{
+		"Private": {
+			connectivity: BrokerConnectivityPrivate,
+			brokers:      &kafka.GetBootstrapBrokersOutput{BootstrapBrokerStringSaslIam: private, BootstrapBrokerStringPublicSaslIam: public},
+			expected:     "b-1:9098",
+		},
+		"Private IAM disabled": {
+			connectivity: BrokerConnectivityPrivate,
+			brokers:      &kafka.GetBootstrapBrokersOutput{},
+			err:          "TR002: MSK cluster does not have IAM authentication enabled.",
+		},
+		"Public": {
+			connectivity: BrokerConnectivityPublic,
+			brokers:      &kafka.GetBootstrapBrokersOutput{BootstrapBrokerStringSaslIam: private, BootstrapBrokerStringPublicSaslIam: public},
+			expected:     "b-1-public:9198",
+		},
+		"Public access disabled": {
+			connectivity: BrokerConnectivityPublic,
+			brokers:      &kafka.GetBootstrapBrokersOutput{BootstrapBrokerStringSaslIam: private},
+			clusterType:  kt.ClusterTypeProvisioned,
+			err:          "TR012: TR_BROKER_CONNECTIVITY is public but MSK cluster does not have public access with IAM authentication enabled.",
+		},
+		"Public serverless": {
+			connectivity: BrokerConnectivityPublic,
+			brokers:      &kafka.GetBootstrapBrokersOutput{BootstrapBrokerStringSaslIam: private},
+			clusterType:  kt.ClusterTypeServerless,
+			err:          "TR012: TR_BROKER_CONNECTIVITY is public but MSK Serverless clusters do not support public connectivity.",
+		},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			// Arrange
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			clusterArn := "arn:aws:kafka:us-east-1:123456789012:cluster/c/1"
+			mskClient := mocks.NewMockMskClient(ctrl)
+			if c.clusterType != "" {
+				mskClient.EXPECT().DescribeClusterV2(gomock.Any(), &kafka.DescribeClusterV2Input{ClusterArn: &clusterArn}).
+					Return(&kafka.DescribeClusterV2Output{ClusterInfo: &kt.Cluster{ClusterType: c.clusterType}}, error(nil))
+			}
+			settings := DefaultSettings()
+			settings.BrokerConnectivity = c.connectivity
+			p := NewIamKafkaClientProvider(mskClient, settings)
+
+			// Act
+			brokers, err := p.bootstrapBrokers(context.TODO(), clusterArn, c.brokers)
+
+			// Assert
+			if c.err != "" {
+				assert.Contains(t, describeError(err), c.err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, c.expected, brokers)
+			}
+		})
+	}
+}
+
+func TestKafkaClientID(t *testing.T) {
+	ctx := withStackResource(context.TODO(), newStackResource("stack", "Topic"))
+
+	assert.Equal(t, "amazon-msk-topic-resource-"+shortStackID("stack"), kafkaClientID(ctx, "amazon-msk-topic-resource"))
+	assert.Equal(t, "amazon-msk-topic-resource", kafkaClientID(context.TODO(), "amazon-msk-topic-resource"))
+}
+
+func TestDescribeBootstrapBrokers(t *testing.T) {
+	type testCase struct {
+		responses []*kafka.GetBootstrapBrokersOutput
+		errs      []error
+		selectErr error
+		expected  string
+		err       string
+	}
+
+	empty := &kafka.GetBootstrapBrokersOutput{BootstrapBrokerStringSaslIam: aws.String("")}
+	ready := &kafka.GetBootstrapBrokersOutput{BootstrapBrokerStringSaslIam: aws.String("b-1:9098,b-2:9098")}
+	cases := map[string]testCase{
+		"Available": {
+			responses: []*kafka.GetBootstrapBrokersOutput{ready},
+			errs:      []error{nil},
+			expected:  "b-1:9098,b-2:9098",
+		},
+		"Empty then available": {
+			responses: []*kafka.GetBootstrapBrokersOutput{empty, ready},
+			errs:      []error{nil, nil},
+			expected:  "b-1:9098,b-2:9098",
+		},
+		"Throttled then available": {
+			responses: []*kafka.GetBootstrapBrokersOutput{nil, ready},
+			errs:      []error{newResponseError(429, errors.New("TooManyRequestsException")), nil},
+			expected:  "b-1:9098,b-2:9098",
+		},
+		"Empty on every attempt": {
+			responses: []*kafka.GetBootstrapBrokersOutput{empty, empty, empty},
+			errs:      []error{nil, nil, nil},
+			err:       "MSK returned empty bootstrap brokers for cluster cluster",
+		},
+		"Access denied": {
+			responses: []*kafka.GetBootstrapBrokersOutput{nil},
+			errs:      []error{newResponseError(403, &smithy.GenericAPIError{Code: "AccessDeniedException"})},
+			err:       "AccessDeniedException",
+		},
+		"Authentication disabled": {
+			responses: []*kafka.GetBootstrapBrokersOutput{ready},
+			errs:      []error{nil},
+			selectErr: errors.New("disabled"),
+			err:       "disabled",
+		},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			// Arrange
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			ctx := context.TODO()
+			clusterArn := "cluster"
+			mskClient := mocks.NewMockMskClient(ctrl)
+			for i := range c.responses {
+				mskClient.EXPECT().GetBootstrapBrokers(ctx, &kafka.GetBootstrapBrokersInput{ClusterArn: &clusterArn}).Return(c.responses[i], c.errs[i])
+			}
+			retry := newRetryPolicy(3, time.Millisecond, time.Millisecond, 0)
+			retry.sleep = func(time.Duration) {}
+			selectBrokers := func(b *kafka.GetBootstrapBrokersOutput) (string, error) {
+				return aws.ToString(b.BootstrapBrokerStringSaslIam), c.selectErr
+			}
+
+			// Act
+			_, brokers, err := describeBootstrapBrokers(ctx, mskClient, retry, clusterArn, selectBrokers, zap.NewNop())
+
+			// Assert
+			if c.err != "" {
+				assert.ErrorContains(t, err, c.err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, c.expected, brokers)
+			}
+		})
+	}
+}
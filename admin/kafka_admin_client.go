@@ -0,0 +1,143 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// kafkaAdminClient extends kadm.Client with requests kadm does not expose.
+type kafkaAdminClient struct {
+	*kadm.Client
+	cl *kgo.Client
+	// Time the controller is given to create a topic before the request
+	// times out.
+	createTopicTimeout time.Duration
+}
+
+func newKafkaAdminClient(cl *kgo.Client, createTopicTimeout time.Duration) *kafkaAdminClient {
+	return &kafkaAdminClient{Client: kadm.NewClient(cl), cl: cl, createTopicTimeout: createTopicTimeout}
+}
+
+// Creates a topic. When assignment is not empty, replicas are placed on
+// the brokers it specifies and partitions and replicationFactor are
+// implied by it. Otherwise Kafka chooses where to place them.
+func (c *kafkaAdminClient) CreateTopic(ctx context.Context, partitions int32, replicationFactor int16, configs map[string]*string, assignment map[int32][]int32, topic string) (kadm.CreateTopicResponse, error) {
+	req := kmsg.NewCreateTopicsRequest()
+	req.TimeoutMillis = int32(c.createTopicTimeout.Milliseconds())
+	rt := kmsg.NewCreateTopicsRequestTopic()
+	rt.Topic = topic
+	rt.NumPartitions = partitions
+	rt.ReplicationFactor = replicationFactor
+	if len(assignment) > 0 {
+		rt.NumPartitions = -1
+		rt.ReplicationFactor = -1
+	}
+	for _, p := range sortedPartitions(assignment) {
+		ra := kmsg.NewCreateTopicsRequestTopicReplicaAssignment()
+		ra.Partition = p
+		ra.Replicas = assignment[p]
+		rt.ReplicaAssignment = append(rt.ReplicaAssignment, ra)
+	}
+	for k, v := range configs {
+		rc := kmsg.NewCreateTopicsRequestTopicConfig()
+		rc.Name = k
+		rc.Value = v
+		rt.Configs = append(rt.Configs, rc)
+	}
+	req.Topics = append(req.Topics, rt)
+	resp, err := req.RequestWith(ctx, c.cl)
+	if err != nil {
+		return kadm.CreateTopicResponse{}, err
+	}
+	for _, t := range resp.Topics {
+		if t.Topic == topic {
+			r := kadm.CreateTopicResponse{Topic: t.Topic, ID: t.TopicID, Err: kerr.ErrorForCode(t.ErrorCode)}
+			return r, createTopicError(r.Err, c.createTopicTimeout)
+		}
+	}
+	return kadm.CreateTopicResponse{}, errors.New("requested topic was not part of create topic response")
+}
+
+// Deletes the ACLs matching any of filters in a single request. Unlike
+// kadm.Client.DeleteACLs, filters may target different resources with
+// different operations. Results are in the order of filters.
+func (c *kafkaAdminClient) DeleteACLFilters(ctx context.Context, filters []kmsg.DeleteACLsRequestFilter) (kadm.DeleteACLsResults, error) {
+	req := kmsg.NewPtrDeleteACLsRequest()
+	req.Filters = filters
+	resp, err := req.RequestWith(ctx, c.cl)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Results) != len(req.Filters) {
+		return nil, fmt.Errorf("received %d results to %d filters", len(resp.Results), len(req.Filters))
+	}
+	rs := make(kadm.DeleteACLsResults, 0, len(resp.Results))
+	for i, r := range resp.Results {
+		f := &req.Filters[i]
+		var deleted kadm.DeletedACLs
+		for _, m := range r.MatchingACLs {
+			deleted = append(deleted, kadm.DeletedACL{
+				Principal:  m.Principal,
+				Host:       m.Host,
+				Type:       m.ResourceType,
+				Name:       m.ResourceName,
+				Pattern:    m.ResourcePatternType,
+				Operation:  m.Operation,
+				Permission: m.PermissionType,
+				Err:        kerr.ErrorForCode(m.ErrorCode),
+			})
+		}
+		rs = append(rs, kadm.DeleteACLsResult{
+			Principal:  f.Principal,
+			Host:       f.Host,
+			Type:       f.ResourceType,
+			Name:       f.ResourceName,
+			Pattern:    f.ResourcePatternType,
+			Operation:  f.Operation,
+			Permission: f.PermissionType,
+			Deleted:    deleted,
+			Err:        kerr.ErrorForCode(r.ErrorCode),
+		})
+	}
+	return rs, nil
+}
+
+// Returns the described configs of topic. Configs are looked up by name
+// since a response may describe other resources or none at all.
+func topicConfig(configs kadm.ResourceConfigs, topic string) (kadm.ResourceConfig, error) {
+	for _, rc := range configs {
+		if rc.Name == topic {
+			return rc, nil
+		}
+	}
+	return kadm.ResourceConfig{}, errors.WithStack(fmt.Errorf("configs of topic %s were not described", topic))
+}
+
+// Topics of large clusters may take longer to create than the controller
+// is given. Timeouts are classified so that operators know to raise it.
+func createTopicError(err error, timeout time.Duration) error {
+	if errors.Is(err, kerr.RequestTimedOut) {
+		return newClassifiedError(ErrCodeCreateTopicTimeout, "Cluster did not create the topic within %s. The topic may still be created. Increase %s and retry.", timeout, EnvCreateTopicTimeout)
+	}
+	return err
+}
+
+func sortedPartitions(assignment map[int32][]int32) []int32 {
+	ps := make([]int32, 0, len(assignment))
+	for p := range assignment {
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i] < ps[j] })
+	return ps
+}
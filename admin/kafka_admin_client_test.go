@@ -0,0 +1,54 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+func TestTopicConfig(t *testing.T) {
+	type testCase struct {
+		configs  kadm.ResourceConfigs
+		expected kadm.ResourceConfig
+		err      string
+	}
+
+	a := kadm.ResourceConfig{Name: "a", Configs: []kadm.Config{{Key: "retention.ms", Value: aws.String("1")}}}
+	b := kadm.ResourceConfig{Name: "b", Configs: []kadm.Config{{Key: "retention.ms", Value: aws.String("2")}}}
+	cases := map[string]testCase{
+		"Single topic": {
+			configs:  kadm.ResourceConfigs{b},
+			expected: b,
+		},
+		"Topic after other topic": {
+			configs:  kadm.ResourceConfigs{a, b},
+			expected: b,
+		},
+		"Topic missing": {
+			configs: kadm.ResourceConfigs{a},
+			err:     "configs of topic b were not described",
+		},
+		"No topics": {
+			configs: kadm.ResourceConfigs{},
+			err:     "configs of topic b were not described",
+		},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			rc, err := topicConfig(c.configs, "b")
+
+			if c.err != "" {
+				assert.EqualError(t, err, c.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, rc)
+		})
+	}
+}
@@ -5,11 +5,11 @@ package admin
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/aws-samples/amazon-msk-topic-resource/types"
 
 	"github.com/aws/aws-sdk-go-v2/service/kafka"
+	kt "github.com/aws/aws-sdk-go-v2/service/kafka/types"
 	"github.com/pkg/errors"
 )
 
@@ -17,38 +17,59 @@ type KmsKeyResolverService interface {
 	Resolve(ctx context.Context, info *types.TopicInfo) (string, error)
 }
 
+// Default name of the cluster tag holding the KMS key ARN.
 const TagKmsKey = "TR-KMS-KEY"
 
 type kmsKeyResolver struct {
 	mskClient MskClient
+	tag       string
 }
 
-func newKmsKeyResolver(mskClient MskClient) *kmsKeyResolver {
+func newKmsKeyResolver(mskClient MskClient, tag string) *kmsKeyResolver {
 	return &kmsKeyResolver{
 		mskClient: mskClient,
+		tag:       tag,
 	}
 }
 
 // KMS key used for encrypting SecretsManager secrets is expected to be created and
 // managed along with MSK cluster. MSK cluster administrators should store KMS key
-// ARN under a tag named TR-KMS-KEY in MSK cluster so that TR function can resolve
-// it.
-// If info has users and KMS key cannot be resolved as per above, this function
-// returns an error.
+// ARN under a tag named TR-KMS-KEY (or the tag name configured with
+// TR_KMS_KEY_TAG) in MSK cluster so that TR function can resolve it.
+// If info has SASL/SCRAM users and KMS key cannot be resolved as per above, this
+// function returns an error. It also fails when SASL/SCRAM authentication is not
+// enabled in the cluster, as secrets of such users cannot be associated with it.
 func (a *kmsKeyResolver) Resolve(ctx context.Context, info *types.TopicInfo) (string, error) {
 	var kmsKey string
 	// If we have to setup users ensure that cluster has a kms key.
-	if len(info.Users) > 0 {
+	if hasScramUsers(info) {
 		cluster, err := a.mskClient.DescribeCluster(ctx, &kafka.DescribeClusterInput{
 			ClusterArn: &info.ClusterArn,
 		})
 		if err != nil {
 			return "", errors.WithStack(err)
 		}
+		if !scramEnabled(cluster.ClusterInfo) {
+			return "", errors.WithStack(newClassifiedError(ErrCodeScramAuthDisabled, "MSK cluster does not have SASL/SCRAM authentication enabled. SASL/SCRAM authentication must be enabled before creating users using this CloudFormation custom resource."))
+		}
 		var ok bool
-		if kmsKey, ok = cluster.ClusterInfo.Tags[TagKmsKey]; !ok {
-			return "", errors.WithStack(fmt.Errorf("MSK cluster must have a tag named %s specifying the ARN of KMS key used for encrypting SASL/SCRAM credentials.", TagKmsKey))
+		if kmsKey, ok = cluster.ClusterInfo.Tags[a.tag]; !ok {
+			return "", errors.WithStack(newClassifiedError(ErrCodeKmsKeyTagMissing, "MSK cluster must have a tag named %s specifying the ARN of KMS key used for encrypting SASL/SCRAM credentials.", a.tag))
 		}
 	}
 	return kmsKey, nil
 }
+
+func scramEnabled(c *kt.ClusterInfo) bool {
+	ca := c.ClientAuthentication
+	return ca != nil && ca.Sasl != nil && ca.Sasl.Scram != nil && ca.Sasl.Scram.Enabled
+}
+
+func hasScramUsers(info *types.TopicInfo) bool {
+	for i := range info.Users {
+		if !info.Users[i].Secretless() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"testing"
+
+	tt "github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/admin/mocks"
+
+	"github.com/aws/aws-sdk-go-v2/service/kafka"
+	kt "github.com/aws/aws-sdk-go-v2/service/kafka/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKmsKeyResolver(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type testCase struct {
+		name        string
+		users       []tt.User
+		tag         string
+		clusterInfo *kt.ClusterInfo
+		key         string
+		errCode     string
+		err         string
+	}
+
+	clusterArn := "cluster"
+	scram := &kt.ClientAuthentication{Sasl: &kt.Sasl{Scram: &kt.Scram{Enabled: true}}}
+	iamOnly := &kt.ClientAuthentication{Sasl: &kt.Sasl{Iam: &kt.Iam{Enabled: true}, Scram: &kt.Scram{Enabled: false}}}
+	alice := tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}
+	tls := tt.User{Username: "bob", AuthType: tt.AuthTypeTLS, Principal: "CN=bob", Permissions: []tt.Permission{tt.PermissionRead}}
+	iam := tt.User{Username: "carol", IamPrincipal: "arn:aws:iam::123456789012:role/carol", Permissions: []tt.Permission{tt.PermissionRead}}
+
+	cases := []testCase{
+		{
+			name: "No users",
+		},
+		{
+			name:  "Only TLS users",
+			users: []tt.User{tls},
+		},
+		{
+			name:  "Only IAM users",
+			users: []tt.User{iam},
+		},
+		{
+			name:        "SASL/SCRAM users",
+			users:       []tt.User{alice},
+			clusterInfo: &kt.ClusterInfo{ClientAuthentication: scram, Tags: map[string]string{TagKmsKey: "key"}},
+			key:         "key",
+		},
+		{
+			name:        "SASL/SCRAM disabled",
+			users:       []tt.User{alice},
+			clusterInfo: &kt.ClusterInfo{ClientAuthentication: iamOnly, Tags: map[string]string{TagKmsKey: "key"}},
+			errCode:     ErrCodeScramAuthDisabled,
+		},
+		{
+			name:        "Client authentication not described",
+			users:       []tt.User{alice},
+			clusterInfo: &kt.ClusterInfo{Tags: map[string]string{TagKmsKey: "key"}},
+			errCode:     ErrCodeScramAuthDisabled,
+		},
+		{
+			name:        "Tag missing",
+			users:       []tt.User{alice},
+			clusterInfo: &kt.ClusterInfo{ClientAuthentication: scram},
+			errCode:     ErrCodeKmsKeyTagMissing,
+		},
+		{
+			name:        "Configured tag",
+			users:       []tt.User{alice},
+			tag:         "kms-key-arn",
+			clusterInfo: &kt.ClusterInfo{ClientAuthentication: scram, Tags: map[string]string{TagKmsKey: "other", "kms-key-arn": "key"}},
+			key:         "key",
+		},
+		{
+			name:        "Configured tag missing",
+			users:       []tt.User{alice},
+			tag:         "kms-key-arn",
+			clusterInfo: &kt.ClusterInfo{ClientAuthentication: scram, Tags: map[string]string{TagKmsKey: "key"}},
+			errCode:     ErrCodeKmsKeyTagMissing,
+			err:         "MSK cluster must have a tag named kms-key-arn specifying the ARN of KMS key used for encrypting SASL/SCRAM credentials.",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			ctx := context.TODO()
+			mskClient := mocks.NewMockMskClient(ctrl)
+			if c.tag == "" {
+				c.tag = TagKmsKey
+			}
+			if c.clusterInfo != nil {
+				mskClient.EXPECT().DescribeCluster(ctx, &kafka.DescribeClusterInput{ClusterArn: &clusterArn}).Return(&kafka.DescribeClusterOutput{ClusterInfo: c.clusterInfo}, error(nil))
+			}
+
+			// Act
+			key, err := newKmsKeyResolver(mskClient, c.tag).Resolve(ctx, &tt.TopicInfo{ClusterArn: clusterArn, Users: c.users})
+
+			// Assert
+			if c.errCode == "" {
+				assert.Nil(t, err)
+				assert.Equal(t, c.key, key)
+			} else {
+				var ce *classifiedError
+				assert.ErrorAs(t, err, &ce)
+				assert.Equal(t, c.errCode, ce.code)
+				if c.err != "" {
+					assert.Equal(t, c.err, ce.Error())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	LogFormatJSON    string = "json"
+	LogFormatConsole string = "console"
+)
+
+// Builds the logger of TR function. JSON output follows zap production
+// conventions so that it can be queried with CloudWatch Logs Insights,
+// console output is easier to read while triaging incidents.
+func newLogger(settings *Settings) (*zap.Logger, error) {
+	level, err := zapcore.ParseLevel(settings.LogLevel)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cfg := zap.NewProductionConfig()
+	if settings.LogFormat == LogFormatConsole {
+		cfg.EncoderConfig = zap.NewDevelopmentEncoderConfig()
+	}
+	cfg.Encoding = settings.LogFormat
+	cfg.Level = zap.NewAtomicLevelAt(level)
+	logger, err := cfg.Build()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return logger, nil
+}
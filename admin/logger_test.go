@@ -0,0 +1,34 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestNewLogger(t *testing.T) {
+	// Defaults
+	logger, err := newLogger(DefaultSettings())
+	assert.Nil(t, err)
+	assert.True(t, logger.Core().Enabled(zap.InfoLevel))
+	assert.False(t, logger.Core().Enabled(zap.DebugLevel))
+
+	// Debug logging in console format
+	settings := DefaultSettings()
+	settings.LogLevel = "debug"
+	settings.LogFormat = LogFormatConsole
+	logger, err = newLogger(settings)
+	assert.Nil(t, err)
+	assert.True(t, logger.Core().Enabled(zap.DebugLevel))
+
+	// Warnings only
+	settings = DefaultSettings()
+	settings.LogLevel = "warn"
+	logger, err = newLogger(settings)
+	assert.Nil(t, err)
+	assert.False(t, logger.Core().Enabled(zap.InfoLevel))
+}
@@ -0,0 +1,78 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+const MetricsNamespace = "MSKTopicResource"
+
+const (
+	unitCount        = "Count"
+	unitMilliseconds = "Milliseconds"
+)
+
+// metrics writes CloudWatch Embedded Metric Format (EMF) records.
+// Lambda forwards stdout to CloudWatch Logs, which extracts the metrics
+// from these records without any additional API calls.
+// https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/CloudWatch_Embedded_Metric_Format_Specification.html
+type metrics struct {
+	enabled bool
+	out     io.Writer
+	now     func() time.Time
+	mu      sync.Mutex
+}
+
+func newMetrics(enabled bool, out io.Writer) *metrics {
+	return &metrics{
+		enabled: enabled,
+		out:     out,
+		now:     time.Now,
+	}
+}
+
+func (m *metrics) Count(name string, value int, dimensions map[string]string) {
+	m.put(name, unitCount, float64(value), dimensions)
+}
+
+// Records the time elapsed since start.
+func (m *metrics) Latency(name string, start time.Time, dimensions map[string]string) {
+	m.put(name, unitMilliseconds, float64(m.now().Sub(start).Milliseconds()), dimensions)
+}
+
+func (m *metrics) put(name, unit string, value float64, dimensions map[string]string) {
+	if m == nil || !m.enabled {
+		return
+	}
+	keys := make([]string, 0, len(dimensions))
+	record := make(map[string]interface{})
+	for k, v := range dimensions {
+		keys = append(keys, k)
+		record[k] = v
+	}
+	sort.Strings(keys)
+	record[name] = value
+	record["_aws"] = map[string]interface{}{
+		"Timestamp": m.now().UnixMilli(),
+		"CloudWatchMetrics": []map[string]interface{}{
+			{
+				"Namespace":  MetricsNamespace,
+				"Dimensions": [][]string{keys},
+				"Metrics":    []map[string]string{{"Name": name, "Unit": unit}},
+			},
+		},
+	}
+	buf, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.out.Write(append(buf, '\n'))
+}
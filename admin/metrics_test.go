@@ -0,0 +1,49 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	type testCase struct {
+		enabled bool
+		record  func(m *metrics)
+		output  string
+	}
+
+	cases := map[string]testCase{
+		"Count": {
+			enabled: true,
+			record:  func(m *metrics) { m.Count("Requests", 1, map[string]string{"RequestType": "Create"}) },
+			output:  `{"RequestType":"Create","Requests":1,"_aws":{"CloudWatchMetrics":[{"Dimensions":[["RequestType"]],"Metrics":[{"Name":"Requests","Unit":"Count"}],"Namespace":"MSKTopicResource"}],"Timestamp":1700000000000}}` + "\n",
+		},
+		"Latency": {
+			enabled: true,
+			record: func(m *metrics) {
+				m.Latency("OperationLatency", now.Add(-250*time.Millisecond), map[string]string{"Operation": "BatchAssociateScramSecret"})
+			},
+			output: `{"Operation":"BatchAssociateScramSecret","OperationLatency":250,"_aws":{"CloudWatchMetrics":[{"Dimensions":[["Operation"]],"Metrics":[{"Name":"OperationLatency","Unit":"Milliseconds"}],"Namespace":"MSKTopicResource"}],"Timestamp":1700000000000}}` + "\n",
+		},
+		"Disabled": {
+			enabled: false,
+			record:  func(m *metrics) { m.Count("Requests", 1, nil) },
+			output:  "",
+		},
+	}
+
+	for k, c := range cases {
+		out := &bytes.Buffer{}
+		m := newMetrics(c.enabled, out)
+		m.now = func() time.Time { return now }
+		c.record(m)
+		assert.Equal(t, c.output, out.String(), k)
+	}
+}
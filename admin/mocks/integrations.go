@@ -7,12 +7,14 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	kafka "github.com/aws/aws-sdk-go-v2/service/kafka"
 	kms "github.com/aws/aws-sdk-go-v2/service/kms"
 	secretsmanager "github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	gomock "github.com/golang/mock/gomock"
 	kadm "github.com/twmb/franz-go/pkg/kadm"
+	kmsg "github.com/twmb/franz-go/pkg/kmsg"
 )
 
 // MockKafkaClient is a mock of KafkaClient interface.
@@ -58,6 +60,21 @@ func (mr *MockKafkaClientMockRecorder) AlterTopicConfigs(ctx, configs interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AlterTopicConfigs", reflect.TypeOf((*MockKafkaClient)(nil).AlterTopicConfigs), varargs...)
 }
 
+// CommitOffsets mocks base method.
+func (m *MockKafkaClient) CommitOffsets(ctx context.Context, group string, os kadm.Offsets) (kadm.OffsetResponses, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CommitOffsets", ctx, group, os)
+	ret0, _ := ret[0].(kadm.OffsetResponses)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CommitOffsets indicates an expected call of CommitOffsets.
+func (mr *MockKafkaClientMockRecorder) CommitOffsets(ctx, group, os interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CommitOffsets", reflect.TypeOf((*MockKafkaClient)(nil).CommitOffsets), ctx, group, os)
+}
+
 // CreateACLs mocks base method.
 func (m *MockKafkaClient) CreateACLs(ctx context.Context, b *kadm.ACLBuilder) (kadm.CreateACLsResults, error) {
 	m.ctrl.T.Helper()
@@ -74,18 +91,33 @@ func (mr *MockKafkaClientMockRecorder) CreateACLs(ctx, b interface{}) *gomock.Ca
 }
 
 // CreateTopic mocks base method.
-func (m *MockKafkaClient) CreateTopic(ctx context.Context, partitions int32, replicationFactor int16, configs map[string]*string, topic string) (kadm.CreateTopicResponse, error) {
+func (m *MockKafkaClient) CreateTopic(ctx context.Context, partitions int32, replicationFactor int16, configs map[string]*string, assignment map[int32][]int32, topic string) (kadm.CreateTopicResponse, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateTopic", ctx, partitions, replicationFactor, configs, topic)
+	ret := m.ctrl.Call(m, "CreateTopic", ctx, partitions, replicationFactor, configs, assignment, topic)
 	ret0, _ := ret[0].(kadm.CreateTopicResponse)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateTopic indicates an expected call of CreateTopic.
-func (mr *MockKafkaClientMockRecorder) CreateTopic(ctx, partitions, replicationFactor, configs, topic interface{}) *gomock.Call {
+func (mr *MockKafkaClientMockRecorder) CreateTopic(ctx, partitions, replicationFactor, configs, assignment, topic interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTopic", reflect.TypeOf((*MockKafkaClient)(nil).CreateTopic), ctx, partitions, replicationFactor, configs, topic)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTopic", reflect.TypeOf((*MockKafkaClient)(nil).CreateTopic), ctx, partitions, replicationFactor, configs, assignment, topic)
+}
+
+// DeleteACLFilters mocks base method.
+func (m *MockKafkaClient) DeleteACLFilters(ctx context.Context, filters []kmsg.DeleteACLsRequestFilter) (kadm.DeleteACLsResults, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteACLFilters", ctx, filters)
+	ret0, _ := ret[0].(kadm.DeleteACLsResults)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteACLFilters indicates an expected call of DeleteACLFilters.
+func (mr *MockKafkaClientMockRecorder) DeleteACLFilters(ctx, filters interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteACLFilters", reflect.TypeOf((*MockKafkaClient)(nil).DeleteACLFilters), ctx, filters)
 }
 
 // DeleteACLs mocks base method.
@@ -138,6 +170,21 @@ func (mr *MockKafkaClientMockRecorder) DescribeACLs(ctx, b interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeACLs", reflect.TypeOf((*MockKafkaClient)(nil).DescribeACLs), ctx, b)
 }
 
+// DescribeAllLogDirs mocks base method.
+func (m *MockKafkaClient) DescribeAllLogDirs(ctx context.Context, s kadm.TopicsSet) (kadm.DescribedAllLogDirs, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DescribeAllLogDirs", ctx, s)
+	ret0, _ := ret[0].(kadm.DescribedAllLogDirs)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeAllLogDirs indicates an expected call of DescribeAllLogDirs.
+func (mr *MockKafkaClientMockRecorder) DescribeAllLogDirs(ctx, s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeAllLogDirs", reflect.TypeOf((*MockKafkaClient)(nil).DescribeAllLogDirs), ctx, s)
+}
+
 // DescribeTopicConfigs mocks base method.
 func (m *MockKafkaClient) DescribeTopicConfigs(ctx context.Context, topics ...string) (kadm.ResourceConfigs, error) {
 	m.ctrl.T.Helper()
@@ -158,6 +205,96 @@ func (mr *MockKafkaClientMockRecorder) DescribeTopicConfigs(ctx interface{}, top
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeTopicConfigs", reflect.TypeOf((*MockKafkaClient)(nil).DescribeTopicConfigs), varargs...)
 }
 
+// FetchOffsets mocks base method.
+func (m *MockKafkaClient) FetchOffsets(ctx context.Context, group string) (kadm.OffsetResponses, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchOffsets", ctx, group)
+	ret0, _ := ret[0].(kadm.OffsetResponses)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchOffsets indicates an expected call of FetchOffsets.
+func (mr *MockKafkaClientMockRecorder) FetchOffsets(ctx, group interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchOffsets", reflect.TypeOf((*MockKafkaClient)(nil).FetchOffsets), ctx, group)
+}
+
+// ListBrokers mocks base method.
+func (m *MockKafkaClient) ListBrokers(ctx context.Context) (kadm.BrokerDetails, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBrokers", ctx)
+	ret0, _ := ret[0].(kadm.BrokerDetails)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListBrokers indicates an expected call of ListBrokers.
+func (mr *MockKafkaClientMockRecorder) ListBrokers(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBrokers", reflect.TypeOf((*MockKafkaClient)(nil).ListBrokers), ctx)
+}
+
+// ListEndOffsets mocks base method.
+func (m *MockKafkaClient) ListEndOffsets(ctx context.Context, topics ...string) (kadm.ListedOffsets, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range topics {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ListEndOffsets", varargs...)
+	ret0, _ := ret[0].(kadm.ListedOffsets)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListEndOffsets indicates an expected call of ListEndOffsets.
+func (mr *MockKafkaClientMockRecorder) ListEndOffsets(ctx interface{}, topics ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, topics...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEndOffsets", reflect.TypeOf((*MockKafkaClient)(nil).ListEndOffsets), varargs...)
+}
+
+// ListGroups mocks base method.
+func (m *MockKafkaClient) ListGroups(ctx context.Context, filterStates ...string) (kadm.ListedGroups, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range filterStates {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ListGroups", varargs...)
+	ret0, _ := ret[0].(kadm.ListedGroups)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListGroups indicates an expected call of ListGroups.
+func (mr *MockKafkaClientMockRecorder) ListGroups(ctx interface{}, filterStates ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, filterStates...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListGroups", reflect.TypeOf((*MockKafkaClient)(nil).ListGroups), varargs...)
+}
+
+// ListStartOffsets mocks base method.
+func (m *MockKafkaClient) ListStartOffsets(ctx context.Context, topics ...string) (kadm.ListedOffsets, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range topics {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ListStartOffsets", varargs...)
+	ret0, _ := ret[0].(kadm.ListedOffsets)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListStartOffsets indicates an expected call of ListStartOffsets.
+func (mr *MockKafkaClientMockRecorder) ListStartOffsets(ctx interface{}, topics ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, topics...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListStartOffsets", reflect.TypeOf((*MockKafkaClient)(nil).ListStartOffsets), varargs...)
+}
+
 // ListTopics mocks base method.
 func (m *MockKafkaClient) ListTopics(ctx context.Context, topics ...string) (kadm.TopicDetails, error) {
 	m.ctrl.T.Helper()
@@ -221,6 +358,26 @@ func (mr *MockKmsClientMockRecorder) CreateGrant(ctx, params interface{}, optFns
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateGrant", reflect.TypeOf((*MockKmsClient)(nil).CreateGrant), varargs...)
 }
 
+// ListGrants mocks base method.
+func (m *MockKmsClient) ListGrants(ctx context.Context, params *kms.ListGrantsInput, optFns ...func(*kms.Options)) (*kms.ListGrantsOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ListGrants", varargs...)
+	ret0, _ := ret[0].(*kms.ListGrantsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListGrants indicates an expected call of ListGrants.
+func (mr *MockKmsClientMockRecorder) ListGrants(ctx, params interface{}, optFns ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListGrants", reflect.TypeOf((*MockKmsClient)(nil).ListGrants), varargs...)
+}
+
 // RevokeGrant mocks base method.
 func (m *MockKmsClient) RevokeGrant(ctx context.Context, params *kms.RevokeGrantInput, optFns ...func(*kms.Options)) (*kms.RevokeGrantOutput, error) {
 	m.ctrl.T.Helper()
@@ -284,6 +441,26 @@ func (mr *MockSecretsManagerClientMockRecorder) CreateSecret(ctx, params interfa
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSecret", reflect.TypeOf((*MockSecretsManagerClient)(nil).CreateSecret), varargs...)
 }
 
+// DeleteResourcePolicy mocks base method.
+func (m *MockSecretsManagerClient) DeleteResourcePolicy(ctx context.Context, params *secretsmanager.DeleteResourcePolicyInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DeleteResourcePolicyOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DeleteResourcePolicy", varargs...)
+	ret0, _ := ret[0].(*secretsmanager.DeleteResourcePolicyOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteResourcePolicy indicates an expected call of DeleteResourcePolicy.
+func (mr *MockSecretsManagerClientMockRecorder) DeleteResourcePolicy(ctx, params interface{}, optFns ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteResourcePolicy", reflect.TypeOf((*MockSecretsManagerClient)(nil).DeleteResourcePolicy), varargs...)
+}
+
 // DeleteSecret mocks base method.
 func (m *MockSecretsManagerClient) DeleteSecret(ctx context.Context, params *secretsmanager.DeleteSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DeleteSecretOutput, error) {
 	m.ctrl.T.Helper()
@@ -324,6 +501,46 @@ func (mr *MockSecretsManagerClientMockRecorder) DescribeSecret(ctx, params inter
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeSecret", reflect.TypeOf((*MockSecretsManagerClient)(nil).DescribeSecret), varargs...)
 }
 
+// GetResourcePolicy mocks base method.
+func (m *MockSecretsManagerClient) GetResourcePolicy(ctx context.Context, params *secretsmanager.GetResourcePolicyInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetResourcePolicyOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetResourcePolicy", varargs...)
+	ret0, _ := ret[0].(*secretsmanager.GetResourcePolicyOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetResourcePolicy indicates an expected call of GetResourcePolicy.
+func (mr *MockSecretsManagerClientMockRecorder) GetResourcePolicy(ctx, params interface{}, optFns ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetResourcePolicy", reflect.TypeOf((*MockSecretsManagerClient)(nil).GetResourcePolicy), varargs...)
+}
+
+// GetSecretValue mocks base method.
+func (m *MockSecretsManagerClient) GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetSecretValue", varargs...)
+	ret0, _ := ret[0].(*secretsmanager.GetSecretValueOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSecretValue indicates an expected call of GetSecretValue.
+func (mr *MockSecretsManagerClientMockRecorder) GetSecretValue(ctx, params interface{}, optFns ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSecretValue", reflect.TypeOf((*MockSecretsManagerClient)(nil).GetSecretValue), varargs...)
+}
+
 // ListSecrets mocks base method.
 func (m *MockSecretsManagerClient) ListSecrets(ctx context.Context, params *secretsmanager.ListSecretsInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretsOutput, error) {
 	m.ctrl.T.Helper()
@@ -364,6 +581,149 @@ func (mr *MockSecretsManagerClientMockRecorder) PutResourcePolicy(ctx, params in
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutResourcePolicy", reflect.TypeOf((*MockSecretsManagerClient)(nil).PutResourcePolicy), varargs...)
 }
 
+// TagResource mocks base method.
+func (m *MockSecretsManagerClient) TagResource(ctx context.Context, params *secretsmanager.TagResourceInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.TagResourceOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "TagResource", varargs...)
+	ret0, _ := ret[0].(*secretsmanager.TagResourceOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TagResource indicates an expected call of TagResource.
+func (mr *MockSecretsManagerClientMockRecorder) TagResource(ctx, params interface{}, optFns ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TagResource", reflect.TypeOf((*MockSecretsManagerClient)(nil).TagResource), varargs...)
+}
+
+// UntagResource mocks base method.
+func (m *MockSecretsManagerClient) UntagResource(ctx context.Context, params *secretsmanager.UntagResourceInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.UntagResourceOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "UntagResource", varargs...)
+	ret0, _ := ret[0].(*secretsmanager.UntagResourceOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UntagResource indicates an expected call of UntagResource.
+func (mr *MockSecretsManagerClientMockRecorder) UntagResource(ctx, params interface{}, optFns ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UntagResource", reflect.TypeOf((*MockSecretsManagerClient)(nil).UntagResource), varargs...)
+}
+
+// MockIamClient is a mock of IamClient interface.
+type MockIamClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockIamClientMockRecorder
+}
+
+// MockIamClientMockRecorder is the mock recorder for MockIamClient.
+type MockIamClientMockRecorder struct {
+	mock *MockIamClient
+}
+
+// NewMockIamClient creates a new mock instance.
+func NewMockIamClient(ctrl *gomock.Controller) *MockIamClient {
+	mock := &MockIamClient{ctrl: ctrl}
+	mock.recorder = &MockIamClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIamClient) EXPECT() *MockIamClientMockRecorder {
+	return m.recorder
+}
+
+// GetRole mocks base method.
+func (m *MockIamClient) GetRole(ctx context.Context, roleName string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRole", ctx, roleName)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetRole indicates an expected call of GetRole.
+func (mr *MockIamClientMockRecorder) GetRole(ctx, roleName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRole", reflect.TypeOf((*MockIamClient)(nil).GetRole), ctx, roleName)
+}
+
+// GetUser mocks base method.
+func (m *MockIamClient) GetUser(ctx context.Context, userName string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", ctx, userName)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockIamClientMockRecorder) GetUser(ctx, userName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockIamClient)(nil).GetUser), ctx, userName)
+}
+
+// MockS3Client is a mock of S3Client interface.
+type MockS3Client struct {
+	ctrl     *gomock.Controller
+	recorder *MockS3ClientMockRecorder
+}
+
+// MockS3ClientMockRecorder is the mock recorder for MockS3Client.
+type MockS3ClientMockRecorder struct {
+	mock *MockS3Client
+}
+
+// NewMockS3Client creates a new mock instance.
+func NewMockS3Client(ctrl *gomock.Controller) *MockS3Client {
+	mock := &MockS3Client{ctrl: ctrl}
+	mock.recorder = &MockS3ClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockS3Client) EXPECT() *MockS3ClientMockRecorder {
+	return m.recorder
+}
+
+// PresignGetObject mocks base method.
+func (m *MockS3Client) PresignGetObject(ctx context.Context, bucket, key string, expires time.Duration) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PresignGetObject", ctx, bucket, key, expires)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PresignGetObject indicates an expected call of PresignGetObject.
+func (mr *MockS3ClientMockRecorder) PresignGetObject(ctx, bucket, key, expires interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PresignGetObject", reflect.TypeOf((*MockS3Client)(nil).PresignGetObject), ctx, bucket, key, expires)
+}
+
+// PutObject mocks base method.
+func (m *MockS3Client) PutObject(ctx context.Context, bucket, key string, body []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutObject", ctx, bucket, key, body)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PutObject indicates an expected call of PutObject.
+func (mr *MockS3ClientMockRecorder) PutObject(ctx, bucket, key, body interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutObject", reflect.TypeOf((*MockS3Client)(nil).PutObject), ctx, bucket, key, body)
+}
+
 // MockMskClient is a mock of MskClient interface.
 type MockMskClient struct {
 	ctrl     *gomock.Controller
@@ -447,6 +807,26 @@ func (mr *MockMskClientMockRecorder) DescribeCluster(ctx, params interface{}, op
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeCluster", reflect.TypeOf((*MockMskClient)(nil).DescribeCluster), varargs...)
 }
 
+// DescribeClusterV2 mocks base method.
+func (m *MockMskClient) DescribeClusterV2(ctx context.Context, params *kafka.DescribeClusterV2Input, optFns ...func(*kafka.Options)) (*kafka.DescribeClusterV2Output, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DescribeClusterV2", varargs...)
+	ret0, _ := ret[0].(*kafka.DescribeClusterV2Output)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeClusterV2 indicates an expected call of DescribeClusterV2.
+func (mr *MockMskClientMockRecorder) DescribeClusterV2(ctx, params interface{}, optFns ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeClusterV2", reflect.TypeOf((*MockMskClient)(nil).DescribeClusterV2), varargs...)
+}
+
 // GetBootstrapBrokers mocks base method.
 func (m *MockMskClient) GetBootstrapBrokers(ctx context.Context, params *kafka.GetBootstrapBrokersInput, optFns ...func(*kafka.Options)) (*kafka.GetBootstrapBrokersOutput, error) {
 	m.ctrl.T.Helper()
@@ -466,3 +846,23 @@ func (mr *MockMskClientMockRecorder) GetBootstrapBrokers(ctx, params interface{}
 	varargs := append([]interface{}{ctx, params}, optFns...)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBootstrapBrokers", reflect.TypeOf((*MockMskClient)(nil).GetBootstrapBrokers), varargs...)
 }
+
+// ListScramSecrets mocks base method.
+func (m *MockMskClient) ListScramSecrets(ctx context.Context, params *kafka.ListScramSecretsInput, optFns ...func(*kafka.Options)) (*kafka.ListScramSecretsOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ListScramSecrets", varargs...)
+	ret0, _ := ret[0].(*kafka.ListScramSecretsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListScramSecrets indicates an expected call of ListScramSecrets.
+func (mr *MockMskClientMockRecorder) ListScramSecrets(ctx, params interface{}, optFns ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListScramSecrets", reflect.TypeOf((*MockMskClient)(nil).ListScramSecrets), varargs...)
+}
@@ -0,0 +1,79 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: admin/topic_marker.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	types "github.com/aws-samples/amazon-msk-topic-resource/types"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockTopicMarkerService is a mock of TopicMarkerService interface.
+type MockTopicMarkerService struct {
+	ctrl     *gomock.Controller
+	recorder *MockTopicMarkerServiceMockRecorder
+}
+
+// MockTopicMarkerServiceMockRecorder is the mock recorder for MockTopicMarkerService.
+type MockTopicMarkerServiceMockRecorder struct {
+	mock *MockTopicMarkerService
+}
+
+// NewMockTopicMarkerService creates a new mock instance.
+func NewMockTopicMarkerService(ctrl *gomock.Controller) *MockTopicMarkerService {
+	mock := &MockTopicMarkerService{ctrl: ctrl}
+	mock.recorder = &MockTopicMarkerServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTopicMarkerService) EXPECT() *MockTopicMarkerServiceMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method.
+func (m *MockTopicMarkerService) Delete(ctx context.Context, clusterArn, topic string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, clusterArn, topic)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockTopicMarkerServiceMockRecorder) Delete(ctx, clusterArn, topic interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockTopicMarkerService)(nil).Delete), ctx, clusterArn, topic)
+}
+
+// Get mocks base method.
+func (m *MockTopicMarkerService) Get(ctx context.Context, clusterArn, topic string) (*types.TopicMarker, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, clusterArn, topic)
+	ret0, _ := ret[0].(*types.TopicMarker)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockTopicMarkerServiceMockRecorder) Get(ctx, clusterArn, topic interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockTopicMarkerService)(nil).Get), ctx, clusterArn, topic)
+}
+
+// Put mocks base method.
+func (m *MockTopicMarkerService) Put(ctx context.Context, clusterArn, topic string, marker *types.TopicMarker) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Put", ctx, clusterArn, topic, marker)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Put indicates an expected call of Put.
+func (mr *MockTopicMarkerServiceMockRecorder) Put(ctx, clusterArn, topic, marker interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Put", reflect.TypeOf((*MockTopicMarkerService)(nil).Put), ctx, clusterArn, topic, marker)
+}
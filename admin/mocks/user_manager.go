@@ -10,6 +10,7 @@ import (
 
 	types "github.com/aws-samples/amazon-msk-topic-resource/types"
 	gomock "github.com/golang/mock/gomock"
+	kadm "github.com/twmb/franz-go/pkg/kadm"
 )
 
 // MockUserManagerService is a mock of UserManagerService interface.
@@ -35,18 +36,60 @@ func (m *MockUserManagerService) EXPECT() *MockUserManagerServiceMockRecorder {
 	return m.recorder
 }
 
+// ApplyACLs mocks base method.
+func (m *MockUserManagerService) ApplyACLs(ctx context.Context, acls []*kadm.ACLBuilder) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApplyACLs", ctx, acls)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ApplyACLs indicates an expected call of ApplyACLs.
+func (mr *MockUserManagerServiceMockRecorder) ApplyACLs(ctx, acls interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApplyACLs", reflect.TypeOf((*MockUserManagerService)(nil).ApplyACLs), ctx, acls)
+}
+
+// AssociateSecrets mocks base method.
+func (m *MockUserManagerService) AssociateSecrets(ctx context.Context, clusterArn string, secretArns []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AssociateSecrets", ctx, clusterArn, secretArns)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AssociateSecrets indicates an expected call of AssociateSecrets.
+func (mr *MockUserManagerServiceMockRecorder) AssociateSecrets(ctx, clusterArn, secretArns interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AssociateSecrets", reflect.TypeOf((*MockUserManagerService)(nil).AssociateSecrets), ctx, clusterArn, secretArns)
+}
+
+// CleanupOrphanedSecrets mocks base method.
+func (m *MockUserManagerService) CleanupOrphanedSecrets(ctx context.Context, users []types.User, shortStackID, clusterArn string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CleanupOrphanedSecrets", ctx, users, shortStackID, clusterArn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CleanupOrphanedSecrets indicates an expected call of CleanupOrphanedSecrets.
+func (mr *MockUserManagerServiceMockRecorder) CleanupOrphanedSecrets(ctx, users, shortStackID, clusterArn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CleanupOrphanedSecrets", reflect.TypeOf((*MockUserManagerService)(nil).CleanupOrphanedSecrets), ctx, users, shortStackID, clusterArn)
+}
+
 // CreateACLs mocks base method.
-func (m *MockUserManagerService) CreateACLs(ctx context.Context, topic, username, shortStackID string, permissions []types.Permission) error {
+func (m *MockUserManagerService) CreateACLs(ctx context.Context, topic string, u *types.User, shortStackID string, permissions []types.Permission) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateACLs", ctx, topic, username, shortStackID, permissions)
+	ret := m.ctrl.Call(m, "CreateACLs", ctx, topic, u, shortStackID, permissions)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CreateACLs indicates an expected call of CreateACLs.
-func (mr *MockUserManagerServiceMockRecorder) CreateACLs(ctx, topic, username, shortStackID, permissions interface{}) *gomock.Call {
+func (mr *MockUserManagerServiceMockRecorder) CreateACLs(ctx, topic, u, shortStackID, permissions interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateACLs", reflect.TypeOf((*MockUserManagerService)(nil).CreateACLs), ctx, topic, username, shortStackID, permissions)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateACLs", reflect.TypeOf((*MockUserManagerService)(nil).CreateACLs), ctx, topic, u, shortStackID, permissions)
 }
 
 // CreateUser mocks base method.
@@ -64,17 +107,17 @@ func (mr *MockUserManagerServiceMockRecorder) CreateUser(ctx, shortStackID, topi
 }
 
 // DeleteACLs mocks base method.
-func (m *MockUserManagerService) DeleteACLs(ctx context.Context, topic, username, shortStackID string, permissions []types.Permission) error {
+func (m *MockUserManagerService) DeleteACLs(ctx context.Context, topic string, u *types.User, shortStackID string, permissions []types.Permission) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteACLs", ctx, topic, username, shortStackID, permissions)
+	ret := m.ctrl.Call(m, "DeleteACLs", ctx, topic, u, shortStackID, permissions)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteACLs indicates an expected call of DeleteACLs.
-func (mr *MockUserManagerServiceMockRecorder) DeleteACLs(ctx, topic, username, shortStackID, permissions interface{}) *gomock.Call {
+func (mr *MockUserManagerServiceMockRecorder) DeleteACLs(ctx, topic, u, shortStackID, permissions interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteACLs", reflect.TypeOf((*MockUserManagerService)(nil).DeleteACLs), ctx, topic, username, shortStackID, permissions)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteACLs", reflect.TypeOf((*MockUserManagerService)(nil).DeleteACLs), ctx, topic, u, shortStackID, permissions)
 }
 
 // DeleteUser mocks base method.
@@ -90,3 +133,89 @@ func (mr *MockUserManagerServiceMockRecorder) DeleteUser(ctx, u, kmsKeyID, topic
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockUserManagerService)(nil).DeleteUser), ctx, u, kmsKeyID, topic, shortStackID, clusterArn)
 }
+
+// ProvisionedUsers mocks base method.
+func (m *MockUserManagerService) ProvisionedUsers(ctx context.Context, users []types.User, shortStackID string) ([]types.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProvisionedUsers", ctx, users, shortStackID)
+	ret0, _ := ret[0].([]types.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ProvisionedUsers indicates an expected call of ProvisionedUsers.
+func (mr *MockUserManagerServiceMockRecorder) ProvisionedUsers(ctx, users, shortStackID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProvisionedUsers", reflect.TypeOf((*MockUserManagerService)(nil).ProvisionedUsers), ctx, users, shortStackID)
+}
+
+// ReconcileACLs mocks base method.
+func (m *MockUserManagerService) ReconcileACLs(ctx context.Context, topic string, u *types.User, shortStackID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReconcileACLs", ctx, topic, u, shortStackID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReconcileACLs indicates an expected call of ReconcileACLs.
+func (mr *MockUserManagerServiceMockRecorder) ReconcileACLs(ctx, topic, u, shortStackID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReconcileACLs", reflect.TypeOf((*MockUserManagerService)(nil).ReconcileACLs), ctx, topic, u, shortStackID)
+}
+
+// ReconcileGrants mocks base method.
+func (m *MockUserManagerService) ReconcileGrants(ctx context.Context, u *types.User, kmsKeyID, shortStackID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReconcileGrants", ctx, u, kmsKeyID, shortStackID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReconcileGrants indicates an expected call of ReconcileGrants.
+func (mr *MockUserManagerServiceMockRecorder) ReconcileGrants(ctx, u, kmsKeyID, shortStackID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReconcileGrants", reflect.TypeOf((*MockUserManagerService)(nil).ReconcileGrants), ctx, u, kmsKeyID, shortStackID)
+}
+
+// RevokeTopicACLs mocks base method.
+func (m *MockUserManagerService) RevokeTopicACLs(ctx context.Context, topic string, u *types.User, shortStackID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeTopicACLs", ctx, topic, u, shortStackID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeTopicACLs indicates an expected call of RevokeTopicACLs.
+func (mr *MockUserManagerServiceMockRecorder) RevokeTopicACLs(ctx, topic, u, shortStackID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeTopicACLs", reflect.TypeOf((*MockUserManagerService)(nil).RevokeTopicACLs), ctx, topic, u, shortStackID)
+}
+
+// SecretArns mocks base method.
+func (m *MockUserManagerService) SecretArns(ctx context.Context, users []types.User, shortStackID string) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SecretArns", ctx, users, shortStackID)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SecretArns indicates an expected call of SecretArns.
+func (mr *MockUserManagerServiceMockRecorder) SecretArns(ctx, users, shortStackID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SecretArns", reflect.TypeOf((*MockUserManagerService)(nil).SecretArns), ctx, users, shortStackID)
+}
+
+// UpdateArn mocks base method.
+func (m *MockUserManagerService) UpdateArn(ctx context.Context, u *types.User, oldArns []string, kmsKeyID, shortStackID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateArn", ctx, u, oldArns, kmsKeyID, shortStackID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateArn indicates an expected call of UpdateArn.
+func (mr *MockUserManagerServiceMockRecorder) UpdateArn(ctx, u, oldArns, kmsKeyID, shortStackID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateArn", reflect.TypeOf((*MockUserManagerService)(nil).UpdateArn), ctx, u, oldArns, kmsKeyID, shortStackID)
+}
@@ -0,0 +1,92 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+var contextKeyOpSummary contextKey = contextKey("OpSummary")
+
+// opSummary counts the operations a command performed and the ones it
+// skipped because there was nothing to change (e.g. a secret that is
+// already associated). It is logged once the command completes so that
+// operators can tell what a request actually changed.
+type opSummary struct {
+	mu        sync.Mutex
+	performed map[string]int
+	skipped   map[string]int
+}
+
+func newOpSummary() *opSummary {
+	return &opSummary{
+		performed: make(map[string]int),
+		skipped:   make(map[string]int),
+	}
+}
+
+// Returns a copy of ctx carrying s.
+func withOpSummary(ctx context.Context, s *opSummary) context.Context {
+	return context.WithValue(ctx, contextKeyOpSummary, s)
+}
+
+// Returns the summary carried by ctx. The summary is nil when ctx does
+// not carry one, in which case operations are not counted.
+func opSummaryFrom(ctx context.Context) *opSummary {
+	s, _ := ctx.Value(contextKeyOpSummary).(*opSummary)
+	return s
+}
+
+func (s *opSummary) Performed(op string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.performed[op]++
+}
+
+func (s *opSummary) Skipped(op string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.skipped[op]++
+}
+
+// Returns the total number of operations performed and skipped.
+func (s *opSummary) Totals() (int, int) {
+	if s == nil {
+		return 0, 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sum(s.performed), sum(s.skipped)
+}
+
+func (s *opSummary) Log(logger *zap.Logger) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	logger.Info("Operation Summary",
+		zap.Int("Performed", sum(s.performed)),
+		zap.Int("Skipped", sum(s.skipped)),
+		zap.Any("PerformedOperations", s.performed),
+		zap.Any("SkippedOperations", s.skipped),
+	)
+}
+
+func sum(counts map[string]int) int {
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	return total
+}
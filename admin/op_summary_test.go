@@ -0,0 +1,130 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"testing"
+
+	tt "github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/admin/mocks"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kafka"
+	kt "github.com/aws/aws-sdk-go-v2/service/kafka/types"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smt "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"go.uber.org/zap"
+)
+
+func TestOpSummaryCreate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	// Arrange
+	summary := newOpSummary()
+	ctx := withOpSummary(context.TODO(), summary)
+	stackID := "test"
+	shortStackID := shortStackID(stackID)
+	clusterArn := "arn:aws:kafka:us-east-1:123456789012:cluster/c/uuid"
+	secretArn := "arn:aws:secretsmanager:us-east-1:123456789012:secret:AmazonMSK_alice"
+	alice := tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}
+	info := &tt.TopicInfo{Name: "a", Partitions: 1, ReplicationFactor: 1, ClusterArn: clusterArn, Users: []tt.User{alice}}
+	topicName := canonicalTopicName(info.Name, shortStackID)
+	principal := "User:" + defaultSecretNameTemplate.Name("alice", shortStackID)
+
+	um, m := newTestUserManager(ctrl)
+	kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+	topicMarkers := mocks.NewMockTopicMarkerService(ctrl)
+	cmdCreate := newCmdCreate(m.kafkaClient, kmsKeyResolver, um, topicMarkers, false, testSettings(), logger)
+
+	kmsKeyResolver.EXPECT().Resolve(ctx, info).Return("key", error(nil))
+	m.kafkaClient.EXPECT().ListBrokers(ctx).Return(kadm.BrokerDetails{{NodeID: 1}}, error(nil))
+	m.kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: {Topic: topicName, Err: kerr.UnknownTopicOrPartition}}, error(nil))
+	topicMarkers.EXPECT().Put(ctx, clusterArn, topicName, gomock.Any()).Return(error(nil))
+	// A previous attempt of the request created the topic and the user
+	// secret, associated it and created the group ACL.
+	m.kafkaClient.EXPECT().CreateTopic(ctx, int32(1), int16(1), info.Config, gomock.Nil(), topicName).Return(kadm.CreateTopicResponse{}, kerr.TopicAlreadyExists)
+	m.secretsManagerClient.EXPECT().CreateSecret(gomock.Any(), gomock.Any()).Return(nil, &smt.ResourceExistsException{})
+	m.secretsManagerClient.EXPECT().DescribeSecret(gomock.Any(), gomock.Any()).Return(&secretsmanager.DescribeSecretOutput{ARN: &secretArn}, error(nil))
+	m.kafkaClient.EXPECT().CreateACLs(gomock.Any(), gomock.Any()).Return(kadm.CreateACLsResults{{Principal: principal}}, error(nil))
+	m.kafkaClient.EXPECT().CreateACLs(gomock.Any(), gomock.Any()).Return(kadm.CreateACLsResults{{Principal: principal, Type: kmsg.ACLResourceTypeGroup, Name: "*", Err: kerr.InvalidRequest}}, error(nil))
+	m.kafkaClient.EXPECT().DescribeACLs(gomock.Any(), gomock.Any()).Return(kadm.DescribeACLsResults{{Described: kadm.DescribedACLs{{Principal: principal}}}}, error(nil))
+	m.mskClient.EXPECT().BatchAssociateScramSecret(ctx, gomock.Any()).Return(&kafka.BatchAssociateScramSecretOutput{
+		UnprocessedScramSecrets: []kt.UnprocessedScramSecret{{SecretArn: &secretArn, ErrorMessage: aws.String("The provided secret is already associated with this cluster. To update the association, first disassociate the secret.")}},
+	}, error(nil))
+	m.secretsManagerClient.EXPECT().ListSecrets(ctx, gomock.Any()).Return(&secretsmanager.ListSecretsOutput{}, error(nil))
+	m.kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: {Topic: topicName}}, error(nil))
+
+	// Act
+	_, err = cmdCreate.Run(ctx, info, stackID)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"CreateACL": 1}, summary.performed)
+	assert.Equal(t, map[string]int{"CreateTopic": 1, "CreateSecret": 1, "AssociateSecret": 1, "CreateACL": 1}, summary.skipped)
+	performed, skipped := summary.Totals()
+	assert.Equal(t, 1, performed)
+	assert.Equal(t, 4, skipped)
+}
+
+func TestOpSummaryUpdate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	// Arrange
+	summary := newOpSummary()
+	ctx := withOpSummary(context.TODO(), summary)
+	stackID := "test"
+	topicName := canonicalTopicName("a", shortStackID(stackID))
+	old := &tt.TopicInfo{Name: "a", Config: map[string]*string{"retention.ms": aws.String("1")}}
+	new := &tt.TopicInfo{Name: "a", Config: map[string]*string{"retention.ms": aws.String("2")}}
+
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	kmsKeyResolver := mocks.NewMockKmsKeyResolverService(ctrl)
+	cmdUpdate := newCmdUpdate(kmsKeyResolver, mocks.NewMockUserManagerService(ctrl), kafkaClient, mocks.NewMockTopicMarkerService(ctrl), false, testSettings(), logger)
+
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: kadm.TopicDetail{Topic: topicName, Partitions: kadm.PartitionDetails{}}}, error(nil))
+	kafkaClient.EXPECT().DescribeTopicConfigs(ctx, topicName).Return(kadm.ResourceConfigs{{Name: topicName, Configs: []kadm.Config{{Key: "retention.ms", Value: aws.String("1")}}}}, error(nil))
+	kmsKeyResolver.EXPECT().Resolve(ctx, new).Return("", error(nil))
+	kafkaClient.EXPECT().AlterTopicConfigs(ctx, gomock.Any(), topicName).Return(kadm.AlterConfigsResponses{{Name: topicName}}, error(nil))
+	kafkaClient.EXPECT().DescribeACLs(ctx, gomock.Any()).Return(kadm.DescribeACLsResults{}, error(nil))
+
+	// Act
+	_, err = cmdUpdate.Run(ctx, old, new, stackID)
+	assert.Nil(t, err)
+	// CloudFormation retries the update with identical properties.
+	kmsKeyResolver.EXPECT().Resolve(ctx, new).Return("", error(nil))
+	kafkaClient.EXPECT().ListTopics(ctx, topicName).Return(kadm.TopicDetails{topicName: kadm.TopicDetail{Topic: topicName, Partitions: kadm.PartitionDetails{}}}, error(nil))
+	_, err = cmdUpdate.Run(ctx, new, new, stackID)
+	assert.Nil(t, err)
+
+	// Assert
+	assert.Equal(t, map[string]int{"AlterTopicConfigs": 1}, summary.performed)
+	assert.Equal(t, map[string]int{"DeleteStaleACLs": 1, "UpdateTopic": 1}, summary.skipped)
+}
+
+func TestOpSummaryNil(t *testing.T) {
+	summary := opSummaryFrom(context.TODO())
+	summary.Performed("CreateTopic")
+	summary.Skipped("CreateTopic")
+	summary.Log(zap.NewNop())
+	performed, skipped := summary.Totals()
+	assert.Equal(t, 0, performed)
+	assert.Equal(t, 0, skipped)
+}
@@ -0,0 +1,97 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/pkg/errors"
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+// changePlan describes the changes a command would make to the cluster.
+// It is returned instead of applying the changes when DryRun is set.
+type changePlan struct {
+	TopicName          string
+	CreateTopic        bool                          `json:",omitempty"`
+	Partitions         int                           `json:",omitempty"`
+	ReplicationFactor  int                           `json:",omitempty"`
+	ConfigChanges      []plannedConfigChange         `json:",omitempty"`
+	AddedUsers         []string                      `json:",omitempty"`
+	DeletedUsers       []string                      `json:",omitempty"`
+	AddedPermissions   map[string][]types.Permission `json:",omitempty"`
+	DeletedPermissions map[string][]types.Permission `json:",omitempty"`
+	ChangedArns        []string                      `json:",omitempty"`
+}
+
+type plannedConfigChange struct {
+	Op    string
+	Name  string
+	Value *string
+}
+
+func newCreatePlan(topicName string, info *types.TopicInfo) *changePlan {
+	plan := &changePlan{
+		TopicName:         topicName,
+		CreateTopic:       true,
+		Partitions:        info.Partitions,
+		ReplicationFactor: info.ReplicationFactor,
+	}
+	for k, v := range info.Config {
+		plan.ConfigChanges = append(plan.ConfigChanges, plannedConfigChange{Op: "SET", Name: k, Value: v})
+	}
+	for _, u := range info.Users {
+		plan.AddedUsers = append(plan.AddedUsers, u.Username)
+	}
+	return plan
+}
+
+func newUpdatePlan(topicName string, cdiff []kadm.AlterConfig, udiff *userDiff) *changePlan {
+	plan := &changePlan{
+		TopicName:          topicName,
+		AddedPermissions:   udiff.AddedPermissions,
+		DeletedPermissions: udiff.DeletedPermissions,
+	}
+	for _, c := range cdiff {
+		plan.ConfigChanges = append(plan.ConfigChanges, plannedConfigChange{Op: alterConfigOpName(c.Op), Name: c.Name, Value: c.Value})
+	}
+	for _, u := range udiff.AddedUsers {
+		plan.AddedUsers = append(plan.AddedUsers, u.Username)
+	}
+	for _, u := range udiff.DeletedUsers {
+		plan.DeletedUsers = append(plan.DeletedUsers, u.Username)
+	}
+	for u := range udiff.ChangedArns {
+		plan.ChangedArns = append(plan.ChangedArns, u)
+	}
+	sort.Strings(plan.ChangedArns)
+	return plan
+}
+
+// JSON returns the JSON representation of the plan suitable for
+// returning as a CloudFormation output attribute.
+func (p *changePlan) JSON() (string, error) {
+	buf, err := json.Marshal(p)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(buf), nil
+}
+
+func alterConfigOpName(op kadm.IncrementalOp) string {
+	switch op {
+	case kadm.SetConfig:
+		return "SET"
+	case kadm.DeleteConfig:
+		return "DELETE"
+	case kadm.AppendConfig:
+		return "APPEND"
+	case kadm.SubtractConfig:
+		return "SUBTRACT"
+	}
+	return "UNKNOWN"
+}
@@ -0,0 +1,86 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	PrincipalCheckOff   = "off"
+	PrincipalCheckWarn  = "warn"
+	PrincipalCheckError = "error"
+)
+
+// principalChecker verifies that the IAM principal of a user exists before
+// it is granted access to the user secret. Granting to a mistyped ARN
+// otherwise succeeds and leaves a policy and grant nobody can use.
+// The check is best-effort: principals in other accounts cannot be looked
+// up and are skipped, as are principals TR is not allowed to look up.
+type principalChecker struct {
+	iamClient IamClient
+	mode      string
+	logger    *zap.Logger
+}
+
+func newPrincipalChecker(iamClient IamClient, mode string, logger *zap.Logger) *principalChecker {
+	return &principalChecker{
+		iamClient: iamClient,
+		mode:      mode,
+		logger:    logger,
+	}
+}
+
+// Returns a classified error if the principal does not exist and the
+// checker is in error mode. Other modes only log a warning.
+func (c *principalChecker) Check(ctx context.Context, principalArn, clusterArn string) error {
+	if c == nil || c.iamClient == nil || c.mode == "" || c.mode == PrincipalCheckOff {
+		return nil
+	}
+	pa, err := arn.Parse(principalArn)
+	if err != nil || pa.Service != "iam" {
+		return nil
+	}
+	ca, err := arn.Parse(clusterArn)
+	if err != nil || ca.AccountID != pa.AccountID {
+		c.logger.Sugar().Infow("Skipping check of cross-account principal", "Arn", principalArn)
+		return nil
+	}
+	kind, name := principalKind(pa.Resource)
+	c.logger.Sugar().Infow("Start Operation", "Name", "CheckPrincipal", "Arn", principalArn)
+	switch kind {
+	case "role":
+		err = c.iamClient.GetRole(ctx, name)
+	case "user":
+		err = c.iamClient.GetUser(ctx, name)
+	default:
+		return nil
+	}
+	if errors.Is(err, errIamNoSuchEntity) {
+		if c.mode == PrincipalCheckError {
+			return newClassifiedError(ErrCodePrincipalNotFound, "Principal %s does not exist. Check Arn of the user for typos.", principalArn)
+		}
+		c.logger.Sugar().Warnw("Principal does not exist", "Arn", principalArn)
+		return nil
+	}
+	if err != nil {
+		c.logger.Sugar().Warnw("Unable to check principal", "Arn", principalArn, "Error", err.Error())
+	}
+	return nil
+}
+
+// Splits an IAM resource such as role/path/name into its kind and name.
+// Paths are not part of the name accepted by GetRole and GetUser.
+func principalKind(resource string) (string, string) {
+	kind, rest, ok := strings.Cut(resource, "/")
+	if !ok {
+		return "", ""
+	}
+	return kind, rest[strings.LastIndex(rest, "/")+1:]
+}
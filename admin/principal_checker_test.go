@@ -0,0 +1,96 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/admin/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestPrincipalCheckerCheck(t *testing.T) {
+	type testCase struct {
+		mode     string
+		arn      string
+		mock     func(m *mocks.MockIamClient)
+		expected string
+	}
+
+	clusterArn := "arn:aws:kafka:us-east-1:111111111111:cluster/demo/abc"
+	cases := map[string]testCase{
+		"Existing role": {
+			mode: PrincipalCheckError,
+			arn:  "arn:aws:iam::111111111111:role/service/consumer",
+			mock: func(m *mocks.MockIamClient) {
+				m.EXPECT().GetRole(gomock.Any(), "consumer").Return(nil)
+			},
+		},
+		"Existing user": {
+			mode: PrincipalCheckError,
+			arn:  "arn:aws:iam::111111111111:user/alice",
+			mock: func(m *mocks.MockIamClient) {
+				m.EXPECT().GetUser(gomock.Any(), "alice").Return(nil)
+			},
+		},
+		"Non-existent role": {
+			mode: PrincipalCheckError,
+			arn:  "arn:aws:iam::111111111111:role/consumr",
+			mock: func(m *mocks.MockIamClient) {
+				m.EXPECT().GetRole(gomock.Any(), "consumr").Return(errIamNoSuchEntity)
+			},
+			expected: "Principal arn:aws:iam::111111111111:role/consumr does not exist. Check Arn of the user for typos.",
+		},
+		"Non-existent role in warn mode": {
+			mode: PrincipalCheckWarn,
+			arn:  "arn:aws:iam::111111111111:role/consumr",
+			mock: func(m *mocks.MockIamClient) {
+				m.EXPECT().GetRole(gomock.Any(), "consumr").Return(errIamNoSuchEntity)
+			},
+		},
+		"Lookup failure": {
+			mode: PrincipalCheckError,
+			arn:  "arn:aws:iam::111111111111:role/consumer",
+			mock: func(m *mocks.MockIamClient) {
+				m.EXPECT().GetRole(gomock.Any(), "consumer").Return(errors.New("access denied"))
+			},
+		},
+		"Cross-account principal": {
+			mode: PrincipalCheckError,
+			arn:  "arn:aws:iam::222222222222:role/consumer",
+			mock: func(m *mocks.MockIamClient) {},
+		},
+		"Account root": {
+			mode: PrincipalCheckError,
+			arn:  "arn:aws:iam::111111111111:root",
+			mock: func(m *mocks.MockIamClient) {},
+		},
+		"Disabled": {
+			mode: PrincipalCheckOff,
+			arn:  "arn:aws:iam::111111111111:role/consumr",
+			mock: func(m *mocks.MockIamClient) {},
+		},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			iamClient := mocks.NewMockIamClient(ctrl)
+			c.mock(iamClient)
+			checker := newPrincipalChecker(iamClient, c.mode, zap.NewNop())
+			err := checker.Check(context.TODO(), c.arn, clusterArn)
+			if c.expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, c.expected)
+			assert.Equal(t, "TR009: "+c.expected, describeError(err))
+		})
+	}
+}
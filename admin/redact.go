@@ -0,0 +1,45 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import "strings"
+
+// Replaces credentials in logged values.
+const redactedValue = "[REDACTED]"
+
+// Returns s with every occurrence of secrets replaced. Empty secrets are
+// ignored.
+func redact(s string, secrets ...string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, redactedValue)
+		}
+	}
+	return s
+}
+
+// redactedError hides credentials in the message of an error returned by
+// an operation they were passed to. AWS validation errors may quote request
+// parameters, and these errors are logged and reported to CloudFormation.
+type redactedError struct {
+	err     error
+	secrets []string
+}
+
+// Returns err with secrets redacted from its message. errors.Is and
+// errors.As still match the original error.
+func redactError(err error, secrets ...string) error {
+	if err == nil {
+		return nil
+	}
+	return &redactedError{err: err, secrets: secrets}
+}
+
+func (e *redactedError) Error() string {
+	return redact(e.err.Error(), e.secrets...)
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
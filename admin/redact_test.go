@@ -0,0 +1,25 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	assert.Equal(t, "password [REDACTED] and [REDACTED]", redact("password s3cr3t and s3cr3t", "s3cr3t"))
+	assert.Equal(t, "nothing to hide", redact("nothing to hide", ""))
+
+	cause := &types.InvalidParameterException{Message: aws.String("value s3cr3t is invalid")}
+	err := redactError(errors.WithStack(cause), "s3cr3t")
+	assert.EqualError(t, err, "InvalidParameterException: value [REDACTED] is invalid")
+	var ipe *types.InvalidParameterException
+	assert.True(t, errors.As(err, &ipe))
+	assert.Nil(t, redactError(nil, "s3cr3t"))
+}
@@ -0,0 +1,122 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-lambda-go/cfn"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// CloudFormation rejects responses of custom resources larger than
+	// 4096 bytes.
+	maxResponseSize = 4096
+	// URLs are presigned with the temporary credentials of TR function,
+	// which expire within hours. The S3 client caps the expiry at theirs.
+	// OutputLocation is the lasting reference to the object.
+	outputURLExpiry = time.Hour
+	outputKeyPrefix = "tr-outputs/"
+)
+
+// Keeps the response to CloudFormation within maxResponseSize. When the
+// output attributes do not fit, all of them are written to OutputBucket
+// and the largest ones are replaced by the URL and location of the object.
+// Attributes that still fit are kept so that templates can read them with
+// Fn::GetAtt.
+func (h *Handler) limitResponseSize(ctx context.Context, event cfn.Event, physicalResourceID string, props map[string]interface{}, logger *zap.Logger) (map[string]interface{}, error) {
+	size, err := responseSize(event, physicalResourceID, props)
+	if err != nil {
+		return nil, err
+	}
+	if size <= maxResponseSize {
+		return props, nil
+	}
+	if h.settings.OutputBucket == "" {
+		return nil, errors.WithStack(fmt.Errorf("output attributes exceed the limit of %d bytes of CloudFormation responses with %d bytes. Set %s to store them in S3", maxResponseSize, size, EnvOutputBucket))
+	}
+	body, err := json.Marshal(props)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	bucket := h.settings.OutputBucket
+	key := fmt.Sprintf("%s%s/%s/%s.json", outputKeyPrefix, shortStackID(event.StackID), event.LogicalResourceID, event.RequestID)
+	logger.Sugar().Infow("Start Operation", "Name", "PutOutputs", "Bucket", bucket, "Key", key, "Size", size)
+	err = h.s3Client.PutObject(ctx, bucket, key, body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	url, err := h.s3Client.PresignGetObject(ctx, bucket, key, outputURLExpiry)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	limited := make(map[string]interface{}, len(props)+2)
+	for k, v := range props {
+		limited[k] = v
+	}
+	limited[PropOutputURL] = url
+	limited[PropOutputLocation] = fmt.Sprintf("s3://%s/%s", bucket, key)
+	keys := largestFirst(props)
+	moved := make([]string, 0)
+	for {
+		if size, err = responseSize(event, physicalResourceID, limited); err != nil {
+			return nil, err
+		}
+		if size <= maxResponseSize || len(moved) == len(keys) {
+			break
+		}
+		k := keys[len(moved)]
+		delete(limited, k)
+		moved = append(moved, k)
+	}
+	// The presigned URL alone is large when TR function uses temporary
+	// credentials, whose session token is part of the URL.
+	if size > maxResponseSize {
+		return nil, errors.WithStack(fmt.Errorf("response of %d bytes exceeds the limit of %d bytes of CloudFormation responses even without output attributes", size, maxResponseSize))
+	}
+	logger.Sugar().Warnw("Output Attributes Moved", "Bucket", bucket, "Key", key, "Attributes", moved)
+	opSummaryFrom(ctx).Performed("PutOutputs")
+	return limited, nil
+}
+
+// Returns the size of the response CloudFormation receives for a
+// successful request.
+func responseSize(event cfn.Event, physicalResourceID string, props map[string]interface{}) (int, error) {
+	r := cfn.NewResponse(&event)
+	r.Status = cfn.StatusSuccess
+	r.PhysicalResourceID = physicalResourceID
+	r.Data = props
+	buf, err := json.Marshal(r)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return len(buf), nil
+}
+
+// Returns the keys of props ordered by the size of their values, largest
+// first. Keys of values of the same size are ordered by name so that the
+// same attributes are moved on every attempt.
+func largestFirst(props map[string]interface{}) []string {
+	sizes := make(map[string]int, len(props))
+	keys := make([]string, 0, len(props))
+	for k, v := range props {
+		buf, _ := json.Marshal(v)
+		sizes[k] = len(k) + len(buf)
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if sizes[keys[i]] != sizes[keys[j]] {
+			return sizes[keys[i]] > sizes[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
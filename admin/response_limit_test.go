@@ -0,0 +1,102 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/admin/mocks"
+
+	"github.com/aws/aws-lambda-go/cfn"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestLimitResponseSize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type testCase struct {
+		name     string
+		props    map[string]interface{}
+		bucket   string
+		url      string
+		expected map[string]interface{}
+		err      string
+	}
+
+	event := cfn.Event{RequestType: cfn.RequestCreate, RequestID: "request", StackID: "test", LogicalResourceID: "Topic"}
+	key := "tr-outputs/" + shortStackID("test") + "/Topic/request.json"
+	large := map[string]interface{}{
+		PropTopicName:               "orders",
+		PropACLs:                    strings.Repeat("a", 3000),
+		PropSecretArnPrefix + "bob": strings.Repeat("b", 2000),
+	}
+
+	cases := []testCase{
+		{
+			name:     "Within limit",
+			props:    map[string]interface{}{PropTopicName: "orders"},
+			expected: map[string]interface{}{PropTopicName: "orders"},
+		},
+		{
+			name:  "Exceeds limit without bucket",
+			props: large,
+			err:   "Set TR_OUTPUT_BUCKET to store them in S3",
+		},
+		{
+			name:   "Largest attributes moved to S3",
+			props:  large,
+			bucket: "outputs",
+			url:    "https://outputs.s3.eu-west-1.amazonaws.com/" + key + "?X-Amz-Signature=sig",
+			expected: map[string]interface{}{
+				PropTopicName:               "orders",
+				PropSecretArnPrefix + "bob": strings.Repeat("b", 2000),
+				PropOutputURL:               "https://outputs.s3.eu-west-1.amazonaws.com/" + key + "?X-Amz-Signature=sig",
+				PropOutputLocation:          "s3://outputs/" + key,
+			},
+		},
+		{
+			name:   "URL exceeds limit",
+			props:  large,
+			bucket: "outputs",
+			url:    strings.Repeat("u", maxResponseSize),
+			err:    "even without output attributes",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			ctx := context.TODO()
+			settings := DefaultSettings()
+			settings.OutputBucket = c.bucket
+			s3Client := mocks.NewMockS3Client(ctrl)
+			if c.url != "" {
+				body, _ := json.Marshal(c.props)
+				s3Client.EXPECT().PutObject(ctx, c.bucket, key, body).Return(nil)
+				s3Client.EXPECT().PresignGetObject(ctx, c.bucket, key, outputURLExpiry).Return(c.url, nil)
+			}
+			handler := NewHandler(nil, nil, nil, nil, s3Client, nil, settings)
+
+			// Act
+			props, err := handler.limitResponseSize(ctx, event, "topic-id", c.props, zap.NewNop())
+
+			// Assert
+			if c.err == "" {
+				assert.Nil(t, err)
+				assert.Equal(t, c.expected, props)
+				size, _ := responseSize(event, "topic-id", props)
+				assert.LessOrEqual(t, size, maxResponseSize)
+			} else {
+				assert.NotNil(t, err)
+				assert.Contains(t, err.Error(), c.err)
+			}
+		})
+	}
+}
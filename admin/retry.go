@@ -0,0 +1,116 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// retryPolicy retries operations in-process with exponential backoff and
+// jitter. This is much faster than failing the request and waiting for
+// CloudFormation to invoke TR again.
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	// Upper limit on the time spent waiting between attempts. Zero
+	// disables the limit.
+	timeout time.Duration
+	sleep   func(time.Duration)
+}
+
+// RetryConfig tunes the in-process retries of all operations. Operations
+// with their own attempt limit, such as associating secrets, only take the
+// delays from it.
+type RetryConfig struct {
+	// Number of attempts made by operations without their own limit.
+	MaxAttempts int
+	// Delay before the second attempt, doubled before each further one.
+	BaseDelay time.Duration
+	// Upper limit on the delay between two attempts.
+	MaxDelay time.Duration
+}
+
+// Returns a policy making up to maxAttempts attempts spaced with the
+// delays of c.
+func (c RetryConfig) policy(maxAttempts int, timeout time.Duration) *retryPolicy {
+	return newRetryPolicy(maxAttempts, c.BaseDelay, c.MaxDelay, timeout)
+}
+
+func newRetryPolicy(maxAttempts int, baseDelay, maxDelay, timeout time.Duration) *retryPolicy {
+	return &retryPolicy{
+		maxAttempts: maxAttempts,
+		baseDelay:   baseDelay,
+		maxDelay:    maxDelay,
+		timeout:     timeout,
+		sleep:       time.Sleep,
+	}
+}
+
+// permanentError stops retryPolicy.Do from retrying any further.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+func permanent(err error) error {
+	return &permanentError{err}
+}
+
+// Invokes fn until it succeeds, returns a permanent error, the maximum
+// number of attempts is reached or the next attempt would start after the
+// timeout. Returns the last error returned by fn. fn is invoked at least
+// once, even when the maximum number of attempts is not positive, so that
+// a misconfigured policy cannot report success without doing anything.
+func (p *retryPolicy) Do(ctx context.Context, fn func() error) error {
+	var err error
+	var waited time.Duration
+	for attempt := 0; attempt == 0 || attempt < p.maxAttempts; attempt++ {
+		if attempt > 0 {
+			d := p.delay(attempt)
+			if p.timeout > 0 && waited+d > p.timeout {
+				return err
+			}
+			p.sleep(d)
+			waited += d
+		}
+		err = fn()
+		if err == nil {
+			return nil
+		}
+		var pe *permanentError
+		if errors.As(err, &pe) {
+			return pe.err
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func (p *retryPolicy) delay(attempt int) time.Duration {
+	d := p.baseDelay << (attempt - 1)
+	if d <= 0 || d > p.maxDelay {
+		d = p.maxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	// Equal jitter spreads the retries of concurrent invocations while
+	// guaranteeing at least half of the computed delay.
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
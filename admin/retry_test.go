@@ -0,0 +1,73 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryConfigPolicy(t *testing.T) {
+	type testCase struct {
+		config   RetryConfig
+		expected []time.Duration
+	}
+
+	cases := map[string]testCase{
+		"No delay": {
+			config:   RetryConfig{MaxAttempts: 3},
+			expected: []time.Duration{0, 0, 0},
+		},
+		"Delay capped": {
+			config:   RetryConfig{MaxAttempts: 3, BaseDelay: 2 * time.Second, MaxDelay: 5 * time.Second},
+			expected: []time.Duration{2 * time.Second, 4 * time.Second, 5 * time.Second},
+		},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			// Arrange
+			p := c.config.policy(4, 0)
+			var delays []time.Duration
+			p.sleep = func(d time.Duration) { delays = append(delays, d) }
+			attempts := 0
+
+			// Act
+			err := p.Do(context.TODO(), func() error {
+				attempts++
+				return errors.New("boom")
+			})
+
+			// Assert
+			assert.EqualError(t, err, "boom")
+			assert.Equal(t, 4, attempts)
+			assert.Len(t, delays, len(c.expected))
+			// Equal jitter waits at least half of the computed delay.
+			for i, d := range delays {
+				assert.LessOrEqual(t, d, c.expected[i])
+				assert.GreaterOrEqual(t, d, c.expected[i]/2)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyAttemptsOnce(t *testing.T) {
+	// Arrange
+	p := RetryConfig{}.policy(0, 0)
+	attempts := 0
+
+	// Act
+	err := p.Do(context.TODO(), func() error {
+		attempts++
+		return errors.New("boom")
+	})
+
+	// Assert
+	assert.EqualError(t, err, "boom")
+	assert.Equal(t, 1, attempts)
+}
@@ -0,0 +1,63 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/pkg/errors"
+)
+
+// s3SdkClient adapts the S3 module of the AWS SDK to S3Client.
+type s3SdkClient struct {
+	client      *s3.Client
+	presign     *s3.PresignClient
+	credentials aws.CredentialsProvider
+}
+
+func NewS3Client(cfg aws.Config, optFns ...func(*s3.Options)) S3Client {
+	client := s3.NewFromConfig(cfg, optFns...)
+	return &s3SdkClient{
+		client:      client,
+		presign:     s3.NewPresignClient(client),
+		credentials: cfg.Credentials,
+	}
+}
+
+func (c *s3SdkClient) PutObject(ctx context.Context, bucket, key string, body []byte) error {
+	_, err := c.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	return errors.WithStack(err)
+}
+
+// URLs presigned with temporary credentials stop working when the
+// credentials expire, therefore expires is capped at their expiry when it
+// is known.
+func (c *s3SdkClient) PresignGetObject(ctx context.Context, bucket, key string, expires time.Duration) (string, error) {
+	if c.credentials != nil {
+		creds, err := c.credentials.Retrieve(ctx)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		if left := time.Until(creds.Expires); creds.CanExpire && left < expires {
+			expires = left.Truncate(time.Second)
+		}
+	}
+	req, err := c.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return req.URL, nil
+}
@@ -0,0 +1,112 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func testS3Config() aws.Config {
+	return aws.Config{
+		Region: "eu-west-1",
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET", SessionToken: "TOKEN"}, nil
+		}),
+	}
+}
+
+func TestS3SdkClientPutObject(t *testing.T) {
+	type testCase struct {
+		status  int
+		success bool
+	}
+
+	cases := map[string]testCase{
+		"Stored":        {status: http.StatusOK, success: true},
+		"Access denied": {status: http.StatusForbidden},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			// Arrange
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+				assert.Equal(t, http.MethodPut, r.Method)
+				assert.Equal(t, "/bucket/tr-snapshots/orders.json", r.URL.Path)
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				assert.Equal(t, `{"Topic":"orders"}`, string(body))
+				assert.Contains(t, r.Header.Get("Authorization"), "/eu-west-1/s3/aws4_request")
+				w.WriteHeader(c.status)
+			}))
+			defer server.Close()
+			client := NewS3Client(testS3Config(), func(o *s3.Options) {
+				o.EndpointResolver = s3.EndpointResolverFromURL(server.URL)
+				o.UsePathStyle = true
+				o.RetryMaxAttempts = 1
+			})
+
+			// Act
+			err := client.PutObject(context.TODO(), "bucket", "tr-snapshots/orders.json", []byte(`{"Topic":"orders"}`))
+
+			// Assert
+			if c.success {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestS3SdkClientPresignGetObject(t *testing.T) {
+	// Arrange
+	client := NewS3Client(testS3Config())
+
+	// Act
+	signed, err := client.PresignGetObject(context.TODO(), "outputs", "tr-outputs/orders.json", time.Hour)
+
+	// Assert
+	assert.NoError(t, err)
+	u, err := url.Parse(signed)
+	assert.NoError(t, err)
+	assert.Equal(t, "outputs.s3.eu-west-1.amazonaws.com", u.Host)
+	assert.Equal(t, "/tr-outputs/orders.json", u.Path)
+	assert.Equal(t, "3600", u.Query().Get("X-Amz-Expires"))
+	assert.Equal(t, "TOKEN", u.Query().Get("X-Amz-Security-Token"))
+	assert.Contains(t, u.Query().Get("X-Amz-Credential"), "AKID/")
+	assert.NotEmpty(t, u.Query().Get("X-Amz-Signature"))
+}
+
+func TestS3SdkClientPresignGetObjectCredentialsExpiry(t *testing.T) {
+	// Arrange
+	cfg := testS3Config()
+	cfg.Credentials = aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET", SessionToken: "TOKEN", CanExpire: true, Expires: time.Now().Add(10 * time.Minute)}, nil
+	})
+	client := NewS3Client(cfg)
+
+	// Act
+	signed, err := client.PresignGetObject(context.TODO(), "outputs", "tr-outputs/orders.json", time.Hour)
+
+	// Assert
+	assert.NoError(t, err)
+	u, err := url.Parse(signed)
+	assert.NoError(t, err)
+	expires, err := strconv.Atoi(u.Query().Get("X-Amz-Expires"))
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, expires, 600)
+	assert.Greater(t, expires, 500)
+}
@@ -0,0 +1,129 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kafka"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/pkg/errors"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+	"go.uber.org/zap"
+)
+
+const (
+	ScramMechanismSha256 = "SCRAM-SHA-256"
+	ScramMechanismSha512 = "SCRAM-SHA-512"
+)
+
+// ScramKafkaClientProvider authenticates with the SASL/SCRAM credentials
+// stored in a SecretsManager secret instead of the IAM role of TR function.
+type ScramKafkaClientProvider struct {
+	mskClient            MskClient
+	secretsManagerClient SecretsManagerClient
+	secretArn            string
+	mechanism            string
+	dialTimeout          time.Duration
+	requestTimeout       time.Duration
+	createTopicTimeout   time.Duration
+	connectivity         string
+	clientID             string
+	bootstrapRetry       *retryPolicy
+}
+
+// Returns a client for administering the cluster along with bootstrap
+// broker strings of the cluster.
+func (p *ScramKafkaClientProvider) NewKafkaClient(ctx context.Context, clusterArn string) (KafkaClient, *kafka.GetBootstrapBrokersOutput, error) {
+	logger := ctx.Value(contextKeyLogger).(*zap.Logger)
+	b, brokers, err := describeBootstrapBrokers(ctx, p.mskClient, p.bootstrapRetry, clusterArn, p.bootstrapBrokers, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	logger.Sugar().Infow("Operation Finished", "Name", "GetBootstrapBrokers", "Connectivity", p.connectivity, "BootstrapBrokers", brokers)
+	auth, err := p.credentials(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	mechanism, err := scramMechanism(p.mechanism, auth)
+	if err != nil {
+		return nil, nil, err
+	}
+	cl, err := newSASLKafkaClient(ctx, brokers, mechanism, p.clientID, p.dialTimeout, p.requestTimeout, p.createTopicTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cl, b, nil
+}
+
+// Returns the SASL/SCRAM bootstrap broker string of the cluster for the
+// connectivity of the provider.
+func (p *ScramKafkaClientProvider) bootstrapBrokers(b *kafka.GetBootstrapBrokersOutput) (string, error) {
+	brokers := b.BootstrapBrokerStringSaslScram
+	if p.connectivity == BrokerConnectivityPublic {
+		brokers = b.BootstrapBrokerStringPublicSaslScram
+	}
+	if brokers == nil {
+		return "", errors.WithStack(newClassifiedError(ErrCodeScramAuthDisabled, "MSK cluster does not have %s SASL/SCRAM bootstrap brokers. SASL/SCRAM authentication must be enabled when %s is set.", p.connectivity, EnvScramSecretArn))
+	}
+	return *brokers, nil
+}
+
+// Reads the username and password of TR function from the secret. MSK
+// requires the same format for the secrets it associates with clusters.
+func (p *ScramKafkaClientProvider) credentials(ctx context.Context) (scram.Auth, error) {
+	gsv, err := p.secretsManagerClient.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: &p.secretArn,
+	})
+	if err != nil {
+		return scram.Auth{}, errors.WithStack(err)
+	}
+	var credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.Unmarshal([]byte(aws.ToString(gsv.SecretString)), &credentials); err != nil || credentials.Username == "" || credentials.Password == "" {
+		return scram.Auth{}, errors.WithStack(fmt.Errorf("secret %s must contain a JSON object with username and password", p.secretArn))
+	}
+	return scram.Auth{User: credentials.Username, Pass: credentials.Password}, nil
+}
+
+// Returns the SASL mechanism of the given name authenticating with auth.
+func scramMechanism(name string, auth scram.Auth) (sasl.Mechanism, error) {
+	authFn := func(context.Context) (scram.Auth, error) {
+		return auth, nil
+	}
+	switch name {
+	case ScramMechanismSha512:
+		return scram.Sha512(authFn), nil
+	case ScramMechanismSha256:
+		return scram.Sha256(authFn), nil
+	}
+	return nil, errors.WithStack(fmt.Errorf("SCRAM mechanism %q is not supported, use %s or %s", name, ScramMechanismSha512, ScramMechanismSha256))
+}
+
+// Fails when the SCRAM mechanism in settings is not supported so that
+// misconfiguration is reported before any request is handled.
+func NewScramKafkaClientProvider(mskClient MskClient, secretsManagerClient SecretsManagerClient, settings *Settings) (*ScramKafkaClientProvider, error) {
+	if _, err := scramMechanism(settings.ScramMechanism, scram.Auth{}); err != nil {
+		return nil, err
+	}
+	return &ScramKafkaClientProvider{
+		mskClient:            mskClient,
+		secretsManagerClient: secretsManagerClient,
+		secretArn:            settings.ScramSecretArn,
+		mechanism:            settings.ScramMechanism,
+		dialTimeout:          settings.KafkaDialTimeout,
+		requestTimeout:       settings.KafkaRequestTimeout,
+		createTopicTimeout:   settings.CreateTopicTimeout,
+		connectivity:         settings.BrokerConnectivity,
+		clientID:             settings.KafkaClientID,
+		bootstrapRetry:       settings.Retry.policy(settings.BootstrapMaxAttempts, 0),
+	}, nil
+}
@@ -0,0 +1,170 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/admin/mocks"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kafka"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+func TestScramMechanism(t *testing.T) {
+	type testCase struct {
+		name     string
+		expected string
+		err      string
+	}
+
+	cases := map[string]testCase{
+		"SHA-512": {
+			name:     ScramMechanismSha512,
+			expected: "SCRAM-SHA-512",
+		},
+		"SHA-256": {
+			name:     ScramMechanismSha256,
+			expected: "SCRAM-SHA-256",
+		},
+		"Unsupported": {
+			name: "SCRAM-SHA-1",
+			err:  "SCRAM mechanism \"SCRAM-SHA-1\" is not supported, use SCRAM-SHA-512 or SCRAM-SHA-256",
+		},
+		"Lowercase": {
+			name: "scram-sha-512",
+			err:  "SCRAM mechanism \"scram-sha-512\" is not supported, use SCRAM-SHA-512 or SCRAM-SHA-256",
+		},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			mechanism, err := scramMechanism(c.name, scram.Auth{User: "tr", Pass: "pass"})
+
+			if c.err != "" {
+				assert.EqualError(t, err, c.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, mechanism.Name())
+		})
+	}
+}
+
+func TestNewScramKafkaClientProvider(t *testing.T) {
+	// Arrange
+	settings := DefaultSettings()
+	settings.ScramMechanism = "PLAIN"
+
+	// Act
+	p, err := NewScramKafkaClientProvider(nil, nil, settings)
+
+	// Assert
+	assert.Nil(t, p)
+	assert.EqualError(t, err, "SCRAM mechanism \"PLAIN\" is not supported, use SCRAM-SHA-512 or SCRAM-SHA-256")
+}
+
+func TestScramBootstrapBrokers(t *testing.T) {
+	type testCase struct {
+		connectivity string
+		brokers      *kafka.GetBootstrapBrokersOutput
+		expected     string
+		err          string
+	}
+
+	private := aws.String("b-1:9096")
+	public := aws.String("b-1-public:9196")
+	cases := map[string]testCase{
+		"Private": {
+			connectivity: BrokerConnectivityPrivate,
+			brokers:      &kafka.GetBootstrapBrokersOutput{BootstrapBrokerStringSaslScram: private, BootstrapBrokerStringPublicSaslScram: public},
+			expected:     "b-1:9096",
+		},
+		"Public": {
+			connectivity: BrokerConnectivityPublic,
+			brokers:      &kafka.GetBootstrapBrokersOutput{BootstrapBrokerStringSaslScram: private, BootstrapBrokerStringPublicSaslScram: public},
+			expected:     "b-1-public:9196",
+		},
+		"SCRAM disabled": {
+			connectivity: BrokerConnectivityPrivate,
+			brokers:      &kafka.GetBootstrapBrokersOutput{BootstrapBrokerStringSaslIam: aws.String("b-1:9098")},
+			err:          "TR011: MSK cluster does not have private SASL/SCRAM bootstrap brokers.",
+		},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			// Arrange
+			settings := DefaultSettings()
+			settings.BrokerConnectivity = c.connectivity
+			p, err := NewScramKafkaClientProvider(nil, nil, settings)
+			assert.NoError(t, err)
+
+			// Act
+			brokers, err := p.bootstrapBrokers(c.brokers)
+
+			// Assert
+			if c.err != "" {
+				assert.Contains(t, describeError(err), c.err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, c.expected, brokers)
+			}
+		})
+	}
+}
+
+func TestScramCredentials(t *testing.T) {
+	type testCase struct {
+		secret   string
+		expected scram.Auth
+		err      string
+	}
+
+	secretArn := "arn:aws:secretsmanager:us-east-1:123456789012:secret:AmazonMSK_tr"
+	cases := map[string]testCase{
+		"Valid": {
+			secret:   `{"username":"tr","password":"pass"}`,
+			expected: scram.Auth{User: "tr", Pass: "pass"},
+		},
+		"Missing password": {
+			secret: `{"username":"tr"}`,
+			err:    "secret " + secretArn + " must contain a JSON object with username and password",
+		},
+		"Not JSON": {
+			secret: "tr:pass",
+			err:    "secret " + secretArn + " must contain a JSON object with username and password",
+		},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			// Arrange
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			secretsManagerClient := mocks.NewMockSecretsManagerClient(ctrl)
+			secretsManagerClient.EXPECT().GetSecretValue(gomock.Any(), &secretsmanager.GetSecretValueInput{SecretId: &secretArn}).
+				Return(&secretsmanager.GetSecretValueOutput{SecretString: &c.secret}, error(nil))
+			settings := DefaultSettings()
+			settings.ScramSecretArn = secretArn
+			p, err := NewScramKafkaClientProvider(nil, secretsManagerClient, settings)
+			assert.NoError(t, err)
+
+			// Act
+			auth, err := p.credentials(context.TODO())
+
+			// Assert
+			if c.err != "" {
+				assert.EqualError(t, err, c.err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, c.expected, auth)
+			}
+		})
+	}
+}
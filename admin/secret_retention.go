@@ -0,0 +1,47 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import "context"
+
+var contextKeyRetainedSecrets contextKey = contextKey("RetainedSecrets")
+
+// retainedSecrets names the users whose secrets DeleteUser disassociates
+// from the cluster without deleting them. Secrets are named after users,
+// therefore the clusters of a topic share them. A shared secret is only
+// deleted along with its user in the last cluster, and not at all when
+// its user is recreated.
+type retainedSecrets struct {
+	all       bool
+	usernames map[string]bool
+}
+
+// Returns a set retaining the secrets of every user.
+func retainAllSecrets() *retainedSecrets {
+	return &retainedSecrets{all: true}
+}
+
+func newRetainedSecrets() *retainedSecrets {
+	return &retainedSecrets{usernames: make(map[string]bool)}
+}
+
+// Returns a copy of ctx carrying r.
+func withRetainedSecrets(ctx context.Context, r *retainedSecrets) context.Context {
+	return context.WithValue(ctx, contextKeyRetainedSecrets, r)
+}
+
+// Returns the secrets retained in ctx. The result is nil when ctx does not
+// carry any, in which case secrets are deleted along with their users.
+func retainedSecretsFrom(ctx context.Context) *retainedSecrets {
+	r, _ := ctx.Value(contextKeyRetainedSecrets).(*retainedSecrets)
+	return r
+}
+
+func (r *retainedSecrets) Add(username string) {
+	r.usernames[username] = true
+}
+
+func (r *retainedSecrets) Retains(username string) bool {
+	return r != nil && (r.all || r.usernames[username])
+}
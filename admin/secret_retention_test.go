@@ -0,0 +1,26 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetainedSecrets(t *testing.T) {
+	// Secrets are deleted without a set.
+	r := retainedSecretsFrom(context.TODO())
+	assert.Nil(t, r)
+	assert.False(t, r.Retains("alice"))
+
+	r = newRetainedSecrets()
+	r.Add("alice")
+	assert.Equal(t, r, retainedSecretsFrom(withRetainedSecrets(context.TODO(), r)))
+	assert.True(t, r.Retains("alice"))
+	assert.False(t, r.Retains("bob"))
+
+	assert.True(t, retainAllSecrets().Retains("bob"))
+}
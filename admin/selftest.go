@@ -0,0 +1,123 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	SelfTestAction string = "selftest"
+
+	SelfTestPass    string = "PASS"
+	SelfTestWarn    string = "WARN"
+	SelfTestFail    string = "FAIL"
+	SelfTestSkipped string = "SKIPPED"
+)
+
+// SelfTestRequest is sent by operators invoking TR function directly to
+// check that it can manage topics in a cluster, e.g.
+// {"action":"selftest","clusterArn":"arn:aws:kafka:..."}.
+type SelfTestRequest struct {
+	Action     string `json:"action"`
+	ClusterArn string `json:"clusterArn"`
+}
+
+// Returns the self-test request in payload. CloudFormation events do not
+// have an action field and are never mistaken for one.
+func ParseSelfTestRequest(payload []byte) (*SelfTestRequest, bool) {
+	var req SelfTestRequest
+	if err := json.Unmarshal(payload, &req); err != nil || req.Action != SelfTestAction {
+		return nil, false
+	}
+	return &req, true
+}
+
+type SelfTestCheck struct {
+	Name   string
+	Status string
+	Detail string `json:",omitempty"`
+}
+
+// SelfTestReport lists the outcome of each check. Ok is false when any
+// check failed.
+type SelfTestReport struct {
+	ClusterArn string
+	Ok         bool
+	Checks     []SelfTestCheck
+}
+
+// Records the outcome of a check and returns true when it passed.
+func (r *SelfTestReport) record(name string, err error, detail string) bool {
+	if err != nil {
+		r.Ok = false
+		r.Checks = append(r.Checks, SelfTestCheck{Name: name, Status: SelfTestFail, Detail: describeError(err)})
+		return false
+	}
+	r.Checks = append(r.Checks, SelfTestCheck{Name: name, Status: SelfTestPass, Detail: detail})
+	return true
+}
+
+func (r *SelfTestReport) skip(names ...string) {
+	for _, n := range names {
+		r.Checks = append(r.Checks, SelfTestCheck{Name: n, Status: SelfTestSkipped})
+	}
+}
+
+// SelfTest checks that TR function can reach the cluster and is allowed to
+// describe it, without changing anything. Failed checks are reported
+// rather than returned as errors so that all problems are visible at once.
+func (h *Handler) SelfTest(ctx context.Context, req *SelfTestRequest) (*SelfTestReport, error) {
+	if req.ClusterArn == "" {
+		return nil, errors.New("clusterArn is required")
+	}
+	logger, err := newLogger(h.settings)
+	if err != nil {
+		panic(err)
+	}
+	logger = logger.With(zap.String("Action", req.Action), zap.String("ClusterArn", req.ClusterArn))
+	ctx = context.WithValue(ctx, contextKeyLogger, logger)
+	defer logger.Sync()
+
+	r := &SelfTestReport{ClusterArn: req.ClusterArn, Ok: true}
+	serverless, err := isServerless(ctx, h.mskClient, req.ClusterArn)
+	if r.record("DescribeCluster", err, fmt.Sprintf("Serverless: %t", serverless)) && !serverless {
+		// The key is only required for SASL/SCRAM users.
+		key, err := newKmsKeyResolver(h.mskClient, h.settings.KmsKeyTag).Resolve(ctx, &types.TopicInfo{ClusterArn: req.ClusterArn, Users: []types.User{{Username: "selftest"}}})
+		var ce *classifiedError
+		if errors.As(err, &ce) && (ce.code == ErrCodeKmsKeyTagMissing || ce.code == ErrCodeScramAuthDisabled) {
+			r.Checks = append(r.Checks, SelfTestCheck{Name: "KmsKeyTag", Status: SelfTestWarn, Detail: describeError(err)})
+		} else {
+			r.record("KmsKeyTag", err, key)
+		}
+	}
+	kafkaClient, brokers, err := h.kafkaClientProvider.NewKafkaClient(ctx, req.ClusterArn)
+	detail := ""
+	if brokers != nil && h.settings.BrokerConnectivity == BrokerConnectivityPublic && brokers.BootstrapBrokerStringPublicSaslIam != nil {
+		detail = *brokers.BootstrapBrokerStringPublicSaslIam
+	} else if brokers != nil && brokers.BootstrapBrokerStringSaslIam != nil {
+		detail = *brokers.BootstrapBrokerStringSaslIam
+	}
+	if !r.record("GetBootstrapBrokers", err, detail) {
+		r.skip("ListBrokers", "ListTopics")
+	} else {
+		bs, err := kafkaClient.ListBrokers(ctx)
+		if !r.record("ListBrokers", err, fmt.Sprintf("%d brokers", len(bs))) {
+			// Listing topics would fail the same way.
+			r.skip("ListTopics")
+		} else {
+			ts, err := kafkaClient.ListTopics(ctx)
+			r.record("ListTopics", err, fmt.Sprintf("%d topics", len(ts)))
+		}
+	}
+	logger.Info("Self Test Finished", zap.Bool("Ok", r.Ok), zap.Any("Checks", r.Checks))
+	return r, nil
+}
@@ -0,0 +1,107 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/admin/mocks"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kafka"
+	kt "github.com/aws/aws-sdk-go-v2/service/kafka/types"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+)
+
+func TestParseSelfTestRequest(t *testing.T) {
+	req, ok := ParseSelfTestRequest([]byte(`{"action":"selftest","clusterArn":"arn"}`))
+	assert.True(t, ok)
+	assert.Equal(t, &SelfTestRequest{Action: SelfTestAction, ClusterArn: "arn"}, req)
+
+	_, ok = ParseSelfTestRequest([]byte(`{"RequestType":"Create","StackId":"test"}`))
+	assert.False(t, ok)
+	_, ok = ParseSelfTestRequest([]byte(`[]`))
+	assert.False(t, ok)
+}
+
+func TestSelfTest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clusterArn := "arn:aws:kafka:us-east-1:123456789012:cluster/c/uuid"
+	provisioned := &kafka.DescribeClusterV2Output{ClusterInfo: &kt.Cluster{ClusterType: kt.ClusterTypeProvisioned}}
+	brokers := &kafka.GetBootstrapBrokersOutput{BootstrapBrokerStringSaslIam: aws.String("b-1:9098")}
+	scram := &kt.ClientAuthentication{Sasl: &kt.Sasl{Scram: &kt.Scram{Enabled: true}}}
+
+	t.Run("Healthy", func(t *testing.T) {
+		mskClient := mocks.NewMockMskClient(ctrl)
+		kafkaClient := mocks.NewMockKafkaClient(ctrl)
+		handler := NewHandler(mskClient, nil, nil, nil, nil, &testKafkaClientProvider{kafkaClient: kafkaClient, brokers: brokers}, DefaultSettings())
+
+		mskClient.EXPECT().DescribeClusterV2(gomock.Any(), gomock.Any()).Return(provisioned, error(nil))
+		mskClient.EXPECT().DescribeCluster(gomock.Any(), gomock.Any()).Return(&kafka.DescribeClusterOutput{ClusterInfo: &kt.ClusterInfo{Tags: map[string]string{TagKmsKey: "key"}, ClientAuthentication: scram}}, error(nil))
+		kafkaClient.EXPECT().ListBrokers(gomock.Any()).Return(kadm.BrokerDetails{{NodeID: 1}, {NodeID: 2}}, error(nil))
+		kafkaClient.EXPECT().ListTopics(gomock.Any()).Return(kadm.TopicDetails{"a": {Topic: "a"}}, error(nil))
+
+		r, err := handler.SelfTest(context.TODO(), &SelfTestRequest{Action: SelfTestAction, ClusterArn: clusterArn})
+
+		assert.Nil(t, err)
+		assert.True(t, r.Ok)
+		assert.Equal(t, []SelfTestCheck{
+			{Name: "DescribeCluster", Status: SelfTestPass, Detail: "Serverless: false"},
+			{Name: "KmsKeyTag", Status: SelfTestPass, Detail: "key"},
+			{Name: "GetBootstrapBrokers", Status: SelfTestPass, Detail: "b-1:9098"},
+			{Name: "ListBrokers", Status: SelfTestPass, Detail: "2 brokers"},
+			{Name: "ListTopics", Status: SelfTestPass, Detail: "1 topics"},
+		}, r.Checks)
+	})
+
+	t.Run("Not authorized", func(t *testing.T) {
+		mskClient := mocks.NewMockMskClient(ctrl)
+		kafkaClient := mocks.NewMockKafkaClient(ctrl)
+		handler := NewHandler(mskClient, nil, nil, nil, nil, &testKafkaClientProvider{kafkaClient: kafkaClient, brokers: brokers}, DefaultSettings())
+
+		mskClient.EXPECT().DescribeClusterV2(gomock.Any(), gomock.Any()).Return(provisioned, error(nil))
+		mskClient.EXPECT().DescribeCluster(gomock.Any(), gomock.Any()).Return(&kafka.DescribeClusterOutput{ClusterInfo: &kt.ClusterInfo{ClientAuthentication: scram}}, error(nil))
+		kafkaClient.EXPECT().ListBrokers(gomock.Any()).Return(nil, kerr.ClusterAuthorizationFailed)
+
+		r, err := handler.SelfTest(context.TODO(), &SelfTestRequest{Action: SelfTestAction, ClusterArn: clusterArn})
+
+		assert.Nil(t, err)
+		assert.False(t, r.Ok)
+		assert.Equal(t, SelfTestWarn, r.Checks[1].Status)
+		assert.Equal(t, SelfTestCheck{Name: "ListBrokers", Status: SelfTestFail, Detail: describeError(kerr.ClusterAuthorizationFailed)}, r.Checks[3])
+		assert.Equal(t, SelfTestCheck{Name: "ListTopics", Status: SelfTestSkipped}, r.Checks[4])
+	})
+
+	t.Run("IAM authentication disabled", func(t *testing.T) {
+		mskClient := mocks.NewMockMskClient(ctrl)
+		cerr := errors.WithStack(newClassifiedError(ErrCodeIamAuthDisabled, "disabled"))
+		handler := NewHandler(mskClient, nil, nil, nil, nil, &testKafkaClientProvider{err: cerr}, DefaultSettings())
+
+		mskClient.EXPECT().DescribeClusterV2(gomock.Any(), gomock.Any()).Return(&kafka.DescribeClusterV2Output{ClusterInfo: &kt.Cluster{ClusterType: kt.ClusterTypeServerless}}, error(nil))
+
+		r, err := handler.SelfTest(context.TODO(), &SelfTestRequest{Action: SelfTestAction, ClusterArn: clusterArn})
+
+		assert.Nil(t, err)
+		assert.False(t, r.Ok)
+		assert.Equal(t, []SelfTestCheck{
+			{Name: "DescribeCluster", Status: SelfTestPass, Detail: "Serverless: true"},
+			{Name: "GetBootstrapBrokers", Status: SelfTestFail, Detail: ErrCodeIamAuthDisabled + ": disabled"},
+			{Name: "ListBrokers", Status: SelfTestSkipped},
+			{Name: "ListTopics", Status: SelfTestSkipped},
+		}, r.Checks)
+	})
+
+	t.Run("Missing cluster", func(t *testing.T) {
+		handler := NewHandler(nil, nil, nil, nil, nil, nil, DefaultSettings())
+		_, err := handler.SelfTest(context.TODO(), &SelfTestRequest{Action: SelfTestAction})
+		assert.EqualError(t, err, "clusterArn is required")
+	})
+}
@@ -0,0 +1,164 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	tt "github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/aws/aws-sdk-go-v2/service/kafka"
+	kt "github.com/aws/aws-sdk-go-v2/service/kafka/types"
+	"github.com/pkg/errors"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"go.uber.org/zap"
+)
+
+// MSK Serverless only supports IAM authentication and does not support
+// Kafka ACLs. Access to topics is granted via IAM policies instead.
+func isServerless(ctx context.Context, mskClient MskClient, clusterArn string) (bool, error) {
+	dc, err := mskClient.DescribeClusterV2(ctx, &kafka.DescribeClusterV2Input{
+		ClusterArn: &clusterArn,
+	})
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	return dc.ClusterInfo != nil && dc.ClusterInfo.ClusterType == kt.ClusterTypeServerless, nil
+}
+
+// iamUserManager manages users of serverless clusters. There are no
+// SASL/SCRAM credentials or ACLs to manage. Users are granted access via
+// IAM policies returned as output attributes of the resource.
+type iamUserManager struct {
+	logger *zap.Logger
+}
+
+func newIamUserManager(logger *zap.Logger) *iamUserManager {
+	return &iamUserManager{logger: logger}
+}
+
+func (um *iamUserManager) CreateUser(ctx context.Context, shortStackID, topic, kmsKeyID, clusterArn string, u *tt.User) error {
+	um.logger.Sugar().Infow("Skip Operation", "Name", "CreateUser", "Reason", "Serverless cluster uses IAM policies", "Username", u.Username)
+	return nil
+}
+
+func (um *iamUserManager) DeleteUser(ctx context.Context, u *tt.User, kmsKeyID, topic, shortStackID, clusterArn string) error {
+	um.logger.Sugar().Infow("Skip Operation", "Name", "DeleteUser", "Reason", "Serverless cluster uses IAM policies", "Username", u.Username)
+	return nil
+}
+
+func (um *iamUserManager) CreateACLs(ctx context.Context, topic string, u *tt.User, shortStackID string, permissions []tt.Permission) error {
+	return nil
+}
+
+func (um *iamUserManager) DeleteACLs(ctx context.Context, topic string, u *tt.User, shortStackID string, permissions []tt.Permission) error {
+	return nil
+}
+
+func (um *iamUserManager) ReconcileACLs(ctx context.Context, topic string, u *tt.User, shortStackID string) error {
+	return nil
+}
+
+func (um *iamUserManager) RevokeTopicACLs(ctx context.Context, topic string, u *tt.User, shortStackID string) error {
+	return nil
+}
+
+func (um *iamUserManager) ApplyACLs(ctx context.Context, acls []*kadm.ACLBuilder) error {
+	return nil
+}
+
+func (um *iamUserManager) UpdateArn(ctx context.Context, u *tt.User, oldArns []string, kmsKeyID, shortStackID string) error {
+	return nil
+}
+
+func (um *iamUserManager) ReconcileGrants(ctx context.Context, u *tt.User, kmsKeyID, shortStackID string) error {
+	return nil
+}
+
+func (um *iamUserManager) ProvisionedUsers(ctx context.Context, users []tt.User, shortStackID string) ([]tt.User, error) {
+	return users, nil
+}
+
+func (um *iamUserManager) AssociateSecrets(ctx context.Context, clusterArn string, secretArns []string) error {
+	return nil
+}
+
+func (um *iamUserManager) SecretArns(ctx context.Context, users []tt.User, shortStackID string) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (um *iamUserManager) CleanupOrphanedSecrets(ctx context.Context, users []tt.User, shortStackID, clusterArn string) error {
+	return nil
+}
+
+// Serverless clusters do not store SASL/SCRAM secrets.
+type iamKmsKeyResolver struct{}
+
+func (r *iamKmsKeyResolver) Resolve(ctx context.Context, info *tt.TopicInfo) (string, error) {
+	return "", nil
+}
+
+type iamPolicyStatement struct {
+	Effect   string
+	Action   []string
+	Resource []string
+}
+
+type iamPolicyDocument struct {
+	Version   string
+	Statement []iamPolicyStatement
+}
+
+// Returns an IAM policy document granting the permissions to the topics.
+// Cluster ARNs have the format arn:aws:kafka:<region>:<account>:cluster/<name>/<uuid>
+// and topic and group ARNs are derived from it. Access to groups is
+// limited to those starting with groupPrefix when it is not empty.
+func newTopicAccessPolicy(clusterArn string, topics []string, groupPrefix string, permissions []tt.Permission) (string, error) {
+	topicArns := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		topicArns = append(topicArns, fmt.Sprintf("%s/%s", strings.Replace(clusterArn, ":cluster/", ":topic/", 1), topic))
+	}
+	groupArn := fmt.Sprintf("%s/%s*", strings.Replace(clusterArn, ":cluster/", ":group/", 1), groupPrefix)
+	statements := []iamPolicyStatement{
+		{Effect: "Allow", Action: []string{"kafka-cluster:Connect"}, Resource: []string{clusterArn}},
+	}
+	topicActions := []string{"kafka-cluster:DescribeTopic"}
+	for _, p := range permissions {
+		switch p {
+		case tt.PermissionRead:
+			topicActions = append(topicActions, "kafka-cluster:ReadData")
+			statements = append(statements, iamPolicyStatement{Effect: "Allow", Action: []string{"kafka-cluster:AlterGroup", "kafka-cluster:DescribeGroup"}, Resource: []string{groupArn}})
+		case tt.PermissionWrite:
+			topicActions = append(topicActions, "kafka-cluster:WriteData")
+		case tt.PermissionOffsetManagement:
+			statements = append(statements, iamPolicyStatement{Effect: "Allow", Action: []string{"kafka-cluster:DeleteGroup"}, Resource: []string{groupArn}})
+		}
+	}
+	statements = append(statements, iamPolicyStatement{Effect: "Allow", Action: topicActions, Resource: topicArns})
+	buf, err := json.Marshal(iamPolicyDocument{Version: "2012-10-17", Statement: statements})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(buf), nil
+}
+
+// Adds an IAM policy document for each user to the output attributes.
+func addIamPolicies(props map[string]interface{}, clusterArn, topic string, users []tt.User) error {
+	for _, u := range users {
+		resource := topic
+		if u.TopicPrefix != "" {
+			resource = u.TopicPrefix + "*"
+		}
+		topics := append([]string{resource}, u.AdditionalTopics...)
+		policy, err := newTopicAccessPolicy(clusterArn, topics, u.GroupPrefix, u.Permissions)
+		if err != nil {
+			return err
+		}
+		props[PropIamPolicyPrefix+u.Username] = policy
+	}
+	return nil
+}
@@ -0,0 +1,400 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	EnvMaxACLOperationsPerUser string = "TR_MAX_ACL_OPERATIONS_PER_USER"
+	EnvMaxResourceFootprint    string = "TR_MAX_RESOURCE_FOOTPRINT"
+	EnvMetricsEnabled          string = "TR_METRICS_ENABLED"
+	EnvDisassociateMaxAttempts string = "TR_DISASSOCIATE_MAX_ATTEMPTS"
+	EnvAssociateMaxAttempts    string = "TR_ASSOCIATE_MAX_ATTEMPTS"
+	EnvACLMaxAttempts          string = "TR_ACL_MAX_ATTEMPTS"
+	EnvACLRetryTimeout         string = "TR_ACL_RETRY_TIMEOUT"
+	EnvFixedDelay              string = "TR_FIXED_DELAY"
+	EnvSecretCreateDelay       string = "TR_SECRET_CREATE_DELAY"
+	EnvUserDeleteDelay         string = "TR_USER_DELETE_DELAY"
+	EnvMinPasswordEntropyBits  string = "TR_MIN_PASSWORD_ENTROPY_BITS"
+	EnvPasswordBytes           string = "TR_PASSWORD_BYTES"
+	EnvPasswordEncoding        string = "TR_PASSWORD_ENCODING"
+	EnvSecretNameTemplate      string = "TR_SECRET_NAME_TEMPLATE"
+	EnvKmsKeyTag               string = "TR_KMS_KEY_TAG"
+	EnvAWSRetryMaxAttempts     string = "TR_AWS_RETRY_MAX_ATTEMPTS"
+	EnvAWSRetryMode            string = "TR_AWS_RETRY_MODE"
+	EnvKafkaDialTimeout        string = "TR_KAFKA_DIAL_TIMEOUT"
+	EnvKafkaRequestTimeout     string = "TR_KAFKA_REQUEST_TIMEOUT"
+	EnvPrincipalCheck          string = "TR_PRINCIPAL_CHECK"
+	EnvBrokerConnectivity      string = "TR_BROKER_CONNECTIVITY"
+	EnvTransactionalACLs       string = "TR_TRANSACTIONAL_ACLS"
+	EnvCapacityWarningPercent  string = "TR_CAPACITY_WARNING_PERCENT"
+	EnvSettleTimeout           string = "TR_SETTLE_TIMEOUT"
+	EnvEnforcedTopicConfig     string = "TR_ENFORCED_TOPIC_CONFIG"
+	EnvLogLevel                string = "TR_LOG_LEVEL"
+	EnvLogFormat               string = "TR_LOG_FORMAT"
+	EnvSnapshotBucket          string = "TR_SNAPSHOT_BUCKET"
+	EnvSnapshotPrefix          string = "TR_SNAPSHOT_PREFIX"
+	EnvCreateTopicTimeout      string = "TR_CREATE_TOPIC_TIMEOUT"
+	EnvKafkaClientID           string = "TR_KAFKA_CLIENT_ID"
+	EnvRetryMaxAttempts        string = "TR_RETRY_MAX_ATTEMPTS"
+	EnvRetryBaseDelay          string = "TR_RETRY_BASE_DELAY"
+	EnvRetryMaxDelay           string = "TR_RETRY_MAX_DELAY"
+	EnvScramSecretArn          string = "TR_SCRAM_SECRET_ARN"
+	EnvScramMechanism          string = "TR_SCRAM_MECHANISM"
+	EnvOutputBucket            string = "TR_OUTPUT_BUCKET"
+	EnvTopicReadyTimeout       string = "TR_TOPIC_READY_TIMEOUT"
+	EnvBootstrapMaxAttempts    string = "TR_BOOTSTRAP_MAX_ATTEMPTS"
+)
+
+// Settings contains operator level configuration of TR function.
+// Unlike topic properties, these are not specified in CloudFormation
+// templates. They are sourced from Lambda environment variables instead.
+type Settings struct {
+	// Maximum number of ACL operations a single user can be granted.
+	// Zero disables the check.
+	MaxACLOperationsPerUser int
+	// Maximum number of topics, secrets, KMS grants and ACLs a single
+	// request can create. Zero disables the check.
+	MaxResourceFootprint int
+	// Emit CloudWatch metrics in embedded metric format.
+	MetricsEnabled bool
+	// Number of attempts made to disassociate a SASL/SCRAM secret from
+	// the cluster before giving up on deleting a user.
+	DisassociateMaxAttempts int
+	// Number of attempts made to associate a SASL/SCRAM secret with the
+	// cluster when MSK throttles or fails the request.
+	AssociateMaxAttempts int
+	// Number of attempts made to create or delete an ACL when the cluster
+	// reports a retriable error.
+	ACLMaxAttempts int
+	// Number of attempts made to describe the bootstrap brokers of the
+	// cluster when MSK fails the request or returns no brokers.
+	BootstrapMaxAttempts int
+	// Upper limit on the time spent waiting between attempts to create or
+	// delete an ACL. Zero disables the limit.
+	ACLRetryTimeout time.Duration
+	// Time to wait for SecretsManager changes to become visible to MSK.
+	// Default for SecretCreateDelay and UserDeleteDelay.
+	FixedDelay time.Duration
+	// Time to wait after creating a user secret before associating it.
+	SecretCreateDelay time.Duration
+	// Time to wait after deleting users before creating users in the
+	// same update.
+	UserDeleteDelay time.Duration
+	// Minimum estimated entropy in bits of generated SASL/SCRAM passwords.
+	MinPasswordEntropyBits int
+	// Number of random bytes in generated passwords. More bytes are used
+	// when required to meet MinPasswordEntropyBits.
+	PasswordBytes int
+	// Encoding of generated passwords (base64-nopad, base64url or hex).
+	PasswordEncoding string
+	// Name of secrets generated for users, which is also their SASL/SCRAM
+	// username. {username} and {suffix} are replaced by the declared
+	// username and the name suffix of the stack.
+	SecretNameTemplate string
+	// Name of the cluster tag holding the ARN of the KMS key used to
+	// encrypt generated secrets.
+	KmsKeyTag string
+	// Maximum attempts made by AWS SDK clients for each API call.
+	// Zero uses the SDK default.
+	AWSRetryMaxAttempts int
+	// Retry mode (standard or adaptive) of AWS SDK clients. Empty uses
+	// the SDK default.
+	AWSRetryMode string
+	// Time allowed to establish a connection to a broker.
+	KafkaDialTimeout time.Duration
+	// Upper limit on the time spent retrying a Kafka request.
+	// Zero disables the limit.
+	KafkaRequestTimeout time.Duration
+	// Whether to check that user principals exist before granting them
+	// access (off, warn or error). Cross-account principals are not checked.
+	PrincipalCheck string
+	// Bootstrap brokers TR function connects to (private or public).
+	BrokerConnectivity string
+	// Apply the ACLs of all users created or changed by a request as a
+	// unit, rolling back the ones created when any of them fails.
+	TransactionalACLs bool
+	// Percentage of the recommended partition replica count or broker
+	// storage of a provisioned cluster at which creating a topic logs a
+	// warning. Zero disables the check.
+	CapacityWarningPercent int
+	// Time allowed for secret associations and ACLs created by a request
+	// to become observable before it completes. Zero disables waiting.
+	SettleTimeout time.Duration
+	// Time allowed for a created topic to be listed with all of its
+	// partitions before users are created. Zero disables waiting.
+	TopicReadyTimeout time.Duration
+	// Topic configs applied to every topic, overriding the values declared
+	// in templates.
+	EnforcedTopicConfig map[string]string
+	// Minimum level of logged entries (debug, info, warn or error).
+	LogLevel string
+	// Encoding of log entries (json or console).
+	LogFormat string
+	// S3 bucket receiving the snapshots of topics deleted with the
+	// SNAPSHOT deletion policy. Empty when snapshots are not configured.
+	SnapshotBucket string
+	// Prefix of the keys of snapshots in SnapshotBucket.
+	SnapshotPrefix string
+	// Time the controller is given to create a topic before the request
+	// times out.
+	CreateTopicTimeout time.Duration
+	// Client ID of the connections TR makes to brokers.
+	KafkaClientID string
+	// Attempts and delays of in-process retries.
+	Retry RetryConfig
+	// ARN of the secret holding the SASL/SCRAM credentials TR function
+	// connects to brokers with. Empty uses IAM authentication.
+	ScramSecretArn string
+	// SASL/SCRAM mechanism (SCRAM-SHA-512 or SCRAM-SHA-256).
+	ScramMechanism string
+	// S3 bucket receiving output attributes too large for the response
+	// to CloudFormation. Empty fails such requests.
+	OutputBucket string
+}
+
+func DefaultSettings() *Settings {
+	return &Settings{
+		MaxACLOperationsPerUser: 20,
+		MaxResourceFootprint:    500,
+		MetricsEnabled:          true,
+		DisassociateMaxAttempts: 5,
+		AssociateMaxAttempts:    5,
+		ACLMaxAttempts:          5,
+		BootstrapMaxAttempts:    3,
+		ACLRetryTimeout:         30 * time.Second,
+		FixedDelay:              30 * time.Second,
+		SecretCreateDelay:       30 * time.Second,
+		UserDeleteDelay:         30 * time.Second,
+		MinPasswordEntropyBits:  64,
+		PasswordBytes:           9,
+		PasswordEncoding:        PasswordEncodingBase64NoPad,
+		SecretNameTemplate:      string(defaultSecretNameTemplate),
+		KmsKeyTag:               TagKmsKey,
+		KafkaDialTimeout:        10 * time.Second,
+		KafkaRequestTimeout:     30 * time.Second,
+		PrincipalCheck:          PrincipalCheckOff,
+		BrokerConnectivity:      BrokerConnectivityPrivate,
+		CapacityWarningPercent:  80,
+		LogLevel:                "info",
+		LogFormat:               LogFormatJSON,
+		SnapshotPrefix:          "tr-snapshots/",
+		CreateTopicTimeout:      15 * time.Second,
+		KafkaClientID:           "amazon-msk-topic-resource",
+		Retry:                   RetryConfig{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second},
+		ScramMechanism:          ScramMechanismSha512,
+	}
+}
+
+// Returns default settings overridden by any values specified in
+// environment variables.
+func NewSettingsFromEnv() (*Settings, error) {
+	s := DefaultSettings()
+	var err error
+	if s.MaxACLOperationsPerUser, err = intFromEnv(EnvMaxACLOperationsPerUser, s.MaxACLOperationsPerUser); err != nil {
+		return nil, err
+	}
+	if s.MaxResourceFootprint, err = intFromEnv(EnvMaxResourceFootprint, s.MaxResourceFootprint); err != nil {
+		return nil, err
+	}
+	if s.MetricsEnabled, err = boolFromEnv(EnvMetricsEnabled, s.MetricsEnabled); err != nil {
+		return nil, err
+	}
+	if s.DisassociateMaxAttempts, err = intFromEnv(EnvDisassociateMaxAttempts, s.DisassociateMaxAttempts); err != nil {
+		return nil, err
+	}
+	if s.DisassociateMaxAttempts == 0 {
+		return nil, errors.WithStack(fmt.Errorf("environment variable %s must be a positive integer: %q", EnvDisassociateMaxAttempts, os.Getenv(EnvDisassociateMaxAttempts)))
+	}
+	if s.AssociateMaxAttempts, err = intFromEnv(EnvAssociateMaxAttempts, s.AssociateMaxAttempts); err != nil {
+		return nil, err
+	}
+	if s.AssociateMaxAttempts == 0 {
+		return nil, errors.WithStack(fmt.Errorf("environment variable %s must be a positive integer: %q", EnvAssociateMaxAttempts, os.Getenv(EnvAssociateMaxAttempts)))
+	}
+	if s.ACLMaxAttempts, err = intFromEnv(EnvACLMaxAttempts, s.ACLMaxAttempts); err != nil {
+		return nil, err
+	}
+	if s.ACLMaxAttempts == 0 {
+		return nil, errors.WithStack(fmt.Errorf("environment variable %s must be a positive integer: %q", EnvACLMaxAttempts, os.Getenv(EnvACLMaxAttempts)))
+	}
+	if s.BootstrapMaxAttempts, err = intFromEnv(EnvBootstrapMaxAttempts, s.BootstrapMaxAttempts); err != nil {
+		return nil, err
+	}
+	if s.BootstrapMaxAttempts == 0 {
+		return nil, errors.WithStack(fmt.Errorf("environment variable %s must be a positive integer: %q", EnvBootstrapMaxAttempts, os.Getenv(EnvBootstrapMaxAttempts)))
+	}
+	if s.ACLRetryTimeout, err = durationFromEnv(EnvACLRetryTimeout, s.ACLRetryTimeout); err != nil {
+		return nil, err
+	}
+	if s.TransactionalACLs, err = boolFromEnv(EnvTransactionalACLs, s.TransactionalACLs); err != nil {
+		return nil, err
+	}
+	if s.FixedDelay, err = durationFromEnv(EnvFixedDelay, s.FixedDelay); err != nil {
+		return nil, err
+	}
+	if s.SecretCreateDelay, err = durationFromEnv(EnvSecretCreateDelay, s.FixedDelay); err != nil {
+		return nil, err
+	}
+	if s.UserDeleteDelay, err = durationFromEnv(EnvUserDeleteDelay, s.FixedDelay); err != nil {
+		return nil, err
+	}
+	if s.MinPasswordEntropyBits, err = intFromEnv(EnvMinPasswordEntropyBits, s.MinPasswordEntropyBits); err != nil {
+		return nil, err
+	}
+	if s.PasswordBytes, err = intFromEnv(EnvPasswordBytes, s.PasswordBytes); err != nil {
+		return nil, err
+	}
+	if s.PasswordBytes == 0 {
+		return nil, errors.WithStack(fmt.Errorf("environment variable %s must be a positive integer: %q", EnvPasswordBytes, os.Getenv(EnvPasswordBytes)))
+	}
+	if v := os.Getenv(EnvPasswordEncoding); v != "" {
+		if _, ok := passwordEncodings[v]; !ok {
+			return nil, errors.WithStack(fmt.Errorf("environment variable %s must be base64-nopad, base64url or hex: %q", EnvPasswordEncoding, v))
+		}
+		s.PasswordEncoding = v
+	}
+	if v := os.Getenv(EnvSecretNameTemplate); v != "" {
+		if err := secretNameTemplate(v).Validate(); err != nil {
+			return nil, errors.WithStack(fmt.Errorf("environment variable %s is invalid: %v", EnvSecretNameTemplate, err))
+		}
+		s.SecretNameTemplate = v
+	}
+	if v := os.Getenv(EnvKmsKeyTag); v != "" {
+		s.KmsKeyTag = v
+	}
+	if s.CapacityWarningPercent, err = intFromEnv(EnvCapacityWarningPercent, s.CapacityWarningPercent); err != nil {
+		return nil, err
+	}
+	if s.AWSRetryMaxAttempts, err = intFromEnv(EnvAWSRetryMaxAttempts, s.AWSRetryMaxAttempts); err != nil {
+		return nil, err
+	}
+	if s.SettleTimeout, err = durationFromEnv(EnvSettleTimeout, s.SettleTimeout); err != nil {
+		return nil, err
+	}
+	if s.TopicReadyTimeout, err = durationFromEnv(EnvTopicReadyTimeout, s.TopicReadyTimeout); err != nil {
+		return nil, err
+	}
+	if s.KafkaDialTimeout, err = durationFromEnv(EnvKafkaDialTimeout, s.KafkaDialTimeout); err != nil {
+		return nil, err
+	}
+	if s.KafkaRequestTimeout, err = durationFromEnv(EnvKafkaRequestTimeout, s.KafkaRequestTimeout); err != nil {
+		return nil, err
+	}
+	if v := os.Getenv(EnvAWSRetryMode); v != "" {
+		if _, err := aws.ParseRetryMode(v); err != nil {
+			return nil, errors.WithStack(fmt.Errorf("environment variable %s must be standard or adaptive: %q", EnvAWSRetryMode, v))
+		}
+		s.AWSRetryMode = v
+	}
+	if v := os.Getenv(EnvEnforcedTopicConfig); v != "" {
+		if err := json.Unmarshal([]byte(v), &s.EnforcedTopicConfig); err != nil {
+			return nil, errors.WithStack(fmt.Errorf("environment variable %s must be a JSON object with string values: %q", EnvEnforcedTopicConfig, v))
+		}
+	}
+	if v := os.Getenv(EnvLogLevel); v != "" {
+		if _, err := zapcore.ParseLevel(v); err != nil {
+			return nil, errors.WithStack(fmt.Errorf("environment variable %s must be debug, info, warn or error: %q", EnvLogLevel, v))
+		}
+		s.LogLevel = v
+	}
+	if v := os.Getenv(EnvLogFormat); v != "" {
+		if v != LogFormatJSON && v != LogFormatConsole {
+			return nil, errors.WithStack(fmt.Errorf("environment variable %s must be json or console: %q", EnvLogFormat, v))
+		}
+		s.LogFormat = v
+	}
+	if v := os.Getenv(EnvPrincipalCheck); v != "" {
+		if v != PrincipalCheckOff && v != PrincipalCheckWarn && v != PrincipalCheckError {
+			return nil, errors.WithStack(fmt.Errorf("environment variable %s must be off, warn or error: %q", EnvPrincipalCheck, v))
+		}
+		s.PrincipalCheck = v
+	}
+	if v := os.Getenv(EnvBrokerConnectivity); v != "" {
+		if v != BrokerConnectivityPrivate && v != BrokerConnectivityPublic {
+			return nil, errors.WithStack(fmt.Errorf("environment variable %s must be private or public: %q", EnvBrokerConnectivity, v))
+		}
+		s.BrokerConnectivity = v
+	}
+	s.SnapshotBucket = os.Getenv(EnvSnapshotBucket)
+	if v, ok := os.LookupEnv(EnvSnapshotPrefix); ok {
+		s.SnapshotPrefix = v
+	}
+	if s.CreateTopicTimeout, err = durationFromEnv(EnvCreateTopicTimeout, s.CreateTopicTimeout); err != nil {
+		return nil, err
+	}
+	if s.CreateTopicTimeout == 0 {
+		return nil, errors.WithStack(fmt.Errorf("environment variable %s must be a positive duration: %q", EnvCreateTopicTimeout, os.Getenv(EnvCreateTopicTimeout)))
+	}
+	if v := os.Getenv(EnvKafkaClientID); v != "" {
+		s.KafkaClientID = v
+	}
+	if s.Retry.MaxAttempts, err = intFromEnv(EnvRetryMaxAttempts, s.Retry.MaxAttempts); err != nil {
+		return nil, err
+	}
+	if s.Retry.MaxAttempts == 0 {
+		return nil, errors.WithStack(fmt.Errorf("environment variable %s must be a positive integer: %q", EnvRetryMaxAttempts, os.Getenv(EnvRetryMaxAttempts)))
+	}
+	if s.Retry.BaseDelay, err = durationFromEnv(EnvRetryBaseDelay, s.Retry.BaseDelay); err != nil {
+		return nil, err
+	}
+	if s.Retry.MaxDelay, err = durationFromEnv(EnvRetryMaxDelay, s.Retry.MaxDelay); err != nil {
+		return nil, err
+	}
+	if s.Retry.MaxDelay < s.Retry.BaseDelay {
+		return nil, errors.WithStack(fmt.Errorf("environment variable %s must not be less than %s: %q", EnvRetryMaxDelay, EnvRetryBaseDelay, os.Getenv(EnvRetryMaxDelay)))
+	}
+	s.ScramSecretArn = os.Getenv(EnvScramSecretArn)
+	if v := os.Getenv(EnvScramMechanism); v != "" {
+		s.ScramMechanism = v
+	}
+	s.OutputBucket = os.Getenv(EnvOutputBucket)
+	return s, nil
+}
+
+func intFromEnv(name string, def int) (int, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok || v == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 0 {
+		return 0, errors.WithStack(fmt.Errorf("environment variable %s must be a non-negative integer: %q", name, v))
+	}
+	return i, nil
+}
+
+func boolFromEnv(name string, def bool) (bool, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok || v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, errors.WithStack(fmt.Errorf("environment variable %s must be a boolean: %q", name, v))
+	}
+	return b, nil
+}
+
+func durationFromEnv(name string, def time.Duration) (time.Duration, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok || v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, errors.WithStack(fmt.Errorf("environment variable %s must be a non-negative duration (e.g. 30s): %q", name, v))
+	}
+	return d, nil
+}
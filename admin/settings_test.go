@@ -0,0 +1,246 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns the default settings without the delays meant to let AWS
+// services catch up, which only slow tests down.
+func testSettings() *Settings {
+	s := DefaultSettings()
+	s.SecretCreateDelay = 0
+	s.UserDeleteDelay = 0
+	return s
+}
+
+func TestNewSettingsFromEnv(t *testing.T) {
+	type testCase struct {
+		env      map[string]string
+		settings func(s *Settings)
+		err      string
+	}
+
+	cases := map[string]testCase{
+		"Defaults": {
+			env:      map[string]string{},
+			settings: func(s *Settings) {},
+		},
+		"Fixed delay": {
+			env: map[string]string{EnvFixedDelay: "5s"},
+			settings: func(s *Settings) {
+				s.FixedDelay = 5 * time.Second
+				s.SecretCreateDelay = 5 * time.Second
+				s.UserDeleteDelay = 5 * time.Second
+			},
+		},
+		"Per operation delays": {
+			env: map[string]string{EnvFixedDelay: "5s", EnvSecretCreateDelay: "10s", EnvUserDeleteDelay: "1m"},
+			settings: func(s *Settings) {
+				s.FixedDelay = 5 * time.Second
+				s.SecretCreateDelay = 10 * time.Second
+				s.UserDeleteDelay = time.Minute
+			},
+		},
+		"Max resource footprint": {
+			env:      map[string]string{EnvMaxResourceFootprint: "0"},
+			settings: func(s *Settings) { s.MaxResourceFootprint = 0 },
+		},
+		"Zero disassociate attempts": {
+			env: map[string]string{EnvDisassociateMaxAttempts: "0"},
+			err: "environment variable TR_DISASSOCIATE_MAX_ATTEMPTS must be a positive integer: \"0\"",
+		},
+		"Associate max attempts": {
+			env:      map[string]string{EnvAssociateMaxAttempts: "8"},
+			settings: func(s *Settings) { s.AssociateMaxAttempts = 8 },
+		},
+		"Zero associate attempts": {
+			env: map[string]string{EnvAssociateMaxAttempts: "0"},
+			err: "environment variable TR_ASSOCIATE_MAX_ATTEMPTS must be a positive integer: \"0\"",
+		},
+		"Min password entropy": {
+			env:      map[string]string{EnvMinPasswordEntropyBits: "128"},
+			settings: func(s *Settings) { s.MinPasswordEntropyBits = 128 },
+		},
+		"Password bytes and encoding": {
+			env: map[string]string{EnvPasswordBytes: "32", EnvPasswordEncoding: "hex"},
+			settings: func(s *Settings) {
+				s.PasswordBytes = 32
+				s.PasswordEncoding = PasswordEncodingHex
+			},
+		},
+		"Invalid password bytes": {
+			env: map[string]string{EnvPasswordBytes: "0"},
+			err: "environment variable TR_PASSWORD_BYTES must be a positive integer: \"0\"",
+		},
+		"Invalid password encoding": {
+			env: map[string]string{EnvPasswordEncoding: "base32"},
+			err: "environment variable TR_PASSWORD_ENCODING must be base64-nopad, base64url or hex: \"base32\"",
+		},
+		"Secret name template": {
+			env:      map[string]string{EnvSecretNameTemplate: "AmazonMSK_team-a_{username}_{suffix}"},
+			settings: func(s *Settings) { s.SecretNameTemplate = "AmazonMSK_team-a_{username}_{suffix}" },
+		},
+		"Invalid secret name template": {
+			env: map[string]string{EnvSecretNameTemplate: "team-a_{username}_{suffix}"},
+			err: "environment variable TR_SECRET_NAME_TEMPLATE is invalid: secret name template must begin with AmazonMSK_: \"team-a_{username}_{suffix}\"",
+		},
+		"KMS key tag": {
+			env:      map[string]string{EnvKmsKeyTag: "kms-key-arn"},
+			settings: func(s *Settings) { s.KmsKeyTag = "kms-key-arn" },
+		},
+		"Invalid fixed delay": {
+			env: map[string]string{EnvFixedDelay: "5"},
+			err: "environment variable TR_FIXED_DELAY must be a non-negative duration (e.g. 30s): \"5\"",
+		},
+		"AWS retryer": {
+			env: map[string]string{EnvAWSRetryMaxAttempts: "10", EnvAWSRetryMode: "adaptive"},
+			settings: func(s *Settings) {
+				s.AWSRetryMaxAttempts = 10
+				s.AWSRetryMode = "adaptive"
+			},
+		},
+		"Kafka timeouts": {
+			env: map[string]string{EnvKafkaDialTimeout: "30s", EnvKafkaRequestTimeout: "2m"},
+			settings: func(s *Settings) {
+				s.KafkaDialTimeout = 30 * time.Second
+				s.KafkaRequestTimeout = 2 * time.Minute
+			},
+		},
+		"Enforced topic config": {
+			env: map[string]string{EnvEnforcedTopicConfig: `{"unclean.leader.election.enable":"false"}`},
+			settings: func(s *Settings) {
+				s.EnforcedTopicConfig = map[string]string{"unclean.leader.election.enable": "false"}
+			},
+		},
+		"Invalid enforced topic config": {
+			env: map[string]string{EnvEnforcedTopicConfig: `{"min.insync.replicas":2}`},
+			err: "environment variable TR_ENFORCED_TOPIC_CONFIG must be a JSON object with string values: \"{\\\"min.insync.replicas\\\":2}\"",
+		},
+		"Log level and format": {
+			env:      map[string]string{EnvLogLevel: "debug", EnvLogFormat: "console"},
+			settings: func(s *Settings) { s.LogLevel = "debug"; s.LogFormat = LogFormatConsole },
+		},
+		"Invalid log level": {
+			env: map[string]string{EnvLogLevel: "verbose"},
+			err: "environment variable TR_LOG_LEVEL must be debug, info, warn or error: \"verbose\"",
+		},
+		"Invalid log format": {
+			env: map[string]string{EnvLogFormat: "text"},
+			err: "environment variable TR_LOG_FORMAT must be json or console: \"text\"",
+		},
+		"ACL retries": {
+			env:      map[string]string{EnvACLMaxAttempts: "3", EnvACLRetryTimeout: "1m"},
+			settings: func(s *Settings) { s.ACLMaxAttempts = 3; s.ACLRetryTimeout = time.Minute },
+		},
+		"Zero ACL attempts": {
+			env: map[string]string{EnvACLMaxAttempts: "0"},
+			err: "environment variable TR_ACL_MAX_ATTEMPTS must be a positive integer: \"0\"",
+		},
+		"Bootstrap attempts": {
+			env:      map[string]string{EnvBootstrapMaxAttempts: "5"},
+			settings: func(s *Settings) { s.BootstrapMaxAttempts = 5 },
+		},
+		"Zero bootstrap attempts": {
+			env: map[string]string{EnvBootstrapMaxAttempts: "0"},
+			err: "environment variable TR_BOOTSTRAP_MAX_ATTEMPTS must be a positive integer: \"0\"",
+		},
+		"Settle timeout": {
+			env:      map[string]string{EnvSettleTimeout: "1m"},
+			settings: func(s *Settings) { s.SettleTimeout = time.Minute },
+		},
+		"Topic ready timeout": {
+			env:      map[string]string{EnvTopicReadyTimeout: "30s"},
+			settings: func(s *Settings) { s.TopicReadyTimeout = 30 * time.Second },
+		},
+		"Transactional ACLs": {
+			env:      map[string]string{EnvTransactionalACLs: "true"},
+			settings: func(s *Settings) { s.TransactionalACLs = true },
+		},
+		"Capacity warning percent": {
+			env:      map[string]string{EnvCapacityWarningPercent: "0"},
+			settings: func(s *Settings) { s.CapacityWarningPercent = 0 },
+		},
+		"Principal check": {
+			env:      map[string]string{EnvPrincipalCheck: "error"},
+			settings: func(s *Settings) { s.PrincipalCheck = PrincipalCheckError },
+		},
+		"Invalid principal check": {
+			env: map[string]string{EnvPrincipalCheck: "strict"},
+			err: "environment variable TR_PRINCIPAL_CHECK must be off, warn or error: \"strict\"",
+		},
+		"Broker connectivity": {
+			env:      map[string]string{EnvBrokerConnectivity: "public"},
+			settings: func(s *Settings) { s.BrokerConnectivity = BrokerConnectivityPublic },
+		},
+		"Invalid broker connectivity": {
+			env: map[string]string{EnvBrokerConnectivity: "vpc"},
+			err: "environment variable TR_BROKER_CONNECTIVITY must be private or public: \"vpc\"",
+		},
+		"Snapshot location": {
+			env:      map[string]string{EnvSnapshotBucket: "backups", EnvSnapshotPrefix: "kafka/"},
+			settings: func(s *Settings) { s.SnapshotBucket = "backups"; s.SnapshotPrefix = "kafka/" },
+		},
+		"Create topic timeout": {
+			env:      map[string]string{EnvCreateTopicTimeout: "1m"},
+			settings: func(s *Settings) { s.CreateTopicTimeout = time.Minute },
+		},
+		"Zero create topic timeout": {
+			env: map[string]string{EnvCreateTopicTimeout: "0s"},
+			err: "environment variable TR_CREATE_TOPIC_TIMEOUT must be a positive duration: \"0s\"",
+		},
+		"Kafka client ID": {
+			env:      map[string]string{EnvKafkaClientID: "platform-tr"},
+			settings: func(s *Settings) { s.KafkaClientID = "platform-tr" },
+		},
+		"Retry": {
+			env:      map[string]string{EnvRetryMaxAttempts: "5", EnvRetryBaseDelay: "0s", EnvRetryMaxDelay: "0s"},
+			settings: func(s *Settings) { s.Retry = RetryConfig{MaxAttempts: 5} },
+		},
+		"Retry max delay below base delay": {
+			env: map[string]string{EnvRetryBaseDelay: "2s", EnvRetryMaxDelay: "1s"},
+			err: "environment variable TR_RETRY_MAX_DELAY must not be less than TR_RETRY_BASE_DELAY: \"1s\"",
+		},
+		"SCRAM authentication": {
+			env: map[string]string{EnvScramSecretArn: "arn:aws:secretsmanager:us-east-1:123456789012:secret:AmazonMSK_tr", EnvScramMechanism: ScramMechanismSha256},
+			settings: func(s *Settings) {
+				s.ScramSecretArn = "arn:aws:secretsmanager:us-east-1:123456789012:secret:AmazonMSK_tr"
+				s.ScramMechanism = ScramMechanismSha256
+			},
+		},
+		"Output bucket": {
+			env:      map[string]string{EnvOutputBucket: "outputs"},
+			settings: func(s *Settings) { s.OutputBucket = "outputs" },
+		},
+		"Invalid AWS retry mode": {
+			env: map[string]string{EnvAWSRetryMode: "eager"},
+			err: "environment variable TR_AWS_RETRY_MODE must be standard or adaptive: \"eager\"",
+		},
+		"Invalid user delete delay": {
+			env: map[string]string{EnvUserDeleteDelay: "-1s"},
+			err: "environment variable TR_USER_DELETE_DELAY must be a non-negative duration (e.g. 30s): \"-1s\"",
+		},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			for ek, ev := range c.env {
+				t.Setenv(ek, ev)
+			}
+			s, err := NewSettingsFromEnv()
+			if c.err != "" {
+				assert.EqualError(t, err, c.err)
+				return
+			}
+			expected := DefaultSettings()
+			c.settings(expected)
+			assert.Nil(t, err)
+			assert.Equal(t, expected, s)
+		})
+	}
+}
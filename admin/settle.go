@@ -0,0 +1,180 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/kafka"
+	"github.com/pkg/errors"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"go.uber.org/zap"
+)
+
+var contextKeyCreatedResources contextKey = contextKey("CreatedResources")
+
+// Interval between checks made while waiting for resources to settle.
+const settleInterval = 2 * time.Second
+
+// createdResources records the secrets associated and ACLs created by a
+// request so that they can be awaited once the request completes.
+type createdResources struct {
+	mu         sync.Mutex
+	secretArns []string
+	acls       []*kadm.ACLBuilder
+}
+
+func newCreatedResources() *createdResources {
+	return &createdResources{}
+}
+
+// Returns a copy of ctx carrying r.
+func withCreatedResources(ctx context.Context, r *createdResources) context.Context {
+	return context.WithValue(ctx, contextKeyCreatedResources, r)
+}
+
+// Returns the resources recorded in ctx. The result is nil when ctx does
+// not carry any, in which case resources are not recorded.
+func createdResourcesFrom(ctx context.Context) *createdResources {
+	r, _ := ctx.Value(contextKeyCreatedResources).(*createdResources)
+	return r
+}
+
+func (r *createdResources) AddSecret(secretArn string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.secretArns = append(r.secretArns, secretArn)
+}
+
+func (r *createdResources) AddACLs(acls ...*kadm.ACLBuilder) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.acls = append(r.acls, acls...)
+}
+
+// settlePoller waits until the resources created by a request are
+// observable. MSK propagates secret associations and brokers propagate
+// ACLs asynchronously, therefore clients connecting as soon as
+// CloudFormation reports completion may otherwise fail to authenticate or
+// be denied access.
+type settlePoller struct {
+	mskClient   MskClient
+	kafkaClient KafkaClient
+	timeout     time.Duration
+	interval    time.Duration
+	sleep       func(time.Duration)
+	logger      *zap.Logger
+}
+
+func newSettlePoller(mskClient MskClient, kafkaClient KafkaClient, timeout time.Duration, logger *zap.Logger) *settlePoller {
+	return &settlePoller{
+		mskClient:   mskClient,
+		kafkaClient: kafkaClient,
+		timeout:     timeout,
+		interval:    settleInterval,
+		sleep:       time.Sleep,
+		logger:      logger,
+	}
+}
+
+// Polls until all secrets in r are associated with the cluster and all
+// ACLs in r are described by the cluster. Returns an error naming the
+// pending resources when they are not observable within the timeout.
+func (p *settlePoller) Await(ctx context.Context, clusterArn string, r *createdResources) error {
+	r.mu.Lock()
+	secretArns := r.secretArns
+	acls := r.acls
+	r.mu.Unlock()
+	if len(secretArns) == 0 && len(acls) == 0 {
+		return nil
+	}
+	p.logger.Sugar().Infow("Start Operation", "Name", "AwaitSettle", "Secrets", len(secretArns), "ACLs", len(acls), "Timeout", p.timeout.String())
+	var waited time.Duration
+	for {
+		var err error
+		secretArns, err = p.pendingSecrets(ctx, clusterArn, secretArns)
+		if err != nil {
+			return err
+		}
+		acls, err = p.pendingACLs(ctx, acls)
+		if err != nil {
+			return err
+		}
+		if len(secretArns) == 0 && len(acls) == 0 {
+			p.logger.Sugar().Infow("Operation Finished", "Name", "AwaitSettle", "Waited", waited.String())
+			return nil
+		}
+		if waited >= p.timeout {
+			return errors.WithStack(fmt.Errorf("created resources not observable after %s: %d secret associations and %d ACLs pending", p.timeout, len(secretArns), len(acls)))
+		}
+		p.sleep(p.interval)
+		waited += p.interval
+	}
+}
+
+// Returns the secrets in secretArns that are not yet listed against the
+// cluster.
+func (p *settlePoller) pendingSecrets(ctx context.Context, clusterArn string, secretArns []string) ([]string, error) {
+	if len(secretArns) == 0 {
+		return nil, nil
+	}
+	associated := make(map[string]bool)
+	var nextToken *string
+	for {
+		out, err := p.mskClient.ListScramSecrets(ctx, &kafka.ListScramSecretsInput{
+			ClusterArn: &clusterArn,
+			NextToken:  nextToken,
+		})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, arn := range out.SecretArnList {
+			associated[arn] = true
+		}
+		if out.NextToken == nil {
+			break
+		}
+		nextToken = out.NextToken
+	}
+	pending := make([]string, 0)
+	for _, arn := range secretArns {
+		if !associated[arn] {
+			pending = append(pending, arn)
+		}
+	}
+	return pending, nil
+}
+
+// Returns the ACLs in acls that the cluster does not describe yet.
+func (p *settlePoller) pendingACLs(ctx context.Context, acls []*kadm.ACLBuilder) ([]*kadm.ACLBuilder, error) {
+	pending := make([]*kadm.ACLBuilder, 0)
+	for _, acl := range acls {
+		results, err := p.kafkaClient.DescribeACLs(ctx, acl)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		found := len(results) > 0
+		for _, d := range results {
+			if d.Err != nil {
+				return nil, errors.WithStack(d.Err)
+			}
+			if len(d.Described) == 0 {
+				found = false
+			}
+		}
+		if !found {
+			pending = append(pending, acl)
+		}
+	}
+	return pending, nil
+}
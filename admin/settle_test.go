@@ -0,0 +1,90 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/admin/mocks"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kafka"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"go.uber.org/zap"
+)
+
+func TestSettlePollerAwait(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.TODO()
+	clusterArn := "arn:aws:kafka:us-east-1:123456789012:cluster/c/uuid"
+	secretArn := "arn:aws:secretsmanager:us-east-1:123456789012:secret:AmazonMSK_alice"
+	acl := kadm.NewACLs().Topics("a").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpRead).Allow("User:alice").AllowHosts("*")
+	notListed := &kafka.ListScramSecretsOutput{SecretArnList: []string{"other"}}
+	listed := &kafka.ListScramSecretsOutput{SecretArnList: []string{"other"}, NextToken: aws.String("next")}
+	listedNext := &kafka.ListScramSecretsOutput{SecretArnList: []string{secretArn}}
+	notDescribed := kadm.DescribeACLsResults{{}}
+	described := kadm.DescribeACLsResults{{Described: kadm.DescribedACLs{{Principal: "User:alice"}}}}
+
+	t.Run("Observable after delay", func(t *testing.T) {
+		mskClient := mocks.NewMockMskClient(ctrl)
+		kafkaClient := mocks.NewMockKafkaClient(ctrl)
+		var slept []time.Duration
+		p := newSettlePoller(mskClient, kafkaClient, 10*time.Second, zap.NewNop())
+		p.sleep = func(d time.Duration) { slept = append(slept, d) }
+		r := newCreatedResources()
+		r.AddSecret(secretArn)
+		r.AddACLs(acl)
+
+		gomock.InOrder(
+			mskClient.EXPECT().ListScramSecrets(ctx, &kafka.ListScramSecretsInput{ClusterArn: &clusterArn}).Return(notListed, error(nil)),
+			kafkaClient.EXPECT().DescribeACLs(ctx, acl).Return(notDescribed, error(nil)),
+			mskClient.EXPECT().ListScramSecrets(ctx, &kafka.ListScramSecretsInput{ClusterArn: &clusterArn}).Return(listed, error(nil)),
+			mskClient.EXPECT().ListScramSecrets(ctx, &kafka.ListScramSecretsInput{ClusterArn: &clusterArn, NextToken: aws.String("next")}).Return(listedNext, error(nil)),
+			kafkaClient.EXPECT().DescribeACLs(ctx, acl).Return(notDescribed, error(nil)),
+			// Observed resources are not checked again.
+			kafkaClient.EXPECT().DescribeACLs(ctx, acl).Return(described, error(nil)),
+		)
+
+		err := p.Await(ctx, clusterArn, r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []time.Duration{settleInterval, settleInterval}, slept)
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		mskClient := mocks.NewMockMskClient(ctrl)
+		kafkaClient := mocks.NewMockKafkaClient(ctrl)
+		p := newSettlePoller(mskClient, kafkaClient, 3*time.Second, zap.NewNop())
+		p.sleep = func(time.Duration) {}
+		r := newCreatedResources()
+		r.AddSecret(secretArn)
+		r.AddACLs(acl)
+
+		// Checked initially and after 2s and 4s.
+		mskClient.EXPECT().ListScramSecrets(ctx, gomock.Any()).Return(notListed, error(nil)).Times(3)
+		kafkaClient.EXPECT().DescribeACLs(ctx, acl).Return(described, error(nil)).Times(1)
+
+		err := p.Await(ctx, clusterArn, r)
+
+		assert.EqualError(t, err, "created resources not observable after 3s: 1 secret associations and 0 ACLs pending")
+	})
+
+	t.Run("Nothing created", func(t *testing.T) {
+		p := newSettlePoller(mocks.NewMockMskClient(ctrl), mocks.NewMockKafkaClient(ctrl), time.Second, zap.NewNop())
+		assert.Nil(t, p.Await(ctx, clusterArn, newCreatedResources()))
+	})
+}
+
+func TestCreatedResourcesNil(t *testing.T) {
+	r := createdResourcesFrom(context.TODO())
+	r.AddSecret("arn")
+	r.AddACLs(kadm.NewACLs())
+	assert.Nil(t, r)
+}
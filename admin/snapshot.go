@@ -0,0 +1,180 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"go.uber.org/zap"
+)
+
+// topicSnapshot records the metadata of a topic and the offsets committed
+// by consumer groups so that consumers can be restored after the topic is
+// deleted with the SNAPSHOT deletion policy.
+type topicSnapshot struct {
+	Topic      string
+	ClusterArn string
+	StackID    string
+	Time       string
+	// Configs set on the topic rather than inherited from the cluster.
+	Config     map[string]string
+	Partitions []partitionSnapshot
+	Groups     []groupSnapshot
+}
+
+type partitionSnapshot struct {
+	Partition   int32
+	Replicas    []int32
+	StartOffset int64
+	EndOffset   int64
+}
+
+type groupSnapshot struct {
+	Group string
+	// Committed offsets by partition.
+	Offsets map[int32]int64
+}
+
+// topicSnapshotter exports snapshots of topics to S3.
+type topicSnapshotter struct {
+	kafkaClient KafkaClient
+	s3Client    S3Client
+	bucket      string
+	prefix      string
+	now         func() time.Time
+	logger      *zap.Logger
+}
+
+func newTopicSnapshotter(kafkaClient KafkaClient, s3Client S3Client, bucket, prefix string, logger *zap.Logger) *topicSnapshotter {
+	return &topicSnapshotter{
+		kafkaClient: kafkaClient,
+		s3Client:    s3Client,
+		bucket:      bucket,
+		prefix:      prefix,
+		now:         time.Now,
+		logger:      logger,
+	}
+}
+
+// Exports the snapshot of topic to S3 and returns the key of the object.
+// Any failure to describe the topic or its groups fails the export so that
+// the topic is not deleted without a complete snapshot.
+func (s *topicSnapshotter) Export(ctx context.Context, clusterArn, stackID string, topic kadm.TopicDetail) (string, error) {
+	if s.bucket == "" {
+		return "", errors.WithStack(fmt.Errorf("DeletionPolicy SNAPSHOT requires the %s setting", EnvSnapshotBucket))
+	}
+	now := s.now().UTC()
+	snapshot := &topicSnapshot{
+		Topic:      topic.Topic,
+		ClusterArn: clusterArn,
+		StackID:    stackID,
+		Time:       now.Format(time.RFC3339),
+		Config:     make(map[string]string),
+	}
+	s.logger.Sugar().Infow("Start Operation", "Name", "ExportSnapshot", "TopicName", topic.Topic)
+	err := s.addConfig(ctx, snapshot)
+	if err != nil {
+		return "", err
+	}
+	err = s.addPartitions(ctx, snapshot, topic.Partitions)
+	if err != nil {
+		return "", err
+	}
+	err = s.addGroups(ctx, snapshot)
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(snapshot)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	key := fmt.Sprintf("%s%s/%s.json", s.prefix, topic.Topic, now.Format("20060102T150405Z"))
+	err = s.s3Client.PutObject(ctx, s.bucket, key, body)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	s.logger.Sugar().Infow("Operation Finished", "Name", "ExportSnapshot", "Bucket", s.bucket, "Key", key, "Groups", len(snapshot.Groups))
+	opSummaryFrom(ctx).Performed("ExportSnapshot")
+	return key, nil
+}
+
+func (s *topicSnapshotter) addConfig(ctx context.Context, snapshot *topicSnapshot) error {
+	configs, err := s.kafkaClient.DescribeTopicConfigs(ctx, snapshot.Topic)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	rc, err := topicConfig(configs, snapshot.Topic)
+	if err != nil {
+		return err
+	}
+	if rc.Err != nil {
+		return errors.WithStack(rc.Err)
+	}
+	for _, c := range rc.Configs {
+		if c.Source == kmsg.ConfigSourceDynamicTopicConfig && c.Value != nil {
+			snapshot.Config[c.Key] = *c.Value
+		}
+	}
+	return nil
+}
+
+func (s *topicSnapshotter) addPartitions(ctx context.Context, snapshot *topicSnapshot, partitions kadm.PartitionDetails) error {
+	starts, err := s.kafkaClient.ListStartOffsets(ctx, snapshot.Topic)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	ends, err := s.kafkaClient.ListEndOffsets(ctx, snapshot.Topic)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, p := range partitions.Numbers() {
+		start, ok := starts.Lookup(snapshot.Topic, p)
+		if !ok || start.Err != nil {
+			return errors.WithStack(fmt.Errorf("start offset of partition %d not listed: %v", p, start.Err))
+		}
+		end, ok := ends.Lookup(snapshot.Topic, p)
+		if !ok || end.Err != nil {
+			return errors.WithStack(fmt.Errorf("end offset of partition %d not listed: %v", p, end.Err))
+		}
+		snapshot.Partitions = append(snapshot.Partitions, partitionSnapshot{
+			Partition:   p,
+			Replicas:    partitions[p].Replicas,
+			StartOffset: start.Offset,
+			EndOffset:   end.Offset,
+		})
+	}
+	return nil
+}
+
+// Adds the groups that committed offsets for the topic, sorted by name.
+func (s *topicSnapshotter) addGroups(ctx context.Context, snapshot *topicSnapshot) error {
+	groups, err := s.kafkaClient.ListGroups(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, group := range groups.Groups() {
+		committed, err := s.kafkaClient.FetchOffsets(ctx, group)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if len(committed[snapshot.Topic]) == 0 {
+			continue
+		}
+		g := groupSnapshot{Group: group, Offsets: make(map[int32]int64)}
+		for p, o := range committed[snapshot.Topic] {
+			if o.Err != nil {
+				return errors.WithStack(o.Err)
+			}
+			g.Offsets[p] = o.At
+		}
+		snapshot.Groups = append(snapshot.Groups, g)
+	}
+	return nil
+}
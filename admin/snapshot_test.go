@@ -0,0 +1,84 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/admin/mocks"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"go.uber.org/zap"
+)
+
+func TestTopicSnapshotterExport(t *testing.T) {
+	topic := "orders"
+	detail := kadm.TopicDetail{Topic: topic, Partitions: kadm.PartitionDetails{
+		0: {Topic: topic, Partition: 0, Replicas: []int32{1, 2}},
+		1: {Topic: topic, Partition: 1, Replicas: []int32{2, 3}},
+	}}
+	configs := kadm.ResourceConfigs{{Name: topic, Configs: []kadm.Config{
+		{Key: "retention.ms", Value: aws.String("3600000"), Source: kmsg.ConfigSourceDynamicTopicConfig},
+		{Key: "cleanup.policy", Value: aws.String("delete"), Source: kmsg.ConfigSourceDefaultConfig},
+	}}}
+	start := kadm.ListedOffsets{topic: {0: {Topic: topic, Partition: 0, Offset: 5}, 1: {Topic: topic, Partition: 1, Offset: 7}}}
+	end := kadm.ListedOffsets{topic: {0: {Topic: topic, Partition: 0, Offset: 42}, 1: {Topic: topic, Partition: 1, Offset: 64}}}
+	groups := kadm.ListedGroups{"billing": {Group: "billing"}, "audit": {Group: "audit"}}
+	expected := `{"Topic":"orders","ClusterArn":"cluster","StackID":"stack","Time":"2024-05-01T10:20:30Z",` +
+		`"Config":{"retention.ms":"3600000"},` +
+		`"Partitions":[{"Partition":0,"Replicas":[1,2],"StartOffset":5,"EndOffset":42},{"Partition":1,"Replicas":[2,3],"StartOffset":7,"EndOffset":64}],` +
+		`"Groups":[{"Group":"billing","Offsets":{"0":40}}]}`
+
+	type testCase struct {
+		bucket string
+		putErr error
+		err    string
+	}
+
+	cases := map[string]testCase{
+		"Exported":       {bucket: "bucket"},
+		"Missing bucket": {err: "DeletionPolicy SNAPSHOT requires the TR_SNAPSHOT_BUCKET setting"},
+		"Put fails":      {bucket: "bucket", putErr: errors.New("denied"), err: "denied"},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			// Arrange
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			ctx := context.TODO()
+			kafkaClient := mocks.NewMockKafkaClient(ctrl)
+			s3Client := mocks.NewMockS3Client(ctrl)
+			snapshots := newTopicSnapshotter(kafkaClient, s3Client, c.bucket, "tr-snapshots/", zap.NewNop())
+			snapshots.now = func() time.Time { return time.Date(2024, 5, 1, 10, 20, 30, 0, time.UTC) }
+			if c.bucket != "" {
+				kafkaClient.EXPECT().DescribeTopicConfigs(ctx, topic).Return(configs, error(nil))
+				kafkaClient.EXPECT().ListStartOffsets(ctx, topic).Return(start, error(nil))
+				kafkaClient.EXPECT().ListEndOffsets(ctx, topic).Return(end, error(nil))
+				kafkaClient.EXPECT().ListGroups(ctx).Return(groups, error(nil))
+				kafkaClient.EXPECT().FetchOffsets(ctx, "audit").Return(kadm.OffsetResponses{"payments": {0: {Offset: kadm.Offset{Topic: "payments", At: 3}}}}, error(nil))
+				kafkaClient.EXPECT().FetchOffsets(ctx, "billing").Return(kadm.OffsetResponses{topic: {0: {Offset: kadm.Offset{Topic: topic, At: 40}}}}, error(nil))
+				s3Client.EXPECT().PutObject(ctx, "bucket", "tr-snapshots/orders/20240501T102030Z.json", []byte(expected)).Return(c.putErr)
+			}
+
+			// Act
+			key, err := snapshots.Export(ctx, "cluster", "stack", detail)
+
+			// Assert
+			if c.err == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, "tr-snapshots/orders/20240501T102030Z.json", key)
+			} else {
+				assert.EqualError(t, err, c.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const StackReportAction string = "stackreport"
+
+// StackReportRequest is sent by operators invoking TR function directly to
+// find the topics and users created by a stack, e.g.
+// {"action":"stackreport","clusterArn":"arn:aws:kafka:...","stackId":"arn:aws:cloudformation:..."}.
+// Resources declaring a NameSuffix are found by setting nameSuffix instead
+// of stackId.
+type StackReportRequest struct {
+	Action     string `json:"action"`
+	ClusterArn string `json:"clusterArn"`
+	StackID    string `json:"stackId"`
+	NameSuffix string `json:"nameSuffix"`
+}
+
+// Returns the stack report request in payload. CloudFormation events do
+// not have an action field and are never mistaken for one.
+func ParseStackReportRequest(payload []byte) (*StackReportRequest, bool) {
+	var req StackReportRequest
+	if err := json.Unmarshal(payload, &req); err != nil || req.Action != StackReportAction {
+		return nil, false
+	}
+	return &req, true
+}
+
+type StackReportSecret struct {
+	Name string
+	Arn  string
+}
+
+// StackReport lists the topics in the cluster and the secrets of users
+// named with the suffix of a stack.
+type StackReport struct {
+	ClusterArn string
+	StackID    string `json:",omitempty"`
+	NameSuffix string
+	Topics     []string
+	Secrets    []StackReportSecret
+}
+
+// DescribeStack reports the topics and users created by a stack without
+// changing anything. Topics and secrets are matched by the name suffix of
+// the stack. Secrets tagged by another stack using the same suffix are
+// excluded.
+func (h *Handler) DescribeStack(ctx context.Context, req *StackReportRequest) (*StackReport, error) {
+	if req.ClusterArn == "" {
+		return nil, errors.New("clusterArn is required")
+	}
+	if req.StackID == "" && req.NameSuffix == "" {
+		return nil, errors.New("stackId or nameSuffix is required")
+	}
+	logger, err := newLogger(h.settings)
+	if err != nil {
+		panic(err)
+	}
+	logger = logger.With(zap.String("Action", req.Action), zap.String("ClusterArn", req.ClusterArn), zap.String("StackID", req.StackID))
+	ctx = context.WithValue(ctx, contextKeyLogger, logger)
+	defer logger.Sync()
+	if req.StackID != "" {
+		ctx = withStackResource(ctx, newStackResource(req.StackID, ""))
+	}
+
+	suffix := req.NameSuffix
+	if suffix == "" {
+		suffix = shortStackID(req.StackID)
+	}
+	r := &StackReport{ClusterArn: req.ClusterArn, StackID: req.StackID, NameSuffix: suffix, Topics: []string{}, Secrets: []StackReportSecret{}}
+	kafkaClient, _, err := h.kafkaClientProvider.NewKafkaClient(ctx, req.ClusterArn)
+	if err != nil {
+		return nil, err
+	}
+	logger.Sugar().Infow("Start Operation", "Name", "ListTopics")
+	topics, err := kafkaClient.ListTopics(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for name := range topics {
+		if strings.HasSuffix(name, "-"+suffix) {
+			r.Topics = append(r.Topics, name)
+		}
+	}
+	sort.Strings(r.Topics)
+	secrets, err := listStackSecrets(ctx, h.secretsManagerClient, secretNameTemplate(h.settings.SecretNameTemplate), suffix, logger)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range secrets {
+		r.Secrets = append(r.Secrets, StackReportSecret{Name: aws.ToString(e.Name), Arn: aws.ToString(e.ARN)})
+	}
+	sort.Slice(r.Secrets, func(i, j int) bool { return r.Secrets[i].Name < r.Secrets[j].Name })
+	logger.Info("Stack Report Finished", zap.Int("Topics", len(r.Topics)), zap.Int("Secrets", len(r.Secrets)))
+	return r, nil
+}
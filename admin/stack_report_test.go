@@ -0,0 +1,75 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/admin/mocks"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smt "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+func TestParseStackReportRequest(t *testing.T) {
+	req, ok := ParseStackReportRequest([]byte(`{"action":"stackreport","clusterArn":"arn","stackId":"stack"}`))
+	assert.True(t, ok)
+	assert.Equal(t, &StackReportRequest{Action: StackReportAction, ClusterArn: "arn", StackID: "stack"}, req)
+
+	_, ok = ParseStackReportRequest([]byte(`{"action":"selftest","clusterArn":"arn"}`))
+	assert.False(t, ok)
+	_, ok = ParseStackReportRequest([]byte(`{"RequestType":"Create","StackId":"test"}`))
+	assert.False(t, ok)
+}
+
+func TestDescribeStack(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	stackID := "arn:aws:cloudformation:us-east-1:123456789012:stack/s/uuid"
+	suffix := shortStackID(stackID)
+	kafkaClient := mocks.NewMockKafkaClient(ctrl)
+	secretsManagerClient := mocks.NewMockSecretsManagerClient(ctrl)
+	handler := NewHandler(nil, nil, secretsManagerClient, nil, nil, &testKafkaClientProvider{kafkaClient: kafkaClient}, DefaultSettings())
+	alice := defaultSecretNameTemplate.Name("alice", suffix)
+	bob := defaultSecretNameTemplate.Name("bob", suffix)
+	prefix, _ := defaultSecretNameTemplate.Affixes(suffix)
+
+	kafkaClient.EXPECT().ListTopics(gomock.Any()).Return(kadm.TopicDetails{
+		"orders-" + suffix:   {Topic: "orders-" + suffix},
+		"payments-" + suffix: {Topic: "payments-" + suffix},
+		"orders-OTHER":       {Topic: "orders-OTHER"},
+	}, error(nil))
+	secretsManagerClient.EXPECT().ListSecrets(gomock.Any(), &secretsmanager.ListSecretsInput{
+		Filters: []smt.Filter{{Key: smt.FilterNameStringTypeName, Values: []string{prefix}}},
+	}).Return(&secretsmanager.ListSecretsOutput{SecretList: []smt.SecretListEntry{
+		{Name: aws.String(bob), ARN: aws.String("arn-bob")},
+		{Name: aws.String(alice), ARN: aws.String("arn-alice")},
+		// Same suffix, generated by another stack.
+		{Name: aws.String(defaultSecretNameTemplate.Name("carol", suffix)), ARN: aws.String("arn-carol"), Tags: newStackResource("other", "Topic").SecretTags("orders")},
+		{Name: aws.String(defaultSecretNameTemplate.Name("dave", suffix+"X")), ARN: aws.String("arn-dave")},
+	}}, error(nil))
+
+	// Act
+	r, err := handler.DescribeStack(context.TODO(), &StackReportRequest{Action: StackReportAction, ClusterArn: "cluster", StackID: stackID})
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, &StackReport{
+		ClusterArn: "cluster",
+		StackID:    stackID,
+		NameSuffix: suffix,
+		Topics:     []string{"orders-" + suffix, "payments-" + suffix},
+		Secrets:    []StackReportSecret{{Name: alice, Arn: "arn-alice"}, {Name: bob, Arn: "arn-bob"}},
+	}, r)
+
+	_, err = handler.DescribeStack(context.TODO(), &StackReportRequest{Action: StackReportAction, ClusterArn: "cluster"})
+	assert.EqualError(t, err, "stackId or nameSuffix is required")
+}
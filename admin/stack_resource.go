@@ -0,0 +1,109 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	smt "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+)
+
+const (
+	TagSecretStackID           = "tr:stack-id"
+	TagSecretLogicalResourceID = "tr:logical-resource-id"
+	TagSecretTopic             = "tr:topic"
+)
+
+var contextKeyStackResource contextKey = contextKey("StackResource")
+
+// stackResource identifies the CloudFormation resource a request is made
+// for. Secrets generated for users are tagged with it so that their cost
+// can be attributed and the secrets of a stack can be told apart from the
+// secrets of other stacks using the same NameSuffix.
+type stackResource struct {
+	StackID           string
+	LogicalResourceID string
+}
+
+func newStackResource(stackID, logicalResourceID string) *stackResource {
+	return &stackResource{StackID: stackID, LogicalResourceID: logicalResourceID}
+}
+
+// Returns a copy of ctx carrying r.
+func withStackResource(ctx context.Context, r *stackResource) context.Context {
+	return context.WithValue(ctx, contextKeyStackResource, r)
+}
+
+// Returns the resource in ctx. The result is nil when ctx does not carry
+// one, in which case secrets are not tagged.
+func stackResourceFrom(ctx context.Context) *stackResource {
+	r, _ := ctx.Value(contextKeyStackResource).(*stackResource)
+	return r
+}
+
+// Returns the tags of a secret generated for a user of topic.
+func (r *stackResource) SecretTags(topic string) []smt.Tag {
+	if r == nil {
+		return nil
+	}
+	return []smt.Tag{
+		{Key: aws.String(TagSecretStackID), Value: aws.String(r.StackID)},
+		{Key: aws.String(TagSecretLogicalResourceID), Value: aws.String(r.LogicalResourceID)},
+		{Key: aws.String(TagSecretTopic), Value: aws.String(topic)},
+	}
+}
+
+// Reports whether a secret with tags may be deleted on behalf of r.
+// Secrets tagged by another stack are not. Secrets created before they
+// were tagged are matched by name only.
+func (r *stackResource) OwnsSecret(tags []smt.Tag) bool {
+	if r == nil {
+		return true
+	}
+	for _, t := range tags {
+		if aws.ToString(t.Key) == TagSecretStackID {
+			return aws.ToString(t.Value) == r.StackID
+		}
+	}
+	return true
+}
+
+// Reports whether a secret with tags was generated for a user of r. Unlike
+// OwnsSecret, secrets created before they were tagged and secrets of other
+// resources of the stack are not matched.
+func (r *stackResource) CreatedSecret(tags []smt.Tag) bool {
+	if r == nil {
+		return false
+	}
+	var stackID, logicalResourceID string
+	for _, t := range tags {
+		switch aws.ToString(t.Key) {
+		case TagSecretStackID:
+			stackID = aws.ToString(t.Value)
+		case TagSecretLogicalResourceID:
+			logicalResourceID = aws.ToString(t.Value)
+		}
+	}
+	return stackID == r.StackID && logicalResourceID == r.LogicalResourceID
+}
+
+// Reports whether a secret with tags was generated for a user of the same
+// name declared by another resource of the stack of r. Such users share
+// the secret of the resource that created it.
+func (r *stackResource) SharesSecret(tags []smt.Tag) bool {
+	if r == nil {
+		return false
+	}
+	var stackID, logicalResourceID string
+	for _, t := range tags {
+		switch aws.ToString(t.Key) {
+		case TagSecretStackID:
+			stackID = aws.ToString(t.Value)
+		case TagSecretLogicalResourceID:
+			logicalResourceID = aws.ToString(t.Value)
+		}
+	}
+	return stackID == r.StackID && logicalResourceID != "" && logicalResourceID != r.LogicalResourceID
+}
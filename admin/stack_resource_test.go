@@ -0,0 +1,129 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	smt "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStackResourceSecretTags(t *testing.T) {
+	// Arrange
+	r := newStackResource("stack", "Topic")
+
+	// Act
+	tags := r.SecretTags("orders")
+
+	// Assert
+	assert.Equal(t, []smt.Tag{
+		{Key: aws.String(TagSecretStackID), Value: aws.String("stack")},
+		{Key: aws.String(TagSecretLogicalResourceID), Value: aws.String("Topic")},
+		{Key: aws.String(TagSecretTopic), Value: aws.String("orders")},
+	}, tags)
+	assert.Nil(t, (*stackResource)(nil).SecretTags("orders"))
+}
+
+func TestStackResourceOwnsSecret(t *testing.T) {
+	type testCase struct {
+		resource *stackResource
+		tags     []smt.Tag
+		expected bool
+	}
+
+	cases := map[string]testCase{
+		"Tagged by the stack": {
+			resource: newStackResource("stack", "Topic"),
+			tags:     newStackResource("stack", "Other").SecretTags("orders"),
+			expected: true,
+		},
+		"Tagged by another stack": {
+			resource: newStackResource("stack", "Topic"),
+			tags:     newStackResource("other", "Topic").SecretTags("orders"),
+		},
+		"Untagged": {
+			resource: newStackResource("stack", "Topic"),
+			expected: true,
+		},
+		"No resource": {
+			tags:     newStackResource("other", "Topic").SecretTags("orders"),
+			expected: true,
+		},
+	}
+
+	for k, c := range cases {
+		assert.Equal(t, c.expected, c.resource.OwnsSecret(c.tags), k)
+	}
+}
+
+func TestStackResourceCreatedSecret(t *testing.T) {
+	type testCase struct {
+		resource *stackResource
+		tags     []smt.Tag
+		expected bool
+	}
+
+	cases := map[string]testCase{
+		"Tagged by the resource": {
+			resource: newStackResource("stack", "Topic"),
+			tags:     newStackResource("stack", "Topic").SecretTags("orders"),
+			expected: true,
+		},
+		"Tagged by another resource of the stack": {
+			resource: newStackResource("stack", "Topic"),
+			tags:     newStackResource("stack", "Other").SecretTags("orders"),
+		},
+		"Tagged by another stack": {
+			resource: newStackResource("stack", "Topic"),
+			tags:     newStackResource("other", "Topic").SecretTags("orders"),
+		},
+		"Untagged": {
+			resource: newStackResource("stack", "Topic"),
+		},
+		"No resource": {
+			tags: newStackResource("stack", "Topic").SecretTags("orders"),
+		},
+	}
+
+	for k, c := range cases {
+		assert.Equal(t, c.expected, c.resource.CreatedSecret(c.tags), k)
+	}
+}
+
+func TestStackResourceSharesSecret(t *testing.T) {
+	type testCase struct {
+		resource *stackResource
+		tags     []smt.Tag
+		expected bool
+	}
+
+	cases := map[string]testCase{
+		"Tagged by another resource of the stack": {
+			resource: newStackResource("stack", "Topic"),
+			tags:     newStackResource("stack", "Other").SecretTags("orders"),
+			expected: true,
+		},
+		"Tagged by the resource": {
+			resource: newStackResource("stack", "Topic"),
+			tags:     newStackResource("stack", "Topic").SecretTags("orders"),
+		},
+		"Tagged by another stack": {
+			resource: newStackResource("stack", "Topic"),
+			tags:     newStackResource("other", "Other").SecretTags("orders"),
+		},
+		"Tagged without resource": {
+			resource: newStackResource("stack", "Topic"),
+			tags:     []smt.Tag{{Key: aws.String(TagSecretStackID), Value: aws.String("stack")}},
+		},
+		"No resource": {
+			tags: newStackResource("stack", "Other").SecretTags("orders"),
+		},
+	}
+
+	for k, c := range cases {
+		assert.Equal(t, c.expected, c.resource.SharesSecret(c.tags), k)
+	}
+}
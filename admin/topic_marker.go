@@ -0,0 +1,215 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smt "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	TagMarkerStackID   = "tr:stack-id"
+	TagMarkerRequestID = "tr:request-id"
+	// Topic of the resource replaced by or replacing the marked topic.
+	TagMarkerLinkedTopic = "tr:linked-topic"
+	TagReservedPrefix    = "tr:"
+	TagLabelPrefix       = "tr:label:"
+)
+
+type TopicMarkerService interface {
+	Get(ctx context.Context, clusterArn, topic string) (*types.TopicMarker, error)
+	Put(ctx context.Context, clusterArn, topic string, marker *types.TopicMarker) error
+	Delete(ctx context.Context, clusterArn, topic string) error
+}
+
+// Kafka brokers reject unknown topic configuration properties. Therefore
+// we cannot attach any metadata (tags, labels) to the topic itself.
+// Instead, a marker secret is stored in SecretsManager for each topic
+// managed by TR.
+type topicMarkerStore struct {
+	secretsManagerClient SecretsManagerClient
+	logger               *zap.Logger
+}
+
+func newTopicMarkerStore(secretsManagerClient SecretsManagerClient, logger *zap.Logger) *topicMarkerStore {
+	return &topicMarkerStore{
+		secretsManagerClient: secretsManagerClient,
+		logger:               logger,
+	}
+}
+
+// Returns nil if the topic does not have a marker.
+func (s *topicMarkerStore) Get(ctx context.Context, clusterArn, topic string) (*types.TopicMarker, error) {
+	name := topicMarkerName(clusterArn, topic)
+	s.logger.Sugar().Infow("Start Operation", "Name", "DescribeSecret", "Marker", name)
+	ds, err := s.secretsManagerClient.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{
+		SecretId: &name,
+	})
+	if err != nil {
+		var e *smt.ResourceNotFoundException
+		if errors.As(err, &e) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	marker := &types.TopicMarker{}
+	for _, t := range ds.Tags {
+		key := aws.ToString(t.Key)
+		if key == TagMarkerStackID {
+			marker.StackID = aws.ToString(t.Value)
+		} else if key == TagMarkerRequestID {
+			marker.RequestID = aws.ToString(t.Value)
+		} else if key == TagMarkerLinkedTopic {
+			marker.LinkedTopic = aws.ToString(t.Value)
+		} else if strings.HasPrefix(key, TagLabelPrefix) {
+			if marker.Labels == nil {
+				marker.Labels = make(map[string]string)
+			}
+			marker.Labels[strings.TrimPrefix(key, TagLabelPrefix)] = aws.ToString(t.Value)
+		} else if !strings.HasPrefix(key, TagReservedPrefix) {
+			if marker.Tags == nil {
+				marker.Tags = make(map[string]string)
+			}
+			marker.Tags[key] = aws.ToString(t.Value)
+		}
+	}
+	return marker, nil
+}
+
+// Creates the marker or, if it already exists, replaces its tags and labels
+// with the ones in the specified marker.
+func (s *topicMarkerStore) Put(ctx context.Context, clusterArn, topic string, marker *types.TopicMarker) error {
+	for k := range marker.Tags {
+		if strings.HasPrefix(k, TagReservedPrefix) {
+			return errors.WithStack(fmt.Errorf("tag key %s uses reserved prefix %s", k, TagReservedPrefix))
+		}
+	}
+	name := topicMarkerName(clusterArn, topic)
+	buf, err := json.Marshal(marker)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	tags := markerTags(marker)
+	s.logger.Sugar().Infow("Start Operation", "Name", "CreateSecret", "Marker", name)
+	_, err = s.secretsManagerClient.CreateSecret(ctx, &secretsmanager.CreateSecretInput{
+		Name:         &name,
+		SecretString: aws.String(string(buf)),
+		Tags:         tags,
+	})
+	if err != nil {
+		var e *smt.ResourceExistsException
+		if !errors.As(err, &e) {
+			return errors.WithStack(err)
+		}
+		s.logger.Sugar().Infow("Retry Handled", "Operation", "CreateSecret", "Marker", name)
+		err = s.replaceTags(ctx, name, tags)
+		if err != nil {
+			return err
+		}
+	}
+	opSummaryFrom(ctx).Performed("PutTopicMarker")
+	return nil
+}
+
+func (s *topicMarkerStore) replaceTags(ctx context.Context, name string, tags []smt.Tag) error {
+	ds, err := s.secretsManagerClient.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{
+		SecretId: &name,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// Updates do not carry the linked topic, therefore it is kept until
+	// the marker is deleted.
+	desired := map[string]bool{TagMarkerLinkedTopic: true}
+	for _, t := range tags {
+		desired[aws.ToString(t.Key)] = true
+	}
+	removed := make([]string, 0)
+	for _, t := range ds.Tags {
+		if !desired[aws.ToString(t.Key)] {
+			removed = append(removed, aws.ToString(t.Key))
+		}
+	}
+	if len(removed) > 0 {
+		s.logger.Sugar().Infow("Start Operation", "Name", "UntagResource", "Marker", name)
+		_, err = s.secretsManagerClient.UntagResource(ctx, &secretsmanager.UntagResourceInput{
+			SecretId: &name,
+			TagKeys:  removed,
+		})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	s.logger.Sugar().Infow("Start Operation", "Name", "TagResource", "Marker", name)
+	_, err = s.secretsManagerClient.TagResource(ctx, &secretsmanager.TagResourceInput{
+		SecretId: &name,
+		Tags:     tags,
+	})
+	return errors.WithStack(err)
+}
+
+func (s *topicMarkerStore) Delete(ctx context.Context, clusterArn, topic string) error {
+	name := topicMarkerName(clusterArn, topic)
+	s.logger.Sugar().Infow("Start Operation", "Name", "DeleteSecret", "Marker", name)
+	_, err := s.secretsManagerClient.DeleteSecret(ctx, &secretsmanager.DeleteSecretInput{
+		SecretId:                   &name,
+		ForceDeleteWithoutRecovery: aws.Bool(true),
+	})
+	if err != nil {
+		var e *smt.ResourceNotFoundException
+		if !errors.As(err, &e) {
+			return errors.WithStack(err)
+		}
+		s.logger.Sugar().Infow("Retry Handled", "Operation", "DeleteSecret", "Marker", name)
+		opSummaryFrom(ctx).Skipped("DeleteTopicMarker")
+		return nil
+	}
+	opSummaryFrom(ctx).Performed("DeleteTopicMarker")
+	return nil
+}
+
+// Returns the tags of the marker secret. Tags are followed by labels,
+// each sorted by key.
+func markerTags(marker *types.TopicMarker) []smt.Tag {
+	tags := []smt.Tag{{Key: aws.String(TagMarkerStackID), Value: aws.String(marker.StackID)}}
+	if marker.RequestID != "" {
+		tags = append(tags, smt.Tag{Key: aws.String(TagMarkerRequestID), Value: aws.String(marker.RequestID)})
+	}
+	if marker.LinkedTopic != "" {
+		tags = append(tags, smt.Tag{Key: aws.String(TagMarkerLinkedTopic), Value: aws.String(marker.LinkedTopic)})
+	}
+	tags = append(tags, sortedTags("", marker.Tags)...)
+	tags = append(tags, sortedTags(TagLabelPrefix, marker.Labels)...)
+	return tags
+}
+
+func sortedTags(prefix string, m map[string]string) []smt.Tag {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	tags := make([]smt.Tag, 0, len(keys))
+	for _, k := range keys {
+		tags = append(tags, smt.Tag{Key: aws.String(prefix + k), Value: aws.String(m[k])})
+	}
+	return tags
+}
+
+// Topic names are only unique within a cluster. Marker name therefore
+// includes a short hash of the cluster ARN.
+func topicMarkerName(clusterArn, topic string) string {
+	return fmt.Sprintf("tr/%s/%s", shortClusterID(clusterArn), topic)
+}
@@ -0,0 +1,100 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"testing"
+
+	tt "github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/admin/mocks"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smt "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestTopicMarkerTagsRoundTrip(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	// Arrange
+	ctx := context.TODO()
+	sm := mocks.NewMockSecretsManagerClient(ctrl)
+	store := newTopicMarkerStore(sm, logger)
+	name := topicMarkerName("cluster", "topic")
+	marker := &tt.TopicMarker{StackID: "stack", Tags: map[string]string{"team": "payments", "env": "prod"}, Labels: map[string]string{"owner": "alice", "team": "orders"}, RequestID: "request", LinkedTopic: "old-topic"}
+	var stored []smt.Tag
+	sm.EXPECT().CreateSecret(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, in *secretsmanager.CreateSecretInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error) {
+		stored = in.Tags
+		return &secretsmanager.CreateSecretOutput{}, nil
+	})
+	sm.EXPECT().DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: &name}).DoAndReturn(func(context.Context, *secretsmanager.DescribeSecretInput, ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error) {
+		return &secretsmanager.DescribeSecretOutput{Tags: stored}, nil
+	})
+
+	// Act
+	err = store.Put(ctx, "cluster", "topic", marker)
+	assert.Nil(t, err)
+	actual, err := store.Get(ctx, "cluster", "topic")
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, marker, actual)
+}
+
+func TestTopicMarkerPutReplacesTags(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	// Arrange
+	ctx := context.TODO()
+	sm := mocks.NewMockSecretsManagerClient(ctrl)
+	store := newTopicMarkerStore(sm, logger)
+	name := topicMarkerName("cluster", "topic")
+	marker := &tt.TopicMarker{StackID: "stack", Tags: map[string]string{"team": "payments"}}
+	sm.EXPECT().CreateSecret(ctx, gomock.Any()).Return(nil, &smt.ResourceExistsException{})
+	sm.EXPECT().DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: &name}).Return(&secretsmanager.DescribeSecretOutput{
+		Tags: []smt.Tag{
+			{Key: aws.String(TagMarkerStackID), Value: aws.String("stack")},
+			{Key: aws.String(TagMarkerLinkedTopic), Value: aws.String("old-topic")},
+			{Key: aws.String("team"), Value: aws.String("orders")},
+			{Key: aws.String("env"), Value: aws.String("prod")},
+		},
+	}, nil)
+	sm.EXPECT().UntagResource(ctx, &secretsmanager.UntagResourceInput{SecretId: &name, TagKeys: []string{"env"}}).Return(&secretsmanager.UntagResourceOutput{}, nil)
+	sm.EXPECT().TagResource(ctx, &secretsmanager.TagResourceInput{SecretId: &name, Tags: markerTags(marker)}).Return(&secretsmanager.TagResourceOutput{}, nil)
+
+	// Act
+	err = store.Put(ctx, "cluster", "topic", marker)
+
+	// Assert
+	assert.Nil(t, err)
+}
+
+func TestTopicMarkerPutRejectsReservedTags(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+	store := newTopicMarkerStore(mocks.NewMockSecretsManagerClient(ctrl), logger)
+
+	err = store.Put(context.TODO(), "cluster", "topic", &tt.TopicMarker{StackID: "stack", Tags: map[string]string{"tr:stack-id": "other"}})
+
+	assert.EqualError(t, err, "tag key tr:stack-id uses reserved prefix tr:")
+}
@@ -7,12 +7,19 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode"
 
 	tt "github.com/aws-samples/amazon-msk-topic-resource/types"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/kafka"
+	kt "github.com/aws/aws-sdk-go-v2/service/kafka/types"
 	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/kms/types"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
@@ -42,8 +49,17 @@ const SecretTemplate string = `{"username":"%s", "password":"%s"}`
 type UserManagerService interface {
 	CreateUser(ctx context.Context, shortStackID, topic, kmsKeyID, clusterArn string, u *tt.User) error
 	DeleteUser(ctx context.Context, u *tt.User, kmsKeyID, topic, shortStackID, clusterArn string) error
-	CreateACLs(ctx context.Context, topic, username, shortStackID string, permissions []tt.Permission) error
-	DeleteACLs(ctx context.Context, topic, username, shortStackID string, permissions []tt.Permission) error
+	CreateACLs(ctx context.Context, topic string, u *tt.User, shortStackID string, permissions []tt.Permission) error
+	DeleteACLs(ctx context.Context, topic string, u *tt.User, shortStackID string, permissions []tt.Permission) error
+	ReconcileACLs(ctx context.Context, topic string, u *tt.User, shortStackID string) error
+	RevokeTopicACLs(ctx context.Context, topic string, u *tt.User, shortStackID string) error
+	ApplyACLs(ctx context.Context, acls []*kadm.ACLBuilder) error
+	UpdateArn(ctx context.Context, u *tt.User, oldArns []string, kmsKeyID, shortStackID string) error
+	ReconcileGrants(ctx context.Context, u *tt.User, kmsKeyID, shortStackID string) error
+	ProvisionedUsers(ctx context.Context, users []tt.User, shortStackID string) ([]tt.User, error)
+	AssociateSecrets(ctx context.Context, clusterArn string, secretArns []string) error
+	SecretArns(ctx context.Context, users []tt.User, shortStackID string) (map[string]string, error)
+	CleanupOrphanedSecrets(ctx context.Context, users []tt.User, shortStackID, clusterArn string) error
 }
 
 type userManager struct {
@@ -52,154 +68,334 @@ type userManager struct {
 	mskClient            MskClient
 	kafkaClient          KafkaClient
 	logger               *zap.Logger
-	fixedDelay           func()
+	secretCreateDelay    func()
+	metrics              *metrics
+	disassociateRetry    *retryPolicy
+	associateRetry       *retryPolicy
+	aclRetry             *retryPolicy
+	describeRetry        *retryPolicy
+	passwordPolicy       passwordPolicy
+	principalChecker     *principalChecker
+	secretNames          secretNameTemplate
 }
 
-func newUserManager(secretsManagerClient SecretsManagerClient, kmsClient KmsClient, mskClient MskClient, kafkaClient KafkaClient, logger *zap.Logger, fixedDelay func()) *userManager {
+func newUserManager(secretsManagerClient SecretsManagerClient, kmsClient KmsClient, mskClient MskClient, kafkaClient KafkaClient, iamClient IamClient, metrics *metrics, settings *Settings, logger *zap.Logger) *userManager {
 	return &userManager{
 		secretsManagerClient: secretsManagerClient,
 		kmsClient:            kmsClient,
 		mskClient:            mskClient,
 		kafkaClient:          kafkaClient,
 		logger:               logger,
-		fixedDelay:           fixedDelay,
+		secretCreateDelay: func() {
+			time.Sleep(settings.SecretCreateDelay)
+		},
+		metrics:           metrics,
+		disassociateRetry: settings.Retry.policy(settings.DisassociateMaxAttempts, 0),
+		associateRetry:    settings.Retry.policy(settings.AssociateMaxAttempts, 0),
+		aclRetry:          settings.Retry.policy(settings.ACLMaxAttempts, settings.ACLRetryTimeout),
+		describeRetry:     settings.Retry.policy(settings.Retry.MaxAttempts, 0),
+		passwordPolicy:    newPasswordPolicy(settings),
+		principalChecker:  newPrincipalChecker(iamClient, settings.PrincipalCheck, logger),
+		secretNames:       secretNameTemplate(settings.SecretNameTemplate),
 	}
 }
 
 func (um *userManager) CreateUser(ctx context.Context, shortStackID, topic, kmsKeyID, clusterArn string, u *tt.User) error {
-	username := canonicalUsername(u.Username, shortStackID)
-	password, err := um.generatePassword()
+	// Checked before any credentials are provisioned so that nothing is
+	// left to clean up.
+	if err := um.checkTopicsExist(ctx, u.AdditionalTopics); err != nil {
+		return err
+	}
+	if u.UsesTLS() {
+		// Certificate principals authenticate without a secret.
+		return um.grantAccess(ctx, topic, u.Principal, u)
+	}
+	if u.UsesIAM() {
+		// IAM principals authenticate with their role or user.
+		return um.grantAccess(ctx, topic, u.IamPrincipal, u)
+	}
+	for _, principalArn := range u.Principals() {
+		if err := um.principalChecker.Check(ctx, principalArn, clusterArn); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	var username, secretArn string
+	var err error
+	if u.SecretArn != "" {
+		username = u.Username
+		secretArn = u.SecretArn
+		err = um.validateExternalSecret(ctx, u)
+	} else {
+		username = um.secretNames.Name(u.Username, shortStackID)
+		secretArn, err = um.createSecret(ctx, username, kmsKeyID, topic, u)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if b := associationBatchFrom(ctx); b != nil {
+		// Associated together with the secrets of other new users by
+		// AssociateSecrets.
+		b.Add(u.Username, secretArn)
+	} else {
+		err = um.AssociateSecrets(ctx, clusterArn, []string{secretArn})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	createdResourcesFrom(ctx).AddSecret(secretArn)
+	return um.grantAccess(ctx, topic, username, u)
+}
+
+// Creates ACLs for the principal and initialises offsets of its group.
+func (um *userManager) grantAccess(ctx context.Context, topic, principal string, u *tt.User) error {
+	name, pattern := topicACLResource(topic, u)
+	err := um.createACLs(ctx, name, pattern, u.AdditionalTopics, u.Hosts(), principal, u.Permissions, userGroupACLs(u), u.GroupPrefix, u.DescribesTopic())
+	if err != nil {
+		return &aclError{errors.WithStack(err)}
+	}
+	err = um.initGroupOffsets(ctx, topic, u)
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	return nil
+}
+
+// Creates a secret with generated credentials for the user and returns its
+// ARN. The secret is tagged with the resource of the request and topic.
+func (um *userManager) createSecret(ctx context.Context, username, kmsKeyID, topic string, u *tt.User) (string, error) {
+	password, err := um.generatePassword()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
 	um.logger.Sugar().Infow("Start Operation", "Name", "CreateSecret", "Username", u.Username, "KmsKeyId", kmsKeyID)
 	var secretArn string
+	shared := false
 	csr, err := um.secretsManagerClient.CreateSecret(ctx, &secretsmanager.CreateSecretInput{
 		Name:         aws.String(username),
 		KmsKeyId:     &kmsKeyID,
 		SecretString: aws.String(fmt.Sprintf(SecretTemplate, username, password)),
+		Tags:         stackResourceFrom(ctx).SecretTags(topic),
 	})
+	err = redactError(err, password)
 	if err != nil {
 		// If secret already exists, describe to find out its ARN
 		var ral *smt.ResourceExistsException
 		if !errors.As(err, &ral) {
-			return errors.WithStack(err)
+			return "", errors.WithStack(err)
 		}
-		um.logger.Sugar().Infow("Retry Handled", "Operation", "CreateSecret", "Username", u.Username)
 		ds, err := um.secretsManagerClient.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{
 			SecretId: &username,
 		})
 		if err != nil {
-			return errors.WithStack(err)
+			return "", errors.WithStack(err)
 		}
 		secretArn = *ds.ARN
+		shared = stackResourceFrom(ctx).SharesSecret(ds.Tags)
+		if shared {
+			// A user of the same name in another topic of the stack
+			// shares the secret. Its association and ACLs exist too.
+			um.logger.Sugar().Infow("Skip Operation", "Name", "CreateSecret", "Username", u.Username, "Reason", "Secret shared with another resource of the stack")
+			existencePolicyFrom(ctx).Own("secret association", secretArn)
+			existencePolicyFrom(ctx).Own("principal", "User:"+username)
+		} else {
+			if err := existencePolicyFrom(ctx).Adopt("secret", username); err != nil {
+				return "", err
+			}
+			um.logger.Sugar().Infow("Retry Handled", "Operation", "CreateSecret", "Username", u.Username)
+		}
+		opSummaryFrom(ctx).Skipped("CreateSecret")
 	} else {
+		opSummaryFrom(ctx).Performed("CreateSecret")
+		existencePolicyFrom(ctx).Own("secret", username)
 		secretArn = *csr.ARN
 	}
-	if u.Arn != "" {
-		err = um.grantAccessToSecretForArn(ctx, username, kmsKeyID, secretArn, u.Arn)
+	if principals := u.Principals(); len(principals) > 0 {
+		err = um.grantAccessToSecret(ctx, username, kmsKeyID, secretArn, principals)
 		if err != nil {
-			return errors.WithStack(err)
+			return "", errors.WithStack(err)
 		}
 	}
 
 	// Wait to ensure that Secret is created and available
-	// for association with MSK.
-	um.fixedDelay()
+	// for association with MSK. A shared secret has been available
+	// since the other resource was created.
+	if !shared {
+		um.secretCreateDelay()
+	}
+	return secretArn, nil
+}
 
-	um.logger.Sugar().Infow("Start Operation", "Name", "BatchAssociateScramSecret", "Username", username, "SecretArn", secretArn)
-	bass, err := um.mskClient.BatchAssociateScramSecret(ctx, &kafka.BatchAssociateScramSecretInput{
-		ClusterArn:    &clusterArn,
-		SecretArnList: []string{secretArn},
+// Externally managed secrets are associated as is. Check upfront that
+// they contain credentials for the declared user, otherwise association
+// succeeds but ACLs are granted to a principal that cannot authenticate.
+func (um *userManager) validateExternalSecret(ctx context.Context, u *tt.User) error {
+	um.logger.Sugar().Infow("Start Operation", "Name", "GetSecretValue", "SecretArn", u.SecretArn)
+	gsv, err := um.secretsManagerClient.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: &u.SecretArn,
 	})
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	if len(bass.UnprocessedScramSecrets) == 1 {
-		uss := bass.UnprocessedScramSecrets[0]
-		if *uss.ErrorMessage != "The provided secret is already associated with this cluster. To update the association, first disassociate the secret." {
-			return errors.WithStack(fmt.Errorf("failed to associate secret: %s %s", *uss.ErrorCode, *uss.ErrorMessage))
-		}
-		um.logger.Sugar().Infow("Retry Handled", "Operation", "BatchAssociateScramSecret", "Username", username)
+	var credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.Unmarshal([]byte(aws.ToString(gsv.SecretString)), &credentials); err != nil || credentials.Username == "" || credentials.Password == "" {
+		return errors.WithStack(fmt.Errorf("secret %s must contain a JSON object with username and password", u.SecretArn))
+	}
+	if credentials.Username != u.Username {
+		return errors.WithStack(fmt.Errorf("secret %s contains username %s but user is declared as %s", u.SecretArn, credentials.Username, u.Username))
+	}
+	return nil
+}
+
+// Commits the initial offsets for the group declared by the user so that its
+// consumers start at the beginning or the end of the topic. Offsets already
+// committed by the group are left untouched.
+func (um *userManager) initGroupOffsets(ctx context.Context, topic string, u *tt.User) error {
+	if u.InitialGroupOffset == "" {
+		return nil
+	}
+	um.logger.Sugar().Infow("Start Operation", "Name", "InitGroupOffsets", "Group", u.GroupName, "InitialGroupOffset", u.InitialGroupOffset)
+	committed, err := um.kafkaClient.FetchOffsets(ctx, u.GroupName)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(committed[topic]) > 0 {
+		um.logger.Sugar().Infow("Retry Handled", "Operation", "InitGroupOffsets", "Group", u.GroupName)
+		opSummaryFrom(ctx).Skipped("InitGroupOffsets")
+		return nil
 	}
-	err = um.createACLs(ctx, topic, username, u.Permissions)
+	var listed kadm.ListedOffsets
+	if u.InitialGroupOffset == tt.GroupOffsetEarliest {
+		listed, err = um.kafkaClient.ListStartOffsets(ctx, topic)
+	} else {
+		listed, err = um.kafkaClient.ListEndOffsets(ctx, topic)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := listed.Error(); err != nil {
+		return errors.WithStack(err)
+	}
+	cor, err := um.kafkaClient.CommitOffsets(ctx, u.GroupName, listed.Offsets())
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	if err := cor.Error(); err != nil {
+		return errors.WithStack(err)
+	}
+	opSummaryFrom(ctx).Performed("InitGroupOffsets")
 	return nil
 }
 
+func (um *userManager) deleteUserACLs(ctx context.Context, u *tt.User, topic, username string) error {
+	name, pattern := topicACLResource(topic, u)
+	err := um.deleteACLs(ctx, name, pattern, u.AdditionalTopics, u.Hosts(), username, u.Permissions, userGroupACLs(u), u.GroupPrefix, u.DescribesTopic())
+	return errors.WithStack(err)
+}
+
+// aclError reports a failure to create ACLs for a user whose
+// credentials are already provisioned.
+type aclError struct {
+	err error
+}
+
+func (e *aclError) Error() string {
+	return e.err.Error()
+}
+
+func (e *aclError) Unwrap() error {
+	return e.err
+}
+
 // Performs the clean up operations for resources created in createUser in reverse order.
 func (um *userManager) DeleteUser(ctx context.Context, u *tt.User, kmsKeyID, topic, shortStackID, clusterArn string) error {
-	username := canonicalUsername(u.Username, shortStackID)
-	err := um.deleteACLs(ctx, topic, username, u.Permissions)
-	if err != nil {
-		return errors.WithStack(err)
+	username := principalName(u, shortStackID, um.secretNames)
+	if u.Secretless() || u.SecretArn != "" {
+		err := um.deleteUserACLs(ctx, u, topic, username)
+		if err != nil || u.Secretless() {
+			return err
+		}
+		// Externally managed secrets are owned by another process.
+		// Only remove their association with the cluster.
+		return errors.WithStack(um.disassociateSecret(ctx, clusterArn, u.SecretArn))
 	}
 
 	um.logger.Sugar().Infow("Start Operation", "Name", "DescribeSecret", "Username", username)
-	ds, err := um.secretsManagerClient.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{
-		SecretId: &username,
+	var ds *secretsmanager.DescribeSecretOutput
+	// A secret created moments ago may not be visible yet. Retry before
+	// concluding that it does not exist, otherwise it would be left
+	// associated with the cluster.
+	err := um.describeRetry.Do(ctx, func() error {
+		var err error
+		ds, err = um.secretsManagerClient.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{
+			SecretId: &username,
+		})
+		var e *smt.ResourceNotFoundException
+		if err != nil && !errors.As(err, &e) {
+			return permanent(err)
+		}
+		return err
 	})
 	if err != nil {
 		var e *smt.ResourceNotFoundException
 		if errors.As(err, &e) {
 			// Since secret is deleted as the last action in this flow,
-			// we can assume that there's no more clean-up to do for this user.
+			// we can assume that there's no more clean-up to do for this
+			// user other than ACLs left by an interrupted create.
 			um.logger.Sugar().Infow("Retry Handled", "Operation", "DescribeSecret")
-			return nil
+			opSummaryFrom(ctx).Skipped("DeleteSecret")
+			return um.deleteUserACLs(ctx, u, topic, username)
 		} else {
 			return errors.WithStack(err)
 		}
 	}
 
-	um.logger.Sugar().Infow("Start Operation", "Name", "BatchDisassociateScramSecret")
-	bdss, err := um.mskClient.BatchDisassociateScramSecret(ctx, &kafka.BatchDisassociateScramSecretInput{
-		ClusterArn:    &clusterArn,
-		SecretArnList: []string{*ds.ARN},
-	})
+	if !stackResourceFrom(ctx).OwnsSecret(ds.Tags) {
+		// The same NameSuffix is used by another stack, whose user is
+		// still associated with the secret and authorized by the ACLs.
+		um.logger.Sugar().Infow("Skip Operation", "Name", "DeleteUser", "Username", username, "Reason", "Secret belongs to another stack")
+		opSummaryFrom(ctx).Skipped("DeleteUser")
+		return nil
+	}
+
+	err = um.deleteUserACLs(ctx, u, topic, username)
 	if err != nil {
-		if isRetriable(err) {
-			return errors.WithStack(err)
-		}
-		um.logger.Sugar().Errorw("Operation Failed", "Error", err)
-	} else {
-		if len(bdss.UnprocessedScramSecrets) == 1 {
-			e := bdss.UnprocessedScramSecrets[0]
-			if *e.ErrorMessage != "The provided secret ARN is invalid." {
-				return errors.WithStack(errors.New(*bdss.UnprocessedScramSecrets[0].ErrorMessage))
-			}
-			um.logger.Sugar().Infow("Retry Handled", "Operation", "BatchDisassociateScramSecret")
-		}
+		return err
 	}
 
-	if u.Arn != "" {
-		// Find GrantID by attempting to create the Grant with the same name
-		um.logger.Sugar().Infow("Start Operation", "Name", "CreateGrant")
-		cgo, err := um.kmsClient.CreateGrant(ctx, &kms.CreateGrantInput{
-			KeyId:            &kmsKeyID,
-			Name:             &username,
-			Operations:       []types.GrantOperation{types.GrantOperationDecrypt},
-			GranteePrincipal: &u.Arn,
-		})
-		if err != nil {
-			if isRetriable(err) {
-				return errors.WithStack(err)
-			}
-			um.logger.Sugar().Errorw("Operation Failed", "Error", err)
-		} else {
-			um.logger.Sugar().Infow("Start Operation", "Name", "RevokeGrant")
-			_, err = um.kmsClient.RevokeGrant(ctx, &kms.RevokeGrantInput{
-				GrantId: cgo.GrantId,
-				KeyId:   &kmsKeyID,
-			})
-			if err != nil {
-				if isRetriable(err) {
-					return errors.WithStack(err)
-				}
-				um.logger.Sugar().Errorw("Operation Failed", "Error", err)
-			}
-		}
+	if stackResourceFrom(ctx).SharesSecret(ds.Tags) {
+		// The resource that created the secret still authenticates the
+		// user with it, therefore it stays associated.
+		um.logger.Sugar().Infow("Skip Operation", "Name", "DeleteSecret", "Username", username, "Reason", "Secret shared with another resource of the stack")
+		opSummaryFrom(ctx).Skipped("DeleteSecret")
+		return nil
+	}
+
+	err = um.disassociateSecret(ctx, clusterArn, *ds.ARN)
+	if err != nil {
+		// Leave the secret intact so that the operator can intervene.
+		// Deleting it now would leave MSK referencing a deleted secret.
+		return errors.WithStack(err)
+	}
+
+	if retainedSecretsFrom(ctx).Retains(username) {
+		// Still associated with another cluster or used by the
+		// recreated user.
+		um.logger.Sugar().Infow("Skip Operation", "Name", "DeleteSecret", "Username", username, "Reason", "Secret is shared")
+		existencePolicyFrom(ctx).Own("secret", username)
+		opSummaryFrom(ctx).Skipped("DeleteSecret")
+		return nil
+	}
+
+	// Grants are revoked before the secret is deleted. Retriable
+	// failures to revoke them fail the deletion so that the retry
+	// still finds the secret and its grants are not orphaned.
+	err = um.revokeGrants(ctx, username, kmsKeyID, u.Principals())
+	if err != nil {
+		return errors.WithStack(err)
 	}
 
 	um.logger.Sugar().Infow("Start Operation", "Name", "DeleteSecret", "Username", username)
@@ -212,113 +408,1291 @@ func (um *userManager) DeleteUser(ctx context.Context, u *tt.User, kmsKeyID, top
 			return errors.WithStack(err)
 		}
 		um.logger.Sugar().Errorw("Operation Failed", zap.Error(err))
+	} else {
+		opSummaryFrom(ctx).Performed("DeleteSecret")
 	}
 
 	return nil
 }
 
-func (um *userManager) CreateACLs(ctx context.Context, topic, username, shortStackID string, permissions []tt.Permission) error {
-	username = canonicalUsername(username, shortStackID)
-	return um.createACLs(ctx, topic, username, permissions)
+// Maximum number of values in a ListSecrets filter.
+const listSecretsFilterValues = 10
+
+// Maximum number of secrets associated by one BatchAssociateScramSecret
+// call.
+const maxAssociateSecrets = 10
+
+// Returns the users that may have resources to clean up. A generated
+// secret is created before any other resource of its user and deleted
+// after them, therefore users without one were never created or are
+// already deleted. Users without a generated secret are always returned.
+func (um *userManager) ProvisionedUsers(ctx context.Context, users []tt.User, shortStackID string) ([]tt.User, error) {
+	existing, err := um.generatedSecrets(ctx, users, shortStackID)
+	if err != nil {
+		return nil, err
+	}
+	provisioned := make([]tt.User, 0, len(users))
+	for i := range users {
+		_, ok := existing[um.secretNames.Name(users[i].Username, shortStackID)]
+		if users[i].Secretless() || users[i].SecretArn != "" || ok {
+			provisioned = append(provisioned, users[i])
+		}
+	}
+	return provisioned, nil
+}
+
+// Returns the ARNs of the secrets of users by declared username. TLS users
+// and users whose secret does not exist are omitted.
+func (um *userManager) SecretArns(ctx context.Context, users []tt.User, shortStackID string) (map[string]string, error) {
+	existing, err := um.generatedSecrets(ctx, users, shortStackID)
+	if err != nil {
+		return nil, err
+	}
+	arns := make(map[string]string)
+	for i := range users {
+		if users[i].SecretArn != "" {
+			arns[users[i].Username] = users[i].SecretArn
+		} else if arn, ok := existing[um.secretNames.Name(users[i].Username, shortStackID)]; ok && !users[i].Secretless() {
+			arns[users[i].Username] = arn
+		}
+	}
+	return arns, nil
 }
 
-func (um *userManager) createACLs(ctx context.Context, topic, username string, permissions []tt.Permission) error {
-	um.logger.Sugar().Infow("Start Operation", "Name", "CreateACLs")
-	acls := um.userPermissionToACL(topic, username, permissions)
-	for _, acl := range acls {
-		car, err := um.kafkaClient.CreateACLs(ctx, acl)
+// Returns the ARNs of the existing generated secrets of users by secret
+// name.
+func (um *userManager) generatedSecrets(ctx context.Context, users []tt.User, shortStackID string) (map[string]string, error) {
+	names := make([]string, 0, len(users))
+	for i := range users {
+		if !users[i].Secretless() && users[i].SecretArn == "" {
+			names = append(names, um.secretNames.Name(users[i].Username, shortStackID))
+		}
+	}
+	existing := make(map[string]string)
+	for start := 0; start < len(names); start += listSecretsFilterValues {
+		end := start + listSecretsFilterValues
+		if end > len(names) {
+			end = len(names)
+		}
+		um.logger.Sugar().Infow("Start Operation", "Name", "ListSecrets", "Count", end-start)
+		var nextToken *string
+		for {
+			out, err := um.secretsManagerClient.ListSecrets(ctx, &secretsmanager.ListSecretsInput{
+				Filters:   []smt.Filter{{Key: smt.FilterNameStringTypeName, Values: names[start:end]}},
+				NextToken: nextToken,
+			})
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			// The name filter matches prefixes, callers look up exact names.
+			for _, e := range out.SecretList {
+				existing[aws.ToString(e.Name)] = aws.ToString(e.ARN)
+			}
+			if out.NextToken == nil {
+				break
+			}
+			nextToken = out.NextToken
+		}
+	}
+	return existing, nil
+}
+
+// Deletes the secrets generated for the stack that do not belong to any
+// of users. Such secrets are left behind when a user is removed while its
+// secret cannot be deleted, or when users are removed from the stack
+// outside CloudFormation. Secrets are disassociated from the cluster before
+// they are deleted.
+func (um *userManager) CleanupOrphanedSecrets(ctx context.Context, users []tt.User, shortStackID, clusterArn string) error {
+	orphans, err := um.cleanupOrphanedSecrets(ctx, users, shortStackID)
+	if err != nil {
+		return err
+	}
+	for _, e := range orphans {
+		if err := ctx.Err(); err != nil {
+			return errors.WithStack(err)
+		}
+		name := aws.ToString(e.Name)
+		err := um.disassociateSecret(ctx, clusterArn, aws.ToString(e.ARN))
 		if err != nil {
 			return errors.WithStack(err)
 		}
-		if car[0].Err != nil {
-			return errors.WithStack(car[0].Err)
+		if retainedSecretsFrom(ctx).Retains(name) {
+			um.logger.Sugar().Infow("Skip Operation", "Name", "DeleteSecret", "Secret", name, "Reason", "Secret is shared")
+			opSummaryFrom(ctx).Skipped("DeleteSecret")
+			continue
 		}
+		um.logger.Sugar().Infow("Start Operation", "Name", "DeleteSecret", "Secret", name)
+		_, err = um.secretsManagerClient.DeleteSecret(ctx, &secretsmanager.DeleteSecretInput{
+			SecretId:                   e.ARN,
+			ForceDeleteWithoutRecovery: aws.Bool(true),
+		})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		opSummaryFrom(ctx).Performed("DeleteSecret")
 	}
 	return nil
 }
 
-func (um *userManager) DeleteACLs(ctx context.Context, topic, username, shortStackID string, permissions []tt.Permission) error {
-	return um.deleteACLs(ctx, topic, canonicalUsername(username, shortStackID), permissions)
+// Returns the secrets generated for users of the resource in ctx whose
+// names do not belong to any of users. Other resources of the stack share
+// the NameSuffix, therefore secrets are only matched by their tags and
+// untagged secrets are never considered orphans.
+func (um *userManager) cleanupOrphanedSecrets(ctx context.Context, users []tt.User, shortStackID string) ([]smt.SecretListEntry, error) {
+	desired := make(map[string]bool, len(users))
+	for i := range users {
+		if !users[i].Secretless() && users[i].SecretArn == "" {
+			desired[um.secretNames.Name(users[i].Username, shortStackID)] = true
+		}
+	}
+	secrets, err := listStackSecrets(ctx, um.secretsManagerClient, um.secretNames, shortStackID, um.logger)
+	if err != nil {
+		return nil, err
+	}
+	orphans := make([]smt.SecretListEntry, 0)
+	for _, e := range secrets {
+		if !desired[aws.ToString(e.Name)] && stackResourceFrom(ctx).CreatedSecret(e.Tags) {
+			orphans = append(orphans, e)
+		}
+	}
+	return orphans, nil
 }
 
-func (um *userManager) userPermissionToACL(topic, username string, permissions []tt.Permission) []*kadm.ACLBuilder {
-	acls := make([]*kadm.ACLBuilder, 0)
-	topicACLBuilder := kadm.NewACLs().Topics(topic).ResourcePatternType(kadm.ACLPatternLiteral)
-	groupACLBuilder := kadm.NewACLs().Groups("*").ResourcePatternType(kadm.ACLPatternLiteral)
-	topicOps := make([]kmsg.ACLOperation, 0)
-	groupOps := make([]kadm.ACLOperation, 0)
-	for _, permission := range permissions {
-		if permission == tt.PermissionRead {
-			topicOps = append(topicOps, kadm.OpRead)
-			groupOps = append(groupOps, kadm.OpRead)
-			groupOps = append(groupOps, kadm.OpDescribe)
+// Returns the secrets generated for users of the stack, excluding those
+// tagged by another stack. ListSecrets filters names by prefix, therefore
+// all pages are listed and names are matched against the suffix of the
+// stack too.
+func listStackSecrets(ctx context.Context, client SecretsManagerClient, names secretNameTemplate, shortStackID string, logger *zap.Logger) ([]smt.SecretListEntry, error) {
+	prefix, _ := names.Affixes(shortStackID)
+	logger.Sugar().Infow("Start Operation", "Name", "ListSecrets", "Prefix", prefix)
+	secrets := make([]smt.SecretListEntry, 0)
+	var nextToken *string
+	for {
+		out, err := client.ListSecrets(ctx, &secretsmanager.ListSecretsInput{
+			Filters:   []smt.Filter{{Key: smt.FilterNameStringTypeName, Values: []string{prefix}}},
+			NextToken: nextToken,
+		})
+		if err != nil {
+			return nil, errors.WithStack(err)
 		}
-		if permission == tt.PermissionWrite {
-			topicOps = append(topicOps, kadm.OpWrite)
+		for _, e := range out.SecretList {
+			if names.IsStackSecret(aws.ToString(e.Name), shortStackID) && stackResourceFrom(ctx).OwnsSecret(e.Tags) {
+				secrets = append(secrets, e)
+			}
 		}
+		if out.NextToken == nil {
+			break
+		}
+		nextToken = out.NextToken
 	}
-	topicACLBuilder.Operations(topicOps...)
-	groupACLBuilder.Operations(groupOps...)
-	acls = append(acls, topicACLBuilder.Allow(fmt.Sprintf("User:%s", username)).AllowHosts("*"))
-	if len(groupOps) > 0 {
-		acls = append(acls, groupACLBuilder.Allow(fmt.Sprintf("User:%s", username)).AllowHosts("*"))
+	return secrets, nil
+}
+
+// Associates the secrets with the cluster, up to maxAssociateSecrets per
+// call. MSK throttles these calls aggressively when many users are created
+// at once, therefore throttling and server errors are retried with backoff.
+// Secrets that are already associated are adopted.
+func (um *userManager) AssociateSecrets(ctx context.Context, clusterArn string, secretArns []string) error {
+	for start := 0; start < len(secretArns); start += maxAssociateSecrets {
+		end := start + maxAssociateSecrets
+		if end > len(secretArns) {
+			end = len(secretArns)
+		}
+		if err := um.associateSecrets(ctx, clusterArn, secretArns[start:end]); err != nil {
+			return err
+		}
 	}
-	return acls
+	return nil
 }
 
-func (um *userManager) grantAccessToSecretForArn(ctx context.Context, username, kmsKeyID, secretArn, principalArn string) error {
-	um.logger.Sugar().Infow("Start Operation", "Name", "PutResourcePolicy", "ARN", principalArn)
-	_, err := um.secretsManagerClient.PutResourcePolicy(ctx, &secretsmanager.PutResourcePolicyInput{
-		SecretId:       &secretArn,
-		ResourcePolicy: aws.String(fmt.Sprintf(SecretPolicyTemplate, principalArn)),
+func (um *userManager) associateSecrets(ctx context.Context, clusterArn string, secretArns []string) error {
+	return um.associateRetry.Do(ctx, func() error {
+		um.logger.Sugar().Infow("Start Operation", "Name", "BatchAssociateScramSecret", "SecretArns", secretArns)
+		start := time.Now()
+		bass, err := um.mskClient.BatchAssociateScramSecret(ctx, &kafka.BatchAssociateScramSecretInput{
+			ClusterArn:    &clusterArn,
+			SecretArnList: secretArns,
+		})
+		um.metrics.Latency("OperationLatency", start, map[string]string{"Operation": "BatchAssociateScramSecret"})
+		if err != nil {
+			um.logger.Sugar().Errorw("Operation Failed", "Error", err)
+			if !isRetriable(err) {
+				return permanent(errors.WithStack(err))
+			}
+			return errors.WithStack(err)
+		}
+		unprocessed := make(map[string]bool)
+		for _, uss := range bass.UnprocessedScramSecrets {
+			secretArn := aws.ToString(uss.SecretArn)
+			if aws.ToString(uss.ErrorMessage) != "The provided secret is already associated with this cluster. To update the association, first disassociate the secret." {
+				return permanent(errors.WithStack(fmt.Errorf("failed to associate secret %s: %s %s", secretArn, aws.ToString(uss.ErrorCode), aws.ToString(uss.ErrorMessage))))
+			}
+			if err := existencePolicyFrom(ctx).Adopt("secret association", secretArn); err != nil {
+				return permanent(err)
+			}
+			um.logger.Sugar().Infow("Retry Handled", "Operation", "BatchAssociateScramSecret", "SecretArn", secretArn)
+			opSummaryFrom(ctx).Skipped("AssociateSecret")
+			unprocessed[secretArn] = true
+		}
+		for _, secretArn := range secretArns {
+			if !unprocessed[secretArn] {
+				opSummaryFrom(ctx).Performed("AssociateSecret")
+			}
+		}
+		return nil
 	})
-	if err != nil {
-		return errors.WithStack(err)
-	}
+}
 
-	um.logger.Sugar().Infow("Start Operation", "Name", "CreateGrant", "ARN", principalArn)
-	_, err = um.kmsClient.CreateGrant(ctx, &kms.CreateGrantInput{
-		Name:             &username,
-		KeyId:            &kmsKeyID,
-		GranteePrincipal: &principalArn,
-		Operations:       []types.GrantOperation{types.GrantOperationDecrypt},
+// Disassociates the secret from the cluster and confirms that it is no
+// longer listed against the cluster before returning.
+func (um *userManager) disassociateSecret(ctx context.Context, clusterArn, secretArn string) error {
+	return um.disassociateRetry.Do(ctx, func() error {
+		um.logger.Sugar().Infow("Start Operation", "Name", "BatchDisassociateScramSecret", "SecretArn", secretArn)
+		start := time.Now()
+		bdss, err := um.mskClient.BatchDisassociateScramSecret(ctx, &kafka.BatchDisassociateScramSecretInput{
+			ClusterArn:    &clusterArn,
+			SecretArnList: []string{secretArn},
+		})
+		um.metrics.Latency("OperationLatency", start, map[string]string{"Operation": "BatchDisassociateScramSecret"})
+		if err != nil {
+			var nf *kt.NotFoundException
+			if errors.As(err, &nf) {
+				// Cluster no longer exists, therefore there's no association to remove.
+				um.logger.Sugar().Infow("Retry Handled", "Operation", "BatchDisassociateScramSecret")
+				return nil
+			}
+			um.logger.Sugar().Errorw("Operation Failed", "Error", err)
+			if !isRetriable(err) {
+				return permanent(errors.WithStack(err))
+			}
+			return errors.WithStack(err)
+		}
+		if len(bdss.UnprocessedScramSecrets) == 1 {
+			e := bdss.UnprocessedScramSecrets[0]
+			if aws.ToString(e.ErrorMessage) != "The provided secret ARN is invalid." {
+				um.logger.Sugar().Errorw("Operation Failed", "Error", aws.ToString(e.ErrorMessage))
+				return errors.WithStack(errors.New(aws.ToString(e.ErrorMessage)))
+			}
+			um.logger.Sugar().Infow("Retry Handled", "Operation", "BatchDisassociateScramSecret")
+		}
+		associated, err := um.isSecretAssociated(ctx, clusterArn, secretArn)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if associated {
+			return errors.WithStack(fmt.Errorf("secret %s is still associated with cluster %s", secretArn, clusterArn))
+		}
+		return nil
 	})
+}
+
+func (um *userManager) isSecretAssociated(ctx context.Context, clusterArn, secretArn string) (bool, error) {
+	um.logger.Sugar().Infow("Start Operation", "Name", "ListScramSecrets")
+	var nextToken *string
+	for {
+		out, err := um.mskClient.ListScramSecrets(ctx, &kafka.ListScramSecretsInput{
+			ClusterArn: &clusterArn,
+			NextToken:  nextToken,
+		})
+		if err != nil {
+			return false, errors.WithStack(err)
+		}
+		for _, arn := range out.SecretArnList {
+			if arn == secretArn {
+				return true, nil
+			}
+		}
+		if out.NextToken == nil {
+			return false, nil
+		}
+		nextToken = out.NextToken
+	}
+}
+
+func (um *userManager) CreateACLs(ctx context.Context, topic string, u *tt.User, shortStackID string, permissions []tt.Permission) error {
+	name, pattern := topicACLResource(topic, u)
+	if err := um.checkTopicsExist(ctx, u.AdditionalTopics); err != nil {
+		return err
+	}
+	return um.createACLs(ctx, name, pattern, u.AdditionalTopics, u.Hosts(), principalName(u, shortStackID, um.secretNames), permissions, userGroupACLs(u), u.GroupPrefix, u.DescribesTopic())
+}
+
+// Fails when any of the additional topics of a user does not exist. Kafka
+// allows ACLs on topics that do not exist, which would grant access to a
+// topic created later with the same name.
+func (um *userManager) checkTopicsExist(ctx context.Context, topics []string) error {
+	if len(topics) == 0 {
+		return nil
+	}
+	um.logger.Sugar().Infow("Start Operation", "Name", "ListTopics", "Topics", topics)
+	details, err := um.kafkaClient.ListTopics(ctx, topics...)
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	var missing []string
+	for _, t := range topics {
+		if d, ok := details[t]; !ok || d.Err != nil {
+			missing = append(missing, t)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.WithStack(fmt.Errorf("AdditionalTopics do not exist in the cluster: %s", strings.Join(missing, ", ")))
+	}
 	return nil
 }
 
-func (a *userManager) deleteACLs(ctx context.Context, topic, username string, permissions []tt.Permission) error {
-	a.logger.Sugar().Infow("Start Operation", "Name", "DeleteKafkaACL")
-	acls := a.userPermissionToACL(topic, username, permissions)
+// Returns the name and pattern type of the topic resource in the ACLs of
+// u. Users with a TopicPrefix are granted access to every topic starting
+// with the prefix rather than to topic alone.
+func topicACLResource(topic string, u *tt.User) (string, kadm.ACLPattern) {
+	if u.TopicPrefix != "" {
+		return u.TopicPrefix, kadm.ACLPatternPrefixed
+	}
+	return topic, kadm.ACLPatternLiteral
+}
+
+func (um *userManager) createACLs(ctx context.Context, topic string, pattern kadm.ACLPattern, additionalTopics, hosts []string, username string, permissions []tt.Permission, groups groupACLs, groupPrefix string, topicDescribe bool) error {
+	acls := um.userPermissionToACL(topic, pattern, additionalTopics, hosts, username, permissions, groups, groupPrefix, topicDescribe)
+	if tx := aclTransactionFrom(ctx); tx != nil {
+		// Applied along with the ACLs of all other users by ApplyACLs.
+		tx.Add(acls...)
+		return nil
+	}
+	um.logger.Sugar().Infow("Start Operation", "Name", "CreateACLs")
 	for _, acl := range acls {
-		r, err := a.kafkaClient.DeleteACLs(ctx, acl)
-		if err != nil {
-			if kerr.IsRetriable(err) {
-				return errors.WithStack(err)
-			}
-			a.logger.Sugar().Errorw("Operation Failed", "Error", err)
-			return nil
+		// Stop issuing calls once the invocation is cancelled or about to
+		// time out.
+		if err := ctx.Err(); err != nil {
+			return errors.WithStack(err)
 		}
-		if r[0].Err != nil {
-			if kerr.IsRetriable(r[0].Err) {
-				return errors.WithStack(r[0].Err)
+		err := um.aclRetry.Do(ctx, func() error {
+			_, err := um.createACL(ctx, acl)
+			if err != nil && !kerr.IsRetriable(err) {
+				return permanent(err)
 			}
-			a.logger.Sugar().Errorw("Operation Failed", "Error", err)
+			return err
+		})
+		if err != nil {
+			return errors.WithStack(err)
 		}
 	}
+	um.metrics.Count("ACLsCreated", len(acls), nil)
 	return nil
 }
 
-func (a *userManager) generatePassword() (string, error) {
-	buf := make([]byte, 9)
-	n, err := rand.Read(buf)
+// Creates the ACLs in builder and returns the ones created successfully,
+// including when another ACL fails. Failures for ACLs that already exist
+// (e.g. created by a previous attempt of the same request) are ignored so
+// that retries are idempotent.
+func (um *userManager) createACL(ctx context.Context, acl *kadm.ACLBuilder) (kadm.CreateACLsResults, error) {
+	// Kafka reports success when an ACL already exists, therefore
+	// collisions are only detected by describing the ACLs first.
+	if existencePolicyFrom(ctx).Strict() {
+		err := um.adoptExistingACLs(ctx, acl)
+		if err != nil {
+			return nil, err
+		}
+	}
+	car, err := um.kafkaClient.CreateACLs(ctx, acl)
 	if err != nil {
-		return "", errors.WithStack(err)
+		return nil, errors.WithStack(err)
+	}
+	created := make(kadm.CreateACLsResults, 0, len(car))
+	for _, r := range car {
+		if r.Err == nil {
+			opSummaryFrom(ctx).Performed("CreateACL")
+			created = append(created, r)
+		}
+	}
+	for _, r := range car {
+		if r.Err == nil {
+			continue
+		}
+		exists, err := um.aclExists(ctx, &r)
+		if err != nil {
+			return created, errors.WithStack(err)
+		}
+		if !exists {
+			return created, errors.WithStack(r.Err)
+		}
+		if err := existencePolicyFrom(ctx).AdoptACL(r.Principal, fmt.Sprintf("%s %s on %s", r.Principal, r.Operation, r.Name)); err != nil {
+			return created, err
+		}
+		um.logger.Sugar().Infow("Retry Handled", "Operation", "CreateACLs", "Principal", r.Principal, "Resource", r.Name, "ACLOperation", r.Operation.String(), "Error", r.Err)
+		opSummaryFrom(ctx).Skipped("CreateACL")
+	}
+	createdResourcesFrom(ctx).AddACLs(acl)
+	return created, nil
+}
+
+// Returns an error when any ACL in acl already exists and the existence
+// policy does not allow adopting it.
+func (um *userManager) adoptExistingACLs(ctx context.Context, acl *kadm.ACLBuilder) error {
+	results, err := um.kafkaClient.DescribeACLs(ctx, acl)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			return errors.WithStack(r.Err)
+		}
+		for _, d := range r.Described {
+			if err := existencePolicyFrom(ctx).AdoptACL(d.Principal, fmt.Sprintf("%s %s on %s", d.Principal, d.Operation, d.Name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Returns a filter matching exactly the ACL in r, or nil if the resource
+// type is not managed by TR.
+func aclFilter(r *kadm.CreateACLsResult) *kadm.ACLBuilder {
+	filter := kadm.NewACLs().ResourcePatternType(r.Pattern).Allow(r.Principal).AllowHosts(r.Host).Operations(r.Operation)
+	switch r.Type {
+	case kmsg.ACLResourceTypeTopic:
+		return filter.Topics(r.Name)
+	case kmsg.ACLResourceTypeGroup:
+		return filter.Groups(r.Name)
+	}
+	return nil
+}
+
+// Reports whether the ACL in a failed creation result is already present.
+func (um *userManager) aclExists(ctx context.Context, r *kadm.CreateACLsResult) (bool, error) {
+	filter := aclFilter(r)
+	if filter == nil {
+		return false, nil
+	}
+	results, err := um.kafkaClient.DescribeACLs(ctx, filter)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	for _, d := range results {
+		if d.Err == nil && len(d.Described) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Creates acls as a unit. ACLs present beforehand are recorded first so
+// that, when any of acls cannot be created, the ACLs created by this call
+// are deleted again without touching ACLs that existed before (e.g. group
+// ACLs shared with other topics). Retriable failures are retried before
+// rolling back, the same as in createACLs.
+func (um *userManager) ApplyACLs(ctx context.Context, acls []*kadm.ACLBuilder) error {
+	if len(acls) == 0 {
+		return nil
+	}
+	um.logger.Sugar().Infow("Start Operation", "Name", "ApplyACLs", "Count", len(acls))
+	existing := make(map[string]bool)
+	for _, acl := range acls {
+		results, err := um.kafkaClient.DescribeACLs(ctx, acl)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		for _, r := range results {
+			if r.Err != nil {
+				return errors.WithStack(r.Err)
+			}
+			for _, d := range r.Described {
+				existing[aclKey(d.Principal, d.Host, d.Type, d.Name, d.Pattern, d.Operation)] = true
+			}
+		}
+	}
+	created := make(kadm.CreateACLsResults, 0)
+	for _, acl := range acls {
+		if err := ctx.Err(); err != nil {
+			return errors.WithStack(err)
+		}
+		err := um.aclRetry.Do(ctx, func() error {
+			results, err := um.createACL(ctx, acl)
+			for _, r := range results {
+				key := aclKey(r.Principal, r.Host, r.Type, r.Name, r.Pattern, r.Operation)
+				if !existing[key] {
+					created = append(created, r)
+					// A retried attempt reports the ACL again.
+					existing[key] = true
+				}
+			}
+			if err != nil && !kerr.IsRetriable(err) {
+				return permanent(err)
+			}
+			return err
+		})
+		if err != nil {
+			if rerr := um.rollbackACLs(ctx, created); rerr != nil {
+				return errors.WithStack(fmt.Errorf("%s (rollback failed: %s)", err, rerr))
+			}
+			return errors.WithStack(fmt.Errorf("%s (rolled back %d ACLs)", err, len(created)))
+		}
+	}
+	um.metrics.Count("ACLsCreated", len(created), nil)
+	return nil
+}
+
+// Deletes ACLs created by ApplyACLs in reverse order of creation. Keeps
+// going when a deletion fails and returns the first failure.
+func (um *userManager) rollbackACLs(ctx context.Context, created kadm.CreateACLsResults) error {
+	um.logger.Sugar().Warnw("Rolling back ACLs", "Count", len(created))
+	var first error
+	for i := len(created) - 1; i >= 0; i-- {
+		filter := aclFilter(&created[i])
+		if filter == nil {
+			continue
+		}
+		dr, err := um.kafkaClient.DeleteACLs(ctx, filter)
+		if err == nil {
+			for _, r := range dr {
+				if r.Err != nil {
+					err = r.Err
+					break
+				}
+			}
+		}
+		if err != nil {
+			um.logger.Sugar().Errorw("Operation Failed", "Operation", "DeleteACLs", "Principal", created[i].Principal, "Resource", created[i].Name, "Error", err)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return errors.WithStack(first)
+}
+
+func aclKey(principal, host string, resourceType kmsg.ACLResourceType, name string, pattern kadm.ACLPattern, op kadm.ACLOperation) string {
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%s", principal, host, resourceType, name, pattern, op)
+}
+
+// Deletes the ACLs of permissions removed from u. DESCRIBE on the topic is
+// shared by READ and WRITE, therefore it is left to ReconcileACLs, which
+// deletes it once neither remains.
+func (um *userManager) DeleteACLs(ctx context.Context, topic string, u *tt.User, shortStackID string, permissions []tt.Permission) error {
+	name, pattern := topicACLResource(topic, u)
+	return um.deleteACLs(ctx, name, pattern, u.AdditionalTopics, u.Hosts(), principalName(u, shortStackID, um.secretNames), permissions, userGroupACLs(u), u.GroupPrefix, false)
+}
+
+// Deletes all ACLs of u on the topic, including DESCRIBE. Group ACLs are
+// kept since they apply to every group of the user.
+func (um *userManager) RevokeTopicACLs(ctx context.Context, topic string, u *tt.User, shortStackID string) error {
+	name, pattern := topicACLResource(topic, u)
+	return um.deleteACLs(ctx, name, pattern, nil, u.Hosts(), principalName(u, shortStackID, um.secretNames), u.Permissions, groupACLsNone, "", u.DescribesTopic())
+}
+
+// Compares the ACLs granted to the user with its declared permissions and
+// corrects any drift caused by changes made outside TR. Missing ACLs are
+// created and extra topic ACLs, including those of hosts not allowed to the
+// user, are deleted. Extra group ACLs are retained because they apply to
+// all groups ("*"), or all groups with the group prefix, and may be
+// required by the same user declared in another topic. The exception is
+// DESCRIBE on groups for a user with GroupDescribe disabled and without
+// OFFSET_MANAGEMENT, which is deleted so that disabling it revokes
+// access. Group ACLs of a user with NoGroupAcls are left to operators.
+func (um *userManager) ReconcileACLs(ctx context.Context, topic string, u *tt.User, shortStackID string) error {
+	principal := fmt.Sprintf("User:%s", principalName(u, shortStackID, um.secretNames))
+	topic, pattern := topicACLResource(topic, u)
+	um.logger.Sugar().Infow("Start Operation", "Name", "DescribeACLs", "Principal", principal)
+	group, groupPattern := groupACLResource(u.GroupPrefix)
+	// A filter matches a single pattern type. Prefixed topic or group
+	// ACLs are described along with literal ones by matching any type.
+	filterPattern := kadm.ACLPatternLiteral
+	if pattern != kadm.ACLPatternLiteral || groupPattern != kadm.ACLPatternLiteral {
+		filterPattern = kadm.ACLPatternAny
+	}
+	filter := kadm.NewACLs().Topics(topic).Groups(group).ResourcePatternType(filterPattern).Allow(principal).AllowHosts().Operations()
+	results, err := um.kafkaClient.DescribeACLs(ctx, filter)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	topicOps, groupOps := permissionsToOperations(u.Permissions, userGroupACLs(u), u.DescribesTopic())
+	wantTopic := make(map[kadm.ACLOperation]bool)
+	for _, op := range topicOps {
+		wantTopic[op] = true
+	}
+	wantGroup := make(map[kadm.ACLOperation]bool)
+	for _, op := range groupOps {
+		wantGroup[op] = true
+	}
+	hosts := u.Hosts()
+	wantHosts := make(map[string]bool)
+	hasTopic := make(map[string]map[kadm.ACLOperation]bool)
+	hasGroup := make(map[string]map[kadm.ACLOperation]bool)
+	for _, host := range hosts {
+		wantHosts[host] = true
+		hasTopic[host] = make(map[kadm.ACLOperation]bool)
+		hasGroup[host] = make(map[kadm.ACLOperation]bool)
+	}
+	extra := make([]*kadm.ACLBuilder, 0)
+	for _, r := range results {
+		if r.Err != nil {
+			return errors.WithStack(r.Err)
+		}
+		for _, d := range r.Described {
+			if d.Principal != principal {
+				continue
+			}
+			switch {
+			case d.Type == kmsg.ACLResourceTypeTopic && d.Name == topic && d.Pattern == pattern:
+				if wantHosts[d.Host] && wantTopic[d.Operation] {
+					hasTopic[d.Host][d.Operation] = true
+					continue
+				}
+				extra = append(extra, kadm.NewACLs().Topics(topic).ResourcePatternType(pattern).Allow(principal).AllowHosts(d.Host).Operations(d.Operation))
+			case d.Type == kmsg.ACLResourceTypeGroup && d.Name == group && d.Pattern == groupPattern && wantHosts[d.Host]:
+				// OFFSET_MANAGEMENT requires DESCRIBE on groups even when
+				// GroupDescribe is disabled.
+				if d.Operation == kadm.OpDescribe && userGroupACLs(u) == groupACLsRead && !wantGroup[kadm.OpDescribe] {
+					extra = append(extra, kadm.NewACLs().Groups(group).ResourcePatternType(groupPattern).Allow(principal).AllowHosts(d.Host).Operations(kadm.OpDescribe))
+					continue
+				}
+				hasGroup[d.Host][d.Operation] = true
+			}
+		}
+	}
+
+	missing := make([]*kadm.ACLBuilder, 0)
+	for _, host := range hosts {
+		if ops := missingOperations(topicOps, hasTopic[host]); len(ops) > 0 {
+			missing = append(missing, kadm.NewACLs().Topics(topic).ResourcePatternType(pattern).Operations(ops...).Allow(principal).AllowHosts(host))
+		}
+		if ops := missingOperations(groupOps, hasGroup[host]); len(ops) > 0 {
+			missing = append(missing, kadm.NewACLs().Groups(group).ResourcePatternType(groupPattern).Operations(ops...).Allow(principal).AllowHosts(host))
+		}
+	}
+	if len(missing) == 0 && len(extra) == 0 {
+		return nil
+	}
+
+	um.logger.Sugar().Warnw("ACL Drift Detected", "Principal", principal, "Missing", len(missing), "Extra", len(extra))
+	for _, acl := range missing {
+		_, err := um.createACL(ctx, acl)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	for _, acl := range extra {
+		dr, err := um.kafkaClient.DeleteACLs(ctx, acl)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if dr[0].Err != nil {
+			return errors.WithStack(dr[0].Err)
+		}
+	}
+	um.metrics.Count("ACLDriftCorrected", len(missing)+len(extra), nil)
+	return nil
+}
+
+func missingOperations(want []kadm.ACLOperation, has map[kadm.ACLOperation]bool) []kadm.ACLOperation {
+	missing := make([]kadm.ACLOperation, 0)
+	for _, op := range want {
+		if !has[op] {
+			missing = append(missing, op)
+		}
+	}
+	return missing
+}
+
+func (um *userManager) userPermissionToACL(topic string, pattern kadm.ACLPattern, additionalTopics, hosts []string, username string, permissions []tt.Permission, groups groupACLs, groupPrefix string, topicDescribe bool) []*kadm.ACLBuilder {
+	specs := userACLSpecs(topic, pattern, additionalTopics, hosts, username, permissions, groups, groupPrefix, topicDescribe)
+	acls := make([]*kadm.ACLBuilder, 0, len(specs))
+	for _, spec := range specs {
+		acls = append(acls, spec.builder())
+	}
+	return acls
+}
+
+// aclSpec describes the operations allowed to a principal on a resource.
+// ACLs applied to the cluster and the ACL audit trail are both derived
+// from it so that they cannot disagree.
+type aclSpec struct {
+	ResourceType kmsg.ACLResourceType
+	Name         string
+	Pattern      kadm.ACLPattern
+	Principal    string
+	Host         string
+	Operations   []kadm.ACLOperation
+}
+
+func (s aclSpec) builder() *kadm.ACLBuilder {
+	b := kadm.NewACLs().ResourcePatternType(s.Pattern).Operations(s.Operations...).Allow(s.Principal).AllowHosts(s.Host)
+	if s.ResourceType == kmsg.ACLResourceTypeGroup {
+		return b.Groups(s.Name)
+	}
+	return b.Topics(s.Name)
+}
+
+// Returns a filter per operation of the spec matching the ACLs created by
+// its builder. As with the builder, no operations match any operation.
+func (s aclSpec) deleteFilters() []kmsg.DeleteACLsRequestFilter {
+	ops := s.Operations
+	if len(ops) == 0 {
+		ops = []kadm.ACLOperation{kadm.OpAny}
+	}
+	filters := make([]kmsg.DeleteACLsRequestFilter, 0, len(ops))
+	for _, op := range ops {
+		f := kmsg.NewDeleteACLsRequestFilter()
+		f.ResourceType = s.ResourceType
+		f.ResourceName = kmsg.StringPtr(s.Name)
+		f.ResourcePatternType = s.Pattern
+		f.Principal = kmsg.StringPtr(s.Principal)
+		f.Host = kmsg.StringPtr(s.Host)
+		f.Operation = op
+		f.PermissionType = kmsg.ACLPermissionTypeAllow
+		filters = append(filters, f)
+	}
+	return filters
+}
+
+// Returns the specs of the ACLs granting permissions to username from
+// each of hosts. The topic specs are always returned, followed by the specs
+// of each additional topic, and the group specs only when they allow any
+// operation.
+func userACLSpecs(topic string, pattern kadm.ACLPattern, additionalTopics, hosts []string, username string, permissions []tt.Permission, groups groupACLs, groupPrefix string, topicDescribe bool) []aclSpec {
+	principal := fmt.Sprintf("User:%s", username)
+	topicOps, groupOps := permissionsToOperations(permissions, groups, topicDescribe)
+	specs := make([]aclSpec, 0)
+	for _, host := range hosts {
+		specs = append(specs, aclSpec{ResourceType: kmsg.ACLResourceTypeTopic, Name: topic, Pattern: pattern, Principal: principal, Host: host, Operations: topicOps})
+	}
+	for _, t := range additionalTopics {
+		for _, host := range hosts {
+			specs = append(specs, aclSpec{ResourceType: kmsg.ACLResourceTypeTopic, Name: t, Pattern: kadm.ACLPatternLiteral, Principal: principal, Host: host, Operations: topicOps})
+		}
+	}
+	if len(groupOps) > 0 {
+		group, groupPattern := groupACLResource(groupPrefix)
+		for _, host := range hosts {
+			specs = append(specs, aclSpec{ResourceType: kmsg.ACLResourceTypeGroup, Name: group, Pattern: groupPattern, Principal: principal, Host: host, Operations: groupOps})
+		}
+	}
+	return specs
+}
+
+// Returns the name and pattern type of the group resource in the ACLs of
+// a user. Users with a group prefix are granted access to every group
+// starting with the prefix rather than to all groups.
+func groupACLResource(groupPrefix string) (string, kadm.ACLPattern) {
+	if groupPrefix != "" {
+		return groupPrefix, kadm.ACLPatternPrefixed
+	}
+	return "*", kadm.ACLPatternLiteral
+}
+
+// Operations granted on consumer groups along with READ.
+type groupACLs int
+
+const (
+	groupACLsRead groupACLs = iota
+	groupACLsDescribe
+	// Group ACLs are managed outside TR.
+	groupACLsNone
+)
+
+func userGroupACLs(u *tt.User) groupACLs {
+	switch {
+	case u.NoGroupAcls:
+		return groupACLsNone
+	case u.DescribesGroups():
+		return groupACLsDescribe
+	default:
+		return groupACLsRead
+	}
+}
+
+// Maps permissions to the operations granted on topic and group resources.
+// DESCRIBE on groups is only granted along with READ when groups is
+// groupACLsDescribe, and no group operations are granted at all when it
+// is groupACLsNone. DESCRIBE on the topic is granted along with READ or
+// WRITE when topicDescribe is set, since clients fetch topic metadata
+// before consuming or producing.
+//
+// OFFSET_MANAGEMENT grants what offset reset tooling needs on top of READ:
+// DESCRIBE on the topic to list partition offsets, DESCRIBE on groups to
+// check that the group is inactive and DELETE on groups to delete
+// committed offsets.
+func permissionsToOperations(permissions []tt.Permission, groups groupACLs, topicDescribe bool) ([]kadm.ACLOperation, []kadm.ACLOperation) {
+	topicOps := make([]kadm.ACLOperation, 0)
+	groupOps := make([]kadm.ACLOperation, 0)
+	describeTopic := false
+	for _, permission := range permissions {
+		if permission == tt.PermissionRead {
+			topicOps = append(topicOps, kadm.OpRead)
+			groupOps = append(groupOps, kadm.OpRead)
+			if groups == groupACLsDescribe {
+				groupOps = append(groupOps, kadm.OpDescribe)
+			}
+			describeTopic = describeTopic || topicDescribe
+		}
+		if permission == tt.PermissionWrite {
+			topicOps = append(topicOps, kadm.OpWrite)
+			describeTopic = describeTopic || topicDescribe
+		}
+		if permission == tt.PermissionOffsetManagement {
+			describeTopic = true
+			if groups != groupACLsDescribe {
+				groupOps = append(groupOps, kadm.OpDescribe)
+			}
+			groupOps = append(groupOps, kadm.OpDelete)
+		}
+	}
+	if describeTopic {
+		topicOps = append(topicOps, kadm.OpDescribe)
+	}
+	if groups == groupACLsNone {
+		groupOps = groupOps[:0]
+	}
+	return topicOps, groupOps
+}
+
+// Grants the principals access to the secret. They are merged into the
+// existing secret policy so that access granted by MSK or to other
+// principals is kept.
+func (um *userManager) grantAccessToSecret(ctx context.Context, username, kmsKeyID, secretArn string, principalArns []string) error {
+	um.logger.Sugar().Infow("Start Operation", "Name", "GetResourcePolicy", "Username", username)
+	rp, err := um.secretsManagerClient.GetResourcePolicy(ctx, &secretsmanager.GetResourcePolicyInput{
+		SecretId: &secretArn,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	policy := aws.ToString(rp.ResourcePolicy)
+	for _, principalArn := range principalArns {
+		policy, err = replaceSecretPolicyPrincipal(policy, "", principalArn)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	um.logger.Sugar().Infow("Start Operation", "Name", "PutResourcePolicy", "ARN", principalArns)
+	_, err = um.secretsManagerClient.PutResourcePolicy(ctx, &secretsmanager.PutResourcePolicyInput{
+		SecretId:       &secretArn,
+		ResourcePolicy: &policy,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, principalArn := range principalArns {
+		err = um.createGrantForArn(ctx, username, kmsKeyID, principalArn)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+func (um *userManager) createGrantForArn(ctx context.Context, username, kmsKeyID, principalArn string) error {
+	um.logger.Sugar().Infow("Start Operation", "Name", "CreateGrant", "ARN", principalArn)
+	_, err := um.kmsClient.CreateGrant(ctx, &kms.CreateGrantInput{
+		Name:             &username,
+		KeyId:            &kmsKeyID,
+		GranteePrincipal: &principalArn,
+		Operations:       []types.GrantOperation{types.GrantOperationDecrypt},
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// Revokes the decrypt grants of the secret named username held by
+// principalArns. Grants are looked up by name so that no grant is created
+// to find them. Only retriable failures are returned so that a grant
+// revoked outside TR does not block clean up.
+func (um *userManager) revokeGrants(ctx context.Context, username, kmsKeyID string, principalArns []string) error {
+	if len(principalArns) == 0 {
+		return nil
+	}
+	grants, err := um.decryptGrants(ctx, kmsKeyID, username)
+	if err != nil {
+		if isRetriable(err) {
+			return errors.WithStack(err)
+		}
+		um.logger.Sugar().Errorw("Operation Failed", "Error", err)
+		return nil
+	}
+	for _, principalArn := range principalArns {
+		if len(grants[principalArn]) == 0 {
+			um.logger.Sugar().Infow("Skip Operation", "Name", "RevokeGrant", "ARN", principalArn, "Reason", "Grant not found")
+			continue
+		}
+		for _, grantID := range grants[principalArn] {
+			um.logger.Sugar().Infow("Start Operation", "Name", "RevokeGrant", "ARN", principalArn)
+			_, err = um.kmsClient.RevokeGrant(ctx, &kms.RevokeGrantInput{
+				GrantId: aws.String(grantID),
+				KeyId:   &kmsKeyID,
+			})
+			if err != nil {
+				if isRetriable(err) {
+					return errors.WithStack(err)
+				}
+				um.logger.Sugar().Errorw("Operation Failed", "Error", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Recreates the KMS grants of the principals of u that were revoked outside
+// TR. Without them the principals can no longer decrypt the secret of the
+// user even though the secret policy still allows reading it. Users
+// without a generated secret have no grants.
+func (um *userManager) ReconcileGrants(ctx context.Context, u *tt.User, kmsKeyID, shortStackID string) error {
+	principals := u.Principals()
+	if u.Secretless() || u.SecretArn != "" || len(principals) == 0 {
+		return nil
+	}
+	username := um.secretNames.Name(u.Username, shortStackID)
+	granted, err := um.decryptGrants(ctx, kmsKeyID, username)
+	if err != nil {
+		return err
+	}
+	missing := 0
+	for _, principalArn := range principals {
+		if len(granted[principalArn]) > 0 {
+			continue
+		}
+		um.logger.Sugar().Warnw("Grant Drift Detected", "Username", username, "ARN", principalArn)
+		err = um.createGrantForArn(ctx, username, kmsKeyID, principalArn)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		missing++
+	}
+	if missing > 0 {
+		um.metrics.Count("GrantDriftCorrected", missing, nil)
+	}
+	return nil
+}
+
+// Returns the IDs of the decrypt grants named name by grantee principal.
+func (um *userManager) decryptGrants(ctx context.Context, kmsKeyID, name string) (map[string][]string, error) {
+	um.logger.Sugar().Infow("Start Operation", "Name", "ListGrants", "Username", name)
+	grants := make(map[string][]string)
+	var marker *string
+	for {
+		out, err := um.kmsClient.ListGrants(ctx, &kms.ListGrantsInput{
+			KeyId:  &kmsKeyID,
+			Marker: marker,
+		})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, g := range out.Grants {
+			if aws.ToString(g.Name) != name || g.GranteePrincipal == nil || g.GrantId == nil {
+				continue
+			}
+			for _, op := range g.Operations {
+				if op == types.GrantOperationDecrypt {
+					grants[*g.GranteePrincipal] = append(grants[*g.GranteePrincipal], *g.GrantId)
+					break
+				}
+			}
+		}
+		if !out.Truncated || out.NextMarker == nil {
+			return grants, nil
+		}
+		marker = out.NextMarker
+	}
+}
+
+// Moves access to the secret of the user from the principals in oldArns to
+// the principals the user declares now. The secret is left intact so that
+// clients keep using the same credentials. Statements added to the secret
+// policy by MSK when the secret was associated are preserved.
+func (um *userManager) UpdateArn(ctx context.Context, u *tt.User, oldArns []string, kmsKeyID, shortStackID string) error {
+	username := um.secretNames.Name(u.Username, shortStackID)
+	newArns := u.Principals()
+	removed := subtractStrings(oldArns, newArns)
+	added := subtractStrings(newArns, oldArns)
+	um.logger.Sugar().Infow("Start Operation", "Name", "GetResourcePolicy", "Username", username)
+	rp, err := um.secretsManagerClient.GetResourcePolicy(ctx, &secretsmanager.GetResourcePolicyInput{
+		SecretId: &username,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	policy := aws.ToString(rp.ResourcePolicy)
+	for _, principalArn := range removed {
+		policy, err = replaceSecretPolicyPrincipal(policy, principalArn, "")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	for _, principalArn := range added {
+		policy, err = replaceSecretPolicyPrincipal(policy, "", principalArn)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if policy == "" {
+		um.logger.Sugar().Infow("Start Operation", "Name", "DeleteResourcePolicy", "Username", username)
+		_, err = um.secretsManagerClient.DeleteResourcePolicy(ctx, &secretsmanager.DeleteResourcePolicyInput{
+			SecretId: &username,
+		})
+	} else {
+		um.logger.Sugar().Infow("Start Operation", "Name", "PutResourcePolicy", "Username", username, "ARN", newArns)
+		_, err = um.secretsManagerClient.PutResourcePolicy(ctx, &secretsmanager.PutResourcePolicyInput{
+			SecretId:       &username,
+			ResourcePolicy: &policy,
+		})
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	err = um.revokeGrants(ctx, username, kmsKeyID, removed)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, principalArn := range added {
+		err = um.createGrantForArn(ctx, username, kmsKeyID, principalArn)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	opSummaryFrom(ctx).Performed("UpdateArn")
+	return nil
+}
+
+// Returns policy with oldArn removed from and newArn added to the
+// principals granted access to the secret. New principals are merged into
+// the first statement in the format of SecretPolicyTemplate so that
+// access granted to other principals is kept. Other statements are kept
+// as is. Empty ARNs only remove or only add the principal. Returns an
+// empty string when no statements remain.
+func replaceSecretPolicyPrincipal(policy, oldArn, newArn string) (string, error) {
+	doc := map[string]interface{}{"Version": "2012-10-17"}
+	if policy != "" {
+		if err := json.Unmarshal([]byte(policy), &doc); err != nil {
+			return "", errors.WithStack(err)
+		}
+	}
+	var statements []interface{}
+	switch s := doc["Statement"].(type) {
+	case []interface{}:
+		statements = s
+	case map[string]interface{}:
+		statements = []interface{}{s}
+	}
+	kept := make([]interface{}, 0, len(statements)+1)
+	granted := false
+	for _, s := range statements {
+		principals, ok := secretAccessPrincipals(s)
+		if ok && oldArn != "" {
+			principals = removeString(principals, oldArn)
+			if len(principals) == 0 {
+				continue
+			}
+			setSecretAccessPrincipals(s, principals)
+		}
+		// The policy of a secret shared by several clusters is
+		// already updated by the first one.
+		granted = granted || (ok && newArn != "" && containsString(principals, newArn))
+		kept = append(kept, s)
+	}
+	if newArn != "" && !granted {
+		merged := false
+		for _, s := range kept {
+			if principals, ok := secretAccessPrincipals(s); ok {
+				setSecretAccessPrincipals(s, append(principals, newArn))
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			kept = append(kept, map[string]interface{}{
+				"Effect":    "Allow",
+				"Principal": map[string]interface{}{"AWS": newArn},
+				"Action":    "secretsmanager:GetSecretValue",
+				"Resource":  "*",
+			})
+		}
+	}
+	if len(kept) == 0 {
+		return "", nil
+	}
+	doc["Statement"] = kept
+	buf, err := json.Marshal(doc)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(buf), nil
+}
+
+// Returns the principals granted access by statement when it is in the
+// format of SecretPolicyTemplate. Principal.AWS is either a single ARN or
+// a list of ARNs.
+func secretAccessPrincipals(statement interface{}) ([]string, bool) {
+	s, ok := statement.(map[string]interface{})
+	if !ok || s["Effect"] != "Allow" || s["Action"] != "secretsmanager:GetSecretValue" {
+		return nil, false
+	}
+	p, ok := s["Principal"].(map[string]interface{})
+	if !ok || len(p) != 1 {
+		return nil, false
+	}
+	switch v := p["AWS"].(type) {
+	case string:
+		return []string{v}, true
+	case []interface{}:
+		principals := make([]string, 0, len(v))
+		for _, e := range v {
+			arn, ok := e.(string)
+			if !ok {
+				return nil, false
+			}
+			principals = append(principals, arn)
+		}
+		return principals, true
+	}
+	return nil, false
+}
+
+// Sets the principals granted access by statement. A single principal is
+// written as a string as in SecretPolicyTemplate.
+func setSecretAccessPrincipals(statement interface{}, principals []string) {
+	s := statement.(map[string]interface{})
+	if len(principals) == 1 {
+		s["Principal"] = map[string]interface{}{"AWS": principals[0]}
+		return
+	}
+	s["Principal"] = map[string]interface{}{"AWS": principals}
+}
+
+// Deletes the topic and group ACLs of username in a single request.
+func (a *userManager) deleteACLs(ctx context.Context, topic string, pattern kadm.ACLPattern, additionalTopics, hosts []string, username string, permissions []tt.Permission, groups groupACLs, groupPrefix string, topicDescribe bool) error {
+	a.logger.Sugar().Infow("Start Operation", "Name", "DeleteKafkaACL")
+	var filters []kmsg.DeleteACLsRequestFilter
+	for _, spec := range userACLSpecs(topic, pattern, additionalTopics, hosts, username, permissions, groups, groupPrefix, topicDescribe) {
+		filters = append(filters, spec.deleteFilters()...)
+	}
+	if err := ctx.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	// Deleting is best-effort. Only retriable failures are retried
+	// and returned. Deleting ACLs is idempotent, therefore all filters
+	// are retried when any of them fails.
+	return a.aclRetry.Do(ctx, func() error {
+		rs, err := a.kafkaClient.DeleteACLFilters(ctx, filters)
+		if err != nil {
+			if kerr.IsRetriable(err) {
+				return errors.WithStack(err)
+			}
+			a.logger.Sugar().Errorw("Operation Failed", "Error", err)
+			return nil
+		}
+		var retriable error
+		for _, r := range rs {
+			if r.Err == nil {
+				continue
+			}
+			if kerr.IsRetriable(r.Err) {
+				retriable = r.Err
+				continue
+			}
+			a.logger.Sugar().Errorw("Operation Failed", "Error", r.Err, "Resource", aws.ToString(r.Name), "Operation", r.Operation)
+		}
+		return errors.WithStack(retriable)
+	})
+}
+
+// Values of TR_PASSWORD_ENCODING.
+const (
+	PasswordEncodingBase64NoPad = "base64-nopad"
+	PasswordEncodingBase64URL   = "base64url"
+	PasswordEncodingHex         = "hex"
+)
+
+// Encodings of generated passwords. All of them only produce printable
+// ASCII characters that are valid in SASL/SCRAM passwords and do not
+// need escaping in the JSON secret.
+var passwordEncodings = map[string]func([]byte) string{
+	PasswordEncodingBase64NoPad: base64.RawStdEncoding.EncodeToString,
+	PasswordEncodingBase64URL:   base64.RawURLEncoding.EncodeToString,
+	PasswordEncodingHex:         hex.EncodeToString,
+}
+
+// passwordPolicy controls passwords generated for SASL/SCRAM users.
+type passwordPolicy struct {
+	// Number of random bytes encoded into the password.
+	bytes int
+	// One of the keys of passwordEncodings.
+	encoding string
+	// Minimum estimated entropy in bits.
+	minEntropy int
+}
+
+func newPasswordPolicy(settings *Settings) passwordPolicy {
+	return passwordPolicy{
+		bytes:      settings.PasswordBytes,
+		encoding:   settings.PasswordEncoding,
+		minEntropy: settings.MinPasswordEntropyBits,
+	}
+}
+
+// Generates a random password meeting the minimum entropy configured for
+// user manager. Passwords falling short of the estimate are regenerated.
+func (a *userManager) generatePassword() (string, error) {
+	encode, ok := passwordEncodings[a.passwordPolicy.encoding]
+	if !ok {
+		return "", errors.WithStack(fmt.Errorf("unknown password encoding %q", a.passwordPolicy.encoding))
+	}
+	// Generate a third more random bits than required so that the estimate
+	// holds even when some character classes are missing in the output.
+	size := a.passwordPolicy.bytes
+	if s := (a.passwordPolicy.minEntropy + 5) / 6; s > size {
+		size = s
+	}
+	buf := make([]byte, size)
+	for attempt := 0; attempt < 10; attempt++ {
+		n, err := rand.Read(buf)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		if n != len(buf) {
+			return "", errors.New("password generation failed")
+		}
+		password := encode(buf)
+		if passwordEntropy(password) >= float64(a.passwordPolicy.minEntropy) {
+			return password, nil
+		}
+	}
+	return "", errors.WithStack(fmt.Errorf("failed to generate a password with minimum entropy of %d bits", a.passwordPolicy.minEntropy))
+}
+
+// Estimates the entropy of a password in bits based on its length and the
+// size of the character classes it uses.
+func passwordEntropy(password string) float64 {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	pool := 0
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if digit {
+		pool += 10
+	}
+	if symbol {
+		// base64 alphabets only contain two symbols (+/ or -_).
+		pool += 2
 	}
-	if n != len(buf) {
-		return "", errors.New("password generation failed")
+	if pool == 0 {
+		return 0
 	}
-	return base64.StdEncoding.WithPadding(base64.NoPadding).EncodeToString(buf), nil
+	return float64(len(password)) * math.Log2(float64(pool))
 }
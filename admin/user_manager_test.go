@@ -0,0 +1,2084 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	tt "github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/admin/mocks"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/kafka"
+	kt "github.com/aws/aws-sdk-go-v2/service/kafka/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmst "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smt "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type userManagerMocks struct {
+	secretsManagerClient *mocks.MockSecretsManagerClient
+	kmsClient            *mocks.MockKmsClient
+	mskClient            *mocks.MockMskClient
+	kafkaClient          *mocks.MockKafkaClient
+}
+
+func newTestUserManager(ctrl *gomock.Controller) (*userManager, *userManagerMocks) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+	m := &userManagerMocks{
+		secretsManagerClient: mocks.NewMockSecretsManagerClient(ctrl),
+		kmsClient:            mocks.NewMockKmsClient(ctrl),
+		mskClient:            mocks.NewMockMskClient(ctrl),
+		kafkaClient:          mocks.NewMockKafkaClient(ctrl),
+	}
+	retryPolicy := newRetryPolicy(3, time.Millisecond, time.Millisecond, 0)
+	retryPolicy.sleep = func(time.Duration) {}
+	um := newUserManager(m.secretsManagerClient, m.kmsClient, m.mskClient, m.kafkaClient, nil, newMetrics(false, nil), testSettings(), logger)
+	um.disassociateRetry = retryPolicy
+	um.associateRetry = retryPolicy
+	um.aclRetry = retryPolicy
+	um.describeRetry = retryPolicy
+	return um, m
+}
+
+func newResponseError(statusCode int, err error) error {
+	return &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: statusCode}},
+			Err:      err,
+		},
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type testCase struct {
+		name                string
+		disassociateOutputs [][]interface{}
+		listSecretsOutputs  [][]interface{}
+		retained            *retainedSecrets
+		tags                []smt.Tag
+		aclsKept            bool
+		expectDeleteSecret  bool
+		err                 string
+	}
+
+	clusterArn := "cluster"
+	secretArn := "secret"
+	shortStackID := shortStackID("test")
+	bob := &tt.User{Username: "bob", Permissions: []tt.Permission{tt.PermissionWrite}}
+	username := defaultSecretNameTemplate.Name(bob.Username, shortStackID)
+	disassociated := []interface{}{&kafka.BatchDisassociateScramSecretOutput{}, error(nil)}
+	notListed := []interface{}{&kafka.ListScramSecretsOutput{SecretArnList: []string{"other"}}, error(nil)}
+	listed := []interface{}{&kafka.ListScramSecretsOutput{SecretArnList: []string{secretArn}}, error(nil)}
+
+	cases := []testCase{
+		{
+			name:                "Confirmed disassociation then delete",
+			disassociateOutputs: [][]interface{}{disassociated},
+			listSecretsOutputs:  [][]interface{}{notListed},
+			expectDeleteSecret:  true,
+		},
+		{
+			name:                "Shared secret is disassociated but not deleted",
+			disassociateOutputs: [][]interface{}{disassociated},
+			listSecretsOutputs:  [][]interface{}{notListed},
+			retained:            retainAllSecrets(),
+		},
+		{
+			name:                "Secret of own stack is deleted",
+			disassociateOutputs: [][]interface{}{disassociated},
+			listSecretsOutputs:  [][]interface{}{notListed},
+			tags:                []smt.Tag{{Key: aws.String(TagSecretStackID), Value: aws.String("test")}},
+			expectDeleteSecret:  true,
+		},
+		{
+			name:     "Secret and ACLs of another stack are kept",
+			tags:     []smt.Tag{{Key: aws.String(TagSecretStackID), Value: aws.String("other")}},
+			aclsKept: true,
+		},
+		{
+			name:                "Disassociation confirmed after retry",
+			disassociateOutputs: [][]interface{}{disassociated, disassociated},
+			listSecretsOutputs:  [][]interface{}{listed, notListed},
+			expectDeleteSecret:  true,
+		},
+		{
+			name: "Failed disassociation preserves secret",
+			disassociateOutputs: [][]interface{}{
+				{&kafka.BatchDisassociateScramSecretOutput{UnprocessedScramSecrets: []kt.UnprocessedScramSecret{{ErrorCode: aws.String("500"), ErrorMessage: aws.String("internal error")}}}, error(nil)},
+				{&kafka.BatchDisassociateScramSecretOutput{UnprocessedScramSecrets: []kt.UnprocessedScramSecret{{ErrorCode: aws.String("500"), ErrorMessage: aws.String("internal error")}}}, error(nil)},
+				{&kafka.BatchDisassociateScramSecretOutput{UnprocessedScramSecrets: []kt.UnprocessedScramSecret{{ErrorCode: aws.String("500"), ErrorMessage: aws.String("internal error")}}}, error(nil)},
+			},
+			err: "internal error",
+		},
+		{
+			name:                "Unrecoverable disassociation error preserves secret",
+			disassociateOutputs: [][]interface{}{{(*kafka.BatchDisassociateScramSecretOutput)(nil), newResponseError(403, &kt.ForbiddenException{Message: aws.String("forbidden")})}},
+			err:                 "ForbiddenException: forbidden",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			ctx := withStackResource(context.TODO(), newStackResource("test", "Topic"))
+			if c.retained != nil {
+				ctx = withRetainedSecrets(ctx, c.retained)
+			}
+			um, m := newTestUserManager(ctrl)
+			m.secretsManagerClient.EXPECT().DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: &username}).
+				Return(&secretsmanager.DescribeSecretOutput{ARN: &secretArn, Tags: c.tags}, error(nil))
+			if !c.aclsKept {
+				m.kafkaClient.EXPECT().DeleteACLFilters(ctx, gomock.Any()).Return(kadm.DeleteACLsResults{kadm.DeleteACLsResult{}}, error(nil))
+			}
+			calls := make([]*gomock.Call, 0)
+			for i, o := range c.disassociateOutputs {
+				calls = append(calls, m.mskClient.EXPECT().BatchDisassociateScramSecret(ctx, &kafka.BatchDisassociateScramSecretInput{ClusterArn: &clusterArn, SecretArnList: []string{secretArn}}).Return(o...))
+				if i < len(c.listSecretsOutputs) {
+					calls = append(calls, m.mskClient.EXPECT().ListScramSecrets(ctx, &kafka.ListScramSecretsInput{ClusterArn: &clusterArn}).Return(c.listSecretsOutputs[i]...))
+				}
+			}
+			gomock.InOrder(calls...)
+			if c.expectDeleteSecret {
+				m.secretsManagerClient.EXPECT().DeleteSecret(ctx, &secretsmanager.DeleteSecretInput{SecretId: &username, ForceDeleteWithoutRecovery: aws.Bool(true)}).
+					Return(&secretsmanager.DeleteSecretOutput{}, error(nil))
+			}
+
+			// Act
+			err := um.DeleteUser(ctx, bob, "key", "topic", shortStackID, clusterArn)
+
+			// Assert
+			if c.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+				assert.Contains(t, err.Error(), c.err)
+			}
+		})
+	}
+}
+
+func TestDeleteUserRevokesGrantsBeforeSecret(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type testCase struct {
+		name               string
+		revokeErr          error
+		expectDeleteSecret bool
+		err                string
+	}
+
+	clusterArn := "cluster"
+	secretArn := "secret"
+	grantID := "grant"
+	shortStackID := shortStackID("test")
+	bob := &tt.User{Username: "bob", Arn: "arn:bob", Permissions: []tt.Permission{tt.PermissionWrite}}
+	username := defaultSecretNameTemplate.Name(bob.Username, shortStackID)
+	grants := []kmst.GrantListEntry{
+		{GrantId: &grantID, Name: &username, GranteePrincipal: aws.String("arn:bob"), Operations: []kmst.GrantOperation{kmst.GrantOperationDecrypt}},
+	}
+
+	cases := []testCase{
+		{
+			name:               "Grant revoked then secret deleted",
+			expectDeleteSecret: true,
+		},
+		{
+			name:      "Retriable revoke failure preserves secret",
+			revokeErr: newResponseError(500, fmt.Errorf("internal error")),
+			err:       "internal error",
+		},
+		{
+			name:               "Unrecoverable revoke failure deletes secret",
+			revokeErr:          newResponseError(400, fmt.Errorf("invalid grant")),
+			expectDeleteSecret: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			ctx := withStackResource(context.TODO(), newStackResource("test", "Topic"))
+			um, m := newTestUserManager(ctrl)
+			calls := []*gomock.Call{
+				m.secretsManagerClient.EXPECT().DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: &username}).
+					Return(&secretsmanager.DescribeSecretOutput{ARN: &secretArn}, error(nil)),
+				m.kafkaClient.EXPECT().DeleteACLFilters(ctx, gomock.Any()).Return(kadm.DeleteACLsResults{kadm.DeleteACLsResult{}}, error(nil)),
+				m.mskClient.EXPECT().BatchDisassociateScramSecret(ctx, &kafka.BatchDisassociateScramSecretInput{ClusterArn: &clusterArn, SecretArnList: []string{secretArn}}).
+					Return(&kafka.BatchDisassociateScramSecretOutput{}, error(nil)),
+				m.mskClient.EXPECT().ListScramSecrets(ctx, &kafka.ListScramSecretsInput{ClusterArn: &clusterArn}).
+					Return(&kafka.ListScramSecretsOutput{}, error(nil)),
+				m.kmsClient.EXPECT().ListGrants(ctx, &kms.ListGrantsInput{KeyId: aws.String("key")}).
+					Return(&kms.ListGrantsOutput{Grants: grants}, error(nil)),
+				m.kmsClient.EXPECT().RevokeGrant(ctx, &kms.RevokeGrantInput{GrantId: &grantID, KeyId: aws.String("key")}).
+					Return(&kms.RevokeGrantOutput{}, c.revokeErr),
+			}
+			if c.expectDeleteSecret {
+				calls = append(calls, m.secretsManagerClient.EXPECT().DeleteSecret(ctx, &secretsmanager.DeleteSecretInput{SecretId: &username, ForceDeleteWithoutRecovery: aws.Bool(true)}).
+					Return(&secretsmanager.DeleteSecretOutput{}, error(nil)))
+			}
+			gomock.InOrder(calls...)
+
+			// Act
+			err := um.DeleteUser(ctx, bob, "key", "topic", shortStackID, clusterArn)
+
+			// Assert
+			if c.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+				assert.Contains(t, err.Error(), c.err)
+			}
+		})
+	}
+}
+
+func TestDeleteUserDescribeSecretRetry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type testCase struct {
+		describeOutputs    [][]interface{}
+		expectDeleteSecret bool
+		err                string
+	}
+
+	clusterArn := "cluster"
+	secretArn := "secret"
+	shortStackID := shortStackID("test")
+	bob := &tt.User{Username: "bob", Permissions: []tt.Permission{tt.PermissionWrite}}
+	username := defaultSecretNameTemplate.Name(bob.Username, shortStackID)
+	notFound := []interface{}{(*secretsmanager.DescribeSecretOutput)(nil), &smt.ResourceNotFoundException{Message: aws.String("not found")}}
+	found := []interface{}{&secretsmanager.DescribeSecretOutput{ARN: &secretArn}, error(nil)}
+
+	cases := map[string]testCase{
+		"Visible after retry": {
+			describeOutputs:    [][]interface{}{notFound, found},
+			expectDeleteSecret: true,
+		},
+		"Not found after all attempts": {
+			describeOutputs: [][]interface{}{notFound, notFound, notFound},
+		},
+		"Other errors are not retried": {
+			describeOutputs: [][]interface{}{{(*secretsmanager.DescribeSecretOutput)(nil), newResponseError(403, &smt.InvalidRequestException{Message: aws.String("access denied")})}},
+			err:             "access denied",
+		},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			// Arrange
+			ctx := context.TODO()
+			um, m := newTestUserManager(ctrl)
+			if c.err == "" {
+				m.kafkaClient.EXPECT().DeleteACLFilters(ctx, gomock.Any()).Return(kadm.DeleteACLsResults{kadm.DeleteACLsResult{}}, error(nil))
+			}
+			calls := make([]*gomock.Call, 0)
+			for _, o := range c.describeOutputs {
+				calls = append(calls, m.secretsManagerClient.EXPECT().DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: &username}).Return(o...))
+			}
+			gomock.InOrder(calls...)
+			if c.expectDeleteSecret {
+				m.mskClient.EXPECT().BatchDisassociateScramSecret(ctx, &kafka.BatchDisassociateScramSecretInput{ClusterArn: &clusterArn, SecretArnList: []string{secretArn}}).
+					Return(&kafka.BatchDisassociateScramSecretOutput{}, error(nil))
+				m.mskClient.EXPECT().ListScramSecrets(ctx, &kafka.ListScramSecretsInput{ClusterArn: &clusterArn}).
+					Return(&kafka.ListScramSecretsOutput{}, error(nil))
+				m.secretsManagerClient.EXPECT().DeleteSecret(ctx, &secretsmanager.DeleteSecretInput{SecretId: &username, ForceDeleteWithoutRecovery: aws.Bool(true)}).
+					Return(&secretsmanager.DeleteSecretOutput{}, error(nil))
+			}
+
+			// Act
+			err := um.DeleteUser(ctx, bob, "key", "topic", shortStackID, clusterArn)
+
+			// Assert
+			if c.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorContains(t, err, c.err)
+			}
+		})
+	}
+}
+
+func TestProvisionedUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	ctx := context.TODO()
+	um, m := newTestUserManager(ctrl)
+	users := make([]tt.User, 0)
+	names := make([]string, 0)
+	for i := 0; i < 11; i++ {
+		username := fmt.Sprintf("user%d", i)
+		users = append(users, tt.User{Username: username})
+		names = append(names, defaultSecretNameTemplate.Name(username, "stack"))
+	}
+	tls := tt.User{Username: "tls", AuthType: tt.AuthTypeTLS, Principal: "CN=tls"}
+	external := tt.User{Username: "external", SecretArn: "secret"}
+	users = append(users, tls, external)
+	gomock.InOrder(
+		m.secretsManagerClient.EXPECT().ListSecrets(ctx, &secretsmanager.ListSecretsInput{Filters: []smt.Filter{{Key: smt.FilterNameStringTypeName, Values: names[:10]}}}).
+			Return(&secretsmanager.ListSecretsOutput{SecretList: []smt.SecretListEntry{{Name: &names[0]}}, NextToken: aws.String("next")}, error(nil)),
+		// Prefix matches of other names are ignored.
+		m.secretsManagerClient.EXPECT().ListSecrets(ctx, &secretsmanager.ListSecretsInput{Filters: []smt.Filter{{Key: smt.FilterNameStringTypeName, Values: names[:10]}}, NextToken: aws.String("next")}).
+			Return(&secretsmanager.ListSecretsOutput{SecretList: []smt.SecretListEntry{{Name: aws.String(names[1] + "0")}}}, error(nil)),
+		m.secretsManagerClient.EXPECT().ListSecrets(ctx, &secretsmanager.ListSecretsInput{Filters: []smt.Filter{{Key: smt.FilterNameStringTypeName, Values: names[10:]}}}).
+			Return(&secretsmanager.ListSecretsOutput{SecretList: []smt.SecretListEntry{{Name: &names[10]}}}, error(nil)),
+	)
+
+	// Act
+	provisioned, err := um.ProvisionedUsers(ctx, users, "stack")
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, []tt.User{users[0], users[10], tls, external}, provisioned)
+}
+
+func TestSecretArns(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	ctx := context.TODO()
+	um, m := newTestUserManager(ctrl)
+	alice := tt.User{Username: "alice"}
+	bob := tt.User{Username: "bob"}
+	tls := tt.User{Username: "tls", AuthType: tt.AuthTypeTLS, Principal: "CN=tls"}
+	external := tt.User{Username: "external", SecretArn: "secret-external"}
+	aliceName := defaultSecretNameTemplate.Name("alice", "stack")
+	names := []string{aliceName, defaultSecretNameTemplate.Name("bob", "stack")}
+	m.secretsManagerClient.EXPECT().ListSecrets(ctx, &secretsmanager.ListSecretsInput{Filters: []smt.Filter{{Key: smt.FilterNameStringTypeName, Values: names}}}).
+		Return(&secretsmanager.ListSecretsOutput{SecretList: []smt.SecretListEntry{{Name: &aliceName, ARN: aws.String("secret-alice")}}}, error(nil))
+
+	// Act
+	arns, err := um.SecretArns(ctx, []tt.User{alice, bob, tls, external}, "stack")
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"alice": "secret-alice", "external": "secret-external"}, arns)
+}
+
+func TestCleanupOrphanedSecrets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type testCase struct {
+		name          string
+		retained      *retainedSecrets
+		expectDeletes bool
+	}
+
+	aliceName := defaultSecretNameTemplate.Name("alice", "stack")
+	bobName := defaultSecretNameTemplate.Name("bob", "stack")
+	carolName := defaultSecretNameTemplate.Name("carol", "stack")
+	daveName := defaultSecretNameTemplate.Name("dave", "stack")
+	erinName := defaultSecretNameTemplate.Name("erin", "stack")
+	frankName := defaultSecretNameTemplate.Name("frank", "stack")
+	tags := newStackResource("stack-id", "Topic").SecretTags("a")
+	users := []tt.User{
+		{Username: "alice"},
+		{Username: "tls", AuthType: tt.AuthTypeTLS, Principal: "CN=tls"},
+		{Username: "external", SecretArn: "secret-external"},
+	}
+	filter := []smt.Filter{{Key: smt.FilterNameStringTypeName, Values: []string{"AmazonMSK_"}}}
+
+	cases := []testCase{
+		{
+			name:          "Orphans are disassociated and deleted",
+			expectDeletes: true,
+		},
+		{
+			name:     "Shared orphans are disassociated but not deleted",
+			retained: retainAllSecrets(),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			ctx := withStackResource(context.TODO(), newStackResource("stack-id", "Topic"))
+			if c.retained != nil {
+				ctx = withRetainedSecrets(ctx, c.retained)
+			}
+			um, m := newTestUserManager(ctrl)
+			m.secretsManagerClient.EXPECT().ListSecrets(ctx, &secretsmanager.ListSecretsInput{Filters: filter}).
+				Return(&secretsmanager.ListSecretsOutput{SecretList: []smt.SecretListEntry{
+					{Name: &aliceName, ARN: aws.String("secret-alice"), Tags: tags},
+					{Name: &bobName, ARN: aws.String("secret-bob"), Tags: tags},
+				}, NextToken: aws.String("next")}, error(nil))
+			// Secrets of other stacks are kept, whether told apart by name
+			// or by tags. So are secrets of other resources of the stack
+			// and untagged secrets.
+			m.secretsManagerClient.EXPECT().ListSecrets(ctx, &secretsmanager.ListSecretsInput{Filters: filter, NextToken: aws.String("next")}).
+				Return(&secretsmanager.ListSecretsOutput{SecretList: []smt.SecretListEntry{
+					{Name: aws.String(defaultSecretNameTemplate.Name("bob", "other")), ARN: aws.String("secret-other"), Tags: tags},
+					{Name: &carolName, ARN: aws.String("secret-carol"), Tags: tags},
+					{Name: &daveName, ARN: aws.String("secret-dave"), Tags: newStackResource("other-stack-id", "Topic").SecretTags("a")},
+					{Name: &erinName, ARN: aws.String("secret-erin"), Tags: newStackResource("stack-id", "Sibling").SecretTags("b")},
+					{Name: &frankName, ARN: aws.String("secret-frank")},
+				}}, error(nil))
+			for _, arn := range []string{"secret-bob", "secret-carol"} {
+				m.mskClient.EXPECT().BatchDisassociateScramSecret(ctx, &kafka.BatchDisassociateScramSecretInput{ClusterArn: aws.String("cluster"), SecretArnList: []string{arn}}).
+					Return(&kafka.BatchDisassociateScramSecretOutput{}, error(nil))
+				m.mskClient.EXPECT().ListScramSecrets(ctx, gomock.Any()).
+					Return(&kafka.ListScramSecretsOutput{}, error(nil))
+				if c.expectDeletes {
+					m.secretsManagerClient.EXPECT().DeleteSecret(ctx, &secretsmanager.DeleteSecretInput{SecretId: aws.String(arn), ForceDeleteWithoutRecovery: aws.Bool(true)}).
+						Return(&secretsmanager.DeleteSecretOutput{}, error(nil))
+				}
+			}
+
+			// Act
+			err := um.CleanupOrphanedSecrets(ctx, users, "stack", "cluster")
+
+			// Assert
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestAssociateSecret(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type testCase struct {
+		name             string
+		associateOutputs [][]interface{}
+		err              string
+	}
+
+	clusterArn := "cluster"
+	secretArn := "secret"
+	associated := []interface{}{&kafka.BatchAssociateScramSecretOutput{}, error(nil)}
+	throttled := []interface{}{(*kafka.BatchAssociateScramSecretOutput)(nil), newResponseError(429, &kt.TooManyRequestsException{Message: aws.String("too many requests")})}
+	unavailable := []interface{}{(*kafka.BatchAssociateScramSecretOutput)(nil), newResponseError(503, &kt.ServiceUnavailableException{Message: aws.String("unavailable")})}
+
+	cases := []testCase{
+		{
+			name:             "Associated",
+			associateOutputs: [][]interface{}{associated},
+		},
+		{
+			name:             "Associated after throttling",
+			associateOutputs: [][]interface{}{throttled, unavailable, associated},
+		},
+		{
+			name:             "Retries exhausted",
+			associateOutputs: [][]interface{}{throttled, throttled, throttled},
+			err:              "TooManyRequestsException: too many requests",
+		},
+		{
+			name:             "Unrecoverable error is not retried",
+			associateOutputs: [][]interface{}{{(*kafka.BatchAssociateScramSecretOutput)(nil), newResponseError(403, &kt.ForbiddenException{Message: aws.String("forbidden")})}},
+			err:              "ForbiddenException: forbidden",
+		},
+		{
+			name: "Unprocessed secret is not retried",
+			associateOutputs: [][]interface{}{
+				{&kafka.BatchAssociateScramSecretOutput{UnprocessedScramSecrets: []kt.UnprocessedScramSecret{{SecretArn: &secretArn, ErrorCode: aws.String("400"), ErrorMessage: aws.String("invalid secret")}}}, error(nil)},
+			},
+			err: "failed to associate secret secret: 400 invalid secret",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			ctx := context.TODO()
+			um, m := newTestUserManager(ctrl)
+			calls := make([]*gomock.Call, 0)
+			for _, o := range c.associateOutputs {
+				calls = append(calls, m.mskClient.EXPECT().BatchAssociateScramSecret(ctx, &kafka.BatchAssociateScramSecretInput{ClusterArn: &clusterArn, SecretArnList: []string{secretArn}}).Return(o...))
+			}
+			gomock.InOrder(calls...)
+
+			// Act
+			err := um.AssociateSecrets(ctx, clusterArn, []string{secretArn})
+
+			// Assert
+			if c.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+				assert.Contains(t, err.Error(), c.err)
+			}
+		})
+	}
+}
+
+func TestAssociateSecrets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	summary := newOpSummary()
+	ctx := withOpSummary(context.TODO(), summary)
+	clusterArn := "cluster"
+	secretArns := make([]string, 12)
+	for i := range secretArns {
+		secretArns[i] = fmt.Sprintf("secret-%d", i)
+	}
+	um, m := newTestUserManager(ctrl)
+	gomock.InOrder(
+		m.mskClient.EXPECT().BatchAssociateScramSecret(ctx, &kafka.BatchAssociateScramSecretInput{ClusterArn: &clusterArn, SecretArnList: secretArns[:10]}).Return(&kafka.BatchAssociateScramSecretOutput{
+			UnprocessedScramSecrets: []kt.UnprocessedScramSecret{{SecretArn: &secretArns[3], ErrorCode: aws.String("400"), ErrorMessage: aws.String("The provided secret is already associated with this cluster. To update the association, first disassociate the secret.")}},
+		}, error(nil)),
+		m.mskClient.EXPECT().BatchAssociateScramSecret(ctx, &kafka.BatchAssociateScramSecretInput{ClusterArn: &clusterArn, SecretArnList: secretArns[10:]}).Return(&kafka.BatchAssociateScramSecretOutput{}, error(nil)),
+	)
+
+	// Act
+	err := um.AssociateSecrets(ctx, clusterArn, secretArns)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"AssociateSecret": 11}, summary.performed)
+	assert.Equal(t, map[string]int{"AssociateSecret": 1}, summary.skipped)
+}
+
+func TestUserManagerStrictExistence(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clusterArn := "cluster"
+	secretArn := "secret"
+	username := defaultSecretNameTemplate.Name("alice", "stack")
+	principal := "User:" + username
+	alice := &tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}
+	lenient := newExistencePolicy(false, "request")
+	strict := newExistencePolicy(true, "request")
+	retry := newExistencePolicy(true, "request")
+	retry.Observe(&tt.TopicMarker{RequestID: "request"})
+	exists := func(kind, name string) string {
+		return kind + " " + name + " already exists and was not created by this request. Remove it or disable StrictExistence to adopt it."
+	}
+
+	cases := map[string]struct {
+		policy   *existencePolicy
+		rejected bool
+	}{
+		"Lenient":         {policy: lenient},
+		"Strict":          {policy: strict, rejected: true},
+		"Strict on retry": {policy: retry},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx := withExistencePolicy(context.TODO(), c.policy)
+
+			t.Run("Secret", func(t *testing.T) {
+				// Arrange
+				um, m := newTestUserManager(ctrl)
+				m.secretsManagerClient.EXPECT().CreateSecret(ctx, gomock.Any()).Return(nil, &smt.ResourceExistsException{Message: aws.String("exists")})
+				m.secretsManagerClient.EXPECT().DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: &username}).Return(&secretsmanager.DescribeSecretOutput{ARN: &secretArn}, error(nil))
+
+				// Act
+				arn, err := um.createSecret(ctx, username, "key", "topic", alice)
+
+				// Assert
+				if !c.rejected {
+					assert.Nil(t, err)
+					assert.Equal(t, secretArn, arn)
+				} else {
+					assert.EqualError(t, err, exists("secret", username))
+				}
+			})
+
+			t.Run("Secret association", func(t *testing.T) {
+				// Arrange
+				um, m := newTestUserManager(ctrl)
+				m.mskClient.EXPECT().BatchAssociateScramSecret(ctx, gomock.Any()).Return(&kafka.BatchAssociateScramSecretOutput{UnprocessedScramSecrets: []kt.UnprocessedScramSecret{{
+					SecretArn:    &secretArn,
+					ErrorCode:    aws.String("400"),
+					ErrorMessage: aws.String("The provided secret is already associated with this cluster. To update the association, first disassociate the secret."),
+				}}}, error(nil))
+
+				// Act
+				err := um.AssociateSecrets(ctx, clusterArn, []string{secretArn})
+
+				// Assert
+				if !c.rejected {
+					assert.Nil(t, err)
+				} else {
+					assert.EqualError(t, err, exists("secret association", secretArn))
+				}
+			})
+
+			t.Run("ACL", func(t *testing.T) {
+				// Arrange
+				um, m := newTestUserManager(ctrl)
+				if c.policy.Strict() {
+					m.kafkaClient.EXPECT().DescribeACLs(ctx, gomock.Any()).Return(kadm.DescribeACLsResults{{Described: kadm.DescribedACLs{
+						{Principal: principal, Host: "*", Type: kmsg.ACLResourceTypeTopic, Name: "topic", Pattern: kadm.ACLPatternLiteral, Operation: kadm.OpWrite, Permission: kmsg.ACLPermissionTypeAllow},
+					}}}, error(nil))
+				}
+				if !c.rejected {
+					m.kafkaClient.EXPECT().CreateACLs(ctx, gomock.Any()).Return(kadm.CreateACLsResults{{Principal: principal, Operation: kadm.OpWrite}}, error(nil))
+				}
+
+				// Act
+				err := um.createACLs(ctx, "topic", kadm.ACLPatternLiteral, nil, []string{"*"}, username, []tt.Permission{tt.PermissionWrite}, groupACLsDescribe, "", true)
+
+				// Assert
+				if !c.rejected {
+					assert.Nil(t, err)
+				} else {
+					assert.EqualError(t, err, exists("ACL", principal+" WRITE on topic"))
+				}
+			})
+		})
+	}
+}
+
+func TestCreateUserSharedAcrossTopics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	clusterArn := "cluster"
+	secretArn := "secret"
+	username := defaultSecretNameTemplate.Name("alice", "stack")
+	principal := "User:" + username
+	alice := &tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}
+	// Both topics are declared in the same template with StrictExistence.
+	ctxA := withExistencePolicy(withStackResource(context.TODO(), newStackResource("test", "TopicA")), newExistencePolicy(true, "request"))
+	ctxB := withExistencePolicy(withStackResource(context.TODO(), newStackResource("test", "TopicB")), newExistencePolicy(true, "request"))
+	tags := newStackResource("test", "TopicA").SecretTags("a")
+	groupACL := kadm.DescribedACL{Principal: principal, Host: "*", Type: kmsg.ACLResourceTypeGroup, Name: "*", Pattern: kadm.ACLPatternLiteral, Operation: kadm.OpRead, Permission: kmsg.ACLPermissionTypeAllow}
+	um, m := newTestUserManager(ctrl)
+
+	gomock.InOrder(
+		m.secretsManagerClient.EXPECT().CreateSecret(ctxA, gomock.Any()).Return(&secretsmanager.CreateSecretOutput{ARN: &secretArn}, error(nil)),
+		m.mskClient.EXPECT().BatchAssociateScramSecret(ctxA, &kafka.BatchAssociateScramSecretInput{ClusterArn: &clusterArn, SecretArnList: []string{secretArn}}).
+			Return(&kafka.BatchAssociateScramSecretOutput{}, error(nil)),
+		m.kafkaClient.EXPECT().DescribeACLs(ctxA, gomock.Any()).Return(kadm.DescribeACLsResults{{}}, error(nil)),
+		m.kafkaClient.EXPECT().CreateACLs(ctxA, gomock.Any()).Return(kadm.CreateACLsResults{{Principal: principal}}, error(nil)),
+		m.kafkaClient.EXPECT().DescribeACLs(ctxA, gomock.Any()).Return(kadm.DescribeACLsResults{{}}, error(nil)),
+		m.kafkaClient.EXPECT().CreateACLs(ctxA, gomock.Any()).Return(kadm.CreateACLsResults{{Principal: principal}}, error(nil)),
+		m.secretsManagerClient.EXPECT().CreateSecret(ctxB, gomock.Any()).Return(nil, &smt.ResourceExistsException{Message: aws.String("exists")}),
+		m.secretsManagerClient.EXPECT().DescribeSecret(ctxB, &secretsmanager.DescribeSecretInput{SecretId: &username}).
+			Return(&secretsmanager.DescribeSecretOutput{ARN: &secretArn, Tags: tags}, error(nil)),
+		m.mskClient.EXPECT().BatchAssociateScramSecret(ctxB, &kafka.BatchAssociateScramSecretInput{ClusterArn: &clusterArn, SecretArnList: []string{secretArn}}).
+			Return(&kafka.BatchAssociateScramSecretOutput{UnprocessedScramSecrets: []kt.UnprocessedScramSecret{{
+				SecretArn:    &secretArn,
+				ErrorCode:    aws.String("400"),
+				ErrorMessage: aws.String("The provided secret is already associated with this cluster. To update the association, first disassociate the secret."),
+			}}}, error(nil)),
+		m.kafkaClient.EXPECT().DescribeACLs(ctxB, gomock.Any()).Return(kadm.DescribeACLsResults{{}}, error(nil)),
+		m.kafkaClient.EXPECT().CreateACLs(ctxB, gomock.Any()).Return(kadm.CreateACLsResults{{Principal: principal}}, error(nil)),
+		// Group ACLs of alice were created along with topic a.
+		m.kafkaClient.EXPECT().DescribeACLs(ctxB, gomock.Any()).Return(kadm.DescribeACLsResults{{Described: kadm.DescribedACLs{groupACL}}}, error(nil)),
+		m.kafkaClient.EXPECT().CreateACLs(ctxB, gomock.Any()).Return(kadm.CreateACLsResults{{Principal: principal}}, error(nil)),
+		// Deleting the user of topic b keeps the secret topic a uses.
+		m.secretsManagerClient.EXPECT().DescribeSecret(ctxB, &secretsmanager.DescribeSecretInput{SecretId: &username}).
+			Return(&secretsmanager.DescribeSecretOutput{ARN: &secretArn, Tags: tags}, error(nil)),
+		m.kafkaClient.EXPECT().DeleteACLFilters(ctxB, gomock.Any()).Return(kadm.DeleteACLsResults{{}}, error(nil)),
+	)
+
+	// Act
+	err := um.CreateUser(ctxA, "stack", "a", "key", clusterArn, alice)
+	assert.Nil(t, err)
+	err = um.CreateUser(ctxB, "stack", "b", "key", clusterArn, alice)
+	assert.Nil(t, err)
+	err = um.DeleteUser(ctxB, alice, "key", "b", "stack", clusterArn)
+
+	// Assert
+	assert.Nil(t, err)
+}
+
+func TestUpdateArn(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	ctx := context.TODO()
+	um, m := newTestUserManager(ctrl)
+	username := defaultSecretNameTemplate.Name("bob", "stack")
+	bob := &tt.User{Username: "bob", Arn: "arn:new", Permissions: []tt.Permission{tt.PermissionRead}}
+	// MSK adds its own statement when the secret is associated.
+	policy := `{"Version":"2012-10-17","Statement":[
+		{"Effect":"Allow","Principal":{"AWS":"arn:old"},"Action":"secretsmanager:GetSecretValue","Resource":"*"},
+		{"Sid":"AWSKafkaResourcePolicy","Effect":"Allow","Principal":{"Service":"kafka.amazonaws.com"},"Action":"secretsmanager:getSecretValue","Resource":"*"}
+	]}`
+	grantID := "grant"
+	m.secretsManagerClient.EXPECT().GetResourcePolicy(ctx, &secretsmanager.GetResourcePolicyInput{SecretId: &username}).Return(&secretsmanager.GetResourcePolicyOutput{ResourcePolicy: &policy}, error(nil))
+	m.secretsManagerClient.EXPECT().PutResourcePolicy(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, in *secretsmanager.PutResourcePolicyInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.PutResourcePolicyOutput, error) {
+		assert.Equal(t, username, *in.SecretId)
+		assert.JSONEq(t, `{"Version":"2012-10-17","Statement":[
+			{"Sid":"AWSKafkaResourcePolicy","Effect":"Allow","Principal":{"Service":"kafka.amazonaws.com"},"Action":"secretsmanager:getSecretValue","Resource":"*"},
+			{"Effect":"Allow","Principal":{"AWS":"arn:new"},"Action":"secretsmanager:GetSecretValue","Resource":"*"}
+		]}`, *in.ResourcePolicy)
+		return &secretsmanager.PutResourcePolicyOutput{}, nil
+	})
+	// Only the grant of the removed ARN is revoked.
+	grants := []kmst.GrantListEntry{
+		{GrantId: aws.String("other"), Name: &username, GranteePrincipal: aws.String("arn:other"), Operations: []kmst.GrantOperation{kmst.GrantOperationDecrypt}},
+		{GrantId: &grantID, Name: &username, GranteePrincipal: aws.String("arn:old"), Operations: []kmst.GrantOperation{kmst.GrantOperationDecrypt}},
+	}
+	gomock.InOrder(
+		m.kmsClient.EXPECT().ListGrants(ctx, &kms.ListGrantsInput{KeyId: aws.String("key")}).Return(&kms.ListGrantsOutput{Grants: grants}, error(nil)),
+		m.kmsClient.EXPECT().RevokeGrant(ctx, &kms.RevokeGrantInput{GrantId: &grantID, KeyId: aws.String("key")}).Return(&kms.RevokeGrantOutput{}, error(nil)),
+		m.kmsClient.EXPECT().CreateGrant(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, in *kms.CreateGrantInput, _ ...func(*kms.Options)) (*kms.CreateGrantOutput, error) {
+			assert.Equal(t, "arn:new", *in.GranteePrincipal)
+			return &kms.CreateGrantOutput{GrantId: &grantID}, nil
+		}),
+	)
+
+	// Act
+	err := um.UpdateArn(ctx, bob, []string{"arn:old"}, "key", "stack")
+
+	// Assert
+	assert.Nil(t, err)
+}
+
+func TestUpdateArnsAdded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	ctx := context.TODO()
+	um, m := newTestUserManager(ctrl)
+	username := defaultSecretNameTemplate.Name("bob", "stack")
+	bob := &tt.User{Username: "bob", Arn: "arn:a", Arns: []string{"arn:b"}, Permissions: []tt.Permission{tt.PermissionRead}}
+	policy := fmt.Sprintf(SecretPolicyTemplate, "arn:a")
+	m.secretsManagerClient.EXPECT().GetResourcePolicy(ctx, &secretsmanager.GetResourcePolicyInput{SecretId: &username}).Return(&secretsmanager.GetResourcePolicyOutput{ResourcePolicy: &policy}, error(nil))
+	m.secretsManagerClient.EXPECT().PutResourcePolicy(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, in *secretsmanager.PutResourcePolicyInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.PutResourcePolicyOutput, error) {
+		assert.JSONEq(t, `{"Version":"2012-10-17","Statement":[
+			{"Effect":"Allow","Principal":{"AWS":["arn:a","arn:b"]},"Action":"secretsmanager:GetSecretValue","Resource":"*"}
+		]}`, *in.ResourcePolicy)
+		return &secretsmanager.PutResourcePolicyOutput{}, nil
+	})
+	// Access of the unchanged ARN is kept, only the added ARN is granted.
+	m.kmsClient.EXPECT().CreateGrant(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, in *kms.CreateGrantInput, _ ...func(*kms.Options)) (*kms.CreateGrantOutput, error) {
+		assert.Equal(t, "arn:b", *in.GranteePrincipal)
+		return &kms.CreateGrantOutput{}, nil
+	})
+
+	// Act
+	err := um.UpdateArn(ctx, bob, []string{"arn:a"}, "key", "stack")
+
+	// Assert
+	assert.Nil(t, err)
+}
+
+func TestReplaceSecretPolicyPrincipal(t *testing.T) {
+	granted := fmt.Sprintf(SecretPolicyTemplate, "arn:old")
+
+	// Removing the only statement leaves no policy.
+	policy, err := replaceSecretPolicyPrincipal(granted, "arn:old", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "", policy)
+
+	// Adding an ARN to a secret without a policy.
+	policy, err = replaceSecretPolicyPrincipal("", "", "arn:new")
+	assert.Nil(t, err)
+	assert.JSONEq(t, fmt.Sprintf(SecretPolicyTemplate, "arn:new"), policy)
+
+	// Access granted to other principals is kept and the new ARN is
+	// merged into their statement.
+	policy, err = replaceSecretPolicyPrincipal(fmt.Sprintf(SecretPolicyTemplate, "arn:other"), "arn:old", "arn:new")
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"Version":"2012-10-17","Statement":[
+		{"Effect":"Allow","Principal":{"AWS":["arn:other","arn:new"]},"Action":"secretsmanager:GetSecretValue","Resource":"*"}
+	]}`, policy)
+
+	// Removing an ARN from a merged statement keeps the other ARNs.
+	policy, err = replaceSecretPolicyPrincipal(policy, "arn:other", "")
+	assert.Nil(t, err)
+	assert.JSONEq(t, fmt.Sprintf(SecretPolicyTemplate, "arn:new"), policy)
+
+	// Statements added by MSK are kept as is.
+	msk := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"kafka.amazonaws.com"},"Action":"secretsmanager:GetSecretValue","Resource":"*"}]}`
+	policy, err = replaceSecretPolicyPrincipal(msk, "", "arn:new")
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"Version":"2012-10-17","Statement":[
+		{"Effect":"Allow","Principal":{"Service":"kafka.amazonaws.com"},"Action":"secretsmanager:GetSecretValue","Resource":"*"},
+		{"Effect":"Allow","Principal":{"AWS":"arn:new"},"Action":"secretsmanager:GetSecretValue","Resource":"*"}
+	]}`, policy)
+
+	// Access already moved to the new ARN is not granted twice.
+	policy, err = replaceSecretPolicyPrincipal(fmt.Sprintf(SecretPolicyTemplate, "arn:new"), "arn:old", "arn:new")
+	assert.Nil(t, err)
+	assert.JSONEq(t, fmt.Sprintf(SecretPolicyTemplate, "arn:new"), policy)
+
+	_, err = replaceSecretPolicyPrincipal("{", "arn:old", "arn:new")
+	assert.NotNil(t, err)
+}
+
+func TestInitGroupOffsets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type testCase struct {
+		name      string
+		offset    tt.GroupOffset
+		committed kadm.OffsetResponses
+		expected  kadm.Offsets
+	}
+
+	topic := "topic"
+	start := kadm.ListedOffsets{topic: {0: {Topic: topic, Partition: 0, Offset: 5}, 1: {Topic: topic, Partition: 1, Offset: 7}}}
+	end := kadm.ListedOffsets{topic: {0: {Topic: topic, Partition: 0, Offset: 42}, 1: {Topic: topic, Partition: 1, Offset: 64}}}
+
+	cases := []testCase{
+		{
+			name:      "Earliest",
+			offset:    tt.GroupOffsetEarliest,
+			committed: kadm.OffsetResponses{},
+			expected:  start.Offsets(),
+		},
+		{
+			name:      "Latest",
+			offset:    tt.GroupOffsetLatest,
+			committed: kadm.OffsetResponses{},
+			expected:  end.Offsets(),
+		},
+		{
+			name:      "Existing offsets are preserved",
+			offset:    tt.GroupOffsetLatest,
+			committed: kadm.OffsetResponses{topic: {0: {Offset: kadm.Offset{Topic: topic, Partition: 0, At: 3}}}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			ctx := context.TODO()
+			um, m := newTestUserManager(ctrl)
+			u := &tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}, GroupName: "group", InitialGroupOffset: c.offset}
+			m.kafkaClient.EXPECT().FetchOffsets(ctx, u.GroupName).Return(c.committed, error(nil))
+			if c.expected != nil {
+				m.kafkaClient.EXPECT().ListStartOffsets(ctx, topic).Return(start, error(nil)).MaxTimes(1)
+				m.kafkaClient.EXPECT().ListEndOffsets(ctx, topic).Return(end, error(nil)).MaxTimes(1)
+				m.kafkaClient.EXPECT().CommitOffsets(ctx, u.GroupName, c.expected).Return(kadm.OffsetResponses{}, error(nil))
+			}
+
+			// Act
+			err := um.initGroupOffsets(ctx, topic, u)
+
+			// Assert
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestGeneratePassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, bits := range []int{0, 64, 128, 256} {
+		um, _ := newTestUserManager(ctrl)
+		um.passwordPolicy.minEntropy = bits
+		for i := 0; i < 100; i++ {
+			password, err := um.generatePassword()
+			assert.Nil(t, err)
+			assert.GreaterOrEqual(t, passwordEntropy(password), float64(bits))
+		}
+	}
+}
+
+func TestGeneratePasswordEncodings(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	decoders := map[string]func(string) ([]byte, error){
+		PasswordEncodingBase64NoPad: base64.RawStdEncoding.DecodeString,
+		PasswordEncodingBase64URL:   base64.RawURLEncoding.DecodeString,
+		PasswordEncodingHex:         hex.DecodeString,
+	}
+	for encoding, decode := range decoders {
+		for _, size := range []int{9, 16, 32} {
+			um, _ := newTestUserManager(ctrl)
+			um.passwordPolicy = passwordPolicy{bytes: size, encoding: encoding}
+			password, err := um.generatePassword()
+			assert.Nil(t, err)
+			buf, err := decode(password)
+			assert.Nil(t, err, encoding)
+			assert.Len(t, buf, size, encoding)
+			// Passwords are embedded in the secret JSON as is.
+			assert.NotContains(t, password, "\"")
+			assert.NotContains(t, password, "\\")
+		}
+	}
+
+	um, _ := newTestUserManager(ctrl)
+	um.passwordPolicy.encoding = "base32"
+	_, err := um.generatePassword()
+	assert.EqualError(t, err, "unknown password encoding \"base32\"")
+}
+
+func TestPasswordEntropy(t *testing.T) {
+	cases := map[string]struct {
+		password string
+		entropy  float64
+	}{
+		"Empty":        {password: "", entropy: 0},
+		"Lower only":   {password: "abcd", entropy: 4 * math.Log2(26)},
+		"Mixed case":   {password: "aBcD", entropy: 4 * math.Log2(52)},
+		"Alphanumeric": {password: "aB1D", entropy: 4 * math.Log2(62)},
+		"Base64":       {password: "aB1+", entropy: 4 * math.Log2(64)},
+	}
+
+	for k, c := range cases {
+		assert.InDelta(t, c.entropy, passwordEntropy(c.password), 0.0001, k)
+	}
+}
+
+func TestCreateUserWithExternalSecret(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type testCase struct {
+		name         string
+		secretString string
+		err          string
+	}
+
+	clusterArn := "cluster"
+	secretArn := "arn:aws:secretsmanager:ap-southeast-2:111222333444:secret:AmazonMSK_alice"
+	alice := &tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}, SecretArn: secretArn}
+
+	cases := []testCase{
+		{
+			name:         "Valid secret",
+			secretString: `{"username":"alice","password":"secret"}`,
+		},
+		{
+			name:         "Missing password",
+			secretString: `{"username":"alice"}`,
+			err:          "secret " + secretArn + " must contain a JSON object with username and password",
+		},
+		{
+			name:         "Not JSON",
+			secretString: "alice:secret",
+			err:          "secret " + secretArn + " must contain a JSON object with username and password",
+		},
+		{
+			name:         "Username mismatch",
+			secretString: `{"username":"bob","password":"secret"}`,
+			err:          "secret " + secretArn + " contains username bob but user is declared as alice",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			ctx := context.TODO()
+			um, m := newTestUserManager(ctrl)
+			m.secretsManagerClient.EXPECT().GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: &secretArn}).
+				Return(&secretsmanager.GetSecretValueOutput{SecretString: aws.String(c.secretString)}, error(nil))
+			if c.err == "" {
+				m.mskClient.EXPECT().BatchAssociateScramSecret(ctx, &kafka.BatchAssociateScramSecretInput{ClusterArn: &clusterArn, SecretArnList: []string{secretArn}}).
+					Return(&kafka.BatchAssociateScramSecretOutput{}, error(nil))
+				m.kafkaClient.EXPECT().CreateACLs(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, b *kadm.ACLBuilder) (kadm.CreateACLsResults, error) {
+					return kadm.CreateACLsResults{{Principal: "User:alice"}}, nil
+				}).Times(2)
+			}
+
+			// Act
+			err := um.CreateUser(ctx, "stack", "topic", "key", clusterArn, alice)
+
+			// Assert
+			if c.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, c.err)
+			}
+		})
+	}
+	assert.Equal(t, "alice", principalName(alice, "stack", defaultSecretNameTemplate))
+}
+
+func TestCreateUserSecretCreateDelay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clusterArn := "arn:aws:kafka:us-east-1:123456789012:cluster/c/uuid"
+	secretArn := "arn:aws:secretsmanager:us-east-1:123456789012:secret:AmazonMSK_alice_stack"
+	alice := &tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}
+
+	// Arrange
+	ctx := context.TODO()
+	um, m := newTestUserManager(ctrl)
+	delays := 0
+	um.secretCreateDelay = func() { delays++ }
+	m.secretsManagerClient.EXPECT().CreateSecret(ctx, gomock.Any()).Return(&secretsmanager.CreateSecretOutput{ARN: &secretArn}, error(nil))
+	m.mskClient.EXPECT().BatchAssociateScramSecret(ctx, &kafka.BatchAssociateScramSecretInput{ClusterArn: &clusterArn, SecretArnList: []string{secretArn}}).
+		DoAndReturn(func(context.Context, *kafka.BatchAssociateScramSecretInput, ...func(*kafka.Options)) (*kafka.BatchAssociateScramSecretOutput, error) {
+			assert.Equal(t, 1, delays)
+			return &kafka.BatchAssociateScramSecretOutput{}, nil
+		})
+	m.kafkaClient.EXPECT().CreateACLs(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, b *kadm.ACLBuilder) (kadm.CreateACLsResults, error) {
+		return kadm.CreateACLsResults{{Principal: "User:AmazonMSK_alice_stack"}}, nil
+	}).Times(2)
+
+	// Act
+	err := um.CreateUser(ctx, "stack", "topic", "key", clusterArn, alice)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, 1, delays)
+}
+
+func TestCreateUserSecretNameTemplate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	ctx := context.TODO()
+	clusterArn := "arn:aws:kafka:us-east-1:123456789012:cluster/c/uuid"
+	secretArn := "arn:aws:secretsmanager:us-east-1:123456789012:secret:AmazonMSK_team-a_alice_stack"
+	alice := &tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}
+	um, m := newTestUserManager(ctrl)
+	um.secretNames = "AmazonMSK_team-a_{username}_{suffix}"
+	m.secretsManagerClient.EXPECT().CreateSecret(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, in *secretsmanager.CreateSecretInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error) {
+		var credentials struct{ Username string }
+		assert.Nil(t, json.Unmarshal([]byte(*in.SecretString), &credentials))
+		assert.Equal(t, "AmazonMSK_team-a_alice_stack", *in.Name)
+		assert.Equal(t, "AmazonMSK_team-a_alice_stack", credentials.Username)
+		return &secretsmanager.CreateSecretOutput{ARN: &secretArn}, nil
+	})
+	m.mskClient.EXPECT().BatchAssociateScramSecret(ctx, gomock.Any()).Return(&kafka.BatchAssociateScramSecretOutput{}, error(nil))
+	m.kafkaClient.EXPECT().CreateACLs(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, b *kadm.ACLBuilder) (kadm.CreateACLsResults, error) {
+		return kadm.CreateACLsResults{{Principal: "User:AmazonMSK_team-a_alice_stack"}}, nil
+	}).Times(2)
+
+	// Act
+	err := um.CreateUser(ctx, "stack", "topic", "key", clusterArn, alice)
+
+	// Assert
+	assert.Nil(t, err)
+}
+
+func TestCreateUserMultipleArns(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	ctx := context.TODO()
+	secretArn := "secret-alice"
+	alice := &tt.User{Username: "alice", Arn: "arn:a", Arns: []string{"arn:b", "arn:a"}, Permissions: []tt.Permission{tt.PermissionWrite}}
+	// The secret already exists with access granted to another principal.
+	policy := fmt.Sprintf(SecretPolicyTemplate, "arn:other")
+	um, m := newTestUserManager(ctrl)
+	m.secretsManagerClient.EXPECT().CreateSecret(ctx, gomock.Any()).Return(&secretsmanager.CreateSecretOutput{ARN: &secretArn}, error(nil))
+	m.secretsManagerClient.EXPECT().GetResourcePolicy(ctx, &secretsmanager.GetResourcePolicyInput{SecretId: &secretArn}).Return(&secretsmanager.GetResourcePolicyOutput{ResourcePolicy: &policy}, error(nil))
+	m.secretsManagerClient.EXPECT().PutResourcePolicy(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, in *secretsmanager.PutResourcePolicyInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.PutResourcePolicyOutput, error) {
+		assert.Equal(t, secretArn, *in.SecretId)
+		assert.JSONEq(t, `{"Version":"2012-10-17","Statement":[
+			{"Effect":"Allow","Principal":{"AWS":["arn:other","arn:a","arn:b"]},"Action":"secretsmanager:GetSecretValue","Resource":"*"}
+		]}`, *in.ResourcePolicy)
+		return &secretsmanager.PutResourcePolicyOutput{}, nil
+	})
+	grantees := make([]string, 0)
+	m.kmsClient.EXPECT().CreateGrant(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, in *kms.CreateGrantInput, _ ...func(*kms.Options)) (*kms.CreateGrantOutput, error) {
+		grantees = append(grantees, *in.GranteePrincipal)
+		return &kms.CreateGrantOutput{}, nil
+	}).Times(2)
+	m.mskClient.EXPECT().BatchAssociateScramSecret(ctx, gomock.Any()).Return(&kafka.BatchAssociateScramSecretOutput{}, error(nil))
+	m.kafkaClient.EXPECT().CreateACLs(ctx, gomock.Any()).Return(kadm.CreateACLsResults{{Principal: "User:" + defaultSecretNameTemplate.Name("alice", "stack")}}, error(nil))
+
+	// Act
+	err := um.CreateUser(ctx, "stack", "topic", "key", "cluster", alice)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"arn:a", "arn:b"}, grantees)
+}
+
+func TestCreateUserDoesNotLogPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clusterArn := "arn:aws:kafka:us-east-1:123456789012:cluster/c/uuid"
+	secretArn := "arn:aws:secretsmanager:us-east-1:123456789012:secret:AmazonMSK_alice_stack"
+	alice := &tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}
+
+	cases := map[string]bool{"Secret created": false, "Secret rejected": true}
+	for k, fail := range cases {
+		// Arrange
+		ctx := context.TODO()
+		um, m := newTestUserManager(ctrl)
+		core, logs := observer.New(zap.DebugLevel)
+		um.logger = zap.New(core)
+		var password string
+		m.secretsManagerClient.EXPECT().CreateSecret(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, in *secretsmanager.CreateSecretInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error) {
+			var credentials struct{ Password string }
+			assert.Nil(t, json.Unmarshal([]byte(*in.SecretString), &credentials), k)
+			password = credentials.Password
+			if fail {
+				// Validation errors may quote request parameters.
+				return nil, &smt.InvalidRequestException{Message: aws.String("invalid SecretString " + *in.SecretString)}
+			}
+			return &secretsmanager.CreateSecretOutput{ARN: &secretArn}, nil
+		})
+		if !fail {
+			m.mskClient.EXPECT().BatchAssociateScramSecret(ctx, gomock.Any()).Return(&kafka.BatchAssociateScramSecretOutput{}, error(nil))
+			m.kafkaClient.EXPECT().CreateACLs(ctx, gomock.Any()).Return(kadm.CreateACLsResults{{Principal: "User:AmazonMSK_alice_stack"}}, error(nil)).Times(2)
+		}
+
+		// Act
+		err := um.CreateUser(ctx, "stack", "topic", "key", clusterArn, alice)
+
+		// Assert
+		assert.NotEmpty(t, password, k)
+		assert.NotEmpty(t, logs.All(), k)
+		for _, e := range logs.All() {
+			assert.NotContains(t, e.Message, password, k)
+			assert.NotContains(t, fmt.Sprint(e.ContextMap()), password, k)
+		}
+		if fail {
+			assert.NotNil(t, err, k)
+			assert.NotContains(t, fmt.Sprintf("%+v", err), password, k)
+		} else {
+			assert.Nil(t, err, k)
+		}
+	}
+}
+
+func TestTLSUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	alice := &tt.User{Username: "alice", AuthType: tt.AuthTypeTLS, Principal: "CN=alice.example.com", Permissions: []tt.Permission{tt.PermissionRead}}
+	principal := "User:CN=alice.example.com"
+
+	t.Run("Create", func(t *testing.T) {
+		// Arrange
+		ctx := context.TODO()
+		um, m := newTestUserManager(ctrl)
+		m.kafkaClient.EXPECT().CreateACLs(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, b *kadm.ACLBuilder) (kadm.CreateACLsResults, error) {
+			assert.Equal(t, kadm.NewACLs().Topics("topic").ResourcePatternType(kadm.ACLPatternLiteral).Allow(principal).AllowHosts("*").Operations(kadm.OpRead, kadm.OpDescribe), b)
+			return kadm.CreateACLsResults{{Principal: principal}}, nil
+		})
+		m.kafkaClient.EXPECT().CreateACLs(ctx, gomock.Any()).Return(kadm.CreateACLsResults{{Principal: principal}}, error(nil))
+
+		// Act
+		err := um.CreateUser(ctx, "stack", "topic", "", "cluster", alice)
+
+		// Assert
+		assert.Nil(t, err)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		// Arrange
+		ctx := context.TODO()
+		um, m := newTestUserManager(ctrl)
+		m.kafkaClient.EXPECT().DeleteACLFilters(ctx, gomock.Any()).Return(kadm.DeleteACLsResults{kadm.DeleteACLsResult{}}, error(nil))
+
+		// Act
+		err := um.DeleteUser(ctx, alice, "", "topic", "stack", "cluster")
+
+		// Assert
+		assert.Nil(t, err)
+	})
+	assert.Equal(t, "CN=alice.example.com", principalName(alice, "stack", defaultSecretNameTemplate))
+}
+
+func TestIAMUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	alice := &tt.User{Username: "alice", IamPrincipal: "arn:aws:iam::123456789012:role/alice", Permissions: []tt.Permission{tt.PermissionWrite}, NoGroupAcls: true}
+	principal := "User:arn:aws:iam::123456789012:role/alice"
+
+	t.Run("Create", func(t *testing.T) {
+		// Arrange
+		ctx := context.TODO()
+		um, m := newTestUserManager(ctrl)
+		m.kafkaClient.EXPECT().CreateACLs(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, b *kadm.ACLBuilder) (kadm.CreateACLsResults, error) {
+			assert.Equal(t, kadm.NewACLs().Topics("topic").ResourcePatternType(kadm.ACLPatternLiteral).Allow(principal).AllowHosts("*").Operations(kadm.OpWrite, kadm.OpDescribe), b)
+			return kadm.CreateACLsResults{{Principal: principal}}, nil
+		})
+
+		// Act
+		err := um.CreateUser(ctx, "stack", "topic", "", "cluster", alice)
+
+		// Assert
+		assert.Nil(t, err)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		// Arrange
+		ctx := context.TODO()
+		um, m := newTestUserManager(ctrl)
+		m.kafkaClient.EXPECT().DeleteACLFilters(ctx, gomock.Any()).Return(kadm.DeleteACLsResults{kadm.DeleteACLsResult{}}, error(nil))
+
+		// Act
+		err := um.DeleteUser(ctx, alice, "", "topic", "stack", "cluster")
+
+		// Assert
+		assert.Nil(t, err)
+	})
+	assert.Equal(t, "arn:aws:iam::123456789012:role/alice", principalName(alice, "stack", defaultSecretNameTemplate))
+}
+
+func TestReconcileACLs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type testCase struct {
+		name      string
+		user      *tt.User
+		described kadm.DescribedACLs
+		created   []*kadm.ACLBuilder
+		deleted   []*kadm.ACLBuilder
+	}
+
+	alice := &tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead, tt.PermissionWrite}}
+	groupDescribeDisabled := false
+	aliceNoGroupDescribe := &tt.User{Username: "alice", GroupDescribe: &groupDescribeDisabled, Permissions: []tt.Permission{tt.PermissionRead, tt.PermissionWrite}}
+	aliceNoGroupAcls := &tt.User{Username: "alice", GroupDescribe: &groupDescribeDisabled, NoGroupAcls: true, Permissions: []tt.Permission{tt.PermissionRead, tt.PermissionWrite}}
+	topicDescribeDisabled := false
+	aliceNoTopicDescribe := &tt.User{Username: "alice", TopicDescribe: &topicDescribeDisabled, Permissions: []tt.Permission{tt.PermissionRead, tt.PermissionWrite}}
+	aliceOffsetsNoGroupDescribe := &tt.User{Username: "alice", GroupDescribe: &groupDescribeDisabled, Permissions: []tt.Permission{tt.PermissionRead, tt.PermissionWrite, tt.PermissionOffsetManagement}}
+	aliceHosts := &tt.User{Username: "alice", AllowedHosts: []string{"10.0.0.1"}, Permissions: []tt.Permission{tt.PermissionRead, tt.PermissionWrite}}
+	principal := "User:" + defaultSecretNameTemplate.Name("alice", "stack")
+	topicACL := func(op kadm.ACLOperation) kadm.DescribedACL {
+		return kadm.DescribedACL{Principal: principal, Host: "*", Type: kmsg.ACLResourceTypeTopic, Name: "topic", Pattern: kadm.ACLPatternLiteral, Operation: op, Permission: kmsg.ACLPermissionTypeAllow}
+	}
+	groupACL := func(op kadm.ACLOperation) kadm.DescribedACL {
+		return kadm.DescribedACL{Principal: principal, Host: "*", Type: kmsg.ACLResourceTypeGroup, Name: "*", Pattern: kadm.ACLPatternLiteral, Operation: op, Permission: kmsg.ACLPermissionTypeAllow}
+	}
+	onHost := func(host string, acl kadm.DescribedACL) kadm.DescribedACL {
+		acl.Host = host
+		return acl
+	}
+
+	cases := []testCase{
+		{
+			name:      "No drift",
+			described: kadm.DescribedACLs{topicACL(kadm.OpRead), topicACL(kadm.OpWrite), topicACL(kadm.OpDescribe), groupACL(kadm.OpRead), groupACL(kadm.OpDescribe)},
+		},
+		{
+			name:      "Missing ACLs are created",
+			described: kadm.DescribedACLs{topicACL(kadm.OpRead), groupACL(kadm.OpRead)},
+			created: []*kadm.ACLBuilder{
+				kadm.NewACLs().Topics("topic").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpWrite, kadm.OpDescribe).Allow(principal).AllowHosts("*"),
+				kadm.NewACLs().Groups("*").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpDescribe).Allow(principal).AllowHosts("*"),
+			},
+		},
+		{
+			name:      "Extra topic ACLs are deleted",
+			described: kadm.DescribedACLs{topicACL(kadm.OpRead), topicACL(kadm.OpWrite), topicACL(kadm.OpDescribe), topicACL(kadm.OpAlter), groupACL(kadm.OpRead), groupACL(kadm.OpDescribe), groupACL(kadm.OpDelete)},
+			deleted: []*kadm.ACLBuilder{
+				kadm.NewACLs().Topics("topic").ResourcePatternType(kadm.ACLPatternLiteral).Allow(principal).AllowHosts("*").Operations(kadm.OpAlter),
+			},
+		},
+		{
+			name:      "Group describe is deleted when disabled",
+			user:      aliceNoGroupDescribe,
+			described: kadm.DescribedACLs{topicACL(kadm.OpRead), topicACL(kadm.OpWrite), topicACL(kadm.OpDescribe), groupACL(kadm.OpRead), groupACL(kadm.OpDescribe)},
+			deleted: []*kadm.ACLBuilder{
+				kadm.NewACLs().Groups("*").ResourcePatternType(kadm.ACLPatternLiteral).Allow(principal).AllowHosts("*").Operations(kadm.OpDescribe),
+			},
+		},
+		{
+			name:      "Group describe is not created when disabled",
+			user:      aliceNoGroupDescribe,
+			described: kadm.DescribedACLs{topicACL(kadm.OpRead), topicACL(kadm.OpWrite), topicACL(kadm.OpDescribe), groupACL(kadm.OpRead)},
+		},
+		{
+			name:      "Group describe is kept for offset management when disabled",
+			user:      aliceOffsetsNoGroupDescribe,
+			described: kadm.DescribedACLs{topicACL(kadm.OpRead), topicACL(kadm.OpWrite), topicACL(kadm.OpDescribe), groupACL(kadm.OpRead), groupACL(kadm.OpDescribe), groupACL(kadm.OpDelete)},
+		},
+		{
+			name:      "Topic describe is deleted when disabled",
+			user:      aliceNoTopicDescribe,
+			described: kadm.DescribedACLs{topicACL(kadm.OpRead), topicACL(kadm.OpWrite), topicACL(kadm.OpDescribe), groupACL(kadm.OpRead), groupACL(kadm.OpDescribe)},
+			deleted: []*kadm.ACLBuilder{
+				kadm.NewACLs().Topics("topic").ResourcePatternType(kadm.ACLPatternLiteral).Allow(principal).AllowHosts("*").Operations(kadm.OpDescribe),
+			},
+		},
+		{
+			name:      "Group ACLs managed outside TR are kept",
+			user:      aliceNoGroupAcls,
+			described: kadm.DescribedACLs{topicACL(kadm.OpRead), topicACL(kadm.OpWrite), topicACL(kadm.OpDescribe), groupACL(kadm.OpDescribe)},
+		},
+		{
+			name:      "Group ACLs are not created when managed outside TR",
+			user:      aliceNoGroupAcls,
+			described: kadm.DescribedACLs{topicACL(kadm.OpRead), topicACL(kadm.OpWrite), topicACL(kadm.OpDescribe)},
+		},
+		{
+			name: "No drift on allowed hosts",
+			user: aliceHosts,
+			described: kadm.DescribedACLs{
+				onHost("10.0.0.1", topicACL(kadm.OpRead)), onHost("10.0.0.1", topicACL(kadm.OpWrite)), onHost("10.0.0.1", topicACL(kadm.OpDescribe)),
+				onHost("10.0.0.1", groupACL(kadm.OpRead)), onHost("10.0.0.1", groupACL(kadm.OpDescribe)),
+			},
+		},
+		{
+			// Group ACLs on any host are retained like other extra group
+			// ACLs.
+			name:      "Topic ACLs of hosts not allowed are deleted",
+			user:      aliceHosts,
+			described: kadm.DescribedACLs{topicACL(kadm.OpRead), onHost("10.0.0.1", topicACL(kadm.OpRead)), groupACL(kadm.OpRead), onHost("10.0.0.1", groupACL(kadm.OpRead))},
+			created: []*kadm.ACLBuilder{
+				kadm.NewACLs().Topics("topic").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpWrite, kadm.OpDescribe).Allow(principal).AllowHosts("10.0.0.1"),
+				kadm.NewACLs().Groups("*").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpDescribe).Allow(principal).AllowHosts("10.0.0.1"),
+			},
+			deleted: []*kadm.ACLBuilder{
+				kadm.NewACLs().Topics("topic").ResourcePatternType(kadm.ACLPatternLiteral).Allow(principal).AllowHosts("*").Operations(kadm.OpRead),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			ctx := context.TODO()
+			um, m := newTestUserManager(ctrl)
+			m.kafkaClient.EXPECT().DescribeACLs(ctx, kadm.NewACLs().Topics("topic").Groups("*").ResourcePatternType(kadm.ACLPatternLiteral).Allow(principal).AllowHosts().Operations()).
+				Return(kadm.DescribeACLsResults{{Described: c.described}}, error(nil))
+			for _, b := range c.created {
+				m.kafkaClient.EXPECT().CreateACLs(ctx, b).Return(kadm.CreateACLsResults{{Principal: principal}}, error(nil))
+			}
+			for _, b := range c.deleted {
+				m.kafkaClient.EXPECT().DeleteACLs(ctx, b).Return(kadm.DeleteACLsResults{kadm.DeleteACLsResult{}}, error(nil))
+			}
+
+			u := alice
+			if c.user != nil {
+				u = c.user
+			}
+
+			// Act
+			err := um.ReconcileACLs(ctx, "topic", u, "stack")
+
+			// Assert
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestReconcileGrants(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type testCase struct {
+		user    *tt.User
+		pages   []*kms.ListGrantsOutput
+		created []string
+	}
+
+	shortStackID := shortStackID("test")
+	username := defaultSecretNameTemplate.Name("alice", shortStackID)
+	arn := "arn:aws:iam::123456789012:role/alice"
+	other := "arn:aws:iam::123456789012:role/other"
+	grant := func(name, principal string) kmst.GrantListEntry {
+		return kmst.GrantListEntry{GrantId: aws.String(name + "/" + principal), Name: aws.String(name), GranteePrincipal: aws.String(principal), Operations: []kmst.GrantOperation{kmst.GrantOperationDecrypt}}
+	}
+
+	cases := map[string]testCase{
+		"Grant exists": {
+			user:  &tt.User{Username: "alice", Arn: arn},
+			pages: []*kms.ListGrantsOutput{{Grants: []kmst.GrantListEntry{grant(username, arn)}}},
+		},
+		"Revoked grant is recreated": {
+			user:    &tt.User{Username: "alice", Arn: arn, Arns: []string{other}},
+			pages:   []*kms.ListGrantsOutput{{Grants: []kmst.GrantListEntry{grant(username, other), grant("AmazonMSK_bob", arn)}}},
+			created: []string{arn},
+		},
+		"Grants are listed across pages": {
+			user: &tt.User{Username: "alice", Arn: arn, Arns: []string{other}},
+			pages: []*kms.ListGrantsOutput{
+				{Grants: []kmst.GrantListEntry{grant(username, arn)}, Truncated: true, NextMarker: aws.String("next")},
+				{Grants: []kmst.GrantListEntry{grant(username, other)}},
+			},
+		},
+		"User without principals": {
+			user: &tt.User{Username: "alice"},
+		},
+		"External secret": {
+			user: &tt.User{Username: "alice", Arn: arn, SecretArn: "secret"},
+		},
+	}
+
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			// Arrange
+			ctx := context.TODO()
+			um, m := newTestUserManager(ctrl)
+			var marker *string
+			for _, page := range c.pages {
+				m.kmsClient.EXPECT().ListGrants(ctx, &kms.ListGrantsInput{KeyId: aws.String("key"), Marker: marker}).Return(page, error(nil))
+				marker = page.NextMarker
+			}
+			for _, principalArn := range c.created {
+				m.kmsClient.EXPECT().CreateGrant(ctx, &kms.CreateGrantInput{Name: &username, KeyId: aws.String("key"), GranteePrincipal: aws.String(principalArn), Operations: []kmst.GrantOperation{kmst.GrantOperationDecrypt}}).
+					Return(&kms.CreateGrantOutput{}, error(nil))
+			}
+
+			// Act
+			err := um.ReconcileGrants(ctx, c.user, "key", shortStackID)
+
+			// Assert
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestGroupDescribe(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	um, _ := newTestUserManager(ctrl)
+	principal := "User:" + defaultSecretNameTemplate.Name("alice", "stack")
+	topic := kadm.NewACLs().Topics("topic").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpRead, kadm.OpDescribe).Allow(principal).AllowHosts("*")
+
+	acls := um.userPermissionToACL("topic", kadm.ACLPatternLiteral, nil, []string{"*"}, defaultSecretNameTemplate.Name("alice", "stack"), []tt.Permission{tt.PermissionRead}, groupACLsDescribe, "", true)
+	assert.Equal(t, []*kadm.ACLBuilder{
+		topic,
+		kadm.NewACLs().Groups("*").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpRead, kadm.OpDescribe).Allow(principal).AllowHosts("*"),
+	}, acls)
+
+	acls = um.userPermissionToACL("topic", kadm.ACLPatternLiteral, nil, []string{"*"}, defaultSecretNameTemplate.Name("alice", "stack"), []tt.Permission{tt.PermissionRead}, groupACLsRead, "", true)
+	assert.Equal(t, []*kadm.ACLBuilder{
+		topic,
+		kadm.NewACLs().Groups("*").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpRead).Allow(principal).AllowHosts("*"),
+	}, acls)
+}
+
+func TestPermissionsToOperations(t *testing.T) {
+	type testCase struct {
+		permissions   []tt.Permission
+		topicDescribe bool
+		topicOps      []kadm.ACLOperation
+		groupOps      []kadm.ACLOperation
+	}
+
+	cases := map[string]testCase{
+		"Read": {
+			permissions:   []tt.Permission{tt.PermissionRead},
+			topicDescribe: true,
+			topicOps:      []kadm.ACLOperation{kadm.OpRead, kadm.OpDescribe},
+			groupOps:      []kadm.ACLOperation{kadm.OpRead},
+		},
+		"Write": {
+			permissions:   []tt.Permission{tt.PermissionWrite},
+			topicDescribe: true,
+			topicOps:      []kadm.ACLOperation{kadm.OpWrite, kadm.OpDescribe},
+			groupOps:      []kadm.ACLOperation{},
+		},
+		"Read and write describe once": {
+			permissions:   []tt.Permission{tt.PermissionRead, tt.PermissionWrite},
+			topicDescribe: true,
+			topicOps:      []kadm.ACLOperation{kadm.OpRead, kadm.OpWrite, kadm.OpDescribe},
+			groupOps:      []kadm.ACLOperation{kadm.OpRead},
+		},
+		"Offset management describes once": {
+			permissions:   []tt.Permission{tt.PermissionRead, tt.PermissionOffsetManagement},
+			topicDescribe: true,
+			topicOps:      []kadm.ACLOperation{kadm.OpRead, kadm.OpDescribe},
+			groupOps:      []kadm.ACLOperation{kadm.OpRead, kadm.OpDescribe, kadm.OpDelete},
+		},
+		"Topic describe disabled": {
+			permissions: []tt.Permission{tt.PermissionRead, tt.PermissionWrite},
+			topicOps:    []kadm.ACLOperation{kadm.OpRead, kadm.OpWrite},
+			groupOps:    []kadm.ACLOperation{kadm.OpRead},
+		},
+		"Topic describe disabled with offset management": {
+			permissions: []tt.Permission{tt.PermissionRead, tt.PermissionOffsetManagement},
+			topicOps:    []kadm.ACLOperation{kadm.OpRead, kadm.OpDescribe},
+			groupOps:    []kadm.ACLOperation{kadm.OpRead, kadm.OpDescribe, kadm.OpDelete},
+		},
+	}
+
+	for k, c := range cases {
+		topicOps, groupOps := permissionsToOperations(c.permissions, groupACLsRead, c.topicDescribe)
+		assert.Equal(t, c.topicOps, topicOps, k)
+		assert.Equal(t, c.groupOps, groupOps, k)
+	}
+}
+
+func TestOffsetManagementACLs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	um, _ := newTestUserManager(ctrl)
+	principal := "User:" + defaultSecretNameTemplate.Name("alice", "stack")
+	permissions := []tt.Permission{tt.PermissionRead, tt.PermissionOffsetManagement}
+
+	acls := um.userPermissionToACL("topic", kadm.ACLPatternLiteral, nil, []string{"*"}, defaultSecretNameTemplate.Name("alice", "stack"), permissions, groupACLsDescribe, "", true)
+	assert.Equal(t, []*kadm.ACLBuilder{
+		kadm.NewACLs().Topics("topic").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpRead, kadm.OpDescribe).Allow(principal).AllowHosts("*"),
+		kadm.NewACLs().Groups("*").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpRead, kadm.OpDescribe, kadm.OpDelete).Allow(principal).AllowHosts("*"),
+	}, acls)
+
+	// Group DESCRIBE is still granted as it is required to reset offsets.
+	acls = um.userPermissionToACL("topic", kadm.ACLPatternLiteral, nil, []string{"*"}, defaultSecretNameTemplate.Name("alice", "stack"), permissions, groupACLsRead, "", true)
+	assert.Equal(t, []*kadm.ACLBuilder{
+		kadm.NewACLs().Topics("topic").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpRead, kadm.OpDescribe).Allow(principal).AllowHosts("*"),
+		kadm.NewACLs().Groups("*").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpRead, kadm.OpDescribe, kadm.OpDelete).Allow(principal).AllowHosts("*"),
+	}, acls)
+}
+
+func TestNoGroupAcls(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	ctx := context.TODO()
+	um, m := newTestUserManager(ctrl)
+	alice := &tt.User{Username: "alice", NoGroupAcls: true, Permissions: []tt.Permission{tt.PermissionRead, tt.PermissionOffsetManagement}}
+	principal := "User:" + defaultSecretNameTemplate.Name("alice", "stack")
+	topic := kadm.NewACLs().Topics("topic").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpRead, kadm.OpDescribe).Allow(principal).AllowHosts("*")
+	// Only the topic ACL is created and deleted.
+	gomock.InOrder(
+		m.kafkaClient.EXPECT().CreateACLs(ctx, topic).Return(kadm.CreateACLsResults{{Principal: principal}}, error(nil)),
+		m.kafkaClient.EXPECT().DeleteACLFilters(ctx, aclSpec{ResourceType: kmsg.ACLResourceTypeTopic, Name: "topic", Pattern: kadm.ACLPatternLiteral, Principal: principal, Host: "*", Operations: []kadm.ACLOperation{kadm.OpRead, kadm.OpDescribe}}.deleteFilters()).
+			Return(kadm.DeleteACLsResults{kadm.DeleteACLsResult{}}, error(nil)),
+	)
+
+	// Act
+	createErr := um.CreateACLs(ctx, "topic", alice, "stack", alice.Permissions)
+	deleteErr := um.DeleteACLs(ctx, "topic", alice, "stack", alice.Permissions)
+
+	// Assert
+	assert.Nil(t, createErr)
+	assert.Nil(t, deleteErr)
+}
+
+func TestTopicPrefixACLs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	ctx := context.TODO()
+	um, m := newTestUserManager(ctrl)
+	alice := &tt.User{Username: "alice", AuthType: tt.AuthTypeTLS, Principal: "CN=alice", TopicPrefix: "orders.", Permissions: []tt.Permission{tt.PermissionWrite}}
+	principal := "User:CN=alice"
+	prefixed := kadm.NewACLs().Topics("orders.").ResourcePatternType(kadm.ACLPatternPrefixed).Operations(kadm.OpWrite, kadm.OpDescribe).Allow(principal).AllowHosts("*")
+	prefixedACL := kadm.DescribedACL{Principal: principal, Host: "*", Type: kmsg.ACLResourceTypeTopic, Name: "orders.", Pattern: kadm.ACLPatternPrefixed, Operation: kadm.OpWrite, Permission: kmsg.ACLPermissionTypeAllow}
+	prefixedDescribeACL := kadm.DescribedACL{Principal: principal, Host: "*", Type: kmsg.ACLResourceTypeTopic, Name: "orders.", Pattern: kadm.ACLPatternPrefixed, Operation: kadm.OpDescribe, Permission: kmsg.ACLPermissionTypeAllow}
+	// A literal ACL on a topic named like the prefix is not managed by the user.
+	literalACL := kadm.DescribedACL{Principal: principal, Host: "*", Type: kmsg.ACLResourceTypeTopic, Name: "orders.", Pattern: kadm.ACLPatternLiteral, Operation: kadm.OpRead, Permission: kmsg.ACLPermissionTypeAllow}
+
+	gomock.InOrder(
+		m.kafkaClient.EXPECT().CreateACLs(ctx, prefixed).Return(kadm.CreateACLsResults{{Principal: principal}}, error(nil)),
+		m.kafkaClient.EXPECT().DescribeACLs(ctx, kadm.NewACLs().Topics("orders.").Groups("*").ResourcePatternType(kadm.ACLPatternAny).Allow(principal).AllowHosts().Operations()).
+			Return(kadm.DescribeACLsResults{{Described: kadm.DescribedACLs{prefixedACL, prefixedDescribeACL, literalACL}}}, error(nil)),
+		m.kafkaClient.EXPECT().DeleteACLFilters(ctx, aclSpec{ResourceType: kmsg.ACLResourceTypeTopic, Name: "orders.", Pattern: kadm.ACLPatternPrefixed, Principal: principal, Host: "*", Operations: []kadm.ACLOperation{kadm.OpWrite, kadm.OpDescribe}}.deleteFilters()).
+			Return(kadm.DeleteACLsResults{kadm.DeleteACLsResult{}}, error(nil)),
+	)
+
+	// Act
+	err := um.CreateUser(ctx, "stack", "orders.eu-stack", "", "cluster", alice)
+	assert.Nil(t, err)
+	err = um.ReconcileACLs(ctx, "orders.eu-stack", alice, "stack")
+	assert.Nil(t, err)
+	err = um.DeleteUser(ctx, alice, "", "orders.eu-stack", "stack", "cluster")
+	assert.Nil(t, err)
+}
+
+func TestGroupPrefixACLs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	ctx := context.TODO()
+	um, m := newTestUserManager(ctrl)
+	alice := &tt.User{Username: "alice", GroupPrefix: "orders-", Permissions: []tt.Permission{tt.PermissionRead}}
+	principal := "User:" + defaultSecretNameTemplate.Name("alice", "stack")
+	topic := kadm.NewACLs().Topics("topic").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpRead, kadm.OpDescribe).Allow(principal).AllowHosts("*")
+	groups := kadm.NewACLs().Groups("orders-").ResourcePatternType(kadm.ACLPatternPrefixed).Operations(kadm.OpRead, kadm.OpDescribe).Allow(principal).AllowHosts("*")
+	topicACL := kadm.DescribedACL{Principal: principal, Host: "*", Type: kmsg.ACLResourceTypeTopic, Name: "topic", Pattern: kadm.ACLPatternLiteral, Operation: kadm.OpRead, Permission: kmsg.ACLPermissionTypeAllow}
+	topicDescribeACL := topicACL
+	topicDescribeACL.Operation = kadm.OpDescribe
+	groupACL := kadm.DescribedACL{Principal: principal, Host: "*", Type: kmsg.ACLResourceTypeGroup, Name: "orders-", Pattern: kadm.ACLPatternPrefixed, Operation: kadm.OpRead, Permission: kmsg.ACLPermissionTypeAllow}
+	// ACLs on all groups are not managed by a user with a group prefix.
+	allGroupsACL := kadm.DescribedACL{Principal: principal, Host: "*", Type: kmsg.ACLResourceTypeGroup, Name: "*", Pattern: kadm.ACLPatternLiteral, Operation: kadm.OpRead, Permission: kmsg.ACLPermissionTypeAllow}
+
+	gomock.InOrder(
+		m.kafkaClient.EXPECT().CreateACLs(ctx, topic).Return(kadm.CreateACLsResults{{Principal: principal}}, error(nil)),
+		m.kafkaClient.EXPECT().CreateACLs(ctx, groups).Return(kadm.CreateACLsResults{{Principal: principal}}, error(nil)),
+		m.kafkaClient.EXPECT().DescribeACLs(ctx, kadm.NewACLs().Topics("topic").Groups("orders-").ResourcePatternType(kadm.ACLPatternAny).Allow(principal).AllowHosts().Operations()).
+			Return(kadm.DescribeACLsResults{{Described: kadm.DescribedACLs{topicACL, topicDescribeACL, groupACL, allGroupsACL}}}, error(nil)),
+		// The missing DESCRIBE on groups is created on the prefix.
+		m.kafkaClient.EXPECT().CreateACLs(ctx, kadm.NewACLs().Groups("orders-").ResourcePatternType(kadm.ACLPatternPrefixed).Operations(kadm.OpDescribe).Allow(principal).AllowHosts("*")).
+			Return(kadm.CreateACLsResults{{Principal: principal}}, error(nil)),
+		// DESCRIBE on the topic is left to ReconcileACLs.
+		m.kafkaClient.EXPECT().DeleteACLFilters(ctx, append(
+			aclSpec{ResourceType: kmsg.ACLResourceTypeTopic, Name: "topic", Pattern: kadm.ACLPatternLiteral, Principal: principal, Host: "*", Operations: []kadm.ACLOperation{kadm.OpRead}}.deleteFilters(),
+			aclSpec{ResourceType: kmsg.ACLResourceTypeGroup, Name: "orders-", Pattern: kadm.ACLPatternPrefixed, Principal: principal, Host: "*", Operations: []kadm.ACLOperation{kadm.OpRead, kadm.OpDescribe}}.deleteFilters()...,
+		)).Return(make(kadm.DeleteACLsResults, 3), error(nil)),
+	)
+
+	// Act
+	err := um.CreateACLs(ctx, "topic", alice, "stack", alice.Permissions)
+	assert.Nil(t, err)
+	err = um.ReconcileACLs(ctx, "topic", alice, "stack")
+	assert.Nil(t, err)
+	err = um.DeleteACLs(ctx, "topic", alice, "stack", alice.Permissions)
+
+	// Assert
+	assert.Nil(t, err)
+}
+
+func TestAllowedHostsACLs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	ctx := context.TODO()
+	um, m := newTestUserManager(ctrl)
+	alice := &tt.User{Username: "alice", AllowedHosts: []string{"10.0.0.1", "10.0.0.2"}, Permissions: []tt.Permission{tt.PermissionRead}}
+	principal := "User:" + defaultSecretNameTemplate.Name("alice", "stack")
+
+	calls := make([]*gomock.Call, 0)
+	for _, host := range alice.AllowedHosts {
+		topic := kadm.NewACLs().Topics("topic").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpRead, kadm.OpDescribe).Allow(principal).AllowHosts(host)
+		calls = append(calls, m.kafkaClient.EXPECT().CreateACLs(ctx, topic).Return(kadm.CreateACLsResults{{Principal: principal}}, error(nil)))
+	}
+	for _, host := range alice.AllowedHosts {
+		groups := kadm.NewACLs().Groups("*").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpRead, kadm.OpDescribe).Allow(principal).AllowHosts(host)
+		calls = append(calls, m.kafkaClient.EXPECT().CreateACLs(ctx, groups).Return(kadm.CreateACLsResults{{Principal: principal}}, error(nil)))
+	}
+	filters := make([]kmsg.DeleteACLsRequestFilter, 0)
+	for _, host := range alice.AllowedHosts {
+		filters = append(filters, aclSpec{ResourceType: kmsg.ACLResourceTypeTopic, Name: "topic", Pattern: kadm.ACLPatternLiteral, Principal: principal, Host: host, Operations: []kadm.ACLOperation{kadm.OpRead}}.deleteFilters()...)
+	}
+	for _, host := range alice.AllowedHosts {
+		filters = append(filters, aclSpec{ResourceType: kmsg.ACLResourceTypeGroup, Name: "*", Pattern: kadm.ACLPatternLiteral, Principal: principal, Host: host, Operations: []kadm.ACLOperation{kadm.OpRead, kadm.OpDescribe}}.deleteFilters()...)
+	}
+	calls = append(calls, m.kafkaClient.EXPECT().DeleteACLFilters(ctx, filters).Return(make(kadm.DeleteACLsResults, len(filters)), error(nil)))
+	gomock.InOrder(calls...)
+
+	// Act
+	err := um.CreateACLs(ctx, "topic", alice, "stack", alice.Permissions)
+	assert.Nil(t, err)
+	err = um.DeleteACLs(ctx, "topic", alice, "stack", alice.Permissions)
+
+	// Assert
+	assert.Nil(t, err)
+}
+
+func TestAdditionalTopicsACLs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	ctx := context.TODO()
+	um, m := newTestUserManager(ctrl)
+	alice := &tt.User{Username: "alice", AdditionalTopics: []string{"payments-T6DNBAMI"}, Permissions: []tt.Permission{tt.PermissionRead}}
+	principal := "User:" + defaultSecretNameTemplate.Name("alice", "stack")
+	topic := kadm.NewACLs().Topics("topic").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpRead, kadm.OpDescribe).Allow(principal).AllowHosts("*")
+	additional := kadm.NewACLs().Topics("payments-T6DNBAMI").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpRead, kadm.OpDescribe).Allow(principal).AllowHosts("*")
+	groups := kadm.NewACLs().Groups("*").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpRead, kadm.OpDescribe).Allow(principal).AllowHosts("*")
+
+	gomock.InOrder(
+		m.kafkaClient.EXPECT().ListTopics(ctx, "payments-T6DNBAMI").Return(kadm.TopicDetails{"payments-T6DNBAMI": {Topic: "payments-T6DNBAMI"}}, error(nil)),
+		m.kafkaClient.EXPECT().CreateACLs(ctx, topic).Return(kadm.CreateACLsResults{{Principal: principal}}, error(nil)),
+		m.kafkaClient.EXPECT().CreateACLs(ctx, additional).Return(kadm.CreateACLsResults{{Principal: principal}}, error(nil)),
+		m.kafkaClient.EXPECT().CreateACLs(ctx, groups).Return(kadm.CreateACLsResults{{Principal: principal}}, error(nil)),
+		m.kafkaClient.EXPECT().DeleteACLFilters(ctx, append(append(
+			aclSpec{ResourceType: kmsg.ACLResourceTypeTopic, Name: "topic", Pattern: kadm.ACLPatternLiteral, Principal: principal, Host: "*", Operations: []kadm.ACLOperation{kadm.OpRead}}.deleteFilters(),
+			aclSpec{ResourceType: kmsg.ACLResourceTypeTopic, Name: "payments-T6DNBAMI", Pattern: kadm.ACLPatternLiteral, Principal: principal, Host: "*", Operations: []kadm.ACLOperation{kadm.OpRead}}.deleteFilters()...),
+			aclSpec{ResourceType: kmsg.ACLResourceTypeGroup, Name: "*", Pattern: kadm.ACLPatternLiteral, Principal: principal, Host: "*", Operations: []kadm.ACLOperation{kadm.OpRead, kadm.OpDescribe}}.deleteFilters()...,
+		)).Return(make(kadm.DeleteACLsResults, 4), error(nil)),
+	)
+
+	// Act
+	err := um.CreateACLs(ctx, "topic", alice, "stack", alice.Permissions)
+	assert.Nil(t, err)
+	err = um.DeleteACLs(ctx, "topic", alice, "stack", alice.Permissions)
+
+	// Assert
+	assert.Nil(t, err)
+}
+
+func TestCreateUserMissingAdditionalTopic(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	ctx := context.TODO()
+	um, m := newTestUserManager(ctrl)
+	alice := &tt.User{Username: "alice", AdditionalTopics: []string{"payments", "refunds"}, Permissions: []tt.Permission{tt.PermissionRead}}
+	// No secret is created when an additional topic does not exist.
+	m.kafkaClient.EXPECT().ListTopics(ctx, "payments", "refunds").
+		Return(kadm.TopicDetails{"payments": {Topic: "payments"}, "refunds": {Topic: "refunds", Err: kerr.UnknownTopicOrPartition}}, error(nil))
+
+	// Act
+	err := um.CreateUser(ctx, "stack", "topic", "key", "cluster", alice)
+
+	// Assert
+	assert.EqualError(t, err, "AdditionalTopics do not exist in the cluster: refunds")
+}
+
+func TestCreateACLsIdempotent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type testCase struct {
+		name      string
+		described kadm.DescribedACLs
+		err       string
+	}
+
+	principal := "User:" + defaultSecretNameTemplate.Name("alice", "stack")
+	failed := kadm.CreateACLsResult{Principal: principal, Host: "*", Type: kmsg.ACLResourceTypeTopic, Name: "topic", Pattern: kadm.ACLPatternLiteral, Operation: kadm.OpWrite, Permission: kmsg.ACLPermissionTypeAllow, Err: kerr.InvalidRequest}
+
+	cases := []testCase{
+		{
+			name:      "Duplicate ACL",
+			described: kadm.DescribedACLs{{Principal: principal, Host: "*", Type: kmsg.ACLResourceTypeTopic, Name: "topic", Pattern: kadm.ACLPatternLiteral, Operation: kadm.OpWrite, Permission: kmsg.ACLPermissionTypeAllow}},
+		},
+		{
+			name: "ACL not created",
+			err:  kerr.InvalidRequest.Error(),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			ctx := context.TODO()
+			um, m := newTestUserManager(ctrl)
+			m.kafkaClient.EXPECT().CreateACLs(ctx, gomock.Any()).Return(kadm.CreateACLsResults{{Principal: principal, Operation: kadm.OpRead}, failed}, error(nil))
+			m.kafkaClient.EXPECT().DescribeACLs(ctx, kadm.NewACLs().ResourcePatternType(kadm.ACLPatternLiteral).Allow(principal).AllowHosts("*").Operations(kadm.OpWrite).Topics("topic")).
+				Return(kadm.DescribeACLsResults{{Described: c.described}}, error(nil))
+
+			// Act
+			err := um.createACLs(ctx, "topic", kadm.ACLPatternLiteral, nil, []string{"*"}, defaultSecretNameTemplate.Name("alice", "stack"), []tt.Permission{tt.PermissionWrite}, groupACLsDescribe, "", true)
+
+			// Assert
+			if c.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, c.err)
+			}
+		})
+	}
+}
+
+func TestACLRetries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	username := defaultSecretNameTemplate.Name("alice", "stack")
+	principal := "User:" + username
+	created := kadm.CreateACLsResults{{Principal: principal, Operation: kadm.OpWrite}}
+	deleted := kadm.DeleteACLsResults{{Principal: &principal}}
+
+	t.Run("Create retried", func(t *testing.T) {
+		// Arrange
+		ctx := context.TODO()
+		um, m := newTestUserManager(ctrl)
+		gomock.InOrder(
+			m.kafkaClient.EXPECT().CreateACLs(ctx, gomock.Any()).Return(kadm.CreateACLsResults{{Principal: principal, Operation: kadm.OpWrite, Err: kerr.NotController}}, error(nil)),
+			m.kafkaClient.EXPECT().CreateACLs(ctx, gomock.Any()).Return(created, error(nil)),
+		)
+
+		// Act
+		err := um.createACLs(ctx, "topic", kadm.ACLPatternLiteral, nil, []string{"*"}, username, []tt.Permission{tt.PermissionWrite}, groupACLsDescribe, "", true)
+
+		// Assert
+		assert.Nil(t, err)
+	})
+
+	t.Run("Create not retried", func(t *testing.T) {
+		// Arrange
+		ctx := context.TODO()
+		um, m := newTestUserManager(ctrl)
+		m.kafkaClient.EXPECT().CreateACLs(ctx, gomock.Any()).Return(kadm.CreateACLsResults{{Principal: principal, Operation: kadm.OpWrite, Err: kerr.InvalidRequest}}, error(nil))
+
+		// Act
+		err := um.createACLs(ctx, "topic", kadm.ACLPatternLiteral, nil, []string{"*"}, username, []tt.Permission{tt.PermissionWrite}, groupACLsDescribe, "", true)
+
+		// Assert
+		assert.EqualError(t, err, kerr.InvalidRequest.Error())
+	})
+
+	t.Run("Delete retried", func(t *testing.T) {
+		// Arrange
+		ctx := context.TODO()
+		um, m := newTestUserManager(ctrl)
+		gomock.InOrder(
+			m.kafkaClient.EXPECT().DeleteACLFilters(ctx, gomock.Any()).Return(nil, kerr.RequestTimedOut),
+			m.kafkaClient.EXPECT().DeleteACLFilters(ctx, gomock.Any()).Return(kadm.DeleteACLsResults{{Err: kerr.NotController}}, error(nil)),
+			m.kafkaClient.EXPECT().DeleteACLFilters(ctx, gomock.Any()).Return(deleted, error(nil)),
+		)
+
+		// Act
+		err := um.deleteACLs(ctx, "topic", kadm.ACLPatternLiteral, nil, []string{"*"}, username, []tt.Permission{tt.PermissionWrite}, groupACLsDescribe, "", true)
+
+		// Assert
+		assert.Nil(t, err)
+	})
+
+	t.Run("Delete is best-effort", func(t *testing.T) {
+		// Arrange
+		ctx := context.TODO()
+		um, m := newTestUserManager(ctrl)
+		m.kafkaClient.EXPECT().DeleteACLFilters(ctx, gomock.Any()).Return(kadm.DeleteACLsResults{{Err: kerr.SecurityDisabled}}, error(nil))
+
+		// Act
+		err := um.deleteACLs(ctx, "topic", kadm.ACLPatternLiteral, nil, []string{"*"}, username, []tt.Permission{tt.PermissionWrite}, groupACLsDescribe, "", true)
+
+		// Assert
+		assert.Nil(t, err)
+	})
+
+	t.Run("Delete retries exhausted", func(t *testing.T) {
+		// Arrange
+		ctx := context.TODO()
+		um, m := newTestUserManager(ctrl)
+		m.kafkaClient.EXPECT().DeleteACLFilters(ctx, gomock.Any()).Return(nil, kerr.RequestTimedOut).Times(3)
+
+		// Act
+		err := um.deleteACLs(ctx, "topic", kadm.ACLPatternLiteral, nil, []string{"*"}, username, []tt.Permission{tt.PermissionWrite}, groupACLsDescribe, "", true)
+
+		// Assert
+		assert.ErrorIs(t, err, kerr.RequestTimedOut)
+	})
+
+	t.Run("Retries bounded by timeout", func(t *testing.T) {
+		// Arrange
+		ctx := context.TODO()
+		um, m := newTestUserManager(ctrl)
+		var waited time.Duration
+		um.aclRetry = newRetryPolicy(10, time.Second, time.Second, 2*time.Second)
+		um.aclRetry.sleep = func(d time.Duration) { waited += d }
+		m.kafkaClient.EXPECT().DeleteACLFilters(ctx, gomock.Any()).Return(nil, kerr.RequestTimedOut).MinTimes(2).MaxTimes(5)
+
+		// Act
+		err := um.deleteACLs(ctx, "topic", kadm.ACLPatternLiteral, nil, []string{"*"}, username, []tt.Permission{tt.PermissionWrite}, groupACLsDescribe, "", true)
+
+		// Assert
+		assert.ErrorIs(t, err, kerr.RequestTimedOut)
+		assert.LessOrEqual(t, waited, 2*time.Second)
+	})
+}
+
+func TestDeleteACLsRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("Topic and group ACLs deleted in one request per user", func(t *testing.T) {
+		// Arrange
+		ctx := context.TODO()
+		um, m := newTestUserManager(ctrl)
+		users := []*tt.User{
+			{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead, tt.PermissionWrite}},
+			{Username: "bob", Permissions: []tt.Permission{tt.PermissionRead}},
+			{Username: "carol", Permissions: []tt.Permission{tt.PermissionWrite}},
+		}
+		var requests int
+		m.kafkaClient.EXPECT().DeleteACLFilters(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, fs []kmsg.DeleteACLsRequestFilter) (kadm.DeleteACLsResults, error) {
+			requests++
+			return make(kadm.DeleteACLsResults, len(fs)), nil
+		}).AnyTimes()
+
+		// Act
+		for _, u := range users {
+			err := um.DeleteACLs(ctx, "topic", u, "stack", u.Permissions)
+			assert.Nil(t, err)
+		}
+
+		// Assert
+		assert.Equal(t, len(users), requests)
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		// Arrange
+		ctx := context.TODO()
+		um, m := newTestUserManager(ctrl)
+		username := defaultSecretNameTemplate.Name("alice", "stack")
+		principal := "User:" + username
+		filter := func(resourceType kmsg.ACLResourceType, name string, op kadm.ACLOperation) kmsg.DeleteACLsRequestFilter {
+			f := kmsg.NewDeleteACLsRequestFilter()
+			f.ResourceType = resourceType
+			f.ResourceName = kmsg.StringPtr(name)
+			f.ResourcePatternType = kadm.ACLPatternLiteral
+			f.Principal = kmsg.StringPtr(principal)
+			f.Host = kmsg.StringPtr("*")
+			f.Operation = op
+			f.PermissionType = kmsg.ACLPermissionTypeAllow
+			return f
+		}
+		m.kafkaClient.EXPECT().DeleteACLFilters(ctx, []kmsg.DeleteACLsRequestFilter{
+			filter(kmsg.ACLResourceTypeTopic, "topic", kadm.OpRead),
+			filter(kmsg.ACLResourceTypeTopic, "topic", kadm.OpDescribe),
+			filter(kmsg.ACLResourceTypeGroup, "*", kadm.OpRead),
+			filter(kmsg.ACLResourceTypeGroup, "*", kadm.OpDescribe),
+		}).Return(make(kadm.DeleteACLsResults, 4), error(nil))
+
+		// Act
+		err := um.deleteACLs(ctx, "topic", kadm.ACLPatternLiteral, nil, []string{"*"}, username, []tt.Permission{tt.PermissionRead}, groupACLsDescribe, "", true)
+
+		// Assert
+		assert.Nil(t, err)
+	})
+
+	t.Run("Retried when any filter fails", func(t *testing.T) {
+		// Arrange
+		ctx := context.TODO()
+		um, m := newTestUserManager(ctrl)
+		username := defaultSecretNameTemplate.Name("alice", "stack")
+		gomock.InOrder(
+			m.kafkaClient.EXPECT().DeleteACLFilters(ctx, gomock.Any()).Return(kadm.DeleteACLsResults{{}, {Err: kerr.SecurityDisabled}, {Err: kerr.NotController}}, error(nil)),
+			m.kafkaClient.EXPECT().DeleteACLFilters(ctx, gomock.Any()).Return(make(kadm.DeleteACLsResults, 3), error(nil)),
+		)
+
+		// Act
+		err := um.deleteACLs(ctx, "topic", kadm.ACLPatternLiteral, nil, []string{"*"}, username, []tt.Permission{tt.PermissionRead}, groupACLsRead, "", true)
+
+		// Assert
+		assert.Nil(t, err)
+	})
+}
+
+func TestACLsCancelled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	um, _ := newTestUserManager(ctrl)
+	alice := &tt.User{Username: "alice", Permissions: []tt.Permission{tt.PermissionRead}}
+
+	// Act
+	createErr := um.CreateACLs(ctx, "topic", alice, "stack", alice.Permissions)
+	deleteErr := um.DeleteACLs(ctx, "topic", alice, "stack", alice.Permissions)
+
+	// Assert
+	assert.ErrorIs(t, createErr, context.Canceled)
+	assert.ErrorIs(t, deleteErr, context.Canceled)
+}
+
+func TestApplyACLs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type testCase struct {
+		name      string
+		deleteErr error
+		err       string
+	}
+
+	alice := "User:" + defaultSecretNameTemplate.Name("alice", "stack")
+	bob := "User:" + defaultSecretNameTemplate.Name("bob", "stack")
+	aliceTopic := kadm.NewACLs().Topics("topic").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpRead).Allow(alice).AllowHosts("*")
+	bobGroup := kadm.NewACLs().Groups("*").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpRead).Allow(bob).AllowHosts("*")
+	bobTopic := kadm.NewACLs().Topics("topic").ResourcePatternType(kadm.ACLPatternLiteral).Operations(kadm.OpWrite).Allow(bob).AllowHosts("*")
+	aliceTopicACL := kadm.CreateACLsResult{Principal: alice, Host: "*", Type: kmsg.ACLResourceTypeTopic, Name: "topic", Pattern: kadm.ACLPatternLiteral, Operation: kadm.OpRead, Permission: kmsg.ACLPermissionTypeAllow}
+	// bob is declared in another topic and already has the group ACL.
+	bobGroupACL := kadm.CreateACLsResult{Principal: bob, Host: "*", Type: kmsg.ACLResourceTypeGroup, Name: "*", Pattern: kadm.ACLPatternLiteral, Operation: kadm.OpRead, Permission: kmsg.ACLPermissionTypeAllow}
+	bobTopicACL := kadm.CreateACLsResult{Principal: bob, Host: "*", Type: kmsg.ACLResourceTypeTopic, Name: "topic", Pattern: kadm.ACLPatternLiteral, Operation: kadm.OpWrite, Permission: kmsg.ACLPermissionTypeAllow, Err: kerr.InvalidRequest}
+
+	cases := []testCase{
+		{
+			name: "Later ACL fails",
+			err:  kerr.InvalidRequest.Error() + " (rolled back 1 ACLs)",
+		},
+		{
+			name:      "Rollback fails",
+			deleteErr: kerr.RequestTimedOut,
+			err:       kerr.InvalidRequest.Error() + " (rollback failed: " + kerr.RequestTimedOut.Error() + ")",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			ctx := context.TODO()
+			um, m := newTestUserManager(ctrl)
+			gomock.InOrder(
+				m.kafkaClient.EXPECT().DescribeACLs(ctx, aliceTopic).Return(kadm.DescribeACLsResults{{}}, error(nil)),
+				m.kafkaClient.EXPECT().DescribeACLs(ctx, bobGroup).Return(kadm.DescribeACLsResults{{Described: kadm.DescribedACLs{{Principal: bob, Host: "*", Type: kmsg.ACLResourceTypeGroup, Name: "*", Pattern: kadm.ACLPatternLiteral, Operation: kadm.OpRead, Permission: kmsg.ACLPermissionTypeAllow}}}}, error(nil)),
+				m.kafkaClient.EXPECT().DescribeACLs(ctx, bobTopic).Return(kadm.DescribeACLsResults{{}}, error(nil)),
+				m.kafkaClient.EXPECT().CreateACLs(ctx, aliceTopic).Return(kadm.CreateACLsResults{aliceTopicACL}, error(nil)),
+				m.kafkaClient.EXPECT().CreateACLs(ctx, bobGroup).Return(kadm.CreateACLsResults{bobGroupACL}, error(nil)),
+				m.kafkaClient.EXPECT().CreateACLs(ctx, bobTopic).Return(kadm.CreateACLsResults{bobTopicACL}, error(nil)),
+				m.kafkaClient.EXPECT().DescribeACLs(ctx, gomock.Any()).Return(kadm.DescribeACLsResults{{}}, error(nil)),
+				// Only the ACL created by this call is removed.
+				m.kafkaClient.EXPECT().DeleteACLs(ctx, aclFilter(&aliceTopicACL)).Return(kadm.DeleteACLsResults{{}}, c.deleteErr),
+			)
+
+			// Act
+			err := um.ApplyACLs(ctx, []*kadm.ACLBuilder{aliceTopic, bobGroup, bobTopic})
+
+			// Assert
+			assert.EqualError(t, err, c.err)
+		})
+	}
+
+	t.Run("All ACLs created", func(t *testing.T) {
+		// Arrange
+		ctx := context.TODO()
+		um, m := newTestUserManager(ctrl)
+		m.kafkaClient.EXPECT().DescribeACLs(ctx, gomock.Any()).Return(kadm.DescribeACLsResults{{}}, error(nil)).Times(2)
+		m.kafkaClient.EXPECT().CreateACLs(ctx, aliceTopic).Return(kadm.CreateACLsResults{aliceTopicACL}, error(nil))
+		m.kafkaClient.EXPECT().CreateACLs(ctx, bobGroup).Return(kadm.CreateACLsResults{bobGroupACL}, error(nil))
+
+		// Act
+		err := um.ApplyACLs(ctx, []*kadm.ACLBuilder{aliceTopic, bobGroup})
+
+		// Assert
+		assert.Nil(t, err)
+	})
+
+	t.Run("Retriable failure is retried", func(t *testing.T) {
+		// Arrange
+		ctx := context.TODO()
+		um, m := newTestUserManager(ctrl)
+		unavailable := aliceTopicACL
+		unavailable.Err = kerr.CoordinatorNotAvailable
+		m.kafkaClient.EXPECT().DescribeACLs(ctx, aliceTopic).Return(kadm.DescribeACLsResults{{}}, error(nil))
+		gomock.InOrder(
+			m.kafkaClient.EXPECT().CreateACLs(ctx, aliceTopic).Return(kadm.CreateACLsResults{unavailable}, error(nil)),
+			m.kafkaClient.EXPECT().DescribeACLs(ctx, gomock.Any()).Return(kadm.DescribeACLsResults{{}}, error(nil)),
+			m.kafkaClient.EXPECT().CreateACLs(ctx, aliceTopic).Return(kadm.CreateACLsResults{aliceTopicACL}, error(nil)),
+		)
+
+		// Act
+		err := um.ApplyACLs(ctx, []*kadm.ACLBuilder{aliceTopic})
+
+		// Assert
+		assert.Nil(t, err)
+	})
+}
+
+func TestCreateACLsInTransaction(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Arrange
+	tx := newACLTransaction()
+	ctx := withACLTransaction(context.TODO(), tx)
+	um, _ := newTestUserManager(ctrl)
+
+	// Act
+	err := um.CreateACLs(ctx, "topic", &tt.User{Username: "alice"}, "stack", []tt.Permission{tt.PermissionRead})
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, um.userPermissionToACL("topic", kadm.ACLPatternLiteral, nil, []string{"*"}, defaultSecretNameTemplate.Name("alice", "stack"), []tt.Permission{tt.PermissionRead}, groupACLsDescribe, "", true), tx.ACLs())
+}
@@ -0,0 +1,80 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws-samples/amazon-msk-topic-resource/types"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// User credentials and ACLs are in place.
+	UserStatusACLsApplied = "ACLS_APPLIED"
+	// User credentials are in place but ACLs could not be created.
+	UserStatusCreated = "CREATED"
+	// User could not be created.
+	UserStatusFailed = "FAILED"
+	// User was not attempted because a previous user failed.
+	UserStatusSkipped = "SKIPPED"
+	// User was created but deleted again because a later user failed.
+	UserStatusRolledBack = "ROLLED_BACK"
+)
+
+// Returns the ARNs of the secrets of the users of info, which are reported
+// as attributes so that applications can look up their credentials.
+// Serverless clusters do not use secrets.
+func lookupSecretArns(ctx context.Context, userManager UserManagerService, info *types.TopicInfo, shortStackID string, serverless bool) (map[string]string, error) {
+	if serverless || !hasScramUsers(info) {
+		return nil, nil
+	}
+	arns, err := userManager.SecretArns(ctx, info.Users, shortStackID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return arns, nil
+}
+
+// userResult reports the outcome of provisioning a single user so that
+// operators can tell which users were affected by a partial failure.
+type userResult struct {
+	Username string
+	Status   string
+	Reason   string `json:",omitempty"`
+}
+
+func newUserResult(username string, err error) userResult {
+	if err == nil {
+		return userResult{Username: username, Status: UserStatusACLsApplied}
+	}
+	status := UserStatusFailed
+	var ae *aclError
+	if errors.As(err, &ae) {
+		status = UserStatusCreated
+	}
+	return userResult{Username: username, Status: status, Reason: err.Error()}
+}
+
+func userResultsJSON(results []userResult) (string, error) {
+	buf, err := json.Marshal(results)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(buf), nil
+}
+
+// Returns a compact summary of user results suitable for
+// CloudFormation failure reasons.
+func userResultsSummary(results []userResult) string {
+	s := make([]string, len(results))
+	for i, r := range results {
+		s[i] = fmt.Sprintf("%s: %s", r.Username, r.Status)
+	}
+	return fmt.Sprintf("user results [%s]", strings.Join(s, ", "))
+}
@@ -8,21 +8,71 @@ import (
 	"encoding/base32"
 	"encoding/base64"
 	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	tt "github.com/aws-samples/amazon-msk-topic-resource/types"
 
 	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
 	"github.com/pkg/errors"
 )
 
+// Codes of AWS API errors that retrying cannot resolve, regardless of the
+// status code they are returned with.
+var nonRetriableErrorCodes = map[string]bool{
+	"AccessDeniedException":            true,
+	"ValidationException":              true,
+	"MalformedPolicyDocumentException": true,
+	"InvalidParameterException":        true,
+	"InvalidArnException":              true,
+	"DisabledException":                true,
+	"UnauthorizedException":            true,
+}
+
+// Reports whether err is transient. AWS API errors are transient when
+// they indicate throttling or a server side failure, unless their code is
+// known to be fatal.
 func isRetriable(err error) bool {
+	var ae smithy.APIError
+	if errors.As(err, &ae) && nonRetriableErrorCodes[ae.ErrorCode()] {
+		return false
+	}
 	var re *awshttp.ResponseError
 	if errors.As(err, &re) {
-		return re.Response.StatusCode >= 500
+		return re.Response.StatusCode >= 500 || re.Response.StatusCode == http.StatusTooManyRequests
 	}
 	return true
 }
 
 func shortStackID(stackID string) string {
-	h := sha256.Sum256([]byte(stackID))
+	return shortHash(stackID)
+}
+
+// Returns the suffix used in canonical topic and usernames. Defaults to
+// the short stack ID unless the resource specifies its own suffix.
+func nameSuffix(info *tt.TopicInfo, stackID string) string {
+	if info.NameSuffix != nil {
+		return *info.NameSuffix
+	}
+	return shortStackID(stackID)
+}
+
+func shortClusterID(clusterArn string) string {
+	return shortHash(clusterArn)
+}
+
+// Physical resource ID of a topic. Topics of the same name in different
+// clusters are distinguished by a short hash of the cluster ARN, which
+// cannot be mistaken for part of the topic name since '@' is not valid in
+// topic names.
+func physicalResourceID(clusterArn, topicName string) string {
+	return fmt.Sprintf("%s@%s", topicName, shortClusterID(clusterArn))
+}
+
+func shortHash(s string) string {
+	h := sha256.Sum256([]byte(s))
 	id := base32.StdEncoding.WithPadding(base64.NoPadding).EncodeToString(h[0:])
 	return id[0:8]
 }
@@ -30,17 +80,120 @@ func shortStackID(stackID string) string {
 // Canonical topic name is used to ensure that same topic name
 // used in two different CF templates are not referring to the same
 // topic. Canonical topic name is created by appending a short hash
-// of stackID to topic name.
+// of stackID (or the suffix specified in the resource) to topic name.
 func canonicalTopicName(name, shortStackID string) string {
+	if shortStackID == "" {
+		return name
+	}
 	return fmt.Sprintf("%s-%s", name, shortStackID)
 }
 
-// Canonical username is used to ensure that two usernames used in
-// two different CF templates are not referring to the same user.
-// Canonical user name is created by appending a short hash of stackID
-// to topic name.
-// If two topics in the same CF template use the same username, they
-// will share the user account.
-func canonicalUsername(username, shortStackID string) string {
-	return fmt.Sprintf("AmazonMSK_%s_%s", username, shortStackID)
+// secretNameTemplate formats the names of secrets generated for users,
+// which are also their SASL/SCRAM usernames. {username} is replaced by the
+// declared username and {suffix} by the name suffix of the stack, so that
+// two usernames used in two different CF templates do not refer to the
+// same user. If two topics in the same CF template use the same username,
+// they will share the user account.
+type secretNameTemplate string
+
+const (
+	defaultSecretNameTemplate secretNameTemplate = "AmazonMSK_{username}_{suffix}"
+	// MSK only associates secrets whose name begins with this prefix.
+	secretNamePrefix    = "AmazonMSK_"
+	placeholderUsername = "{username}"
+	placeholderSuffix   = "{suffix}"
+)
+
+var secretNameLiteral = regexp.MustCompile(`^[A-Za-z0-9/_+=.@-]*$`)
+
+// Returns an error when names formatted by t cannot be associated with
+// MSK or do not identify the stack.
+func (t secretNameTemplate) Validate() error {
+	s := string(t)
+	if !strings.HasPrefix(s, secretNamePrefix) {
+		return errors.WithStack(fmt.Errorf("secret name template must begin with %s: %q", secretNamePrefix, s))
+	}
+	if strings.Count(s, placeholderUsername) != 1 || strings.Count(s, placeholderSuffix) != 1 {
+		return errors.WithStack(fmt.Errorf("secret name template must contain %s and %s once: %q", placeholderUsername, placeholderSuffix, s))
+	}
+	literal := strings.NewReplacer(placeholderUsername, "", placeholderSuffix, "").Replace(s)
+	if !secretNameLiteral.MatchString(literal) {
+		return errors.WithStack(fmt.Errorf("secret name template may only contain letters, digits and /_+=.@- besides placeholders: %q", s))
+	}
+	return nil
+}
+
+// Returns the secret name of username. When shortStackID is empty,
+// {suffix} is omitted along with a separator preceding it.
+func (t secretNameTemplate) Name(username, shortStackID string) string {
+	r := strings.NewReplacer(placeholderUsername, username, placeholderSuffix, shortStackID)
+	if shortStackID == "" {
+		r = strings.NewReplacer(placeholderUsername, username, "_"+placeholderSuffix, "", "-"+placeholderSuffix, "", placeholderSuffix, "")
+	}
+	return r.Replace(string(t))
+}
+
+// Returns the parts of the secret names of the stack preceding and
+// following the username. Names only differ in the username.
+func (t secretNameTemplate) Affixes(shortStackID string) (string, string) {
+	parts := strings.SplitN(t.Name("\x00", shortStackID), "\x00", 2)
+	return parts[0], parts[1]
+}
+
+// Reports whether name is the secret name of a user generated by the
+// stack.
+func (t secretNameTemplate) IsStackSecret(name, shortStackID string) bool {
+	prefix, suffix := t.Affixes(shortStackID)
+	return len(name) > len(prefix)+len(suffix) && strings.HasPrefix(name, prefix) && strings.HasSuffix(name, suffix)
+}
+
+// Reports whether principal belongs to a user generated by the stack.
+func (t secretNameTemplate) IsStackPrincipal(principal, shortStackID string) bool {
+	return strings.HasPrefix(principal, "User:") && t.IsStackSecret(strings.TrimPrefix(principal, "User:"), shortStackID)
+}
+
+// Returns the SASL/SCRAM username used as ACL principal for the user.
+// Users with an externally managed secret keep their declared username.
+func principalName(u *tt.User, shortStackID string, names secretNameTemplate) string {
+	if u.UsesTLS() {
+		return u.Principal
+	}
+	if u.UsesIAM() {
+		return u.IamPrincipal
+	}
+	if u.SecretArn != "" {
+		return u.Username
+	}
+	return names.Name(u.Username, shortStackID)
+}
+
+func containsString(values []string, v string) bool {
+	for _, e := range values {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns values without v.
+func removeString(values []string, v string) []string {
+	kept := make([]string, 0, len(values))
+	for _, e := range values {
+		if e != v {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// Returns the values in a that are not in b.
+func subtractStrings(a, b []string) []string {
+	diff := make([]string, 0)
+	for _, e := range a {
+		if !containsString(b, e) {
+			diff = append(diff, e)
+		}
+	}
+	return diff
 }
@@ -0,0 +1,102 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package admin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	kmst "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	smt "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretNameTemplate(t *testing.T) {
+	cases := []struct {
+		template       secretNameTemplate
+		shortStackID   string
+		name           string
+		stackPrincipal string
+		otherPrincipal string
+	}{
+		{defaultSecretNameTemplate, "abc", "AmazonMSK_alice_abc", "User:AmazonMSK_bob_abc", "User:AmazonMSK_bob_xyz"},
+		{defaultSecretNameTemplate, "", "AmazonMSK_alice", "", ""},
+		{"AmazonMSK_team-a_{username}_{suffix}", "abc", "AmazonMSK_team-a_alice_abc", "User:AmazonMSK_team-a_bob_abc", "User:AmazonMSK_bob_abc"},
+		{"AmazonMSK_{suffix}.{username}", "abc", "AmazonMSK_abc.alice", "User:AmazonMSK_abc.bob", "User:AmazonMSK_xyz.bob"},
+	}
+
+	for _, c := range cases {
+		t.Run(string(c.template)+"/"+c.shortStackID, func(t *testing.T) {
+			assert.Nil(t, c.template.Validate())
+			assert.Equal(t, c.name, c.template.Name("alice", c.shortStackID))
+			if c.stackPrincipal != "" {
+				assert.True(t, c.template.IsStackPrincipal(c.stackPrincipal, c.shortStackID))
+				assert.False(t, c.template.IsStackPrincipal(c.otherPrincipal, c.shortStackID))
+				assert.False(t, c.template.IsStackPrincipal("User:CN=bob", c.shortStackID))
+			}
+		})
+	}
+}
+
+func TestSecretNameTemplateValidate(t *testing.T) {
+	cases := map[string]string{
+		"MSK_{username}_{suffix}":              "secret name template must begin with AmazonMSK_: \"MSK_{username}_{suffix}\"",
+		"AmazonMSK_{username}":                 "secret name template must contain {username} and {suffix} once: \"AmazonMSK_{username}\"",
+		"AmazonMSK_{username}_{username}":      "secret name template must contain {username} and {suffix} once: \"AmazonMSK_{username}_{username}\"",
+		"AmazonMSK_{username} {suffix}":        "secret name template may only contain letters, digits and /_+=.@- besides placeholders: \"AmazonMSK_{username} {suffix}\"",
+		"AmazonMSK_{username}_{suffix}_{team}": "secret name template may only contain letters, digits and /_+=.@- besides placeholders: \"AmazonMSK_{username}_{suffix}_{team}\"",
+	}
+
+	for template, msg := range cases {
+		t.Run(template, func(t *testing.T) {
+			assert.EqualError(t, secretNameTemplate(template).Validate(), msg)
+		})
+	}
+}
+
+func TestIsRetriable(t *testing.T) {
+	type testCase struct {
+		err      error
+		expected bool
+	}
+
+	cases := map[string]testCase{
+		"Access denied": {
+			err: &smithy.OperationError{ServiceID: "KMS", OperationName: "CreateGrant", Err: newResponseError(400, &smithy.GenericAPIError{Code: "AccessDeniedException"})},
+		},
+		"Validation": {
+			err: &smithy.GenericAPIError{Code: "ValidationException", Message: "1 validation error detected"},
+		},
+		"Malformed policy document": {
+			err: &smt.MalformedPolicyDocumentException{Message: aws.String("invalid policy")},
+		},
+		"Disabled KMS key": {
+			err: &kmst.DisabledException{Message: aws.String("key is disabled")},
+		},
+		"Fatal code with server error status": {
+			err: newResponseError(500, &smithy.GenericAPIError{Code: "ValidationException"}),
+		},
+		"Client error": {
+			err: newResponseError(400, &smt.InvalidRequestException{Message: aws.String("invalid request")}),
+		},
+		"Throttling": {
+			err:      newResponseError(429, &smithy.GenericAPIError{Code: "ThrottlingException"}),
+			expected: true,
+		},
+		"Server error": {
+			err:      newResponseError(503, &smt.InternalServiceError{Message: aws.String("unavailable")}),
+			expected: true,
+		},
+		"Not an AWS error": {
+			err:      errors.New("connection reset"),
+			expected: true,
+		},
+	}
+
+	for k, c := range cases {
+		assert.Equal(t, c.expected, isRetriable(c.err), k)
+	}
+}
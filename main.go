@@ -5,12 +5,12 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/aws-samples/amazon-msk-topic-resource/admin"
 
 	"github.com/aws/aws-lambda-go/cfn"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/kafka"
 	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
@@ -22,19 +22,64 @@ func init() {
 	// Create the handler and store in global space to re-use
 	// client instances between multiple invocations of Lambda.
 	handler = cfn.LambdaWrap(func(ctx context.Context, event cfn.Event) (string, map[string]interface{}, error) {
-		cfg, err := config.LoadDefaultConfig(ctx)
+		handler, err := newHandler(ctx)
 		if err != nil {
 			return "", nil, err
 		}
-		mskClient := kafka.NewFromConfig(cfg)
-		secretsManagerClient := secretsmanager.NewFromConfig(cfg)
-		kmsClient := kms.NewFromConfig(cfg)
-		kafkaClientProvider := admin.NewIamKafkaClientProvider(mskClient)
-		handler := admin.NewHandler(mskClient, kmsClient, secretsManagerClient, kafkaClientProvider)
 		return handler.Handle(ctx, event)
 	})
 }
 
+func newHandler(ctx context.Context) (*admin.Handler, error) {
+	settings, err := admin.NewSettingsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := admin.LoadAWSConfig(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+	mskClient := kafka.NewFromConfig(cfg)
+	secretsManagerClient := secretsmanager.NewFromConfig(cfg)
+	kmsClient := kms.NewFromConfig(cfg)
+	iamClient := admin.NewIamClient(cfg)
+	s3Client := admin.NewS3Client(cfg)
+	var kafkaClientProvider admin.KafkaClientProvider = admin.NewIamKafkaClientProvider(mskClient, settings)
+	if settings.ScramSecretArn != "" {
+		kafkaClientProvider, err = admin.NewScramKafkaClientProvider(mskClient, secretsManagerClient, settings)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return admin.NewHandler(mskClient, kmsClient, secretsManagerClient, iamClient, s3Client, kafkaClientProvider, settings), nil
+}
+
+// Operators invoke the function directly with a self-test request to
+// check connectivity and permissions, or with a stack report request to
+// find the resources of a stack. Any other event is handled as a
+// CloudFormation custom resource request.
+func invoke(ctx context.Context, payload json.RawMessage) (interface{}, error) {
+	if req, ok := admin.ParseSelfTestRequest(payload); ok {
+		handler, err := newHandler(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler.SelfTest(ctx, req)
+	}
+	if req, ok := admin.ParseStackReportRequest(payload); ok {
+		handler, err := newHandler(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler.DescribeStack(ctx, req)
+	}
+	var event cfn.Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return handler(ctx, event)
+}
+
 func main() {
-	lambda.Start(handler)
+	lambda.Start(invoke)
 }
@@ -0,0 +1,46 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package types
+
+import "fmt"
+
+const (
+	ConfigProfileStreaming    = "streaming"
+	ConfigProfileCompactedLog = "compacted-log"
+)
+
+// Built-in topic configuration bundles that can be referenced via
+// ConfigProfile property instead of repeating them in every topic.
+var configProfiles = map[string]map[string]string{
+	ConfigProfileStreaming: {
+		"cleanup.policy": "delete",
+		"retention.ms":   "604800000",
+	},
+	ConfigProfileCompactedLog: {
+		"cleanup.policy":            "compact",
+		"min.cleanable.dirty.ratio": "0.5",
+		"delete.retention.ms":       "86400000",
+	},
+}
+
+// Merges the properties of the named profile into config. Properties
+// explicitly specified in config take precedence over the profile.
+func expandConfigProfile(profile string, config map[string]*string) (map[string]*string, error) {
+	if profile == "" {
+		return config, nil
+	}
+	p, ok := configProfiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("unknown ConfigProfile: %s", profile)
+	}
+	merged := make(map[string]*string, len(p)+len(config))
+	for k, v := range p {
+		v := v
+		merged[k] = &v
+	}
+	for k, v := range config {
+		merged[k] = v
+	}
+	return merged, nil
+}
@@ -6,7 +6,12 @@ package types
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/xeipuuv/gojsonschema"
 )
@@ -26,23 +31,96 @@ const topicInfoSchema string = `
 			"properties": {
 				"Username": {
 					"type": "string",
-					"description": "Username for the user. TR will append a short random string to ensure that usernames created via different stacks do not conflict. All usernames created within a stack has the same suffix."
+					"description": "Username for the user. TR will append a short random string to ensure that usernames created via different stacks do not conflict. All usernames created within a stack has the same suffix. Only ASCII letters, digits, '.', '_' and '-' are allowed."
 				},
 				"Arn": {
 					"type": "string",
 					"description": "ARN of an IAM entity that should have access to the SecretsManager secret containing credentials for the user. This can be used to configure producers and consumers discover credentials at runtime."
 				},
+				"Arns": {
+					"type": "array",
+					"description": "ARNs of additional IAM entities that should have access to the SecretsManager secret containing credentials for the user.",
+					"items": {
+						"type": "string"
+					}
+				},
 				"Permissions": { 
 					"type": "array",
-					"description": "Operations allowed for this user. Available options are READ/WRITE.",
+					"description": "Operations allowed for this user. Available options are READ/WRITE/OFFSET_MANAGEMENT. OFFSET_MANAGEMENT allows a READ user to reset and delete consumer group offsets and requires READ.",
 					"items": {
 						"type": "string",
 						"enum": [
 							"READ",
-							"WRITE"
+							"WRITE",
+							"OFFSET_MANAGEMENT"
 						]
 					}
-				} 
+				},
+				"SecretArn": {
+					"type": "string",
+					"description": "ARN of an existing SecretsManager secret containing username and password for the user. When specified, TR associates this secret with the cluster instead of generating credentials. The username stored in the secret must match Username."
+				},
+				"GroupName": {
+					"type": "string",
+					"description": "Consumer group used by this user. Required when InitialGroupOffset is specified."
+				},
+				"InitialGroupOffset": {
+					"type": "string",
+					"description": "Position in the topic where consumers in GroupName start when the user is created.",
+					"enum": ["EARLIEST", "LATEST"]
+				},
+				"AuthType": {
+					"type": "string",
+					"description": "Client authentication used by the user. SCRAM users get SASL/SCRAM credentials. TLS users authenticate with a client certificate and are only granted ACLs.",
+					"enum": ["SCRAM", "TLS"]
+				},
+				"Principal": {
+					"type": "string",
+					"description": "Distinguished name of the client certificate (e.g. CN=client.example.com) used by a TLS user. Required when AuthType is TLS."
+				},
+				"IamPrincipal": {
+					"type": "string",
+					"description": "ARN of the IAM role or user of an application authenticating with IAM. TR creates ACLs for this principal instead of generating SASL/SCRAM credentials.",
+					"pattern": "^arn:aws[a-z-]*:(iam|sts)::[0-9]{12}:.+$"
+				},
+				"TopicPrefix": {
+					"type": "string",
+					"description": "Grants Permissions on every topic whose name starts with this prefix (e.g. orders.) instead of on this topic alone.",
+					"pattern": "^[a-zA-Z0-9._-]+$"
+				},
+				"AdditionalTopics": {
+					"type": "array",
+					"description": "Names of existing topics in the cluster on which Permissions are granted along with this topic.",
+					"items": {
+						"type": "string",
+						"pattern": "^[a-zA-Z0-9._-]+$"
+					}
+				},
+				"GroupDescribe": {
+					"type": "string",
+					"description": "Whether READ also grants DESCRIBE on all consumer groups (default true). Describing groups lets the user list the members, assigned partitions and committed offsets of every consumer group in the cluster, not only its own. Set to false to grant READ on groups only. Some tools (e.g. kafka-consumer-groups.sh) need DESCRIBE to inspect the user's own group.",
+					"enum": ["true", "false"]
+				},
+				"NoGroupAcls": {
+					"type": "string",
+					"description": "When true, TR does not create ACLs on consumer groups for the user, even with READ, so that they can be managed separately (default false).",
+					"enum": ["true", "false"]
+				},
+				"TopicDescribe": {
+					"type": "string",
+					"description": "Whether READ and WRITE also grant DESCRIBE on the topic (default true). Clients need DESCRIBE to fetch topic metadata.",
+					"enum": ["true", "false"]
+				},
+				"AllowedHosts": {
+					"type": "array",
+					"description": "IP addresses the user is allowed to connect from, overriding AllowedHosts of the topic.",
+					"items": {
+						"type": "string"
+					}
+				}
+			},
+			"dependencies": {
+				"InitialGroupOffset": ["GroupName"]
 			},
 			"additionalProperties": false
 		}
@@ -54,28 +132,55 @@ const topicInfoSchema string = `
 		},
 		"Name": {
 			"type": "string",
-			"description": "Topic name. TR will append a short random string to ensure that topic names created via different stacks do not conflict. All topics created within a stack has the same suffix."
+			"description": "Topic name. TR will append a short random string to ensure that topic names created via different stacks do not conflict. All topics created within a stack has the same suffix. Only ASCII letters, digits, '.', '_' and '-' are allowed."
 		},
 		"Partitions": {
 			"type": "string",
 			"description": "Number of partitions in this topic",
 			"pattern": "^[0-9]*$"
 		},
+		"NameSuffix": {
+			"type": "string",
+			"description": "Suffix appended to topic and usernames instead of the short hash of stack ID. Empty string disables the suffix.",
+			"pattern": "^[a-zA-Z0-9._-]*$"
+		},
 		"ReplicationFactor": {
 			"type": "string",
 			"description": "Replication factor for the topic",
 			"pattern": "^[0-9]*$"
 		},
+		"ReplicaAssignment": {
+			"type": "object",
+			"description": "Broker IDs holding the replicas of each partition, keyed by partition. The first broker of a partition is its preferred leader. Must assign every partition with ReplicationFactor replicas. Only applies when the topic is created.",
+			"propertyNames": { "pattern": "^[0-9]+$" },
+			"additionalProperties": {
+				"type": "array",
+				"items": { "type": "string", "pattern": "^[0-9]+$" }
+			}
+		},
+		"RackAware": {
+			"type": "string",
+			"description": "When true, replicas of each partition are placed on brokers in distinct racks (availability zones) when the topic is created (default false). Cannot be specified with ReplicaAssignment.",
+			"enum": ["true", "false"]
+		},
 		"ClusterArn": {
-			"description": "MSK cluster ARN",
-			"type": "string"
+			"description": "MSK cluster ARN, or an array of cluster ARNs to manage the same topic and users in each cluster (e.g. for active/active replication).",
+			"oneOf": [
+				{ "type": "string" },
+				{ "type": "array", "items": { "type": "string" }, "minItems": 1, "uniqueItems": true }
+			]
 		},
 		"Config": {
 			"type": "object",
 			"properties": {},
-			"description": "Additional topic configuration properties. Any Kafka topic property such as min.insync.replicas or MSK specific topic property such local.retention.ms can be specified here.",
+			"description": "Additional topic configuration properties. Any Kafka topic property such as min.insync.replicas or MSK specific topic property such local.retention.ms can be specified here. Keys starting with tr. are reserved.",
 			"additionalProperties": true
 		},
+		"ConfigProfile": {
+			"type": "string",
+			"description": "Name of a built-in configuration profile merged under Config. Properties specified in Config take precedence.",
+			"enum": ["streaming", "compacted-log"]
+		},
 		"Users": {
 			"type": "array",
 			"description": "List of users and their permissions",
@@ -83,8 +188,65 @@ const topicInfoSchema string = `
 		},
 		"DeletionPolicy": {
 			"type": "string",
-			"description": "Specify what to be done to the topic and data when the CloudFormation stack is deleted",
-			"enum": ["DELETE", "RETAIN"]
+			"description": "Specify what to be done to the topic and data when the CloudFormation stack is deleted. SNAPSHOT exports the topic metadata and offsets of consumer groups to S3 before deleting the topic.",
+			"enum": ["DELETE", "RETAIN", "SNAPSHOT"]
+		},
+		"Tags": {
+			"type": "object",
+			"description": "Tags attached to the topic. Kafka topics cannot carry AWS tags, so TR applies them to the marker secret recording the stack managing the topic.",
+			"additionalProperties": { "type": "string" }
+		},
+		"Labels": {
+			"type": "object",
+			"description": "Metadata recorded for the topic (e.g. owner). Labels are returned as Label.<Key> output attributes.",
+			"propertyNames": { "pattern": "^[a-zA-Z0-9._-]{1,119}$" },
+			"additionalProperties": { "type": "string", "maxLength": 256 }
+		},
+		"DeleteProtection": {
+			"type": "string",
+			"description": "When true, topic data is only deleted if ConfirmDelete is set to the topic name.",
+			"enum": ["true", "false"]
+		},
+		"ConfirmDelete": {
+			"type": "string",
+			"description": "Topic name confirming that topic data protected by DeleteProtection can be deleted."
+		},
+		"DryRun": {
+			"type": "string",
+			"description": "When true, TR computes the changes required to create or update the topic and reports them via DryRunPlan output attribute without applying them.",
+			"enum": ["true", "false"]
+		},
+		"StrictExistence": {
+			"type": "string",
+			"description": "When true, the topic, secrets, secret associations and ACLs that already exist when TR creates them fail the request instead of being adopted, unless they were created by a previous attempt of the same request.",
+			"enum": ["true", "false"]
+		},
+		"ForceConfigReset": {
+			"type": "string",
+			"description": "When true, configs removed from Config on update are always reset to the broker default, even when their current value differs from the value previously specified.",
+			"enum": ["true", "false"]
+		},
+		"ReplaceOnNameChange": {
+			"type": "string",
+			"description": "When true, changing Name creates a topic with the new name and returns a new physical resource ID so that CloudFormation deletes the old topic, subject to DeletionPolicy. Users keep their credentials. Otherwise Name cannot be updated.",
+			"enum": ["true", "false"]
+		},
+		"CleanupOrphanedSecrets": {
+			"type": "string",
+			"description": "When true, TR deletes secrets generated for the stack that do not belong to any user of the topic on update. Only enable it when no other topic uses the same NameSuffix.",
+			"enum": ["true", "false"]
+		},
+		"GroupPrefix": {
+			"type": "string",
+			"description": "Grants the consumer group ACLs of READ users on every group whose name starts with this prefix instead of on all groups.",
+			"pattern": "^[a-zA-Z0-9._-]+$"
+		},
+		"AllowedHosts": {
+			"type": "array",
+			"description": "IP addresses the users of the topic are allowed to connect from. ACLs apply to any host (*) when not specified.",
+			"items": {
+				"type": "string"
+			}
 		}
 	},
 	"additionalProperties": false
@@ -93,31 +255,183 @@ const topicInfoSchema string = `
 
 type Permission string
 type DeletionPolicy string
+type GroupOffset string
+type AuthType string
 
 const (
-	PermissionRead       Permission     = "READ"
-	PermissionWrite      Permission     = "WRITE"
-	DeletionPolicyDelete DeletionPolicy = "DELETE"
-	DeletionPolicyRetain DeletionPolicy = "RETAIN"
+	PermissionRead             Permission     = "READ"
+	PermissionWrite            Permission     = "WRITE"
+	PermissionOffsetManagement Permission     = "OFFSET_MANAGEMENT"
+	DeletionPolicyDelete       DeletionPolicy = "DELETE"
+	DeletionPolicyRetain       DeletionPolicy = "RETAIN"
+	DeletionPolicySnapshot     DeletionPolicy = "SNAPSHOT"
+	GroupOffsetEarliest        GroupOffset    = "EARLIEST"
+	GroupOffsetLatest          GroupOffset    = "LATEST"
+	AuthTypeSCRAM              AuthType       = "SCRAM"
+	AuthTypeTLS                AuthType       = "TLS"
+
+	// Config keys in this namespace are reserved for markers recorded by TR.
+	ConfigReservedPrefix = "tr."
 )
 
 type User struct {
-	Username    string
-	Arn         string
+	Username string
+	Arn      string
+	// Additional IAM entities with access to the secret.
+	Arns        []string
 	Permissions []Permission
+	// Externally managed secret used instead of generating credentials.
+	SecretArn string
+	// Consumer group whose offsets are initialised when the user is created.
+	GroupName          string
+	InitialGroupOffset GroupOffset
+	// Defaults to SCRAM when empty.
+	AuthType AuthType
+	// Certificate principal of a TLS user.
+	Principal string
+	// IAM role or user ARN of an application authenticating with IAM. ACLs
+	// are created for it and no secret is provisioned.
+	IamPrincipal string
+	// Grants access to all topics starting with this prefix instead of
+	// the topic alone.
+	TopicPrefix string
+	// Existing topics the user is granted Permissions on along with the
+	// topic.
+	AdditionalTopics []string
+	// Defaults to true when nil.
+	GroupDescribe *bool `json:",string"`
+	// Group ACLs of the user are managed outside TR.
+	NoGroupAcls bool `json:",string"`
+	// Defaults to true when nil.
+	TopicDescribe *bool `json:",string"`
+	// Grants group ACLs on every group starting with this prefix instead
+	// of all groups. Set from the GroupPrefix of the topic.
+	GroupPrefix string `json:"-"`
+	// Hosts the ACLs of the user apply to. Set from the AllowedHosts of
+	// the topic unless specified.
+	AllowedHosts []string
+}
+
+// Returns the ARNs of the IAM entities with access to the secret of the
+// user, Arn first and without duplicates.
+func (u *User) Principals() []string {
+	var principals []string
+	seen := make(map[string]bool, len(u.Arns)+1)
+	for _, arn := range append([]string{u.Arn}, u.Arns...) {
+		if arn == "" || seen[arn] {
+			continue
+		}
+		seen[arn] = true
+		principals = append(principals, arn)
+	}
+	return principals
+}
+
+// Reports whether the user authenticates with a client certificate
+// instead of SASL/SCRAM credentials.
+func (u *User) UsesTLS() bool {
+	return u.AuthType == AuthTypeTLS
+}
+
+// Reports whether the user is an IAM principal instead of a SASL/SCRAM
+// user.
+func (u *User) UsesIAM() bool {
+	return u.IamPrincipal != ""
+}
+
+// Reports whether the user authenticates without a SASL/SCRAM secret,
+// either with a client certificate or with IAM.
+func (u *User) Secretless() bool {
+	return u.UsesTLS() || u.UsesIAM()
+}
+
+// Returns the hosts the ACLs of the user apply to, any host when none are
+// allowed explicitly.
+func (u *User) Hosts() []string {
+	if len(u.AllowedHosts) == 0 {
+		return []string{"*"}
+	}
+	return u.AllowedHosts
+}
+
+// Reports whether READ grants DESCRIBE on consumer groups in addition to
+// READ.
+func (u *User) DescribesGroups() bool {
+	return u.GroupDescribe == nil || *u.GroupDescribe
+}
+
+// Reports whether READ and WRITE grant DESCRIBE on the topic in addition
+// to READ or WRITE.
+func (u *User) DescribesTopic() bool {
+	return u.TopicDescribe == nil || *u.TopicDescribe
 }
 
 type TopicInfo struct {
 	Name              string
+	NameSuffix        *string
 	Partitions        int `json:",string"`
 	ReplicationFactor int `json:",string"`
+	ReplicaAssignment ReplicaAssignment
+	RackAware         bool `json:",string"`
 	ClusterArn        string
+	ClusterArns       []string `json:"-"`
 	Config            map[string]*string
+	ConfigProfile     string
 	Users             []User
 	DeletionPolicy    DeletionPolicy
+	DeleteProtection  bool `json:",string"`
+	ConfirmDelete     string
+	Tags              map[string]string
+	Labels            map[string]string
+	DryRun            bool `json:",string"`
+	StrictExistence   bool `json:",string"`
+	ForceConfigReset  bool `json:",string"`
+	// Changing Name creates a new topic and CloudFormation deletes the
+	// old one along with its data.
+	ReplaceOnNameChange bool `json:",string"`
+	// Secrets are matched by NameSuffix, which is shared by the topics of
+	// a stack unless specified.
+	CleanupOrphanedSecrets bool `json:",string"`
+	// Consumer group prefix shared by the users of the topic.
+	GroupPrefix string
+	// Hosts the ACLs of all users apply to unless a user specifies its
+	// own.
+	AllowedHosts []string
+}
+
+// Returns the ARNs of the clusters the topic is managed in. ClusterArns
+// is only set when ClusterArn is an array, in which case ClusterArn is
+// the first cluster.
+func (t *TopicInfo) Clusters() []string {
+	if len(t.ClusterArns) > 0 {
+		return t.ClusterArns
+	}
+	return []string{t.ClusterArn}
+}
+
+// Returns a copy of t that manages the topic in clusterArn.
+func (t *TopicInfo) ForCluster(clusterArn string) *TopicInfo {
+	c := *t
+	c.ClusterArn = clusterArn
+	return &c
+}
+
+// Reports whether t and o are managed in the same clusters regardless of
+// their order.
+func (t *TopicInfo) SameClusters(o *TopicInfo) bool {
+	a := append([]string{}, t.Clusters()...)
+	b := append([]string{}, o.Clusters()...)
+	sort.Strings(a)
+	sort.Strings(b)
+	return strings.Join(a, ",") == strings.Join(b, ",")
 }
 
 func NewTopicInfo(props map[string]interface{}) (*TopicInfo, error) {
+	// Treat missing properties as empty so that schema validation
+	// reports each required property rather than an invalid type.
+	if props == nil {
+		props = map[string]interface{}{}
+	}
 	buf, err := json.Marshal(props)
 	if err != nil {
 		return nil, err
@@ -131,13 +445,287 @@ func NewTopicInfo(props map[string]interface{}) (*TopicInfo, error) {
 
 	if result.Valid() {
 		var ti = TopicInfo{DeletionPolicy: DeletionPolicyRetain}
-		err := json.Unmarshal(buf, &ti)
-		return &ti, err
+		// ClusterArn is either a string or an array, therefore it is
+		// decoded separately.
+		v := struct {
+			*TopicInfo
+			ClusterArn json.RawMessage
+		}{TopicInfo: &ti}
+		err := json.Unmarshal(buf, &v)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(v.ClusterArn, &ti.ClusterArns); err == nil {
+			ti.ClusterArn = ti.ClusterArns[0]
+		} else if err := json.Unmarshal(v.ClusterArn, &ti.ClusterArn); err != nil {
+			return nil, err
+		}
+		if err := validateTopicName(&ti); err != nil {
+			return nil, err
+		}
+		ti.Config, err = expandConfigProfile(ti.ConfigProfile, ti.Config)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateConfigKeys(ti.Config); err != nil {
+			return nil, err
+		}
+		if err := validateMinInsyncReplicas(&ti); err != nil {
+			return nil, err
+		}
+		if err := validateReplicaAssignment(&ti); err != nil {
+			return nil, err
+		}
+		if err := validateHosts("AllowedHosts", ti.AllowedHosts); err != nil {
+			return nil, err
+		}
+		// Without a suffix, secret names do not identify the stack.
+		if ti.CleanupOrphanedSecrets && ti.NameSuffix != nil && *ti.NameSuffix == "" {
+			return nil, errors.New("CleanupOrphanedSecrets cannot be used with an empty NameSuffix")
+		}
+		for i := range ti.Users {
+			if err := validateName(fmt.Sprintf("Users.%d.Username", i), ti.Users[i].Username); err != nil {
+				return nil, err
+			}
+			// Access to externally managed secrets is not controlled by TR.
+			if ti.Users[i].Arn != "" && ti.Users[i].SecretArn != "" {
+				return nil, fmt.Errorf("Users.%d: Arn cannot be specified with SecretArn", i)
+			}
+			if len(ti.Users[i].Arns) > 0 && ti.Users[i].SecretArn != "" {
+				return nil, fmt.Errorf("Users.%d: Arns cannot be specified with SecretArn", i)
+			}
+			if err := validateAuthType(&ti.Users[i]); err != nil {
+				return nil, fmt.Errorf("Users.%d: %s", i, err)
+			}
+			// A group prefix of the user takes precedence over the topic.
+			if ti.Users[i].GroupPrefix == "" {
+				ti.Users[i].GroupPrefix = ti.GroupPrefix
+			}
+			if err := validateHosts(fmt.Sprintf("Users.%d.AllowedHosts", i), ti.Users[i].AllowedHosts); err != nil {
+				return nil, err
+			}
+			if len(ti.Users[i].AllowedHosts) == 0 {
+				ti.Users[i].AllowedHosts = ti.AllowedHosts
+			}
+			ti.Users[i].Permissions = uniquePermissions(ti.Users[i].Permissions)
+			if err := validatePermissions(ti.Users[i].Permissions); err != nil {
+				return nil, fmt.Errorf("Users.%d: %s", i, err)
+			}
+		}
+		return &ti, nil
 	} else {
-		msgs := make([]string, len(result.Errors()))
-		for i, e := range result.Errors() {
-			msgs[i] = e.String()
+		return nil, newValidationError(result.Errors())
+	}
+}
+
+// Kafka, MSK and SecretsManager compare names byte by byte, therefore
+// Unicode names that look the same but use different normalization forms
+// would refer to different topics and users. Restricting names to ASCII
+// characters Kafka allows in topic names avoids such mismatches.
+func validateName(field, name string) error {
+	for i, r := range name {
+		if r > unicode.MaxASCII {
+			return fmt.Errorf("%s: non-ASCII character %q at offset %d is not allowed, names that look the same can differ in bytes", field, r, i)
+		}
+		if !isNameChar(r) {
+			return fmt.Errorf("%s: character %q at offset %d is not allowed, use ASCII letters, digits, '.', '_' or '-'", field, r, i)
+		}
+	}
+	return nil
+}
+
+// Kafka rejects topic names longer than 249 characters. The limit applies
+// to the name TR creates, i.e. including the suffix appended to Name.
+const maxTopicNameLength = 249
+
+// Length of the short hash of the stack ID used as the suffix unless
+// NameSuffix is specified.
+const defaultNameSuffixLength = 8
+
+func validateTopicName(ti *TopicInfo) error {
+	if err := validateName("Name", ti.Name); err != nil {
+		return err
+	}
+	if ti.Name == "." || ti.Name == ".." {
+		return fmt.Errorf("Name: %q is not a legal topic name", ti.Name)
+	}
+	suffixLength := defaultNameSuffixLength + 1
+	if ti.NameSuffix != nil {
+		suffixLength = len(*ti.NameSuffix)
+		if suffixLength > 0 {
+			suffixLength++
+		}
+	}
+	if len(ti.Name)+suffixLength > maxTopicNameLength {
+		return fmt.Errorf("Name: %d characters with the %d characters of the suffix exceed the limit of %d characters of topic names", len(ti.Name), suffixLength, maxTopicNameLength)
+	}
+	return nil
+}
+
+func isNameChar(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-'
+}
+
+// Rejects config keys that would collide with markers TR records in the
+// topic config.
+func validateConfigKeys(config map[string]*string) error {
+	keys := make([]string, 0, len(config))
+	for k := range config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if strings.HasPrefix(k, ConfigReservedPrefix) {
+			return fmt.Errorf("Config.%s: keys starting with %s are reserved", k, ConfigReservedPrefix)
+		}
+	}
+	return nil
+}
+
+// Producers using acks=all are rejected when fewer replicas than
+// min.insync.replicas are in sync. A value exceeding ReplicationFactor can
+// never be met and makes the topic unwritable.
+func validateMinInsyncReplicas(ti *TopicInfo) error {
+	v, ok := ti.Config["min.insync.replicas"]
+	if !ok || v == nil {
+		return nil
+	}
+	n, err := strconv.Atoi(*v)
+	if err != nil {
+		return fmt.Errorf("Config.min.insync.replicas: invalid value %q, must be an integer", *v)
+	}
+	if n > ti.ReplicationFactor {
+		return fmt.Errorf("Config.min.insync.replicas: %d exceeds ReplicationFactor %d, the topic would not accept writes with acks=all", n, ti.ReplicationFactor)
+	}
+	return nil
+}
+
+// ReplicaAssignment maps partitions to the IDs of brokers holding their
+// replicas.
+type ReplicaAssignment map[int32][]int32
+
+// CloudFormation passes broker IDs as strings.
+func (a *ReplicaAssignment) UnmarshalJSON(b []byte) error {
+	var raw map[int32][]json.Number
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*a = make(ReplicaAssignment, len(raw))
+	for p, ids := range raw {
+		replicas := make([]int32, len(ids))
+		for i, id := range ids {
+			v, err := strconv.ParseInt(id.String(), 10, 32)
+			if err != nil {
+				return fmt.Errorf("ReplicaAssignment.%d: invalid broker ID %q", p, id)
+			}
+			replicas[i] = int32(v)
+		}
+		(*a)[p] = replicas
+	}
+	return nil
+}
+
+// Kafka requires an assignment to cover every partition with the same
+// number of replicas. Check that it matches Partitions and
+// ReplicationFactor so that both properties keep describing the topic.
+func validateReplicaAssignment(ti *TopicInfo) error {
+	if len(ti.ReplicaAssignment) == 0 {
+		return nil
+	}
+	if ti.RackAware {
+		return errors.New("RackAware cannot be specified with ReplicaAssignment")
+	}
+	if len(ti.ReplicaAssignment) != ti.Partitions {
+		return fmt.Errorf("ReplicaAssignment: assigns %d partitions but Partitions is %d", len(ti.ReplicaAssignment), ti.Partitions)
+	}
+	for p := 0; p < ti.Partitions; p++ {
+		replicas, ok := ti.ReplicaAssignment[int32(p)]
+		if !ok {
+			return fmt.Errorf("ReplicaAssignment: partition %d is not assigned", p)
+		}
+		if len(replicas) != ti.ReplicationFactor {
+			return fmt.Errorf("ReplicaAssignment.%d: assigns %d replicas but ReplicationFactor is %d", p, len(replicas), ti.ReplicationFactor)
+		}
+		seen := make(map[int32]bool, len(replicas))
+		for _, b := range replicas {
+			if seen[b] {
+				return fmt.Errorf("ReplicaAssignment.%d: broker %d assigned more than once", p, b)
+			}
+			seen[b] = true
+		}
+	}
+	return nil
+}
+
+// TLS and IAM users have no SASL/SCRAM secret. Therefore properties
+// configuring the secret do not apply to them.
+func validateAuthType(u *User) error {
+	if u.UsesIAM() {
+		if u.UsesTLS() {
+			return errors.New("IamPrincipal cannot be specified when AuthType is TLS")
+		}
+		if u.Arn != "" || len(u.Arns) > 0 || u.SecretArn != "" {
+			return errors.New("Arn, Arns and SecretArn cannot be specified with IamPrincipal")
+		}
+	}
+	if !u.UsesTLS() {
+		if u.Principal != "" {
+			return errors.New("Principal can only be specified when AuthType is TLS")
+		}
+		return nil
+	}
+	if u.Principal == "" {
+		return errors.New("Principal is required when AuthType is TLS")
+	}
+	if u.Arn != "" || u.SecretArn != "" {
+		return errors.New("Arn and SecretArn cannot be specified when AuthType is TLS")
+	}
+	if len(u.Arns) > 0 {
+		return errors.New("Arns cannot be specified when AuthType is TLS")
+	}
+	return nil
+}
+
+// Kafka matches the host of an ACL against the IP address of the client
+// verbatim, therefore only IP addresses and * match any client. Host
+// names and CIDR ranges are never matched.
+func validateHosts(field string, hosts []string) error {
+	for i, h := range hosts {
+		if h != "*" && net.ParseIP(h) == nil {
+			return fmt.Errorf("%s.%d: %q is not an IP address or *", field, i, h)
+		}
+	}
+	return nil
+}
+
+// Offset management is only meaningful for consumers, therefore it has to
+// be combined with READ.
+func validatePermissions(permissions []Permission) error {
+	var read, offsets bool
+	for _, p := range permissions {
+		switch p {
+		case PermissionRead:
+			read = true
+		case PermissionOffsetManagement:
+			offsets = true
+		}
+	}
+	if offsets && !read {
+		return errors.New("OFFSET_MANAGEMENT can only be specified with READ")
+	}
+	return nil
+}
+
+// Removes duplicate permissions preserving the order in which they
+// were first declared.
+func uniquePermissions(permissions []Permission) []Permission {
+	seen := make(map[Permission]bool, len(permissions))
+	unique := make([]Permission, 0, len(permissions))
+	for _, p := range permissions {
+		if !seen[p] {
+			seen[p] = true
+			unique = append(unique, p)
 		}
-		return nil, errors.New(strings.Join(msgs, " "))
 	}
+	return unique
 }
@@ -5,9 +5,11 @@ package types
 
 import (
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/xeipuuv/gojsonschema"
 )
 
 func TestNewTopicInfo(t *testing.T) {
@@ -21,6 +23,10 @@ func TestNewTopicInfo(t *testing.T) {
 			Input: map[string]interface{}{},
 			Err:   errors.New("(root): ServiceToken is required (root): Name is required (root): Partitions is required (root): ReplicationFactor is required (root): ClusterArn is required"),
 		},
+		"Nil map": {
+			Input: nil,
+			Err:   errors.New("(root): ServiceToken is required (root): Name is required (root): Partitions is required (root): ReplicationFactor is required (root): ClusterArn is required"),
+		},
 		"Basic Topic Info": {
 			Input: map[string]interface{}{
 				"ServiceToken":      "st",
@@ -37,6 +43,43 @@ func TestNewTopicInfo(t *testing.T) {
 				DeletionPolicy:    DeletionPolicyRetain,
 			},
 		},
+		"Multiple clusters": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        []string{"arn-a", "arn-b"},
+			},
+			Output: &TopicInfo{
+				Name:              "topic-a",
+				Partitions:        1,
+				ReplicationFactor: 3,
+				ClusterArn:        "arn-a",
+				ClusterArns:       []string{"arn-a", "arn-b"},
+				DeletionPolicy:    DeletionPolicyRetain,
+			},
+		},
+		"Empty cluster list": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        []string{},
+			},
+			Err: errors.New("ClusterArn: Must validate one and only one schema (oneOf) ClusterArn: Array must have at least 1 items"),
+		},
+		"Duplicate clusters": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        []string{"arn-a", "arn-a"},
+			},
+			Err: errors.New("ClusterArn: Must validate one and only one schema (oneOf) ClusterArn: array items[0,1] must be unique"),
+		},
 		"Topic with users": {
 			Input: map[string]interface{}{
 				"ServiceToken":      "st",
@@ -61,6 +104,239 @@ func TestNewTopicInfo(t *testing.T) {
 				DeletionPolicy: DeletionPolicyRetain,
 			},
 		},
+		"Config": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Config":            map[string]interface{}{"retention.ms": "1000", "track.me": "x"},
+			},
+			Output: &TopicInfo{
+				Name:              "topic-a",
+				Partitions:        1,
+				ReplicationFactor: 3,
+				ClusterArn:        "arn",
+				Config:            map[string]*string{"retention.ms": stringPtr("1000"), "track.me": stringPtr("x")},
+				DeletionPolicy:    DeletionPolicyRetain,
+			},
+		},
+		"Reserved config key": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Config":            map[string]interface{}{"retention.ms": "1000", "tr.managed-by": "me"},
+			},
+			Err: errors.New("Config.tr.managed-by: keys starting with tr. are reserved"),
+		},
+		"Replica assignment": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "2",
+				"ReplicationFactor": "2",
+				"ClusterArn":        "arn",
+				"ReplicaAssignment": map[string]interface{}{"0": []string{"1", "2"}, "1": []string{"2", "3"}},
+			},
+			Output: &TopicInfo{
+				Name:              "topic-a",
+				Partitions:        2,
+				ReplicationFactor: 2,
+				ReplicaAssignment: ReplicaAssignment{0: {1, 2}, 1: {2, 3}},
+				ClusterArn:        "arn",
+				DeletionPolicy:    DeletionPolicyRetain,
+			},
+		},
+		"Replica assignment missing partition": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "2",
+				"ReplicationFactor": "2",
+				"ClusterArn":        "arn",
+				"ReplicaAssignment": map[string]interface{}{"0": []string{"1", "2"}, "2": []string{"2", "3"}},
+			},
+			Err: errors.New("ReplicaAssignment: partition 1 is not assigned"),
+		},
+		"Replica assignment partition count mismatch": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "2",
+				"ReplicationFactor": "2",
+				"ClusterArn":        "arn",
+				"ReplicaAssignment": map[string]interface{}{"0": []string{"1", "2"}},
+			},
+			Err: errors.New("ReplicaAssignment: assigns 1 partitions but Partitions is 2"),
+		},
+		"Replica assignment replication factor mismatch": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"ReplicaAssignment": map[string]interface{}{"0": []string{"1", "2"}},
+			},
+			Err: errors.New("ReplicaAssignment.0: assigns 2 replicas but ReplicationFactor is 3"),
+		},
+		"Replica assignment duplicate broker": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "2",
+				"ClusterArn":        "arn",
+				"ReplicaAssignment": map[string]interface{}{"0": []string{"1", "1"}},
+			},
+			Err: errors.New("ReplicaAssignment.0: broker 1 assigned more than once"),
+		},
+		"Rack aware with replica assignment": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "2",
+				"ClusterArn":        "arn",
+				"RackAware":         "true",
+				"ReplicaAssignment": map[string]interface{}{"0": []string{"1", "2"}},
+			},
+			Err: errors.New("RackAware cannot be specified with ReplicaAssignment"),
+		},
+		"Invalid replica assignment": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "1",
+				"ClusterArn":        "arn",
+				"ReplicaAssignment": map[string]interface{}{"0": []string{"b-1"}},
+			},
+			Err: errors.New("ReplicaAssignment.0.0: Does not match pattern '^[0-9]+$'"),
+		},
+		"Invalid topic prefix": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "TopicPrefix": "orders.*", "Permissions": []string{"READ"}},
+				},
+			},
+			Err: errors.New("Users.0.TopicPrefix: Does not match pattern '^[a-zA-Z0-9._-]+$'"),
+		},
+		"Non-ASCII name": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "caf\u00e9",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+			},
+			Err: errors.New("Name: non-ASCII character 'é' at offset 3 is not allowed, names that look the same can differ in bytes"),
+		},
+		"Decomposed Unicode name": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "cafe\u0301",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+			},
+			Err: errors.New("Name: non-ASCII character '\u0301' at offset 4 is not allowed, names that look the same can differ in bytes"),
+		},
+		"Disallowed character in name": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+			},
+			Err: errors.New("Name: character ' ' at offset 5 is not allowed, use ASCII letters, digits, '.', '_' or '-'"),
+		},
+		"Dot name": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "..",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+			},
+			Err: errors.New("Name: \"..\" is not a legal topic name"),
+		},
+		"Name too long with suffix": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              strings.Repeat("a", 241),
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+			},
+			Err: errors.New("Name: 241 characters with the 9 characters of the suffix exceed the limit of 249 characters of topic names"),
+		},
+		"Name too long with NameSuffix": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              strings.Repeat("a", 245),
+				"NameSuffix":        "prod",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+			},
+			Err: errors.New("Name: 245 characters with the 5 characters of the suffix exceed the limit of 249 characters of topic names"),
+		},
+		"Longest name without suffix": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              strings.Repeat("a", 249),
+				"NameSuffix":        "",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+			},
+			Output: &TopicInfo{
+				Name:              strings.Repeat("a", 249),
+				NameSuffix:        stringPtr(""),
+				Partitions:        1,
+				ReplicationFactor: 3,
+				ClusterArn:        "arn",
+				DeletionPolicy:    DeletionPolicyRetain,
+			},
+		},
+		"Non-ASCII username": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "Permissions": []string{"READ"}},
+					{"Username": "\u0430lice", "Permissions": []string{"READ"}},
+				},
+			},
+			Err: errors.New("Users.1.Username: non-ASCII character 'а' at offset 0 is not allowed, names that look the same can differ in bytes"),
+		},
+		"Disallowed character in username": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Users": []map[string]interface{}{
+					{"Username": "alice@example.com", "Permissions": []string{"READ"}},
+				},
+			},
+			Err: errors.New("Users.0.Username: character '@' at offset 5 is not allowed, use ASCII letters, digits, '.', '_' or '-'"),
+		},
 		"Invalid Partitions": {
 			Input: map[string]interface{}{
 				"ServiceToken":      "st",
@@ -87,7 +363,7 @@ func TestNewTopicInfo(t *testing.T) {
 			},
 			Err: errors.New("ReplicationFactor: Does not match pattern '^[0-9]*$'"),
 		},
-		"Invalid permission": {
+		"Duplicate permissions": {
 			Input: map[string]interface{}{
 				"ServiceToken":      "st",
 				"Name":              "topic-a",
@@ -95,44 +371,662 @@ func TestNewTopicInfo(t *testing.T) {
 				"ReplicationFactor": "3",
 				"ClusterArn":        "arn",
 				"Users": []map[string]interface{}{
-					{"Username": "alice", "Arn": "a", "Permissions": []string{"READING"}},
+					{"Username": "alice", "Permissions": []string{"WRITE", "READ", "WRITE", "READ"}},
+					{"Username": "bob", "Permissions": []string{"READ", "READ"}},
+				},
+			},
+			Output: &TopicInfo{
+				Name:              "topic-a",
+				Partitions:        1,
+				ReplicationFactor: 3,
+				ClusterArn:        "arn",
+				Users: []User{
+					{Username: "alice", Permissions: []Permission{"WRITE", "READ"}},
+					{Username: "bob", Permissions: []Permission{"READ"}},
 				},
+				DeletionPolicy: DeletionPolicyRetain,
 			},
-			Err: errors.New("Users.0.Permissions.0: Users.0.Permissions.0 must be one of the following: \"READ\", \"WRITE\""),
 		},
-		"DeletionPolicyRetain": {
+		"Arn with SecretArn": {
 			Input: map[string]interface{}{
 				"ServiceToken":      "st",
 				"Name":              "topic-a",
 				"Partitions":        "1",
 				"ReplicationFactor": "3",
 				"ClusterArn":        "arn",
-				"DeletionPolicy":    "RETAIN",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "Arn": "a", "SecretArn": "s", "Permissions": []string{"READ"}},
+				},
+			},
+			Err: errors.New("Users.0: Arn cannot be specified with SecretArn"),
+		},
+		"TLS user": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "AuthType": "TLS", "Principal": "CN=alice.example.com", "Permissions": []string{"READ"}},
+				},
 			},
 			Output: &TopicInfo{
 				Name:              "topic-a",
 				Partitions:        1,
 				ReplicationFactor: 3,
 				ClusterArn:        "arn",
-				DeletionPolicy:    DeletionPolicyRetain,
+				Users: []User{
+					{Username: "alice", AuthType: AuthTypeTLS, Principal: "CN=alice.example.com", Permissions: []Permission{"READ"}},
+				},
+				DeletionPolicy: DeletionPolicyRetain,
 			},
 		},
-		"Invalid DeletionPolicy": {
+		"Group describe disabled": {
 			Input: map[string]interface{}{
 				"ServiceToken":      "st",
 				"Name":              "topic-a",
 				"Partitions":        "1",
 				"ReplicationFactor": "3",
 				"ClusterArn":        "arn",
-				"DeletionPolicy":    "INVALID_POLICY",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "GroupDescribe": "false", "Permissions": []string{"READ"}},
+				},
+			},
+			Output: &TopicInfo{
+				Name:              "topic-a",
+				Partitions:        1,
+				ReplicationFactor: 3,
+				ClusterArn:        "arn",
+				Users: []User{
+					{Username: "alice", GroupDescribe: boolPtr(false), Permissions: []Permission{"READ"}},
+				},
+				DeletionPolicy: DeletionPolicyRetain,
 			},
-			Err: errors.New("DeletionPolicy: DeletionPolicy must be one of the following: \"DELETE\", \"RETAIN\""),
 		},
-	}
-
-	for k, c := range cases {
-		ti, err := NewTopicInfo(c.Input)
-		assert.Equal(t, c.Err, err, k)
-		assert.Equal(t, c.Output, ti, k)
-	}
+		"Topic describe disabled": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "TopicDescribe": "false", "Permissions": []string{"WRITE"}},
+				},
+			},
+			Output: &TopicInfo{
+				Name:              "topic-a",
+				Partitions:        1,
+				ReplicationFactor: 3,
+				ClusterArn:        "arn",
+				Users: []User{
+					{Username: "alice", TopicDescribe: boolPtr(false), Permissions: []Permission{"WRITE"}},
+				},
+				DeletionPolicy: DeletionPolicyRetain,
+			},
+		},
+		"Invalid group describe": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "GroupDescribe": "no", "Permissions": []string{"READ"}},
+				},
+			},
+			Err: errors.New("Users.0.GroupDescribe: Users.0.GroupDescribe must be one of the following: \"true\", \"false\""),
+		},
+		"Arns with SecretArn": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "Arns": []string{"a"}, "SecretArn": "s", "Permissions": []string{"READ"}},
+				},
+			},
+			Err: errors.New("Users.0: Arns cannot be specified with SecretArn"),
+		},
+		"TLS user with Arns": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "AuthType": "TLS", "Principal": "CN=alice", "Arns": []string{"a"}, "Permissions": []string{"READ"}},
+				},
+			},
+			Err: errors.New("Users.0: Arns cannot be specified when AuthType is TLS"),
+		},
+		"TLS user without Principal": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "AuthType": "TLS", "Permissions": []string{"READ"}},
+				},
+			},
+			Err: errors.New("Users.0: Principal is required when AuthType is TLS"),
+		},
+		"TLS user with SecretArn": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "AuthType": "TLS", "Principal": "CN=alice", "SecretArn": "s", "Permissions": []string{"READ"}},
+				},
+			},
+			Err: errors.New("Users.0: Arn and SecretArn cannot be specified when AuthType is TLS"),
+		},
+		"Principal without TLS": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "Principal": "CN=alice", "Permissions": []string{"READ"}},
+				},
+			},
+			Err: errors.New("Users.0: Principal can only be specified when AuthType is TLS"),
+		},
+		"IAM user": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "IamPrincipal": "arn:aws:iam::123456789012:role/alice", "Permissions": []string{"READ"}},
+				},
+			},
+			Output: &TopicInfo{
+				Name:              "topic-a",
+				Partitions:        1,
+				ReplicationFactor: 3,
+				ClusterArn:        "arn",
+				Users: []User{
+					{Username: "alice", IamPrincipal: "arn:aws:iam::123456789012:role/alice", Permissions: []Permission{"READ"}},
+				},
+				DeletionPolicy: DeletionPolicyRetain,
+			},
+		},
+		"IAM user with Arn": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "IamPrincipal": "arn:aws:iam::123456789012:role/alice", "Arn": "a", "Permissions": []string{"READ"}},
+				},
+			},
+			Err: errors.New("Users.0: Arn, Arns and SecretArn cannot be specified with IamPrincipal"),
+		},
+		"IAM user with TLS": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "AuthType": "TLS", "Principal": "CN=alice", "IamPrincipal": "arn:aws:iam::123456789012:role/alice", "Permissions": []string{"READ"}},
+				},
+			},
+			Err: errors.New("Users.0: IamPrincipal cannot be specified when AuthType is TLS"),
+		},
+		"OFFSET_MANAGEMENT without READ": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "Permissions": []string{"WRITE", "OFFSET_MANAGEMENT"}},
+				},
+			},
+			Err: errors.New("Users.0: OFFSET_MANAGEMENT can only be specified with READ"),
+		},
+		"CleanupOrphanedSecrets with empty NameSuffix": {
+			Input: map[string]interface{}{
+				"ServiceToken":           "st",
+				"Name":                   "topic-a",
+				"NameSuffix":             "",
+				"Partitions":             "1",
+				"ReplicationFactor":      "3",
+				"ClusterArn":             "arn",
+				"CleanupOrphanedSecrets": "true",
+			},
+			Err: errors.New("CleanupOrphanedSecrets cannot be used with an empty NameSuffix"),
+		},
+		"NameSuffix": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"NameSuffix":        "v2",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+			},
+			Output: &TopicInfo{
+				Name:              "topic-a",
+				NameSuffix:        stringPtr("v2"),
+				Partitions:        1,
+				ReplicationFactor: 3,
+				ClusterArn:        "arn",
+				DeletionPolicy:    DeletionPolicyRetain,
+			},
+		},
+		"GroupPrefix": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"GroupPrefix":       "orders-",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "Permissions": []string{"READ"}},
+				},
+			},
+			Output: &TopicInfo{
+				Name:              "topic-a",
+				Partitions:        1,
+				ReplicationFactor: 3,
+				ClusterArn:        "arn",
+				GroupPrefix:       "orders-",
+				Users:             []User{{Username: "alice", Permissions: []Permission{PermissionRead}, GroupPrefix: "orders-"}},
+				DeletionPolicy:    DeletionPolicyRetain,
+			},
+		},
+		"Invalid GroupPrefix": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"GroupPrefix":       "orders*",
+			},
+			Err: errors.New("GroupPrefix: Does not match pattern '^[a-zA-Z0-9._-]+$'"),
+		},
+		"AllowedHosts": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"AllowedHosts":      []string{"10.0.0.1", "10.0.0.2"},
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "Permissions": []string{"READ"}},
+					{"Username": "bob", "AllowedHosts": []string{"*"}, "Permissions": []string{"READ"}},
+				},
+			},
+			Output: &TopicInfo{
+				Name:              "topic-a",
+				Partitions:        1,
+				ReplicationFactor: 3,
+				ClusterArn:        "arn",
+				AllowedHosts:      []string{"10.0.0.1", "10.0.0.2"},
+				Users: []User{
+					{Username: "alice", Permissions: []Permission{PermissionRead}, AllowedHosts: []string{"10.0.0.1", "10.0.0.2"}},
+					{Username: "bob", Permissions: []Permission{PermissionRead}, AllowedHosts: []string{"*"}},
+				},
+				DeletionPolicy: DeletionPolicyRetain,
+			},
+		},
+		"Invalid AllowedHosts": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"AllowedHosts":      []string{"10.0.0.0/8"},
+			},
+			Err: errors.New(`AllowedHosts.0: "10.0.0.0/8" is not an IP address or *`),
+		},
+		"Invalid user AllowedHosts": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "AllowedHosts": []string{"*", "client.example.com"}, "Permissions": []string{"READ"}},
+				},
+			},
+			Err: errors.New(`Users.0.AllowedHosts.1: "client.example.com" is not an IP address or *`),
+		},
+		"Invalid AdditionalTopics": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "AdditionalTopics": []string{"orders*"}, "Permissions": []string{"READ"}},
+				},
+			},
+			Err: errors.New("Users.0.AdditionalTopics.0: Does not match pattern '^[a-zA-Z0-9._-]+$'"),
+		},
+		"Invalid NameSuffix": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"NameSuffix":        "v2/x",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+			},
+			Err: errors.New("NameSuffix: Does not match pattern '^[a-zA-Z0-9._-]*$'"),
+		},
+		"Invalid permission": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "Arn": "a", "Permissions": []string{"READING"}},
+				},
+			},
+			Err: errors.New("Users.0.Permissions.0: Users.0.Permissions.0 must be one of the following: \"READ\", \"WRITE\", \"OFFSET_MANAGEMENT\""),
+		},
+		"User with initial group offset": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "Permissions": []string{"READ"}, "GroupName": "g", "InitialGroupOffset": "EARLIEST"},
+				},
+			},
+			Output: &TopicInfo{
+				Name:              "topic-a",
+				Partitions:        1,
+				ReplicationFactor: 3,
+				ClusterArn:        "arn",
+				Users: []User{
+					{Username: "alice", Permissions: []Permission{"READ"}, GroupName: "g", InitialGroupOffset: GroupOffsetEarliest},
+				},
+				DeletionPolicy: DeletionPolicyRetain,
+			},
+		},
+		"Initial group offset without group": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Users": []map[string]interface{}{
+					{"Username": "alice", "Permissions": []string{"READ"}, "InitialGroupOffset": "LATEST"},
+				},
+			},
+			Err: errors.New("Users.0: Has a dependency on GroupName"),
+		},
+		"DeletionPolicyRetain": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"DeletionPolicy":    "RETAIN",
+			},
+			Output: &TopicInfo{
+				Name:              "topic-a",
+				Partitions:        1,
+				ReplicationFactor: 3,
+				ClusterArn:        "arn",
+				DeletionPolicy:    DeletionPolicyRetain,
+			},
+		},
+		"DryRun": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"DryRun":            "true",
+			},
+			Output: &TopicInfo{
+				Name:              "topic-a",
+				Partitions:        1,
+				ReplicationFactor: 3,
+				ClusterArn:        "arn",
+				DeletionPolicy:    DeletionPolicyRetain,
+				DryRun:            true,
+			},
+		},
+		"Tags": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Tags":              map[string]interface{}{"team": "payments"},
+			},
+			Output: &TopicInfo{
+				Name:              "topic-a",
+				Partitions:        1,
+				ReplicationFactor: 3,
+				ClusterArn:        "arn",
+				DeletionPolicy:    DeletionPolicyRetain,
+				Tags:              map[string]string{"team": "payments"},
+			},
+		},
+		"Labels": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Labels":            map[string]interface{}{"owner": "payments@example.com"},
+			},
+			Output: &TopicInfo{
+				Name:              "topic-a",
+				Partitions:        1,
+				ReplicationFactor: 3,
+				ClusterArn:        "arn",
+				DeletionPolicy:    DeletionPolicyRetain,
+				Labels:            map[string]string{"owner": "payments@example.com"},
+			},
+		},
+		"Invalid label key": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Labels":            map[string]interface{}{"owner/team": "payments"},
+			},
+			Err: errors.New("Labels: Property name of \"owner/team\" does not match Labels: Does not match pattern '^[a-zA-Z0-9._-]{1,119}$'"),
+		},
+		"ConfigProfile with explicit override": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"ConfigProfile":     "compacted-log",
+				"Config":            map[string]interface{}{"delete.retention.ms": "3600000", "min.insync.replicas": "2"},
+			},
+			Output: &TopicInfo{
+				Name:              "topic-a",
+				Partitions:        1,
+				ReplicationFactor: 3,
+				ClusterArn:        "arn",
+				ConfigProfile:     ConfigProfileCompactedLog,
+				Config: map[string]*string{
+					"cleanup.policy":            stringPtr("compact"),
+					"min.cleanable.dirty.ratio": stringPtr("0.5"),
+					"delete.retention.ms":       stringPtr("3600000"),
+					"min.insync.replicas":       stringPtr("2"),
+				},
+				DeletionPolicy: DeletionPolicyRetain,
+			},
+		},
+		"min.insync.replicas equal to ReplicationFactor": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Config":            map[string]interface{}{"min.insync.replicas": "3"},
+			},
+			Output: &TopicInfo{
+				Name:              "topic-a",
+				Partitions:        1,
+				ReplicationFactor: 3,
+				ClusterArn:        "arn",
+				Config:            map[string]*string{"min.insync.replicas": stringPtr("3")},
+				DeletionPolicy:    DeletionPolicyRetain,
+			},
+		},
+		"min.insync.replicas greater than ReplicationFactor": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "2",
+				"ClusterArn":        "arn",
+				"Config":            map[string]interface{}{"min.insync.replicas": "3"},
+			},
+			Err: errors.New("Config.min.insync.replicas: 3 exceeds ReplicationFactor 2, the topic would not accept writes with acks=all"),
+		},
+		"Invalid min.insync.replicas": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"Config":            map[string]interface{}{"min.insync.replicas": "two"},
+			},
+			Err: errors.New("Config.min.insync.replicas: invalid value \"two\", must be an integer"),
+		},
+		"Unknown ConfigProfile": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"ConfigProfile":     "bulk",
+			},
+			Err: errors.New("ConfigProfile: ConfigProfile must be one of the following: \"streaming\", \"compacted-log\""),
+		},
+		"Invalid DeletionPolicy": {
+			Input: map[string]interface{}{
+				"ServiceToken":      "st",
+				"Name":              "topic-a",
+				"Partitions":        "1",
+				"ReplicationFactor": "3",
+				"ClusterArn":        "arn",
+				"DeletionPolicy":    "INVALID_POLICY",
+			},
+			Err: errors.New("DeletionPolicy: DeletionPolicy must be one of the following: \"DELETE\", \"RETAIN\", \"SNAPSHOT\""),
+		},
+	}
+
+	for k, c := range cases {
+		ti, err := NewTopicInfo(c.Input)
+		if c.Err == nil {
+			assert.Nil(t, err, k)
+		} else {
+			assert.EqualError(t, err, c.Err.Error(), k)
+		}
+		assert.Equal(t, c.Output, ti, k)
+	}
+}
+
+func TestClusters(t *testing.T) {
+	single := &TopicInfo{ClusterArn: "arn-a"}
+	multiple := &TopicInfo{ClusterArn: "arn-a", ClusterArns: []string{"arn-a", "arn-b"}}
+
+	assert.Equal(t, []string{"arn-a"}, single.Clusters())
+	assert.Equal(t, []string{"arn-a", "arn-b"}, multiple.Clusters())
+	assert.Equal(t, "arn-b", multiple.ForCluster("arn-b").ClusterArn)
+	assert.Equal(t, "arn-a", multiple.ClusterArn)
+	assert.True(t, single.SameClusters(&TopicInfo{ClusterArn: "arn-a", ClusterArns: []string{"arn-a"}}))
+	assert.True(t, multiple.SameClusters(&TopicInfo{ClusterArn: "arn-b", ClusterArns: []string{"arn-b", "arn-a"}}))
+	assert.False(t, single.SameClusters(multiple))
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestUserPrincipals(t *testing.T) {
+	assert.Nil(t, (&User{}).Principals())
+	assert.Equal(t, []string{"a", "b"}, (&User{Arn: "a", Arns: []string{"b", "a", ""}}).Principals())
+	assert.Equal(t, []string{"b"}, (&User{Arns: []string{"b"}}).Principals())
+}
+
+func TestValidationError(t *testing.T) {
+	// Act
+	_, err := NewTopicInfo(map[string]interface{}{
+		"ServiceToken":      "st",
+		"Name":              "topic-a",
+		"Partitions":        "1a",
+		"ReplicationFactor": "3",
+		"ClusterArn":        "arn",
+		"Users": []map[string]interface{}{
+			{"Username": "alice", "Permissions": []string{"OWN"}},
+		},
+	})
+
+	// Assert
+	var ve *ValidationError
+	assert.ErrorAs(t, err, &ve)
+	assert.ElementsMatch(t, []FieldError{
+		{Pointer: "/Partitions", Field: "Partitions", Message: "Does not match pattern '^[0-9]*$'"},
+		{Pointer: "/Users/0/Permissions/0", Field: "Users.0.Permissions.0", Message: `Users.0.Permissions.0 must be one of the following: "READ", "WRITE", "OFFSET_MANAGEMENT"`},
+	}, ve.Fields)
+	assert.Equal(t, ve.String(), err.Error())
+}
+
+func TestJSONPointer(t *testing.T) {
+	root := gojsonschema.NewJsonContext("(root)", nil)
+	labels := gojsonschema.NewJsonContext("Labels", root)
+
+	assert.Equal(t, "", jsonPointer(root))
+	assert.Equal(t, "/Labels", jsonPointer(labels))
+	assert.Equal(t, "/Labels/a~1b~0c", jsonPointer(gojsonschema.NewJsonContext("a/b~c", labels)))
 }
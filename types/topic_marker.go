@@ -0,0 +1,22 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package types
+
+// TopicMarker records the stack managing a topic.
+type TopicMarker struct {
+	StackID string
+	// Tags declared on the resource. They are stored as tags of the
+	// marker rather than in its value.
+	Tags map[string]string `json:"-"`
+	// Labels declared on the resource. Stored as tags with a reserved
+	// prefix so that they cannot collide with Tags.
+	Labels map[string]string `json:"-"`
+	// CloudFormation request that last wrote the marker. Used to tell
+	// retries of a request apart from collisions.
+	RequestID string `json:"-"`
+	// Topic of the resource that this one replaced or was replaced by
+	// when its Name changed. Both resources share the users, therefore
+	// their secrets are kept on delete while the other topic is managed.
+	LinkedTopic string `json:"-"`
+}
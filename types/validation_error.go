@@ -0,0 +1,66 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package types
+
+import (
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// FieldError is a property of the resource that does not match the schema.
+type FieldError struct {
+	// JSON pointer of the property, e.g. /Users/0/Permissions. Empty for
+	// errors of the properties object itself such as missing properties.
+	Pointer string
+	// Dotted path of the property as reported by the schema validator,
+	// e.g. Users.0.Permissions.
+	Field   string
+	Message string
+}
+
+// ValidationError lists every property of the resource that does not
+// match the schema so that they can be fixed at once.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func newValidationError(errs []gojsonschema.ResultError) *ValidationError {
+	e := &ValidationError{Fields: make([]FieldError, len(errs))}
+	for i, re := range errs {
+		e.Fields[i] = FieldError{
+			Pointer: jsonPointer(re.Context()),
+			Field:   re.Field(),
+			Message: re.Description(),
+		}
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	return e.String()
+}
+
+// Returns the errors of the schema validator joined with spaces.
+func (e *ValidationError) String() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return strings.Join(msgs, " ")
+}
+
+// Returns the RFC 6901 pointer of the property in context. Config keys
+// are chosen by users, therefore each reference token is escaped.
+func jsonPointer(context *gojsonschema.JsonContext) string {
+	const sep = "\x00"
+	tokens := strings.Split(context.String(sep), sep)
+	var b strings.Builder
+	// The first token is the root of the document.
+	for _, t := range tokens[1:] {
+		b.WriteString("/")
+		b.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(t))
+	}
+	return b.String()
+}